@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danvergara/morphos/pkg/files"
+	"github.com/danvergara/morphos/pkg/packaging"
+	"github.com/danvergara/morphos/pkg/progress"
+)
+
+const (
+	manifestFilename = "manifest.json"
+
+	// maxMemory is the memory used to store the files of a form,
+	// the rest of them are stored in temporary files.
+	maxMemory = 32 << 20
+
+	statusSucceeded = "succeeded"
+	statusFailed    = "failed"
+)
+
+var (
+	// batchConcurrency is the number of files of a batch converted at the same time.
+	batchConcurrency = runtime.NumCPU()
+	// batchMaxFiles is the maximum number of files accepted in a single request.
+	batchMaxFiles = 500
+)
+
+func init() {
+	if v, err := strconv.Atoi(os.Getenv("MORPHOS_BATCH_CONCURRENCY")); err == nil && v > 0 {
+		batchConcurrency = v
+	}
+
+	if v, err := strconv.Atoi(os.Getenv("MORPHOS_BATCH_MAX_FILES")); err == nil && v > 0 {
+		batchMaxFiles = v
+	}
+}
+
+// runner is a conversion ready to be run, either of a single file or of a batch of them.
+type runner interface {
+	convert(ctx context.Context) (ConvertedFile, io.Reader, error)
+}
+
+// parseRequest reads the conversion requested in the form.
+// If the form holds several files, they are converted as a batch,
+// unless they are images to merge into a single pdf.
+func parseRequest(r *http.Request) (runner, error) {
+	if err := r.ParseMultipartForm(maxMemory); err == nil {
+		merge, err := wantsMerge(r.Form)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case merge:
+			return parseMerge(r)
+		case len(r.MultipartForm.File[uploadFileFormField]) > 1:
+			return parseBatch(r)
+		}
+	}
+
+	return parseConversion(r)
+}
+
+// batchItem is a file of a batch.
+type batchItem struct {
+	filename   string
+	target     string
+	conversion conversion
+	// err is the reason why the file can't be converted, if any.
+	err error
+}
+
+// batch is a set of files converted in a single request.
+// Every file is converted on its own, so a file that can't be
+// converted does not stop the rest of them.
+type batch struct {
+	items []batchItem
+	// format and level set how the converted files are packaged.
+	format packaging.Format
+	level  int
+}
+
+// manifestEntry describes the outcome of the conversion of a file of a batch.
+type manifestEntry struct {
+	Input  string   `json:"input"`
+	Target string   `json:"target"`
+	Output string   `json:"output,omitempty"`
+	Status string   `json:"status"`
+	Error  string   `json:"error,omitempty"`
+	Plan   []string `json:"plan,omitempty"`
+}
+
+// parseBatch reads the files of a batch from the form.
+// The target format is either shared by every file, or sent once per file,
+// in the same order as the files.
+func parseBatch(r *http.Request) (batch, error) {
+	fileHeaders := r.MultipartForm.File[uploadFileFormField]
+	targets := r.MultipartForm.Value["targetFormat"]
+
+	if len(fileHeaders) > batchMaxFiles {
+		return batch{}, WithHTTPStatus(
+			fmt.Errorf("too many files: %d, the limit is %d", len(fileHeaders), batchMaxFiles),
+			http.StatusBadRequest,
+		)
+	}
+
+	if len(targets) != 1 && len(targets) != len(fileHeaders) {
+		return batch{}, WithHTTPStatus(
+			fmt.Errorf("expected a single targetFormat, or one per file, got %d for %d files", len(targets), len(fileHeaders)),
+			http.StatusBadRequest,
+		)
+	}
+
+	// The converted files are packaged according to the archive options.
+	opts, err := files.ArchiveOptions.Parse(r.Form)
+	if err != nil {
+		log.Printf("error occurred while parsing the archive options: %v", err)
+		return batch{}, WithHTTPStatus(err, http.StatusBadRequest)
+	}
+
+	b := batch{
+		format: packaging.Format(opts.String(files.ArchiveFormatOption, string(packaging.Zip))),
+		level:  opts.Int(files.CompressionOption, packaging.DefaultCompression),
+	}
+
+	for i, fileHeader := range fileHeaders {
+		item := batchItem{
+			filename: fileHeader.Filename,
+			target:   targets[0],
+		}
+
+		if len(targets) > 1 {
+			item.target = targets[i]
+		}
+
+		fileBytes, err := readFormFile(fileHeader)
+		if err != nil {
+			log.Printf("error ocurred reading file: %v", err)
+			return batch{}, WithHTTPStatus(err, http.StatusBadRequest)
+		}
+
+		item.conversion, item.err = newConversion(item.filename, fileBytes, item.target, r.Form)
+		b.items = append(b.items, item)
+	}
+
+	return b, nil
+}
+
+// readFormFile reads the whole content of a file of a form.
+func readFormFile(fileHeader *multipart.FileHeader) ([]byte, error) {
+	f, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return io.ReadAll(f)
+}
+
+// convert converts the files of the batch concurrently, up to batchConcurrency at a time.
+// It returns an archive with the converted files and a manifest.
+func (b batch) convert(ctx context.Context) (ConvertedFile, io.Reader, error) {
+	var (
+		entries = make([]manifestEntry, len(b.items))
+		outputs = make([][]byte, len(b.items))
+		sem     = make(chan struct{}, batchConcurrency)
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		done    int
+	)
+
+	progress.Report(ctx, progress.Event{
+		Stage:   progress.Received,
+		Total:   len(b.items),
+		Message: fmt.Sprintf("received %d files", len(b.items)),
+	})
+
+	// Reports the files converted so far,
+	// instead of the progress of every conversion.
+	fileDone := func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		done++
+		progress.Report(ctx, progress.Event{
+			Stage:   progress.Converting,
+			Current: done,
+			Total:   len(b.items),
+			Message: fmt.Sprintf("converted %d of %d files", done, len(b.items)),
+		})
+	}
+	convertCtx := progress.WithReporter(ctx, nil)
+
+	for i, item := range b.items {
+		entries[i] = manifestEntry{
+			Input:  item.filename,
+			Target: item.target,
+		}
+
+		if item.err != nil {
+			entries[i].Status = statusFailed
+			entries[i].Error = item.err.Error()
+			fileDone()
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, c conversion) {
+			defer wg.Done()
+			defer fileDone()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				entries[i].Status = statusFailed
+				entries[i].Error = ctx.Err().Error()
+				return
+			}
+
+			convertedFile, output, err := c.convert(convertCtx)
+			if err == nil {
+				outputs[i], err = io.ReadAll(output)
+			}
+			if err != nil {
+				entries[i].Status = statusFailed
+				entries[i].Error = err.Error()
+				return
+			}
+
+			entries[i].Status = statusSucceeded
+			entries[i].Output = convertedFile.Filename
+			entries[i].Plan = convertedFile.Plan
+		}(i, item.conversion)
+	}
+
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return ConvertedFile{}, nil, WithHTTPStatus(err, http.StatusGatewayTimeout)
+	}
+
+	progress.Report(ctx, progress.Event{
+		Stage:   progress.Packaging,
+		Message: fmt.Sprintf("packaging %d files", len(b.items)),
+	})
+
+	archiveFiles, err := batchFiles(entries, outputs)
+	if err != nil {
+		log.Printf("error occurred packaging the batch: %v", err)
+		return ConvertedFile{}, nil, WithHTTPStatus(err, http.StatusInternalServerError)
+	}
+
+	convertedFile := ConvertedFile{
+		Filename: fmt.Sprintf("morphos-%s.%s", time.Now().Format("20060102-150405"), b.format.Extension()),
+		FileType: archiveFileType,
+		MIMEType: b.format.MIMEType(),
+	}
+
+	return convertedFile, &packaging.Archive{Format: b.format, Level: b.level, Files: archiveFiles}, nil
+}
+
+// batchFiles returns the converted files of a batch, followed by the manifest.
+// Output names are made unique, so files with the same name don't overwrite each other.
+func batchFiles(entries []manifestEntry, outputs [][]byte) ([]packaging.File, error) {
+	var result []packaging.File
+
+	used := map[string]bool{manifestFilename: true}
+
+	for i := range entries {
+		if entries[i].Status != statusSucceeded {
+			continue
+		}
+
+		entries[i].Output = uniqueName(entries[i].Output, used)
+		result = append(result, packaging.File{Name: entries[i].Output, Content: outputs[i]})
+	}
+
+	manifest, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling the manifest: %w", err)
+	}
+
+	return append(result, packaging.File{Name: manifestFilename, Content: manifest}), nil
+}
+
+// uniqueName returns the name, or the name with a numeric suffix if it was already used.
+// e.g. foo.pdf, foo-1.pdf, foo-2.pdf
+func uniqueName(name string, used map[string]bool) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	candidate := name
+	for n := 1; used[candidate]; n++ {
+		candidate = fmt.Sprintf("%s-%d%s", base, n, ext)
+	}
+
+	used[candidate] = true
+
+	return candidate
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/danvergara/morphos/pkg/packaging"
+)
+
+func TestParseBatch(t *testing.T) {
+	img := pngImage(t, 4, 3)
+
+	var tests = []struct {
+		name     string
+		targets  []string
+		files    []formFile
+		maxFiles int
+		status   int
+		expected []string
+		// failed are the files that can't be converted, by index.
+		failed []int
+	}{
+		{
+			name:     "single target",
+			targets:  []string{"jpeg"},
+			files:    []formFile{{"a.png", img}, {"b.png", img}},
+			expected: []string{"jpeg", "jpeg"},
+		},
+		{
+			name:     "target per file",
+			targets:  []string{"jpeg", "gif", "pdf"},
+			files:    []formFile{{"a.png", img}, {"b.png", img}, {"c.png", img}},
+			expected: []string{"jpeg", "gif", "pdf"},
+		},
+		{
+			name:     "file that can't be converted",
+			targets:  []string{"jpeg", "xlsx"},
+			files:    []formFile{{"a.png", img}, {"b.png", img}},
+			expected: []string{"jpeg", "xlsx"},
+			failed:   []int{1},
+		},
+		{
+			name:    "fewer targets than files",
+			targets: []string{"jpeg", "gif"},
+			files:   []formFile{{"a.png", img}, {"b.png", img}, {"c.png", img}},
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:   "no target",
+			files:  []formFile{{"a.png", img}, {"b.png", img}},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:     "too many files",
+			targets:  []string{"jpeg"},
+			files:    []formFile{{"a.png", img}, {"b.png", img}, {"c.png", img}},
+			maxFiles: 2,
+			status:   http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.maxFiles > 0 {
+				previous := batchMaxFiles
+				batchMaxFiles = tc.maxFiles
+				t.Cleanup(func() { batchMaxFiles = previous })
+			}
+
+			r := newFormRequest(t, "/upload", map[string][]string{"targetFormat": tc.targets}, tc.files...)
+			require.NoError(t, r.ParseMultipartForm(maxMemory))
+
+			b, err := parseBatch(r)
+			if tc.status != 0 {
+				require.Error(t, err)
+				require.Equal(t, tc.status, HTTPStatus(err))
+				return
+			}
+
+			require.NoError(t, err)
+			require.Len(t, b.items, len(tc.files))
+			require.Equal(t, packaging.Zip, b.format)
+
+			for i, item := range b.items {
+				require.Equal(t, tc.files[i].name, item.filename)
+				require.Equal(t, tc.expected[i], item.target)
+
+				if slices.Contains(tc.failed, i) {
+					require.Error(t, item.err)
+				} else {
+					require.NoError(t, item.err)
+				}
+			}
+		})
+	}
+}
+
+func TestUniqueName(t *testing.T) {
+	var tests = []struct {
+		name     string
+		used     []string
+		expected []string
+	}{
+		{name: "unused names", used: nil, expected: []string{"a.pdf", "b.pdf"}},
+		{name: "same name", used: []string{"a.pdf", "a.pdf", "a.pdf"}, expected: []string{"a.pdf", "a-1.pdf", "a-2.pdf"}},
+		{name: "suffixed name already used", used: []string{"a-1.pdf", "a.pdf", "a.pdf"}, expected: []string{"a-1.pdf", "a.pdf", "a-2.pdf"}},
+		{name: "no extension", used: []string{"notes", "notes"}, expected: []string{"notes", "notes-1"}},
+		{name: "manifest", used: []string{manifestFilename}, expected: []string{"manifest-1.json"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			used := map[string]bool{manifestFilename: true}
+
+			names := tc.used
+			if names == nil {
+				names = tc.expected
+			}
+
+			var result []string
+			for _, name := range names {
+				result = append(result, uniqueName(name, used))
+			}
+
+			require.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestBatchConvert(t *testing.T) {
+	img := pngImage(t, 4, 3)
+
+	// Two files with the same name, and a file that can't be converted to the target.
+	r := newFormRequest(
+		t,
+		"/upload",
+		map[string][]string{"targetFormat": {"jpeg", "jpeg", "xlsx"}},
+		formFile{"photo.png", img},
+		formFile{"photo.png", img},
+		formFile{"scan.png", img},
+	)
+	require.NoError(t, r.ParseMultipartForm(maxMemory))
+
+	b, err := parseBatch(r)
+	require.NoError(t, err)
+
+	convertedFile, output, err := b.convert(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "application/zip", convertedFile.MIMEType)
+	require.Regexp(t, `^morphos-\d{8}-\d{6}\.zip$`, convertedFile.Filename)
+
+	archive, err := io.ReadAll(output)
+	require.NoError(t, err)
+
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	require.NoError(t, err)
+
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+
+	require.Equal(t, []string{"photo.jpeg", "photo-1.jpeg", manifestFilename}, names)
+
+	f, err := zr.Open(manifestFilename)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var entries []manifestEntry
+	require.NoError(t, json.NewDecoder(f).Decode(&entries))
+	require.Len(t, entries, 3)
+
+	require.Equal(t, manifestEntry{
+		Input:  "photo.png",
+		Target: "jpeg",
+		Output: "photo.jpeg",
+		Status: statusSucceeded,
+		Plan:   []string{"png", "jpeg"},
+	}, entries[0])
+	require.Equal(t, "photo-1.jpeg", entries[1].Output)
+	require.Equal(t, statusSucceeded, entries[1].Status)
+
+	require.Equal(t, "scan.png", entries[2].Input)
+	require.Equal(t, statusFailed, entries[2].Status)
+	require.Empty(t, entries[2].Output)
+	require.NotEmpty(t, entries[2].Error)
+}
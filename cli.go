@@ -0,0 +1,466 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/gabriel-vasile/mimetype"
+
+	"github.com/danvergara/morphos/pkg/files"
+	"github.com/danvergara/morphos/pkg/packaging"
+	"github.com/danvergara/morphos/pkg/pdfops"
+)
+
+const usage = `morphos converts files, either through its web server or from the command line.
+
+Usage:
+
+	morphos [serve]                         runs the web server
+	morphos convert [flags] <file|dir>...   converts files without a server
+	morphos pdf <operation> <file>...       merges, splits or edits the pages of pdf files
+	morphos formats [--json]                lists the supported formats
+
+Run morphos <command> --help to see the flags of a command.
+`
+
+// errUsage is returned when the command line can't be parsed,
+// the usage is already printed by then.
+var errUsage = errors.New("invalid usage")
+
+// runCLI runs the command given in the arguments, the server if there's none.
+func runCLI(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		return serve(ctx)
+	}
+
+	switch args[0] {
+	case "serve":
+		return serve(ctx)
+	case "convert":
+		return convertCmd(ctx, args[1:], stdout, stderr)
+	case "pdf":
+		return pdfCmd(ctx, args[1:], stdout, stderr)
+	case "formats":
+		return formatsCmd(args[1:], stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return nil
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", args[0], usage)
+		return errUsage
+	}
+}
+
+// serve runs the web server until it gets a signal to stop.
+func serve(ctx context.Context) error {
+	if err := run(ctx); err != nil {
+		return err
+	}
+
+	log.Println("exiting...")
+
+	return nil
+}
+
+// parseInterspersed parses the flags of a command, which may come
+// before or after its positional arguments. e.g. convert in.pdf --to png
+// It returns the positional arguments.
+func parseInterspersed(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+
+		if flags.NArg() == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+}
+
+// optionValues collects the conversion options passed as name=value flags.
+type optionValues url.Values
+
+func (o optionValues) String() string {
+	return url.Values(o).Encode()
+}
+
+func (o optionValues) Set(value string) error {
+	name, v, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("%q is not a name=value pair", value)
+	}
+
+	url.Values(o).Add(name, v)
+
+	return nil
+}
+
+// convertCmd converts files, or the files of directories, to the target format.
+// The converted files are written into the output directory,
+// keeping the directory structure of the inputs.
+func convertCmd(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	var (
+		flags     = flag.NewFlagSet("convert", flag.ContinueOnError)
+		target    = flags.String("to", "", "target format, e.g. png")
+		outDir    = flags.String("o", ".", "directory where the converted files are written")
+		recursive = flags.Bool("recursive", false, "convert the files of the subdirectories too")
+		verbose   = flags.Bool("v", false, "log every step of the conversions")
+		merge     = flags.Bool("merge", false, "merge the images into a single pdf, a page per image, in the order they are given")
+		options   = optionValues{}
+	)
+
+	flags.Var(options, "opt", "conversion option as name=value, e.g. quality=90. It can be repeated")
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: morphos convert [flags] <file|dir>...")
+		flags.PrintDefaults()
+	}
+
+	inputs, err := parseInterspersed(flags, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return errUsage
+	}
+
+	if *target == "" || len(inputs) == 0 {
+		flags.Usage()
+		return errUsage
+	}
+
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	if err := os.MkdirAll(*outDir, 0o755); err != nil {
+		return fmt.Errorf("error creating the output directory: %w", err)
+	}
+
+	// The conversions are cancelled on ctrl+c.
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *merge {
+		return mergePaths(ctx, inputs, *recursive, *outDir, *target, url.Values(options), stdout, stderr)
+	}
+
+	var failed int
+
+	for _, input := range inputs {
+		info, err := os.Stat(input)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", input, err)
+			failed++
+			continue
+		}
+
+		if !info.IsDir() {
+			if err := convertPath(ctx, input, *outDir, *target, url.Values(options), stdout); err != nil {
+				fmt.Fprintf(stderr, "%s: %v\n", input, err)
+				failed++
+			}
+			continue
+		}
+
+		err = filepath.WalkDir(input, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if d.IsDir() {
+				if path != input && !*recursive {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			rel, err := filepath.Rel(input, path)
+			if err != nil {
+				return err
+			}
+
+			// Files that can't be converted to the target format are skipped,
+			// since directories may hold files of any kind.
+			err = convertPath(ctx, path, filepath.Join(*outDir, filepath.Dir(rel)), *target, url.Values(options), stdout)
+			switch {
+			case err == nil:
+			case !errors.Is(err, files.ErrInvalidOption) && HTTPStatus(err) == http.StatusBadRequest:
+				fmt.Fprintf(stderr, "%s: skipped, %v\n", path, err)
+			default:
+				fmt.Fprintf(stderr, "%s: %v\n", path, err)
+				failed++
+			}
+
+			return ctx.Err()
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d files could not be converted", failed)
+	}
+
+	return nil
+}
+
+// convertPath converts the file at path to the target format,
+// and writes the converted file into the given directory.
+func convertPath(ctx context.Context, path, dir, target string, options url.Values, stdout io.Writer) error {
+	fileBytes, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	c, err := newConversion(filepath.Base(path), fileBytes, target, options)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("error creating the output directory: %w", err)
+	}
+
+	convertedFile, err := runConversion(ctx, c, dir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "%s -> %s\n", path, filepath.Join(dir, convertedFile.Filename))
+
+	return nil
+}
+
+// mergePaths merges the images at the given paths, or in the given directories,
+// into a single pdf written into the output directory.
+// Files of directories that aren't images are skipped.
+func mergePaths(ctx context.Context, inputs []string, recursive bool, outDir, target string, options url.Values, stdout, stderr io.Writer) error {
+	var imgs []packaging.File
+
+	for _, input := range inputs {
+		info, err := os.Stat(input)
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			fileBytes, err := os.ReadFile(input)
+			if err != nil {
+				return err
+			}
+
+			imgs = append(imgs, packaging.File{Name: filepath.Base(input), Content: fileBytes})
+			continue
+		}
+
+		err = filepath.WalkDir(input, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if d.IsDir() {
+				if path != input && !recursive {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			fileBytes, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+
+			if f, ok := files.LookupMIME(mimetype.Detect(fileBytes).String()); !ok || f.Category != files.Img {
+				fmt.Fprintf(stderr, "%s: skipped, not an image\n", path)
+				return nil
+			}
+
+			imgs = append(imgs, packaging.File{Name: filepath.Base(path), Content: fileBytes})
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	m, err := newMerge(imgs, target, options)
+	if err != nil {
+		return err
+	}
+
+	convertedFile, err := runConversion(ctx, m, outDir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "%d images -> %s\n", len(imgs), filepath.Join(outDir, convertedFile.Filename))
+
+	return nil
+}
+
+// pdfCmd applies an operation to the pages of pdf files, and writes the result into the output directory.
+// e.g. morphos pdf rotate in.pdf --pages 2 --angle 90
+func pdfCmd(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	var (
+		flags   = flag.NewFlagSet("pdf", flag.ContinueOnError)
+		outDir  = flags.String("o", ".", "directory where the resulting file is written")
+		pages   = flags.String("pages", "", "pages to extract, rotate or delete, or the ranges of pages of every file when splitting, e.g. 1-3,7,10-")
+		every   = flags.Int("every", 0, "splits the file in files of this number of pages")
+		order   = flags.String("order", "", "new order of the pages, e.g. 3,1-2")
+		angle   = flags.String("angle", "90", "degrees the pages are rotated clockwise by: 90, 180 or 270")
+		verbose = flags.Bool("v", false, "log every step of the operation")
+		options = optionValues{}
+	)
+
+	flags.Var(options, "opt", "option as name=value, e.g. archive_format=tar.gz. It can be repeated")
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: morphos pdf <%s> [flags] <file>...\n", strings.Join(pdfops.Operations, "|"))
+		flags.PrintDefaults()
+	}
+
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		flags.Usage()
+		return errUsage
+	}
+
+	operation := args[0]
+
+	inputs, err := parseInterspersed(flags, args[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return errUsage
+	}
+
+	if len(inputs) == 0 {
+		flags.Usage()
+		return errUsage
+	}
+
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	// The flags are options of the operation, the ones set explicitly take precedence over --opt.
+	form := url.Values(options)
+	form.Set(pdfops.OperationOption, operation)
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "pages":
+			form.Set(pdfops.PagesOption, *pages)
+		case "every":
+			form.Set(pdfops.EveryOption, strconv.Itoa(*every))
+		case "order":
+			form.Set(pdfops.OrderOption, *order)
+		case "angle":
+			form.Set(pdfops.AngleOption, *angle)
+		}
+	})
+
+	var pdfs []packaging.File
+
+	for _, input := range inputs {
+		fileBytes, err := os.ReadFile(input)
+		if err != nil {
+			return err
+		}
+
+		pdfs = append(pdfs, packaging.File{Name: filepath.Base(input), Content: fileBytes})
+	}
+
+	o, err := newPDFOperation(pdfs, form)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(*outDir, 0o755); err != nil {
+		return fmt.Errorf("error creating the output directory: %w", err)
+	}
+
+	// The operation is cancelled on ctrl+c.
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	convertedFile, err := runConversion(ctx, o, *outDir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "%s -> %s\n", strings.Join(inputs, ", "), filepath.Join(*outDir, convertedFile.Filename))
+
+	return nil
+}
+
+// formatsCmd lists the supported formats, and the formats they can be converted to.
+func formatsCmd(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("formats", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print the formats as JSON, grouped by category")
+	flags.SetOutput(stderr)
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return errUsage
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(supportedFormatsJSONResponse())
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FORMAT\tCATEGORY\tALIASES\tTARGETS")
+
+	for _, f := range files.Formats() {
+		var targets []string
+
+		reachable, err := files.Reachable(f.Name)
+		if err != nil {
+			return err
+		}
+
+		for _, subTypes := range reachable {
+			targets = append(targets, subTypes...)
+		}
+		sort.Strings(targets)
+
+		fmt.Fprintf(
+			w,
+			"%s\t%s\t%s\t%s\n",
+			f.Name,
+			f.Category,
+			strings.Join(f.Aliases, ","),
+			strings.Join(targets, ","),
+		)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseInterspersed(t *testing.T) {
+	var tests = []struct {
+		name       string
+		args       []string
+		positional []string
+		target     string
+		options    string
+		hasErr     bool
+	}{
+		{name: "flags first", args: []string{"--to", "png", "a.jpg", "b.jpg"}, positional: []string{"a.jpg", "b.jpg"}, target: "png"},
+		{name: "flags last", args: []string{"a.jpg", "b.jpg", "--to", "png"}, positional: []string{"a.jpg", "b.jpg"}, target: "png"},
+		{
+			name:       "flags in between",
+			args:       []string{"a.jpg", "--opt", "quality=90", "b.jpg", "-to=png", "--opt", "width=10"},
+			positional: []string{"a.jpg", "b.jpg"},
+			target:     "png",
+			options:    "quality=90&width=10",
+		},
+		{name: "no positional arguments", args: []string{"--to", "png"}, target: "png"},
+		{name: "unknown flag", args: []string{"a.jpg", "--quality", "90"}, hasErr: true},
+		{name: "invalid option", args: []string{"a.jpg", "--opt", "quality"}, hasErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			flags := flag.NewFlagSet("test", flag.ContinueOnError)
+			flags.SetOutput(io.Discard)
+
+			target := flags.String("to", "", "")
+			options := optionValues{}
+			flags.Var(options, "opt", "")
+
+			positional, err := parseInterspersed(flags, tc.args)
+			if tc.hasErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.positional, positional)
+			require.Equal(t, tc.target, *target)
+			require.Equal(t, tc.options, options.String())
+		})
+	}
+}
+
+func TestConvertCmd(t *testing.T) {
+	// The command discards the log unless it's verbose.
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	input := t.TempDir()
+	img := pngImage(t, 4, 3)
+
+	require.NoError(t, os.WriteFile(filepath.Join(input, "a.png"), img, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(input, "notes.txt"), []byte("not an image"), 0o600))
+	require.NoError(t, os.MkdirAll(filepath.Join(input, "sub"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(input, "sub", "b.png"), img, 0o600))
+
+	var tests = []struct {
+		name string
+		args []string
+		// expected are the files written into the output directory.
+		expected []string
+		stderr   string
+		err      string
+	}{
+		{
+			name:     "file",
+			args:     []string{filepath.Join(input, "a.png"), "--to", "jpeg"},
+			expected: []string{"a.jpeg"},
+		},
+		{
+			name:     "directory",
+			args:     []string{"--to", "jpeg", input},
+			expected: []string{"a.jpeg"},
+			stderr:   "notes.txt: skipped",
+		},
+		{
+			name:     "directory and its subdirectories",
+			args:     []string{"--to", "gif", "--recursive", input},
+			expected: []string{"a.gif", filepath.Join("sub", "b.gif")},
+			stderr:   "notes.txt: skipped",
+		},
+		{
+			name:   "invalid option",
+			args:   []string{filepath.Join(input, "a.png"), "--to", "jpeg", "--opt", "quality=500"},
+			stderr: "invalid option",
+			err:    "1 files could not be converted",
+		},
+		{
+			name:   "missing file",
+			args:   []string{filepath.Join(input, "missing.png"), "--to", "jpeg"},
+			stderr: "missing.png",
+			err:    "1 files could not be converted",
+		},
+		{
+			name: "no target",
+			args: []string{filepath.Join(input, "a.png")},
+			err:  errUsage.Error(),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			output := t.TempDir()
+
+			var stdout, stderr bytes.Buffer
+			err := runCLI(context.Background(), append([]string{"convert", "-o", output}, tc.args...), &stdout, &stderr)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+			} else {
+				require.NoError(t, err, stderr.String())
+			}
+
+			require.Contains(t, stderr.String(), tc.stderr)
+
+			var written []string
+			require.NoError(t, filepath.WalkDir(output, func(path string, d os.DirEntry, err error) error {
+				if err != nil || d.IsDir() {
+					return err
+				}
+
+				rel, err := filepath.Rel(output, path)
+				written = append(written, rel)
+
+				return err
+			}))
+
+			require.Equal(t, tc.expected, written)
+
+			for _, name := range tc.expected {
+				require.Contains(t, stdout.String(), "-> "+filepath.Join(output, name))
+			}
+		})
+	}
+}
+
+func TestConvertCmdMerge(t *testing.T) {
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	input := t.TempDir()
+	img := pngImage(t, 4, 3)
+
+	require.NoError(t, os.WriteFile(filepath.Join(input, "a.png"), img, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(input, "b.png"), img, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(input, "notes.txt"), []byte("not an image"), 0o600))
+
+	output := t.TempDir()
+
+	var stdout, stderr bytes.Buffer
+	err := runCLI(context.Background(), []string{"convert", "-o", output, "--to", "pdf", "--merge", input}, &stdout, &stderr)
+	require.NoError(t, err, stderr.String())
+	require.Contains(t, stderr.String(), "notes.txt: skipped, not an image")
+
+	written, err := filepath.Glob(filepath.Join(output, "*"))
+	require.NoError(t, err)
+	require.Len(t, written, 1)
+	require.Regexp(t, `^morphos-\d{8}-\d{6}\.pdf$`, filepath.Base(written[0]))
+	require.Equal(t, "2 images -> "+written[0]+"\n", stdout.String())
+
+	// Only pdf files can be merged into.
+	err = runCLI(context.Background(), []string{"convert", "-o", output, "--to", "gif", "--merge", input}, io.Discard, io.Discard)
+	require.Error(t, err)
+}
+
+func TestPDFCmd(t *testing.T) {
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	input := filepath.Join(t.TempDir(), "report.pdf")
+	require.NoError(t, os.WriteFile(input, pdfDocument(t, 100, 200, 300), 0o600))
+
+	var tests = []struct {
+		name     string
+		args     []string
+		expected string
+		widths   []int
+		err      error
+	}{
+		{
+			name:     "flags of the operation",
+			args:     []string{"extract", input, "--pages", "3,1"},
+			expected: "report-extract.pdf",
+			widths:   []int{300, 100},
+		},
+		{
+			name:     "options of the operation",
+			args:     []string{"delete", "--opt", "pages=2", input},
+			expected: "report-delete.pdf",
+			widths:   []int{100, 300},
+		},
+		{name: "no operation", args: []string{input}, err: errUsage},
+		{name: "no files", args: []string{"rotate"}, err: errUsage},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			output := t.TempDir()
+
+			var stdout bytes.Buffer
+			err := runCLI(context.Background(), append(append([]string{"pdf"}, tc.args...), "-o", output), &stdout, io.Discard)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, input+" -> "+filepath.Join(output, tc.expected)+"\n", stdout.String())
+
+			result, err := os.ReadFile(filepath.Join(output, tc.expected))
+			require.NoError(t, err)
+			require.Equal(t, tc.widths, pageWidths(t, result))
+		})
+	}
+}
+
+func TestFormatsCmd(t *testing.T) {
+	var stdout bytes.Buffer
+	require.NoError(t, runCLI(context.Background(), []string{"formats"}, &stdout, io.Discard))
+
+	lines := strings.Split(stdout.String(), "\n")
+	require.Regexp(t, `^FORMAT\s+CATEGORY\s+ALIASES\s+TARGETS$`, lines[0])
+	require.Contains(t, stdout.String(), "\njpeg ")
+
+	stdout.Reset()
+	require.NoError(t, runCLI(context.Background(), []string{"formats", "--json"}, &stdout, io.Discard))
+
+	var formats map[string][]string
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &formats))
+	require.Contains(t, formats["image"], "png")
+}
+
+func TestRunCLI(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	require.NoError(t, runCLI(context.Background(), []string{"help"}, &stdout, &stderr))
+	require.Equal(t, usage, stdout.String())
+
+	err := runCLI(context.Background(), []string{"transcode"}, &stdout, &stderr)
+	require.ErrorIs(t, err, errUsage)
+	require.Contains(t, stderr.String(), `unknown command "transcode"`)
+}
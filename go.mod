@@ -7,11 +7,13 @@ require (
 	github.com/gabriel-vasile/mimetype v1.4.3
 	github.com/gen2brain/go-fitz v1.23.7
 	github.com/go-chi/chi/v5 v5.0.10
+	github.com/klauspost/compress v1.17.11
 	github.com/signintech/gopdf v0.20.0
 	github.com/stretchr/testify v1.8.4
 	github.com/tealeg/xlsx/v3 v3.3.6
 	github.com/u2takey/ffmpeg-go v0.5.0
 	golang.org/x/image v0.14.0
+	golang.org/x/net v0.18.0
 	golang.org/x/text v0.14.0
 )
 
@@ -32,6 +34,5 @@ require (
 	github.com/rogpeppe/go-internal v1.9.0 // indirect
 	github.com/shabbyrobe/xmlwriter v0.0.0-20200208144257-9fca06d00ffa // indirect
 	github.com/u2takey/go-utils v0.3.1 // indirect
-	golang.org/x/net v0.18.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
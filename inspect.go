@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+
+	"github.com/danvergara/morphos/pkg/files"
+)
+
+// Inspection describes a file, before converting it.
+type Inspection struct {
+	Filename string `json:"filename"`
+	Size     int    `json:"size"`
+	MIMEType string `json:"mimeType"`
+	// Format is the name the format is registered under. e.g. jpeg.
+	Format   string `json:"format"`
+	Category string `json:"category"`
+	// Targets are the formats the file can be converted to, grouped by category.
+	Targets map[string][]string `json:"targets"`
+	// Details are specific to the format of the file, e.g. the size of an image.
+	// They are missing if the format doesn't tell more about its files.
+	Details any `json:"details,omitempty"`
+}
+
+// inspect describes the file, following the same steps as a conversion
+// to tell if it's supported.
+func inspect(ctx context.Context, filename string, fileBytes []byte) (Inspection, error) {
+	detectedFileType := mimetype.Detect(fileBytes)
+
+	fileType, subType, err := files.TypeAndSupType(detectedFileType.String())
+	if err != nil {
+		log.Printf("error occurred getting type and subtype from mimetype: %v", err)
+		return Inspection{}, WithHTTPStatus(err, http.StatusBadRequest)
+	}
+
+	fileFactory, err := files.BuildFactory(fileType, filename)
+	if err != nil {
+		log.Printf("error occurred while getting a file factory: %v", err)
+		return Inspection{}, WithHTTPStatus(err, http.StatusBadRequest)
+	}
+
+	file, err := fileFactory.NewFile(subType)
+	if err != nil {
+		log.Printf("error occurred getting the file object: %v", err)
+		return Inspection{}, WithHTTPStatus(err, http.StatusBadRequest)
+	}
+
+	format, ok := files.Lookup(subType)
+	if !ok {
+		err := fmt.Errorf("format %s not registered", subType)
+		log.Printf("error occurred looking up the format: %v", err)
+		return Inspection{}, WithHTTPStatus(err, http.StatusBadRequest)
+	}
+
+	targets, err := files.Reachable(subType)
+	if err != nil {
+		log.Printf("error occurred getting the supported formats: %v", err)
+		return Inspection{}, WithHTTPStatus(err, http.StatusInternalServerError)
+	}
+
+	result := Inspection{
+		Filename: filename,
+		Size:     len(fileBytes),
+		MIMEType: detectedFileType.String(),
+		Format:   format.Name,
+		Category: format.Category,
+		Targets:  targets,
+	}
+
+	if inspector, ok := file.(files.Inspector); ok {
+		// Files that can't be read are not valid, even if their type is supported.
+		result.Details, err = inspector.Inspect(ctx, bytes.NewReader(fileBytes))
+		if err != nil {
+			log.Printf("error occurred inspecting the file: %v", err)
+			return Inspection{}, WithHTTPStatus(err, http.StatusUnprocessableEntity)
+		}
+	}
+
+	return result, nil
+}
+
+// uploadedFile returns the path of a file of the upload directory, given its path within it.
+// Only regular files are accepted, and symbolic links are never followed, not even the ones
+// of the directories on the way to the file, so nothing out of the upload directory is read.
+func uploadedFile(name string) (string, error) {
+	path := uploadPath
+	parts := strings.Split(filepath.Clean(name), string(filepath.Separator))
+
+	for i, part := range parts {
+		path = filepath.Join(path, part)
+
+		info, err := os.Lstat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", WithHTTPStatus(err, http.StatusNotFound)
+		}
+		if err != nil {
+			return "", WithHTTPStatus(err, http.StatusInternalServerError)
+		}
+
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return "", WithHTTPStatus(fmt.Errorf("invalid file %q, it's a symbolic link", name), http.StatusBadRequest)
+		}
+
+		if i == len(parts)-1 && !info.Mode().IsRegular() {
+			return "", WithHTTPStatus(fmt.Errorf("invalid file %q, it's not a regular file", name), http.StatusBadRequest)
+		}
+	}
+
+	return path, nil
+}
+
+// inspectFile describes the file sent, or a file of the upload directory
+// named by the file query parameter, e.g. the result of a conversion.
+func inspectFile(w http.ResponseWriter, r *http.Request) error {
+	var (
+		filename  string
+		fileBytes []byte
+	)
+
+	if r.Method == http.MethodGet {
+		name := r.URL.Query().Get("file")
+		// Only the files of the upload directory can be inspected.
+		if name == "" || !filepath.IsLocal(name) {
+			err := fmt.Errorf("invalid file %q, it must be a path within the upload directory", name)
+			log.Printf("error occurred getting the file to inspect: %v", err)
+			return WithHTTPStatus(err, http.StatusBadRequest)
+		}
+
+		path, err := uploadedFile(name)
+		if err != nil {
+			log.Printf("error occurred getting the file to inspect: %v", err)
+			return err
+		}
+
+		fileBytes, err = os.ReadFile(path)
+		if err != nil {
+			log.Printf("error occurred reading the file to inspect: %v", err)
+			return WithHTTPStatus(err, http.StatusInternalServerError)
+		}
+
+		filename = filepath.Base(name)
+	} else {
+		file, fileHeader, err := r.FormFile(uploadFileFormField)
+		if err != nil {
+			log.Printf("error ocurred getting file from form: %v", err)
+			return WithHTTPStatus(err, http.StatusBadRequest)
+		}
+		defer file.Close()
+
+		fileBytes, err = io.ReadAll(file)
+		if err != nil {
+			log.Printf("error ocurred reading file: %v", err)
+			return WithHTTPStatus(err, http.StatusBadRequest)
+		}
+
+		filename = fileHeader.Filename
+	}
+
+	result, err := inspect(r.Context(), filename, fileBytes)
+	if err != nil {
+		return err
+	}
+
+	resp, err := json.Marshal(result)
+	if err != nil {
+		log.Printf("error ocurred marshalling the response: %v", err)
+		return WithHTTPStatus(err, http.StatusInternalServerError)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(resp); err != nil {
+		log.Printf("error ocurred writting to the ResponseWriter : %v", err)
+		return WithHTTPStatus(err, http.StatusInternalServerError)
+	}
+
+	return nil
+}
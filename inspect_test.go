@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspectUploadedFile(t *testing.T) {
+	dir := t.TempDir()
+	setUploadPath(t, filepath.Join(dir, "uploads"))
+
+	img := pngImage(t, 4, 3)
+
+	// A file out of the upload directory, reachable by symbolic links within it.
+	outside := filepath.Join(dir, "outside")
+	require.NoError(t, os.MkdirAll(outside, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "secret.png"), img, 0o600))
+
+	require.NoError(t, os.MkdirAll(filepath.Join(uploadPath, "jobs"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(uploadPath, "jobs", "photo.png"), img, 0o600))
+	require.NoError(t, os.Symlink(filepath.Join(outside, "secret.png"), filepath.Join(uploadPath, "secret.png")))
+	require.NoError(t, os.Symlink(outside, filepath.Join(uploadPath, "linked")))
+
+	var tests = []struct {
+		name   string
+		file   string
+		status int
+	}{
+		{name: "regular file", file: "jobs/photo.png", status: http.StatusOK},
+		{name: "missing file", file: "jobs/missing.png", status: http.StatusNotFound},
+		{name: "no file", file: "", status: http.StatusBadRequest},
+		{name: "directory", file: "jobs", status: http.StatusBadRequest},
+		{name: "out of the upload directory", file: "../outside/secret.png", status: http.StatusBadRequest},
+		{name: "absolute path", file: filepath.Join(outside, "secret.png"), status: http.StatusBadRequest},
+		{name: "symbolic link to a file", file: "secret.png", status: http.StatusBadRequest},
+		{name: "symbolic link to a directory", file: "linked/secret.png", status: http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/inspect?file="+url.QueryEscape(tc.file), nil)
+			w := httptest.NewRecorder()
+
+			apiRouter().ServeHTTP(w, r)
+			require.Equal(t, tc.status, w.Code, w.Body.String())
+
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			var inspection Inspection
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &inspection))
+			require.Equal(t, "photo.png", inspection.Filename)
+			require.Equal(t, len(img), inspection.Size)
+			require.Equal(t, "png", inspection.Format)
+		})
+	}
+}
+
+func TestInspectForm(t *testing.T) {
+	var tests = []struct {
+		name     string
+		file     formFile
+		status   int
+		expected Inspection
+	}{
+		{
+			name:   "image",
+			file:   formFile{name: "photo.png", content: pngImage(t, 4, 3)},
+			status: http.StatusOK,
+			expected: Inspection{
+				Filename: "photo.png",
+				MIMEType: "image/png",
+				Format:   "png",
+				Category: "image",
+			},
+		},
+		{
+			name:   "unsupported file",
+			file:   formFile{name: "notes.bin", content: []byte{0, 1, 2, 3}},
+			status: http.StatusBadRequest,
+		},
+		{
+			// The file has the signature of a png image, but it can't be read.
+			name:   "broken image",
+			file:   formFile{name: "broken.png", content: []byte("\x89PNG\r\n\x1a\n")},
+			status: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			apiRouter().ServeHTTP(w, newFormRequest(t, "/inspect", nil, tc.file))
+			require.Equal(t, tc.status, w.Code, w.Body.String())
+
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			var inspection Inspection
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &inspection))
+			require.Equal(t, tc.expected.Filename, inspection.Filename)
+			require.Equal(t, len(tc.file.content), inspection.Size)
+			require.Equal(t, tc.expected.MIMEType, inspection.MIMEType)
+			require.Equal(t, tc.expected.Format, inspection.Format)
+			require.Equal(t, tc.expected.Category, inspection.Category)
+			require.Contains(t, inspection.Targets["Image"], "jpeg")
+			require.NotNil(t, inspection.Details)
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/danvergara/morphos/pkg/jobs"
+)
+
+// jobPool runs the conversions submitted through the jobs API.
+var jobPool *jobs.Pool
+
+func init() {
+	cfg := jobs.Config{
+		Workers:   runtime.NumCPU(),
+		QueueSize: 100,
+		TTL:       time.Hour,
+		// Removes the directory where the result of the job was stored,
+		// as long as the job got its own directory.
+		OnExpire: func(j jobs.Job) {
+			if j.Result == nil {
+				return
+			}
+
+			if dir := filepath.Dir(j.Result.Path); filepath.Dir(dir) == jobsPath() {
+				os.RemoveAll(dir)
+			}
+		},
+	}
+
+	if v, err := strconv.Atoi(os.Getenv("MORPHOS_JOB_WORKERS")); err == nil && v > 0 {
+		cfg.Workers = v
+	}
+
+	if v, err := strconv.Atoi(os.Getenv("MORPHOS_JOB_QUEUE_SIZE")); err == nil && v >= 0 {
+		cfg.QueueSize = v
+	}
+
+	if v, err := time.ParseDuration(os.Getenv("MORPHOS_JOB_TTL")); err == nil {
+		cfg.TTL = v
+	}
+
+	jobPool = jobs.NewPool(cfg)
+}
+
+// jobsPath is the directory where the results of the jobs are stored.
+// Every job gets its own directory, so jobs converting files with the same
+// name don't overwrite each other.
+func jobsPath() string {
+	return filepath.Join(uploadPath, "jobs")
+}
+
+// newJob returns the task that runs the conversion as a job.
+// The result is written into outDir, or into a directory of its own
+// in the jobs path if outDir is empty.
+func newJob(c runner, outDir string) jobs.Task {
+	return func(ctx context.Context) (jobs.Result, error) {
+		dir := outDir
+
+		if dir == "" {
+			if err := os.MkdirAll(jobsPath(), 0o755); err != nil {
+				return jobs.Result{}, fmt.Errorf("error creating the jobs directory: %w", err)
+			}
+
+			var err error
+			dir, err = os.MkdirTemp(jobsPath(), "")
+			if err != nil {
+				return jobs.Result{}, fmt.Errorf("error creating the job directory: %w", err)
+			}
+		}
+
+		convertedFile, err := runConversion(ctx, c, dir)
+		if err != nil {
+			if outDir == "" {
+				os.RemoveAll(dir)
+			}
+			return jobs.Result{}, err
+		}
+
+		return jobs.Result{
+			Filename: convertedFile.Filename,
+			Path:     filepath.Join(dir, convertedFile.Filename),
+			Plan:     convertedFile.Plan,
+			MIMEType: convertedFile.MIMEType,
+		}, nil
+	}
+}
+
+// submitConversion queues the conversion as a job.
+func submitConversion(c runner, outDir string) (jobs.Job, error) {
+	job, err := jobPool.Submit(newJob(c, outDir))
+	if err != nil {
+		log.Printf("error occurred submitting the job: %v", err)
+		if errors.Is(err, jobs.ErrQueueFull) || errors.Is(err, jobs.ErrStopped) {
+			return jobs.Job{}, WithHTTPStatus(err, http.StatusServiceUnavailable)
+		}
+		return jobs.Job{}, WithHTTPStatus(err, http.StatusInternalServerError)
+	}
+
+	return job, nil
+}
+
+// submitJob validates a conversion and queues it,
+// it responds with the job right away, without waiting for the conversion.
+func submitJob(w http.ResponseWriter, r *http.Request) error {
+	c, err := parseRequest(r)
+	if err != nil {
+		return err
+	}
+
+	job, err := submitConversion(c, "")
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/jobs/%s", job.ID))
+	return writeJSON(w, http.StatusAccepted, job)
+}
+
+// getJob responds with the current state of a job.
+func getJob(w http.ResponseWriter, r *http.Request) error {
+	job, err := jobPool.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		return WithHTTPStatus(err, http.StatusNotFound)
+	}
+
+	return writeJSON(w, http.StatusOK, job)
+}
+
+// getJobResult streams the file produced by a job that succeeded.
+func getJobResult(w http.ResponseWriter, r *http.Request) error {
+	job, err := jobPool.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		return WithHTTPStatus(err, http.StatusNotFound)
+	}
+
+	if job.Status != jobs.Succeeded {
+		return WithHTTPStatus(
+			fmt.Errorf("job %s has no result, its status is %s", job.ID, job.Status),
+			http.StatusConflict,
+		)
+	}
+
+	f, err := os.Open(job.Result.Path)
+	if err != nil {
+		log.Printf("error occurred opening the result of the job %s: %v", job.ID, err)
+		return WithHTTPStatus(err, http.StatusInternalServerError)
+	}
+	defer f.Close()
+
+	setFileHeaders(w, ConvertedFile{
+		Filename: job.Result.Filename,
+		Plan:     job.Result.Plan,
+		MIMEType: job.Result.MIMEType,
+	})
+
+	http.ServeContent(w, r, job.Result.Filename, *job.FinishedAt, f)
+
+	return nil
+}
+
+// getJobEvents streams the state of a job as Server-Sent Events.
+// A progress event, holding the job as JSON, is sent every time the job changes,
+// and a done event is sent once it finishes.
+func getJobEvents(w http.ResponseWriter, r *http.Request) error {
+	return streamJob(w, r, func(job jobs.Job) (string, string, error) {
+		data, err := json.Marshal(job)
+		if err != nil {
+			return "", "", err
+		}
+
+		if job.Done() {
+			return "done", string(data), nil
+		}
+
+		return "progress", string(data), nil
+	})
+}
+
+// streamJob sends an event every time the job changes, until it finishes
+// or the client goes away. The event to send is built by the event function,
+// which returns the name of the event and its data.
+func streamJob(w http.ResponseWriter, r *http.Request, event func(jobs.Job) (string, string, error)) error {
+	id := chi.URLParam(r, "id")
+
+	job, changed, err := jobPool.Watch(id)
+	if err != nil {
+		return WithHTTPStatus(err, http.StatusNotFound)
+	}
+
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	for {
+		name, data, err := event(job)
+		if err != nil {
+			log.Printf("error occurred building the event of the job %s: %v", id, err)
+			return nil
+		}
+
+		if err := writeEvent(w, name, data); err != nil {
+			return nil
+		}
+
+		if err := rc.Flush(); err != nil {
+			log.Printf("error occurred flushing the events of the job %s: %v", id, err)
+			return nil
+		}
+
+		if job.Done() {
+			return nil
+		}
+
+		select {
+		case <-r.Context().Done():
+			return nil
+		case <-changed:
+		}
+
+		job, changed, err = jobPool.Watch(id)
+		if err != nil {
+			return nil
+		}
+	}
+}
+
+// writeEvent writes a Server-Sent Event.
+// Every line of the data is sent in a data field of its own.
+func writeEvent(w io.Writer, name, data string) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "event: %s\n", name)
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteString("\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeJSON writes v to the response as JSON, with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v any) error {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("error ocurred marshalling the response: %v", err)
+		return WithHTTPStatus(err, http.StatusInternalServerError)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(resp); err != nil {
+		log.Printf("error ocurred writting to the ResponseWriter : %v", err)
+		return WithHTTPStatus(err, http.StatusInternalServerError)
+	}
+
+	return nil
+}
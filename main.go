@@ -10,10 +10,13 @@ import (
 	"html/template"
 	"io"
 	"log"
+	"mime"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"syscall"
@@ -22,14 +25,23 @@ import (
 	"github.com/gabriel-vasile/mimetype"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"golang.org/x/text/cases"
-	"golang.org/x/text/language"
 
 	"github.com/danvergara/morphos/pkg/files"
+	// Format packages register their formats into the files registry.
+	_ "github.com/danvergara/morphos/pkg/files/documents"
+	_ "github.com/danvergara/morphos/pkg/files/ebooks"
+	"github.com/danvergara/morphos/pkg/files/images"
+	"github.com/danvergara/morphos/pkg/jobs"
+	"github.com/danvergara/morphos/pkg/packaging"
+	"github.com/danvergara/morphos/pkg/progress"
+	"github.com/danvergara/morphos/pkg/util"
 )
 
 const (
-	uploadFileFormField = "uploadFile"
+	uploadFileFormField   = "uploadFile"
+	conversionChainHeader = "X-Conversion-Chain"
+	// archiveFileType is the file type of the archives, e.g. application/zip.
+	archiveFileType = "application"
 )
 
 var (
@@ -49,6 +61,37 @@ func init() {
 	if uploadPath == "" {
 		uploadPath = "/tmp"
 	}
+
+	// Maximum time every external tool is allowed to run for a single conversion.
+	// e.g. MORPHOS_LIBREOFFICE_TIMEOUT=10m
+	for env, tool := range map[string]util.Tool{
+		"MORPHOS_FFMPEG_TIMEOUT":      util.FFmpeg,
+		"MORPHOS_LIBREOFFICE_TIMEOUT": util.LibreOffice,
+		"MORPHOS_CALIBRE_TIMEOUT":     util.Calibre,
+		"MORPHOS_LIBJXL_TIMEOUT":      util.LibJXL,
+		"MORPHOS_TESSERACT_TIMEOUT":   util.Tesseract,
+	} {
+		value := os.Getenv(env)
+		if value == "" {
+			continue
+		}
+
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			log.Printf("ignoring %s, it is not a valid duration: %v", env, err)
+			continue
+		}
+
+		util.SetTimeout(tool, timeout)
+	}
+
+	// How images are converted to other image formats.
+	// e.g. MORPHOS_IMAGE_BACKEND=go
+	if value := os.Getenv("MORPHOS_IMAGE_BACKEND"); value != "" {
+		if err := images.SetBackend(images.Backend(value)); err != nil {
+			log.Printf("ignoring MORPHOS_IMAGE_BACKEND: %v", err)
+		}
+	}
 }
 
 // statusError struct is the error representation
@@ -61,6 +104,9 @@ type statusError struct {
 // Unwrap method returns the inner error.
 func (e statusError) Unwrap() error { return e.error }
 
+// HTTPStatus method returns the status code of the error.
+func (e statusError) HTTPStatus() int { return e.status }
+
 // HTTPStatus returns a HTTP status code.
 func HTTPStatus(err error) int {
 	if err == nil {
@@ -106,6 +152,18 @@ func toHandler(f func(http.ResponseWriter, *http.Request) error) http.HandlerFun
 type ConvertedFile struct {
 	Filename string
 	FileType string
+	// Plan is the chain of conversions followed to get the file.
+	Plan files.Plan
+	// MIMEType is the MIME type detected for the file.
+	MIMEType string
+}
+
+// FormatsForm holds the data needed to render the fields
+// to pick the target format and the options of the conversion.
+type FormatsForm struct {
+	Source  string
+	Targets map[string][]string
+	Options files.Schema
 }
 
 func index(w http.ResponseWriter, _ *http.Request) error {
@@ -115,6 +173,7 @@ func index(w http.ResponseWriter, _ *http.Request) error {
 		"templates/partials/style.tmpl",
 		"templates/partials/nav.tmpl",
 		"templates/partials/form.tmpl",
+		"templates/partials/options.tmpl",
 		"templates/partials/modal.tmpl",
 		"templates/partials/js.tmpl",
 	}
@@ -134,28 +193,27 @@ func index(w http.ResponseWriter, _ *http.Request) error {
 	return nil
 }
 
+// handleUploadFile queues the conversion of the file sent through the form,
+// and renders its progress, which is updated live through Server-Sent Events.
 func handleUploadFile(w http.ResponseWriter, r *http.Request) error {
-	convertedFileName, convertedFileType, _, err := convertFile(r)
+	c, err := parseRequest(r)
 	if err != nil {
 		return err
 	}
 
-	tmpls := []string{
-		"templates/partials/card_file.tmpl",
-		"templates/partials/modal.tmpl",
+	// The result is stored in the upload path, so it can be downloaded from /files.
+	job, err := submitConversion(c, uploadPath)
+	if err != nil {
+		return err
 	}
 
-	tmpl, err := template.ParseFS(templatesHTML, tmpls...)
+	tmpl, err := template.ParseFS(templatesHTML, "templates/partials/progress.tmpl")
 	if err != nil {
 		log.Printf("error occurred parsing template files: %v", err)
 		return WithHTTPStatus(err, http.StatusInternalServerError)
 	}
 
-	err = tmpl.ExecuteTemplate(
-		w,
-		"content",
-		ConvertedFile{Filename: convertedFileName, FileType: convertedFileType},
-	)
+	err = tmpl.ExecuteTemplate(w, "content", job)
 	if err != nil {
 		log.Printf("error occurred executing template files: %v", err)
 		return WithHTTPStatus(err, http.StatusInternalServerError)
@@ -164,6 +222,48 @@ func handleUploadFile(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// handleJobEvents streams the progress of a job as Server-Sent Events of HTML fragments,
+// meant to be swapped by the htmx sse extension.
+// Once the job is done, the card to download the file, or the error, is sent.
+func handleJobEvents(w http.ResponseWriter, r *http.Request) error {
+	progressTmpl, err := template.ParseFS(templatesHTML, "templates/partials/progress.tmpl")
+	if err != nil {
+		log.Printf("error occurred parsing template files: %v", err)
+		return WithHTTPStatus(err, http.StatusInternalServerError)
+	}
+
+	cardTmpl, err := template.ParseFS(templatesHTML, "templates/partials/card_file.tmpl")
+	if err != nil {
+		log.Printf("error occurred parsing template files: %v", err)
+		return WithHTTPStatus(err, http.StatusInternalServerError)
+	}
+
+	return streamJob(w, r, func(job jobs.Job) (string, string, error) {
+		var buf bytes.Buffer
+
+		switch job.Status {
+		case jobs.Succeeded:
+			fileType, _, err := files.TypeAndSupType(job.Result.MIMEType)
+			if err != nil {
+				return "", "", err
+			}
+
+			err = cardTmpl.ExecuteTemplate(&buf, "content", ConvertedFile{
+				Filename: job.Result.Filename,
+				FileType: fileType,
+				Plan:     job.Result.Plan,
+			})
+			return "done", buf.String(), err
+		case jobs.Failed:
+			err := progressTmpl.ExecuteTemplate(&buf, "failed", job)
+			return "done", buf.String(), err
+		default:
+			err := progressTmpl.ExecuteTemplate(&buf, "progress-elements", job)
+			return "progress", buf.String(), err
+		}
+	})
+}
+
 func handleFileFormat(w http.ResponseWriter, r *http.Request) error {
 	file, _, err := r.FormFile(uploadFileFormField)
 	if err != nil {
@@ -181,6 +281,7 @@ func handleFileFormat(w http.ResponseWriter, r *http.Request) error {
 
 	templates := []string{
 		"templates/partials/form.tmpl",
+		"templates/partials/options.tmpl",
 	}
 
 	fileType, subType, err := files.TypeAndSupType(detectedFileType.String())
@@ -195,19 +296,65 @@ func handleFileFormat(w http.ResponseWriter, r *http.Request) error {
 		return WithHTTPStatus(err, http.StatusBadRequest)
 	}
 
-	f, err := fileFactory.NewFile(subType)
-	if err != nil {
+	if _, err := fileFactory.NewFile(subType); err != nil {
 		log.Printf("error occurred getting the file object: %v", err)
 		return WithHTTPStatus(err, http.StatusInternalServerError)
 	}
 
+	// Get the formats the file can be converted to,
+	// through one or more conversions.
+	targets, err := files.Reachable(subType)
+	if err != nil {
+		log.Printf("error occurred getting the supported formats: %v", err)
+		return WithHTTPStatus(err, http.StatusInternalServerError)
+	}
+
 	tmpl, err := template.ParseFS(templatesHTML, templates...)
 	if err != nil {
 		log.Printf("error occurred parsing template files: %v", err)
 		return WithHTTPStatus(err, http.StatusInternalServerError)
 	}
 
-	if err = tmpl.ExecuteTemplate(w, "format-elements", f.SupportedFormats()); err != nil {
+	// Shows the options of the conversion to the first target listed,
+	// they are updated every time a different target gets selected.
+	formats := FormatsForm{
+		Source:  subType,
+		Targets: targets,
+	}
+
+	if target := firstTarget(targets); target != "" {
+		formats.Options, err = files.ConversionOptions(subType, target)
+		if err != nil {
+			log.Printf("error occurred getting the conversion options: %v", err)
+			return WithHTTPStatus(err, http.StatusInternalServerError)
+		}
+	}
+
+	if err = tmpl.ExecuteTemplate(w, "format-elements", formats); err != nil {
+		log.Printf("error occurred executing template files: %v", err)
+		return WithHTTPStatus(err, http.StatusInternalServerError)
+	}
+
+	return nil
+}
+
+func handleOptions(w http.ResponseWriter, r *http.Request) error {
+	source := r.URL.Query().Get("sourceFormat")
+	target := r.URL.Query().Get("targetFormat")
+
+	options, err := files.ConversionOptions(source, target)
+	if err != nil {
+		log.Printf("error occurred getting the conversion options: %v", err)
+		return WithHTTPStatus(err, http.StatusBadRequest)
+	}
+
+	tmpl, err := template.ParseFS(templatesHTML, "templates/partials/options.tmpl")
+	if err != nil {
+		log.Printf("error occurred parsing template files: %v", err)
+		return WithHTTPStatus(err, http.StatusInternalServerError)
+	}
+
+	if err = tmpl.ExecuteTemplate(w, "options-elements", options); err != nil {
 		log.Printf("error occurred executing template files: %v", err)
 		return WithHTTPStatus(err, http.StatusInternalServerError)
 	}
@@ -253,22 +400,58 @@ func getFormats(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// uploadFile converts the files sent and responds with the result.
+// If several files are sent, they are converted as a batch and returned as an archive.
+// Archives are packaged straight into the response.
 func uploadFile(w http.ResponseWriter, r *http.Request) error {
-	_, _, convertedFileBytes, err := convertFile(r)
+	c, err := parseRequest(r)
 	if err != nil {
 		return err
 	}
 
+	return respondConverted(w, r, c)
+}
+
+// respondConverted runs the conversion and responds with the converted file.
+func respondConverted(w http.ResponseWriter, r *http.Request, c runner) error {
+	// The conversion is cancelled if the client goes away.
+	convertedFile, output, err := c.convert(r.Context())
+	if err != nil {
+		return err
+	}
+
+	setFileHeaders(w, convertedFile)
 	w.WriteHeader(http.StatusOK)
-	w.Header().Set("Content-Type", "application/octet-stream")
-	if _, err := w.Write(convertedFileBytes); err != nil {
+
+	// The status is already sent, so errors can only be logged from now on.
+	if _, err := io.Copy(w, output); err != nil {
 		log.Printf("error occurred writing converted file to response writer: %v", err)
-		return WithHTTPStatus(err, http.StatusInternalServerError)
 	}
 
 	return nil
 }
 
+// setFileHeaders sets the headers of a response that returns a converted file,
+// so the client gets its type and the name it should be saved under.
+func setFileHeaders(w http.ResponseWriter, convertedFile ConvertedFile) {
+	contentType := convertedFile.MIMEType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set(
+		"Content-Disposition",
+		mime.FormatMediaType("attachment", map[string]string{"filename": convertedFile.Filename}),
+	)
+
+	// Lets the client know the route taken to convert the file.
+	// e.g. avif,png,pdf
+	if len(convertedFile.Plan) > 0 {
+		w.Header().Set(conversionChainHeader, strings.Join(convertedFile.Plan, ","))
+	}
+}
+
 func newRouter() http.Handler {
 	r := chi.NewRouter()
 	r.Use(middleware.Logger)
@@ -286,7 +469,14 @@ func newRouter() http.Handler {
 func apiRouter() http.Handler {
 	r := chi.NewRouter()
 	r.Get("/formats", toHandler(getFormats))
+	r.Get("/inspect", toHandler(inspectFile))
+	r.Post("/inspect", toHandler(inspectFile))
 	r.Post("/upload", toHandler(uploadFile))
+	r.Post("/pdf", toHandler(pdfFile))
+	r.Post("/jobs", toHandler(submitJob))
+	r.Get("/jobs/{id}", toHandler(getJob))
+	r.Get("/jobs/{id}/result", toHandler(getJobResult))
+	r.Get("/jobs/{id}/events", toHandler(getJobEvents))
 	return r
 }
 
@@ -298,6 +488,8 @@ func addRoutes(r *chi.Mux, fs, fsUpload http.Handler) {
 	r.Post("/upload", toHandler(handleUploadFile))
 	r.Post("/format", toHandler(handleFileFormat))
 	r.Get("/modal", toHandler(handleModal))
+	r.Get("/options", toHandler(handleOptions))
+	r.Get("/jobs/{id}/events", toHandler(handleJobEvents))
 
 	// Mount the api router.
 	r.Mount("/api/v1", apiRouter())
@@ -317,6 +509,13 @@ func run(ctx context.Context) error {
 		syscall.SIGQUIT)
 	defer stop()
 
+	// The jobs outlive the requests that submitted them,
+	// they are only cancelled once the server is shut down.
+	jobsCtx, cancelJobs := context.WithCancel(context.Background())
+	defer cancelJobs()
+
+	jobPool.Start(jobsCtx)
+
 	r := newRouter()
 
 	srv := &http.Server{
@@ -348,6 +547,9 @@ func run(ctx context.Context) error {
 			fmt.Fprintf(os.Stderr, "error shutting down http server: %s\n", err)
 		}
 
+		cancelJobs()
+		jobPool.Wait()
+
 		log.Println("shutdown completed")
 	}()
 
@@ -359,12 +561,12 @@ func run(ctx context.Context) error {
 func main() {
 	ctx := context.Background()
 
-	if err := run(ctx); err != nil {
-		fmt.Fprintf(os.Stderr, "%s\n", err)
+	if err := runCLI(ctx, os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, errUsage) {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+		}
 		os.Exit(1)
 	}
-
-	log.Println("exiting...")
 }
 
 // renderError functions executes the error template.
@@ -382,26 +584,48 @@ func filename(filename, extension string) string {
 	return fmt.Sprintf("%s.%s", fileNameWithoutExtension(filename), extension)
 }
 
+// firstTarget returns the first format of the targets,
+// in the order they are listed in the form.
+func firstTarget(targets map[string][]string) string {
+	fileTypes := make([]string, 0, len(targets))
+	for fileType := range targets {
+		fileTypes = append(fileTypes, fileType)
+	}
+
+	sort.Strings(fileTypes)
+
+	for _, fileType := range fileTypes {
+		if len(targets[fileType]) > 0 {
+			return targets[fileType][0]
+		}
+	}
+
+	return ""
+}
+
 func healthz(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-// convertFile handles everything required to convert a file.
-// It returns the name of the file, the target file type, the file as a slice of bytes and a possible error.
-// It is both used by the HTML form and the API.
-func convertFile(r *http.Request) (string, string, []byte, error) {
-	var (
-		convertedFile     io.Reader
-		convertedFilePath string
-		convertedFileName string
-		err               error
-	)
+// conversion is a validated request to convert a file.
+// It holds everything needed to run the conversion,
+// so it can be run after the request that asked for it is gone.
+type conversion struct {
+	filename string
+	source   string
+	target   string
+	file     []byte
+	opts     files.ConvertOptions
+}
 
+// parseConversion reads the file and the parameters of a conversion from the form,
+// and checks the conversion can be done before running it.
+func parseConversion(r *http.Request) (conversion, error) {
 	// Parse and validate file and post parameters.
 	file, fileHeader, err := r.FormFile(uploadFileFormField)
 	if err != nil {
 		log.Printf("error ocurred getting file from form: %v", err)
-		return "", "", nil, WithHTTPStatus(err, http.StatusBadRequest)
+		return conversion{}, WithHTTPStatus(err, http.StatusBadRequest)
 	}
 	defer file.Close()
 
@@ -409,12 +633,18 @@ func convertFile(r *http.Request) (string, string, []byte, error) {
 	fileBytes, err := io.ReadAll(file)
 	if err != nil {
 		log.Printf("error ocurred reading file: %v", err)
-		return "", "", nil, WithHTTPStatus(err, http.StatusBadRequest)
+		return conversion{}, WithHTTPStatus(err, http.StatusBadRequest)
 	}
 
 	// Get the sub-type of the input file from the form.
 	targetFileSubType := r.FormValue("targetFormat")
 
+	return newConversion(fileHeader.Filename, fileBytes, targetFileSubType, r.Form)
+}
+
+// newConversion checks a file can be converted to the target format,
+// with the options sent in the form.
+func newConversion(filename string, fileBytes []byte, targetFileSubType string, form url.Values) (conversion, error) {
 	// Call Detect fuction to get the mimetype of the input file.
 	detectedFileType := mimetype.Detect(fileBytes)
 
@@ -422,85 +652,169 @@ func convertFile(r *http.Request) (string, string, []byte, error) {
 	fileType, subType, err := files.TypeAndSupType(detectedFileType.String())
 	if err != nil {
 		log.Printf("error occurred getting type and subtype from mimetype: %v", err)
-		return "", "", nil, WithHTTPStatus(err, http.StatusBadRequest)
+		return conversion{}, WithHTTPStatus(err, http.StatusBadRequest)
 	}
 
 	// Get the right factory based off the input file type.
-	fileFactory, err := files.BuildFactory(fileType, fileHeader.Filename)
+	fileFactory, err := files.BuildFactory(fileType, filename)
 	if err != nil {
 		log.Printf("error occurred while getting a file factory: %v", err)
-		return "", "", nil, WithHTTPStatus(err, http.StatusBadRequest)
+		return conversion{}, WithHTTPStatus(err, http.StatusBadRequest)
 	}
 
-	// Returns an object that implements the File interface based on the sub-type of the input file.
-	f, err := fileFactory.NewFile(subType)
-	if err != nil {
+	// Checks there is an object that implements the File interface based on the sub-type of the input file.
+	if _, err := fileFactory.NewFile(subType); err != nil {
 		log.Printf("error occurred getting the file object: %v", err)
-		return "", "", nil, WithHTTPStatus(err, http.StatusBadRequest)
+		return conversion{}, WithHTTPStatus(err, http.StatusBadRequest)
 	}
 
-	// Return the kind of the output file.
-	targetFileType := files.SupportedFileTypes()[targetFileSubType]
+	// Parses the conversion options sent in the form,
+	// based on the options accepted by the formats involved in the conversion.
+	// It fails if there's no way to convert the file to the target format.
+	schema, err := files.ConversionOptions(subType, targetFileSubType)
+	if err != nil {
+		log.Printf("error occurred while getting the conversion options: %v", err)
+		return conversion{}, WithHTTPStatus(err, http.StatusBadRequest)
+	}
+
+	opts, err := schema.Parse(form)
+	if err != nil {
+		log.Printf("error occurred while parsing the conversion options: %v", err)
+		return conversion{}, WithHTTPStatus(err, http.StatusBadRequest)
+	}
+
+	return conversion{
+		filename: filename,
+		source:   subType,
+		target:   targetFileSubType,
+		file:     fileBytes,
+		opts:     opts,
+	}, nil
+}
+
+// convert converts the file.
+// It returns the converted file, which holds its name, its file type and the
+// chain of conversions followed, the content of the file and a possible error.
+// The progress of the conversion is reported through the context.
+func (c conversion) convert(ctx context.Context) (ConvertedFile, io.Reader, error) {
+	progress.Report(ctx, progress.Event{
+		Stage:   progress.Received,
+		Message: fmt.Sprintf("received %s", c.filename),
+	})
+
+	progress.Report(ctx, progress.Event{
+		Stage:   progress.Detected,
+		Message: fmt.Sprintf("detected a %s file", c.source),
+	})
 
 	// Convert the file to the target format.
+	// The planner figures out the chain of conversions required to get there,
+	// e.g. avif -> png -> pdf.
 	// convertedFile is an io.Reader.
-	convertedFile, err = f.ConvertTo(
-		cases.Title(language.English).String(targetFileType),
-		targetFileSubType,
-		bytes.NewReader(fileBytes),
+	convertedFile, plan, err := files.Convert(
+		ctx,
+		c.filename,
+		c.source,
+		c.target,
+		bytes.NewReader(c.file),
+		c.opts,
 	)
 	if err != nil {
 		log.Printf("error ocurred while processing the input file: %v", err)
-		return "", "", nil, WithHTTPStatus(err, http.StatusInternalServerError)
-	}
-
-	switch fileType {
-	case "application", "text":
-		targetFileSubType = "zip"
+		if errors.Is(err, files.ErrNoPlan) || errors.Is(err, files.ErrInvalidOption) {
+			return ConvertedFile{}, nil, WithHTTPStatus(err, http.StatusBadRequest)
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			return ConvertedFile{}, nil, WithHTTPStatus(err, http.StatusGatewayTimeout)
+		}
+		return ConvertedFile{}, nil, WithHTTPStatus(err, http.StatusInternalServerError)
 	}
 
-	convertedFileName = filename(fileHeader.Filename, targetFileSubType)
-	convertedFilePath = filepath.Join(uploadPath, convertedFileName)
+	log.Printf("converted %s following %s", c.filename, plan)
 
-	newFile, err := os.Create(convertedFilePath)
-	if err != nil {
-		log.Printf("error occurred while creating the output file: %v", err)
-		return "", "", nil, WithHTTPStatus(err, http.StatusInternalServerError)
+	// Archives are packaged as they are written into their destination,
+	// and their extension is the one of the archive format, not the target format.
+	if archive, ok := convertedFile.(*packaging.Archive); ok {
+		return ConvertedFile{
+			Filename: filename(c.filename, archive.Format.Extension()),
+			FileType: archiveFileType,
+			Plan:     plan,
+			MIMEType: archive.Format.MIMEType(),
+		}, archive, nil
 	}
-	defer newFile.Close()
 
 	buf := new(bytes.Buffer)
 	if _, err := buf.ReadFrom(convertedFile); err != nil {
 		log.Printf("error occurred while readinf from the converted file: %v", err)
-		return "", "", nil, WithHTTPStatus(err, http.StatusInternalServerError)
+		return ConvertedFile{}, nil, WithHTTPStatus(err, http.StatusInternalServerError)
 	}
 
 	convertedFileBytes := buf.Bytes()
-	if _, err := newFile.Write(convertedFileBytes); err != nil {
-		log.Printf("error occurred writing converted output to a file in disk: %v", err)
-		return "", "", nil, WithHTTPStatus(err, http.StatusInternalServerError)
-	}
-
 	convertedFileMimeType := mimetype.Detect(convertedFileBytes)
 
 	convertedFileType, _, err := files.TypeAndSupType(convertedFileMimeType.String())
 	if err != nil {
 		log.Printf("error occurred getting the file type of the result file: %v", err)
-		return "", "", nil, WithHTTPStatus(err, http.StatusInternalServerError)
+		return ConvertedFile{}, nil, WithHTTPStatus(err, http.StatusInternalServerError)
+	}
+
+	return ConvertedFile{
+		Filename: filename(c.filename, c.target),
+		FileType: convertedFileType,
+		Plan:     plan,
+		MIMEType: convertedFileMimeType.String(),
+	}, bytes.NewReader(convertedFileBytes), nil
+}
+
+// runConversion converts the file, or the batch of files, and writes the result into the given directory.
+func runConversion(ctx context.Context, c runner, outDir string) (ConvertedFile, error) {
+	convertedFile, output, err := c.convert(ctx)
+	if err != nil {
+		return ConvertedFile{}, err
+	}
+
+	if err := saveFile(ctx, outDir, convertedFile, output); err != nil {
+		return ConvertedFile{}, err
 	}
 
-	return convertedFileName, convertedFileType, convertedFileBytes, nil
+	return convertedFile, nil
+}
+
+// saveFile writes the converted file into the given directory.
+func saveFile(ctx context.Context, outDir string, convertedFile ConvertedFile, output io.Reader) error {
+	convertedFilePath := filepath.Join(outDir, convertedFile.Filename)
+
+	newFile, err := os.Create(convertedFilePath)
+	if err != nil {
+		log.Printf("error occurred while creating the output file: %v", err)
+		return WithHTTPStatus(err, http.StatusInternalServerError)
+	}
+	defer newFile.Close()
+
+	if _, err := io.Copy(newFile, output); err != nil {
+		log.Printf("error occurred writing converted output to a file in disk: %v", err)
+		return WithHTTPStatus(err, http.StatusInternalServerError)
+	}
+
+	progress.Report(ctx, progress.Event{
+		Stage:   progress.Done,
+		Message: fmt.Sprintf("converted to %s", convertedFile.Filename),
+	})
+
+	return nil
 }
 
 // supportedFormatsJSONResponse returns the supported formas as a map formatted to be shown as JSON.
 // The intention of this is showing the supported formats to the client.
+// The formats are read from the files registry, aliases included.
 // Example:
 // {"documents": ["docx", "xls"], "image": ["png", "jpeg"]}
 func supportedFormatsJSONResponse() map[string][]string {
 	result := make(map[string][]string)
 
-	for k, v := range files.SupportedFileTypes() {
-		result[v] = append(result[v], k)
+	for _, f := range files.Formats() {
+		result[f.Category] = append(result[f.Category], f.Name)
+		result[f.Category] = append(result[f.Category], f.Aliases...)
 	}
 
 	return result
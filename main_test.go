@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// formFile is a file sent in a multipart form.
+type formFile struct {
+	name    string
+	content []byte
+}
+
+// pngImage returns a png image of the given size, filled with a single color.
+func pngImage(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.NRGBA{R: 0x33, G: 0x66, B: 0x99, A: 0xff})
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, png.Encode(buf, img))
+
+	return buf.Bytes()
+}
+
+// newFormRequest returns a request that posts a multipart form to the target,
+// with the given fields and files, every file sent as an upload file.
+func newFormRequest(t *testing.T, target string, fields map[string][]string, files ...formFile) *http.Request {
+	t.Helper()
+
+	body := new(bytes.Buffer)
+	mw := multipart.NewWriter(body)
+
+	for name, values := range fields {
+		for _, value := range values {
+			require.NoError(t, mw.WriteField(name, value))
+		}
+	}
+
+	for _, f := range files {
+		part, err := mw.CreateFormFile(uploadFileFormField, f.name)
+		require.NoError(t, err)
+
+		_, err = part.Write(f.content)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, mw.Close())
+
+	r := httptest.NewRequest(http.MethodPost, target, body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+
+	return r
+}
+
+// setUploadPath points the upload directory to dir while the test runs.
+func setUploadPath(t *testing.T, dir string) {
+	t.Helper()
+
+	previous := uploadPath
+	uploadPath = dir
+	t.Cleanup(func() { uploadPath = previous })
+}
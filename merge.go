@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+
+	"github.com/danvergara/morphos/pkg/files"
+	"github.com/danvergara/morphos/pkg/files/images"
+	"github.com/danvergara/morphos/pkg/packaging"
+	"github.com/danvergara/morphos/pkg/progress"
+)
+
+// mergeFormField is the form field that asks to merge the uploaded images into a single pdf,
+// rather than converting every one of them on its own.
+const mergeFormField = "merge"
+
+// merge is a set of images merged into a single pdf, a page per image, in the order they were sent.
+type merge struct {
+	images []packaging.File
+	opts   files.ConvertOptions
+}
+
+// wantsMerge tells whether the form asks to merge the files.
+func wantsMerge(form url.Values) (bool, error) {
+	value := form.Get(mergeFormField)
+	if value == "" {
+		return false, nil
+	}
+
+	merge, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, WithHTTPStatus(
+			fmt.Errorf("%s must be true or false, got %q", mergeFormField, value),
+			http.StatusBadRequest,
+		)
+	}
+
+	return merge, nil
+}
+
+// parseMerge reads the images to merge from the form.
+func parseMerge(r *http.Request) (merge, error) {
+	fileHeaders := r.MultipartForm.File[uploadFileFormField]
+
+	if len(fileHeaders) > batchMaxFiles {
+		return merge{}, WithHTTPStatus(
+			fmt.Errorf("too many files: %d, the limit is %d", len(fileHeaders), batchMaxFiles),
+			http.StatusBadRequest,
+		)
+	}
+
+	var imgs []packaging.File
+
+	for _, fileHeader := range fileHeaders {
+		fileBytes, err := readFormFile(fileHeader)
+		if err != nil {
+			log.Printf("error ocurred reading file: %v", err)
+			return merge{}, WithHTTPStatus(err, http.StatusBadRequest)
+		}
+
+		imgs = append(imgs, packaging.File{Name: fileHeader.Filename, Content: fileBytes})
+	}
+
+	return newMerge(imgs, r.FormValue("targetFormat"), r.Form)
+}
+
+// newMerge checks the files are images that can be merged into the target format,
+// with the options sent in the form.
+func newMerge(imgs []packaging.File, target string, form url.Values) (merge, error) {
+	if target != images.PDF {
+		return merge{}, WithHTTPStatus(
+			fmt.Errorf("images can only be merged into a pdf, not into %q", target),
+			http.StatusBadRequest,
+		)
+	}
+
+	if len(imgs) == 0 {
+		return merge{}, WithHTTPStatus(errors.New("there are no images to merge"), http.StatusBadRequest)
+	}
+
+	for _, img := range imgs {
+		detectedFileType := mimetype.Detect(img.Content)
+
+		if f, ok := files.LookupMIME(detectedFileType.String()); !ok || f.Category != files.Img {
+			return merge{}, WithHTTPStatus(
+				fmt.Errorf("%s is not an image, but a %s file", img.Name, detectedFileType.String()),
+				http.StatusBadRequest,
+			)
+		}
+	}
+
+	opts, err := images.MergeOptions.Parse(form)
+	if err != nil {
+		log.Printf("error occurred while parsing the merge options: %v", err)
+		return merge{}, WithHTTPStatus(err, http.StatusBadRequest)
+	}
+
+	return merge{images: imgs, opts: opts}, nil
+}
+
+// convert merges the images into a pdf.
+func (m merge) convert(ctx context.Context) (ConvertedFile, io.Reader, error) {
+	progress.Report(ctx, progress.Event{
+		Stage:   progress.Received,
+		Total:   len(m.images),
+		Message: fmt.Sprintf("received %d images", len(m.images)),
+	})
+
+	progress.Report(ctx, progress.Event{
+		Stage:   progress.Converting,
+		Total:   len(m.images),
+		Message: fmt.Sprintf("merging %d images into a pdf", len(m.images)),
+	})
+
+	result, err := images.MergePDF(ctx, m.images, m.opts)
+	if err != nil {
+		log.Printf("error occurred while merging the images: %v", err)
+		if errors.Is(err, context.DeadlineExceeded) {
+			return ConvertedFile{}, nil, WithHTTPStatus(err, http.StatusGatewayTimeout)
+		}
+		return ConvertedFile{}, nil, WithHTTPStatus(err, http.StatusInternalServerError)
+	}
+
+	convertedFile := ConvertedFile{
+		Filename: fmt.Sprintf("morphos-%s.%s", time.Now().Format("20060102-150405"), images.PDF),
+		FileType: "application",
+		MIMEType: "application/pdf",
+	}
+
+	return convertedFile, bytes.NewReader(result), nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/gen2brain/go-fitz"
+	"github.com/stretchr/testify/require"
+
+	"github.com/danvergara/morphos/pkg/packaging"
+)
+
+func TestWantsMerge(t *testing.T) {
+	var tests = []struct {
+		name     string
+		value    string
+		expected bool
+		hasErr   bool
+	}{
+		{name: "not sent", value: "", expected: false},
+		{name: "merge", value: "true", expected: true},
+		{name: "don't merge", value: "false", expected: false},
+		{name: "invalid value", value: "yes please", hasErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			merge, err := wantsMerge(url.Values{mergeFormField: {tc.value}})
+			if tc.hasErr {
+				require.Error(t, err)
+				require.Equal(t, http.StatusBadRequest, HTTPStatus(err))
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, merge)
+		})
+	}
+}
+
+func TestParseRequestMerge(t *testing.T) {
+	img := pngImage(t, 4, 3)
+
+	// A single image is merged as well, rather than converted.
+	for _, files := range [][]formFile{{{"a.png", img}}, {{"a.png", img}, {"b.png", img}}} {
+		r := newFormRequest(
+			t,
+			"/upload",
+			map[string][]string{"targetFormat": {"pdf"}, mergeFormField: {"true"}},
+			files...,
+		)
+
+		c, err := parseRequest(r)
+		require.NoError(t, err)
+		require.IsType(t, merge{}, c)
+		require.Len(t, c.(merge).images, len(files))
+	}
+}
+
+func TestNewMerge(t *testing.T) {
+	img := pngImage(t, 4, 3)
+
+	var tests = []struct {
+		name   string
+		imgs   []packaging.File
+		target string
+		form   url.Values
+		status int
+	}{
+		{
+			name:   "images",
+			imgs:   []packaging.File{{Name: "a.png", Content: img}, {Name: "b.png", Content: img}},
+			target: "pdf",
+			form:   url.Values{"page_size": {"a4"}},
+		},
+		{
+			name:   "target is not a pdf",
+			imgs:   []packaging.File{{Name: "a.png", Content: img}},
+			target: "docx",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "no images",
+			target: "pdf",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "file that is not an image",
+			imgs:   []packaging.File{{Name: "a.png", Content: img}, {Name: "notes.txt", Content: []byte("not an image")}},
+			target: "pdf",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "invalid option",
+			imgs:   []packaging.File{{Name: "a.png", Content: img}},
+			target: "pdf",
+			form:   url.Values{"margin": {"500"}},
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := newMerge(tc.imgs, tc.target, tc.form)
+			if tc.status != 0 {
+				require.Error(t, err)
+				require.Equal(t, tc.status, HTTPStatus(err))
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.imgs, m.images)
+			require.Equal(t, "a4", m.opts.String("page_size", ""))
+		})
+	}
+}
+
+func TestMergeConvert(t *testing.T) {
+	imgs := []packaging.File{
+		{Name: "wide.png", Content: pngImage(t, 40, 30)},
+		{Name: "tall.png", Content: pngImage(t, 30, 40)},
+	}
+
+	m, err := newMerge(imgs, "pdf", url.Values{"margin": {"10"}})
+	require.NoError(t, err)
+
+	convertedFile, output, err := m.convert(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "application/pdf", convertedFile.MIMEType)
+	require.Regexp(t, `^morphos-\d{8}-\d{6}\.pdf$`, convertedFile.Filename)
+
+	result, err := io.ReadAll(output)
+	require.NoError(t, err)
+
+	doc, err := fitz.NewFromMemory(result)
+	require.NoError(t, err)
+	defer doc.Close()
+
+	require.Equal(t, len(imgs), doc.NumPage())
+
+	// A page per image, in the order they were sent, as large as the image and its margins.
+	for i, expected := range [][2]int{{60, 50}, {50, 60}} {
+		bounds, err := doc.Bound(i)
+		require.NoError(t, err)
+		require.Equal(t, expected[0], bounds.Dx())
+		require.Equal(t, expected[1], bounds.Dy())
+	}
+}
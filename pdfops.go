@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+
+	"github.com/danvergara/morphos/pkg/files"
+	"github.com/danvergara/morphos/pkg/packaging"
+	"github.com/danvergara/morphos/pkg/pdfops"
+	"github.com/danvergara/morphos/pkg/progress"
+)
+
+// pdfOperation is an operation on the pages of pdf files, e.g. merging them or rotating their pages.
+type pdfOperation struct {
+	pdfs []packaging.File
+	opts files.ConvertOptions
+	// format and level set how the files are packaged when splitting.
+	format packaging.Format
+	level  int
+}
+
+// parsePDFOperation reads the pdf files and the operation to apply to them from the form.
+func parsePDFOperation(r *http.Request) (pdfOperation, error) {
+	if err := r.ParseMultipartForm(maxMemory); err != nil {
+		log.Printf("error ocurred parsing the form: %v", err)
+		return pdfOperation{}, WithHTTPStatus(err, http.StatusBadRequest)
+	}
+
+	fileHeaders := r.MultipartForm.File[uploadFileFormField]
+
+	if len(fileHeaders) > batchMaxFiles {
+		return pdfOperation{}, WithHTTPStatus(
+			fmt.Errorf("too many files: %d, the limit is %d", len(fileHeaders), batchMaxFiles),
+			http.StatusBadRequest,
+		)
+	}
+
+	var pdfs []packaging.File
+
+	for _, fileHeader := range fileHeaders {
+		fileBytes, err := readFormFile(fileHeader)
+		if err != nil {
+			log.Printf("error ocurred reading file: %v", err)
+			return pdfOperation{}, WithHTTPStatus(err, http.StatusBadRequest)
+		}
+
+		pdfs = append(pdfs, packaging.File{Name: fileHeader.Filename, Content: fileBytes})
+	}
+
+	return newPDFOperation(pdfs, r.Form)
+}
+
+// newPDFOperation checks the files are pdf files, and reads the operation from the form.
+func newPDFOperation(pdfs []packaging.File, form url.Values) (pdfOperation, error) {
+	if len(pdfs) == 0 {
+		return pdfOperation{}, WithHTTPStatus(errors.New("there are no pdf files"), http.StatusBadRequest)
+	}
+
+	for _, pdf := range pdfs {
+		if detected := mimetype.Detect(pdf.Content); !detected.Is("application/pdf") {
+			return pdfOperation{}, WithHTTPStatus(
+				fmt.Errorf("%s is not a pdf, but a %s file", pdf.Name, detected.String()),
+				http.StatusBadRequest,
+			)
+		}
+	}
+
+	opts, err := pdfops.Options.Merge(files.ArchiveOptions).Parse(form)
+	if err != nil {
+		log.Printf("error occurred while parsing the pdf options: %v", err)
+		return pdfOperation{}, WithHTTPStatus(err, http.StatusBadRequest)
+	}
+
+	return pdfOperation{
+		pdfs:   pdfs,
+		opts:   opts,
+		format: packaging.Format(opts.String(files.ArchiveFormatOption, string(packaging.Zip))),
+		level:  opts.Int(files.CompressionOption, packaging.DefaultCompression),
+	}, nil
+}
+
+// convert applies the operation to the pdf files.
+// A pdf is returned, but when splitting, whose files are returned in an archive.
+func (o pdfOperation) convert(ctx context.Context) (ConvertedFile, io.Reader, error) {
+	operation := o.opts.String(pdfops.OperationOption, pdfops.OperationMerge)
+
+	progress.Report(ctx, progress.Event{
+		Stage:   progress.Received,
+		Total:   len(o.pdfs),
+		Message: fmt.Sprintf("received %d pdf files", len(o.pdfs)),
+	})
+
+	progress.Report(ctx, progress.Event{
+		Stage:   progress.Converting,
+		Message: fmt.Sprintf("applying %s", operation),
+	})
+
+	result, err := pdfops.Run(ctx, o.pdfs, o.opts)
+	if err != nil {
+		log.Printf("error occurred while applying %s to the pdf files: %v", operation, err)
+		if errors.Is(err, context.DeadlineExceeded) {
+			return ConvertedFile{}, nil, WithHTTPStatus(err, http.StatusGatewayTimeout)
+		}
+		// The files are only read and copied, so errors come from the files or the options.
+		return ConvertedFile{}, nil, WithHTTPStatus(err, http.StatusBadRequest)
+	}
+
+	switch operation {
+	case pdfops.OperationSplit:
+		convertedFile := ConvertedFile{
+			Filename: filename(o.pdfs[0].Name, o.format.Extension()),
+			FileType: archiveFileType,
+			MIMEType: o.format.MIMEType(),
+		}
+
+		return convertedFile, &packaging.Archive{Format: o.format, Level: o.level, Files: result}, nil
+	case pdfops.OperationMerge:
+		// Merged files are named after the time, like batches, rather than after the first file.
+		result[0].Name = fmt.Sprintf("morphos-%s.pdf", time.Now().Format("20060102-150405"))
+	}
+
+	convertedFile := ConvertedFile{
+		Filename: result[0].Name,
+		FileType: "application",
+		MIMEType: "application/pdf",
+	}
+
+	return convertedFile, bytes.NewReader(result[0].Content), nil
+}
+
+// pdfFile applies an operation to the pdf files sent and responds with the result.
+func pdfFile(w http.ResponseWriter, r *http.Request) error {
+	o, err := parsePDFOperation(r)
+	if err != nil {
+		return err
+	}
+
+	return respondConverted(w, r, o)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gen2brain/go-fitz"
+	"github.com/signintech/gopdf"
+	"github.com/stretchr/testify/require"
+)
+
+// pdfDocument returns a pdf with a page per width, 500 points tall, so pages can be told apart by their width.
+func pdfDocument(t *testing.T, widths ...float64) []byte {
+	t.Helper()
+
+	pdf := gopdf.GoPdf{}
+	pdf.Start(gopdf.Config{PageSize: gopdf.Rect{W: widths[0], H: 500}})
+
+	for _, w := range widths {
+		pdf.AddPageWithOption(gopdf.PageOption{PageSize: &gopdf.Rect{W: w, H: 500}})
+		pdf.SetFillColor(0, 0, 0)
+		pdf.RectFromUpperLeftWithStyle(10, 10, w/2, 100, "F")
+	}
+
+	buf := new(bytes.Buffer)
+	_, err := pdf.WriteTo(buf)
+	require.NoError(t, err)
+
+	return buf.Bytes()
+}
+
+// pageWidths returns the width of every page of the pdf.
+func pageWidths(t *testing.T, pdf []byte) []int {
+	t.Helper()
+
+	doc, err := fitz.NewFromMemory(pdf)
+	require.NoError(t, err)
+	defer doc.Close()
+
+	var widths []int
+	for i := 0; i < doc.NumPage(); i++ {
+		bounds, err := doc.Bound(i)
+		require.NoError(t, err)
+		widths = append(widths, bounds.Dx())
+	}
+
+	return widths
+}
+
+func TestPDFFile(t *testing.T) {
+	var tests = []struct {
+		name     string
+		fields   map[string][]string
+		files    []formFile
+		maxFiles int
+		status   int
+		mimeType string
+		// filename is a pattern, as merged files are named after the time.
+		filename string
+		// widths are the widths of the pages of the resulting pdf, or of every pdf of the archive.
+		widths map[string][]int
+	}{
+		{
+			name:     "merge",
+			files:    []formFile{{"a.pdf", pdfDocument(t, 100)}, {"b.pdf", pdfDocument(t, 200, 300)}},
+			status:   http.StatusOK,
+			mimeType: "application/pdf",
+			filename: `^morphos-\d{8}-\d{6}\.pdf$`,
+			widths:   map[string][]int{"": {100, 200, 300}},
+		},
+		{
+			name:     "rotate",
+			fields:   map[string][]string{"operation": {"rotate"}, "pages": {"2"}},
+			files:    []formFile{{"report.pdf", pdfDocument(t, 100, 200)}},
+			status:   http.StatusOK,
+			mimeType: "application/pdf",
+			filename: `^report-rotate\.pdf$`,
+			widths:   map[string][]int{"": {100, 500}},
+		},
+		{
+			name:     "split",
+			fields:   map[string][]string{"operation": {"split"}, "every": {"2"}},
+			files:    []formFile{{"report.pdf", pdfDocument(t, 100, 200, 300)}},
+			status:   http.StatusOK,
+			mimeType: "application/zip",
+			filename: `^report\.zip$`,
+			widths:   map[string][]int{"report_1-2.pdf": {100, 200}, "report_3.pdf": {300}},
+		},
+		{
+			name:   "no files",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "file that is not a pdf",
+			files:  []formFile{{"a.pdf", pdfDocument(t, 100)}, {"photo.png", pngImage(t, 4, 3)}},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown operation",
+			fields: map[string][]string{"operation": {"shuffle"}},
+			files:  []formFile{{"a.pdf", pdfDocument(t, 100)}},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "several files to rotate",
+			fields: map[string][]string{"operation": {"rotate"}},
+			files:  []formFile{{"a.pdf", pdfDocument(t, 100)}, {"b.pdf", pdfDocument(t, 200)}},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "pages out of the file",
+			fields: map[string][]string{"operation": {"extract"}, "pages": {"5"}},
+			files:  []formFile{{"a.pdf", pdfDocument(t, 100)}},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:     "too many files",
+			files:    []formFile{{"a.pdf", pdfDocument(t, 100)}, {"b.pdf", pdfDocument(t, 200)}},
+			maxFiles: 1,
+			status:   http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.maxFiles > 0 {
+				previous := batchMaxFiles
+				batchMaxFiles = tc.maxFiles
+				t.Cleanup(func() { batchMaxFiles = previous })
+			}
+
+			w := httptest.NewRecorder()
+			apiRouter().ServeHTTP(w, newFormRequest(t, "/pdf", tc.fields, tc.files...))
+			require.Equal(t, tc.status, w.Code, w.Body.String())
+
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			require.Equal(t, tc.mimeType, w.Header().Get("Content-Type"))
+
+			_, params, err := mime.ParseMediaType(w.Header().Get("Content-Disposition"))
+			require.NoError(t, err)
+			require.Regexp(t, tc.filename, params["filename"])
+
+			if tc.mimeType == "application/pdf" {
+				require.Equal(t, tc.widths[""], pageWidths(t, w.Body.Bytes()))
+				return
+			}
+
+			zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+			require.NoError(t, err)
+			require.Len(t, zr.File, len(tc.widths))
+
+			for _, f := range zr.File {
+				expected, ok := tc.widths[f.Name]
+				require.True(t, ok, f.Name)
+
+				rc, err := f.Open()
+				require.NoError(t, err)
+
+				content := new(bytes.Buffer)
+				_, err = content.ReadFrom(rc)
+				require.NoError(t, err)
+				require.NoError(t, rc.Close())
+
+				require.Equal(t, expected, pageWidths(t, content.Bytes()), f.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package files
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/gabriel-vasile/mimetype"
+
+	"github.com/danvergara/morphos/pkg/packaging"
+)
+
+const (
+	// ArchiveOption sets when the output of a conversion is wrapped in an archive.
+	ArchiveOption = "archive"
+	// ArchiveFormatOption sets the format of the archive. e.g. tar.gz
+	ArchiveFormatOption = "archive_format"
+	// CompressionOption sets the compression level of the archive, from 0 to 9.
+	CompressionOption = "compression"
+
+	// ArchiveAuto wraps the output in an archive only if there are several files.
+	ArchiveAuto = "auto"
+	// ArchiveAlways wraps the output in an archive, even if there is a single file.
+	ArchiveAlways = "always"
+	// ArchiveNever never wraps the output in an archive,
+	// the conversion fails if it produces several files.
+	ArchiveNever = "never"
+)
+
+// ArchiveOptions are the options accepted by every conversion.
+var ArchiveOptions = Schema{
+	{
+		Name:    ArchiveOption,
+		Label:   "Archive",
+		Help:    "Wrap the result in an archive: only if there are several files (auto), always or never",
+		Type:    ChoiceOption,
+		Default: ArchiveAuto,
+		Choices: []string{ArchiveAuto, ArchiveAlways, ArchiveNever},
+	},
+	{
+		Name:    ArchiveFormatOption,
+		Label:   "Archive format",
+		Type:    ChoiceOption,
+		Default: string(packaging.Zip),
+		Choices: archiveFormats(),
+	},
+	{
+		Name:  CompressionOption,
+		Label: "Compression level",
+		Help:  "From 0 (none) to 9 (best). The default of the archive format if empty",
+		Type:  IntOption,
+		Min:   packaging.NoCompression,
+		Max:   packaging.BestCompression,
+	},
+}
+
+// archiveFormats returns the names of the supported archive formats.
+func archiveFormats() []string {
+	var result []string
+	for _, f := range packaging.Formats() {
+		result = append(result, f.Extension())
+	}
+
+	return result
+}
+
+// Archive applies the archive options to the output of a conversion.
+// Some conversions wrap their output in a zip file, even when it's a single file,
+// which is unwrapped unless the archive option is ArchiveAlways. A single file is
+// wrapped in an archive, under the given filename, only if it's ArchiveAlways.
+// Archives are returned as a *packaging.Archive, of the format set by the options,
+// so they can be copied straight into their destination.
+// It errors out if the archive option is ArchiveNever and there are several files.
+func Archive(opts ConvertOptions, filename string, file io.Reader) (io.Reader, error) {
+	policy := opts.String(ArchiveOption, ArchiveAuto)
+
+	format, err := packaging.ParseFormat(opts.String(ArchiveFormatOption, string(packaging.Zip)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOption, err)
+	}
+
+	fileBytes, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []packaging.File
+
+	if mimetype.Detect(fileBytes).Is("application/zip") {
+		files, err = unzip(fileBytes)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		files = []packaging.File{{Name: filename, Content: fileBytes}}
+	}
+
+	switch {
+	case policy == ArchiveAlways || (len(files) > 1 && policy == ArchiveAuto):
+		return &packaging.Archive{
+			Format: format,
+			Level:  opts.Int(CompressionOption, packaging.DefaultCompression),
+			Files:  files,
+		}, nil
+	case len(files) == 1:
+		return bytes.NewReader(files[0].Content), nil
+	default:
+		return nil, fmt.Errorf(
+			"%w: the conversion produced %d files, they can't be returned without an archive",
+			ErrInvalidOption,
+			len(files),
+		)
+	}
+}
+
+// unzip returns the files compressed in a zip file.
+func unzip(fileBytes []byte) ([]packaging.File, error) {
+	zipReader, err := zip.NewReader(bytes.NewReader(fileBytes), int64(len(fileBytes)))
+	if err != nil {
+		return nil, fmt.Errorf("error opening the zip file: %w", err)
+	}
+
+	var files []packaging.File
+
+	for _, f := range zipReader.File {
+		content, err := readZipFile(f)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, packaging.File{Name: f.Name, Content: content})
+	}
+
+	return files, nil
+}
+
+// readZipFile returns the content of a file compressed in a zip file.
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return io.ReadAll(rc)
+}
@@ -0,0 +1,134 @@
+package files_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/danvergara/morphos/pkg/files"
+	"github.com/danvergara/morphos/pkg/packaging"
+)
+
+// zipOf returns a zip file with the given files, as a slice of bytes.
+func zipOf(t *testing.T, names ...string) []byte {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(name))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	return buf.Bytes()
+}
+
+// zipNames returns the names of the files in a zip file,
+// or nil if it's not a zip file.
+func zipNames(t *testing.T, b []byte) []string {
+	t.Helper()
+
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+
+	return names
+}
+
+// archiveOpts returns the archive options of a conversion, parsed from the given values.
+func archiveOpts(t *testing.T, values url.Values) files.ConvertOptions {
+	t.Helper()
+
+	schema, err := files.ConversionOptions("png", "gif")
+	require.NoError(t, err)
+
+	opts, err := schema.Parse(values)
+	require.NoError(t, err)
+
+	return opts
+}
+
+func TestArchive(t *testing.T) {
+	var tests = []struct {
+		name     string
+		policy   string
+		format   string
+		input    []byte
+		expected []byte
+		zipped   []string
+		hasErr   bool
+	}{
+		{name: "auto single file", policy: files.ArchiveAuto, input: []byte("content"), expected: []byte("content")},
+		{name: "auto single zipped file", policy: files.ArchiveAuto, input: zipOf(t, "foo.pdf"), expected: []byte("foo.pdf")},
+		{name: "auto several files", policy: files.ArchiveAuto, input: zipOf(t, "foo-1.png", "foo-2.png"), zipped: []string{"foo-1.png", "foo-2.png"}},
+		{name: "always single file", policy: files.ArchiveAlways, input: []byte("content"), zipped: []string{"foo.txt"}},
+		{name: "always single zipped file", policy: files.ArchiveAlways, input: zipOf(t, "foo.pdf"), zipped: []string{"foo.pdf"}},
+		{name: "never single zipped file", policy: files.ArchiveNever, input: zipOf(t, "foo.pdf"), expected: []byte("foo.pdf")},
+		{name: "never several files", policy: files.ArchiveNever, input: zipOf(t, "foo-1.png", "foo-2.png"), hasErr: true},
+		{name: "tar single file", policy: files.ArchiveAlways, format: "tar", input: []byte("content")},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			values := url.Values{files.ArchiveOption: {tc.policy}}
+			if tc.format != "" {
+				values.Set(files.ArchiveFormatOption, tc.format)
+			}
+
+			result, err := files.Archive(archiveOpts(t, values), "foo.txt", bytes.NewReader(tc.input))
+			if tc.hasErr {
+				require.ErrorIs(t, err, files.ErrInvalidOption)
+				return
+			}
+			require.NoError(t, err)
+
+			if tc.format != "" {
+				a, ok := result.(*packaging.Archive)
+				require.True(t, ok)
+				require.Equal(t, packaging.Format(tc.format), a.Format)
+				require.Equal(t, []packaging.File{{Name: "foo.txt", Content: tc.input}}, a.Files)
+				return
+			}
+
+			b, err := io.ReadAll(result)
+			require.NoError(t, err)
+
+			if tc.zipped != nil {
+				require.Equal(t, tc.zipped, zipNames(t, b))
+				return
+			}
+
+			require.Equal(t, tc.expected, b)
+		})
+	}
+}
+
+func TestArchiveCompression(t *testing.T) {
+	opts := archiveOpts(t, url.Values{
+		files.ArchiveFormatOption: {"tar.zst"},
+		files.CompressionOption:   {"9"},
+	})
+
+	result, err := files.Archive(opts, "foo.txt", bytes.NewReader(zipOf(t, "foo-1.png", "foo-2.png")))
+	require.NoError(t, err)
+
+	a, ok := result.(*packaging.Archive)
+	require.True(t, ok)
+	require.Equal(t, packaging.TarZstd, a.Format)
+	require.Equal(t, 9, a.Level)
+	require.Len(t, a.Files, 2)
+}
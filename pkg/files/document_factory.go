@@ -2,9 +2,7 @@ package files
 
 import (
 	"fmt"
-
-	"github.com/danvergara/morphos/pkg/files/documents"
-	"github.com/danvergara/morphos/pkg/files/ebooks"
+	"slices"
 )
 
 // DocumentFactory implements the FileFactory interface.
@@ -18,22 +16,14 @@ func NewDocumentFactory(filename string) *DocumentFactory {
 
 // NewFile method returns an object that implements the File interface,
 // given a document format as input.
+// Ebooks are handled by this factory as well, since they share the
+// application MIME type with documents.
 // If not supported, it will error out.
 func (d *DocumentFactory) NewFile(f string) (File, error) {
-	switch f {
-	case documents.PDF:
-		return documents.NewPdf(d.filename), nil
-	case documents.DOCX, documents.DOCXMIMEType:
-		return documents.NewDocx(d.filename), nil
-	case documents.XLSX, documents.XLSXMIMEType:
-		return documents.NewXlsx(d.filename), nil
-	case documents.CSV:
-		return documents.NewCsv(d.filename), nil
-	case ebooks.EpubMimeType, ebooks.EPUB:
-		return ebooks.NewEpub(d.filename), nil
-	case ebooks.MobiMimeType, ebooks.MOBI:
-		return ebooks.NewMobi(d.filename), nil
-	default:
+	format, ok := Lookup(f)
+	if !ok || !slices.Contains([]string{Doc, Ebook}, format.Category) {
 		return nil, fmt.Errorf("type file  %s not recognized", f)
 	}
+
+	return format.Decoder(d.filename), nil
 }
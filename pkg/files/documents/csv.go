@@ -1,18 +1,19 @@
 package documents
 
 import (
-	"archive/zip"
 	"bytes"
+	"context"
 	"encoding/csv"
 	"errors"
 	"fmt"
 	"io"
-	"os"
 	"path/filepath"
 	"slices"
 	"strings"
 
 	"github.com/tealeg/xlsx/v3"
+
+	"github.com/danvergara/morphos/pkg/files"
 )
 
 // Csv struct implements the File and Document interface from the file package.
@@ -22,6 +23,17 @@ type Csv struct {
 	compatibleMIMETypes map[string][]string
 }
 
+func init() {
+	files.Register(files.Format{
+		Name:          CSV,
+		Category:      files.Doc,
+		MIMETypes:     []string{tesxtMimeType + CSV},
+		Decoder:       func(filename string) files.File { return NewCsv(filename) },
+		InputOptions:  csvOptions,
+		OutputOptions: csvOptions,
+	})
+}
+
 // NewCsv returns a pointer to Csv.
 func NewCsv(filename string) *Csv {
 	c := Csv{
@@ -53,7 +65,7 @@ func (c *Csv) SupportedMIMETypes() map[string][]string {
 	return c.compatibleMIMETypes
 }
 
-func (c *Csv) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, error) {
+func (c *Csv) ConvertTo(ctx context.Context, fileType, subType string, file io.Reader, opts files.ConvertOptions) (io.Reader, error) {
 	compatibleFormats, ok := c.SupportedFormats()[fileType]
 	if !ok {
 		return nil, fmt.Errorf("file type not supported: %s", fileType)
@@ -72,15 +84,8 @@ func (c *Csv) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, er
 				strings.TrimSuffix(c.filename, filepath.Ext(c.filename)),
 			)
 
-			xlsxPath := filepath.Join("/tmp", xlsxFilename)
-
-			// Parses the file name of the Zip file.
-			zipFileName := filepath.Join("/tmp", fmt.Sprintf(
-				"%s.zip",
-				strings.TrimSuffix(c.filename, filepath.Ext(c.filename)),
-			))
-
 			reader := csv.NewReader(file)
+			reader.Comma = delimiter(opts)
 			xlsxFile := xlsx.NewFile()
 			sheet, err := xlsxFile.AddSheet(
 				strings.TrimSuffix(c.filename, filepath.Ext(c.filename)),
@@ -102,58 +107,17 @@ func (c *Csv) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, er
 				}
 			}
 
-			if err := xlsxFile.Save(xlsxPath); err != nil {
-				return nil, fmt.Errorf(
-					"error at saving the temporary csv file: %w",
-					err,
-				)
-			}
-
-			tmpCsvFile, err := os.Open(xlsxPath)
-			if err != nil {
+			xlsxBytes := new(bytes.Buffer)
+			if err := xlsxFile.Write(xlsxBytes); err != nil {
 				return nil, fmt.Errorf(
-					"error at opening the pdf file: %w",
+					"error at writing the xlsx file: %w",
 					err,
 				)
 			}
-			defer os.Remove(tmpCsvFile.Name())
-
-			// Creates the zip file that will be returned.
-			archive, err := os.Create(zipFileName)
-			if err != nil {
-				return nil, fmt.Errorf(
-					"error at creating the zip file to store the pdf file: %w",
-					err,
-				)
-			}
-			defer os.Remove(archive.Name())
-
-			// Creates a Zip Writer to add files later on.
-			zipWriter := zip.NewWriter(archive)
-
-			w1, err := zipWriter.Create(xlsxFilename)
-			if err != nil {
-				return nil, fmt.Errorf(
-					"eror at creating a zip file: %w",
-					err,
-				)
-			}
-
-			if _, err := io.Copy(w1, tmpCsvFile); err != nil {
-				return nil, fmt.Errorf(
-					"error at writing the pdf file content to the zip writer: %w",
-					err,
-				)
-			}
-
-			// Closes both zip writer and the zip file after its done with the writing.
-			zipWriter.Close()
-			archive.Close()
 
-			// Reads the zip file as an slice of bytes.
-			zipFile, err := os.ReadFile(zipFileName)
+			zipFile, err := zipSingleFile(xlsxFilename, xlsxBytes.Bytes())
 			if err != nil {
-				return nil, fmt.Errorf("error reading zip file: %v", err)
+				return nil, err
 			}
 
 			return bytes.NewReader(zipFile), nil
@@ -14,6 +14,16 @@ const (
 	MOBI         = "mobi"
 	MobiMimeType = "x-mobipocket-ebook"
 
+	TXT         = "txt"
+	TXTMIMEType = "plain"
+	MD          = "md"
+	MDMIMEType  = "markdown"
+	HTML        = "html"
+	// HOCR is the text recognized in the pages of a file, alongside where every word is.
+	HOCR = "hocr"
+	// OCRPDF is a searchable pdf, made of the pages of a file with the text recognized over them.
+	OCRPDF = "ocr.pdf"
+
 	imageMimeType = "image/"
 	imageType     = "image"
 
@@ -1,20 +1,27 @@
 package documents_test
 
 import (
+	"archive/zip"
 	"bytes"
+	"context"
+	"image"
+	"image/png"
 	"io"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/gabriel-vasile/mimetype"
 	"github.com/stretchr/testify/require"
 
+	"github.com/danvergara/morphos/pkg/files"
 	"github.com/danvergara/morphos/pkg/files/documents"
 )
 
 type filer interface {
 	SupportedFormats() map[string][]string
-	ConvertTo(string, string, io.Reader) (io.Reader, error)
+	ConvertTo(context.Context, string, string, io.Reader, files.ConvertOptions) (io.Reader, error)
 }
 
 type documenter interface {
@@ -143,9 +150,11 @@ func TestPDFTConvertTo(t *testing.T) {
 			require.Equal(t, tc.input.mimetype, detectedFileType.String())
 
 			outoutFile, err := tc.input.documenter.ConvertTo(
+				context.Background(),
 				tc.input.targetFileType,
 				tc.input.targetFormat,
 				bytes.NewReader(inputDoc),
+				files.ConvertOptions{},
 			)
 
 			require.NoError(t, err)
@@ -161,6 +170,149 @@ func TestPDFTConvertTo(t *testing.T) {
 	}
 }
 
+func TestPDFToImages(t *testing.T) {
+	var tests = []struct {
+		name   string
+		values url.Values
+		// expected are the names of the images, alongside their size.
+		expected map[string]image.Point
+	}{
+		{
+			name:   "resolution",
+			values: url.Values{"dpi": {"72"}, "pages": {"1"}},
+			expected: map[string]image.Point{
+				"bitcoin_0.png": image.Pt(612, 792),
+			},
+		},
+		{
+			name:   "width",
+			values: url.Values{"width": {"800"}, "dpi": {"600"}, "pages": {"2,4-5"}},
+			expected: map[string]image.Point{
+				"bitcoin_1.png": image.Pt(800, 1036),
+				"bitcoin_3.png": image.Pt(800, 1036),
+				"bitcoin_4.png": image.Pt(800, 1036),
+			},
+		},
+	}
+
+	schema, err := files.ConversionOptions(documents.PDF, "png")
+	require.NoError(t, err)
+
+	inputDoc, err := os.ReadFile("testdata/bitcoin.pdf")
+	require.NoError(t, err)
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			opts, err := schema.Parse(tc.values)
+			require.NoError(t, err)
+
+			result, err := documents.NewPdf("bitcoin.pdf").ConvertTo(context.Background(), "Image", "png", bytes.NewReader(inputDoc), opts)
+			require.NoError(t, err)
+
+			resultBytes, err := io.ReadAll(result)
+			require.NoError(t, err)
+
+			zipReader, err := zip.NewReader(bytes.NewReader(resultBytes), int64(len(resultBytes)))
+			require.NoError(t, err)
+
+			sizes := make(map[string]image.Point)
+			for _, f := range zipReader.File {
+				rc, err := f.Open()
+				require.NoError(t, err)
+
+				config, err := png.DecodeConfig(rc)
+				require.NoError(t, err)
+				require.NoError(t, rc.Close())
+
+				sizes[f.Name] = image.Pt(config.Width, config.Height)
+			}
+
+			require.Equal(t, tc.expected, sizes)
+		})
+	}
+}
+
+func TestPDFToText(t *testing.T) {
+	var tests = []struct {
+		name   string
+		target string
+		values url.Values
+		// expected are pieces of the text extracted, in order.
+		expected []string
+	}{
+		{
+			name:   "txt",
+			target: "txt",
+			values: url.Values{"pages": {"1-2"}},
+			expected: []string{
+				"Bitcoin: A Peer-to-Peer Electronic Cash System\n\n",
+				"\n\nAbstract. A purely peer-to-peer version of electronic cash would allow online payments to be sent directly",
+				"\n\n2. Transactions\n\nWe define an electronic coin as a chain of digital signatures.",
+			},
+		},
+		{
+			name:   "txt with page breaks",
+			target: "txt",
+			values: url.Values{"pages": {"1-2"}, "page_breaks": {"true"}},
+			expected: []string{
+				"group of attacker nodes.\n\n1\n\n\f\n\n2. Transactions",
+			},
+		},
+		{
+			name:   "md",
+			target: "md",
+			values: url.Values{"pages": {"1-2,7"}, "page_breaks": {"true"}},
+			expected: []string{
+				"# Bitcoin: A Peer-to-Peer Electronic Cash System\n\n",
+				"\n\n**Abstract.** A purely peer-to-peer version",
+				"\n\n## 1. Introduction\n\n",
+				"\n\n---\n\n## 2. Transactions\n\n",
+				"\n\n```\n#include <math.h>\ndouble AttackerSuccessProbability(double q, int z)\n{\n    double p = 1.0 - q;\n",
+			},
+		},
+		{
+			name:   "html",
+			target: "html",
+			values: url.Values{"pages": {"1"}},
+			expected: []string{
+				"<title>bitcoin</title>",
+				"<body>\n<h1>Bitcoin: A Peer-to-Peer Electronic Cash System</h1>\n",
+				"<p><b>Abstract.</b> A purely peer-to-peer version",
+				"they&#39;ll generate the longest chain",
+				"<h2>1. Introduction</h2>",
+			},
+		},
+	}
+
+	inputDoc, err := os.ReadFile("testdata/bitcoin.pdf")
+	require.NoError(t, err)
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			schema, err := files.ConversionOptions(documents.PDF, tc.target)
+			require.NoError(t, err)
+
+			opts, err := schema.Parse(tc.values)
+			require.NoError(t, err)
+
+			result, err := documents.NewPdf("bitcoin.pdf").ConvertTo(context.Background(), "Document", tc.target, bytes.NewReader(inputDoc), opts)
+			require.NoError(t, err)
+
+			resultBytes, err := io.ReadAll(result)
+			require.NoError(t, err)
+
+			text := string(resultBytes)
+			for _, piece := range tc.expected {
+				i := strings.Index(text, piece)
+				require.GreaterOrEqual(t, i, 0, "%q not found", piece)
+				text = text[i+len(piece):]
+			}
+		})
+	}
+}
+
 func TestDOCXTConvertTo(t *testing.T) {
 	type input struct {
 		filename       string
@@ -204,9 +356,11 @@ func TestDOCXTConvertTo(t *testing.T) {
 			require.Equal(t, tc.input.mimetype, detectedFileType.String())
 
 			resultFile, err := tc.input.documenter.ConvertTo(
+				context.Background(),
 				tc.input.targetFileType,
 				tc.input.targetFormat,
 				bytes.NewReader(inputDoc),
+				files.ConvertOptions{},
 			)
 
 			require.NoError(t, err)
@@ -262,9 +416,11 @@ func TestCSVTConvertTo(t *testing.T) {
 			require.Equal(t, tc.input.mimetype, detectedFileType.String())
 
 			resultFile, err := tc.input.documenter.ConvertTo(
+				context.Background(),
 				tc.input.targetFileType,
 				tc.input.targetFormat,
 				bytes.NewReader(inputDoc),
+				files.ConvertOptions{},
 			)
 
 			require.NoError(t, err)
@@ -320,9 +476,11 @@ func TestXLSXTConvertTo(t *testing.T) {
 			require.Equal(t, tc.input.mimetype, detectedFileType.String())
 
 			resultFile, err := tc.input.documenter.ConvertTo(
+				context.Background(),
 				tc.input.targetFileType,
 				tc.input.targetFormat,
 				bytes.NewReader(inputDoc),
+				files.ConvertOptions{},
 			)
 
 			require.NoError(t, err)
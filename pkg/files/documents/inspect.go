@@ -0,0 +1,99 @@
+package documents
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gen2brain/go-fitz"
+	"github.com/tealeg/xlsx/v3"
+)
+
+// PdfInfo describes a pdf file.
+type PdfInfo struct {
+	Pages int `json:"pages"`
+	// PageSizes are the sizes of the pages, in points.
+	PageSizes []PageSize `json:"pageSizes"`
+	Title     string     `json:"title,omitempty"`
+	Author    string     `json:"author,omitempty"`
+	Producer  string     `json:"producer,omitempty"`
+}
+
+// PageSize is the size of a page of a pdf file, in points.
+type PageSize struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Inspect describes the pdf file.
+// This method implements the files.Inspector interface.
+func (p *Pdf) Inspect(_ context.Context, file io.Reader) (any, error) {
+	fileBytes, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := fitz.NewFromMemory(fileBytes)
+	if err != nil {
+		return nil, fmt.Errorf("error opening the pdf file: %w", err)
+	}
+	defer doc.Close()
+
+	info := PdfInfo{Pages: doc.NumPage()}
+
+	for i := 0; i < info.Pages; i++ {
+		bounds, err := doc.Bound(i)
+		if err != nil {
+			return nil, fmt.Errorf("error reading the page %d: %w", i+1, err)
+		}
+
+		info.PageSizes = append(info.PageSizes, PageSize{Width: bounds.Dx(), Height: bounds.Dy()})
+	}
+
+	// The values are padded with null bytes.
+	metadata := doc.Metadata()
+	info.Title = strings.TrimRight(metadata["title"], "\x00")
+	info.Author = strings.TrimRight(metadata["author"], "\x00")
+	info.Producer = strings.TrimRight(metadata["producer"], "\x00")
+
+	return info, nil
+}
+
+// XlsxInfo describes a xlsx file.
+type XlsxInfo struct {
+	Sheets []SheetInfo `json:"sheets"`
+}
+
+// SheetInfo describes a sheet of a xlsx file.
+type SheetInfo struct {
+	Name    string `json:"name"`
+	Rows    int    `json:"rows"`
+	Columns int    `json:"columns"`
+}
+
+// Inspect describes the xlsx file.
+// This method implements the files.Inspector interface.
+func (x *Xlsx) Inspect(_ context.Context, file io.Reader) (any, error) {
+	fileBytes, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	xlFile, err := xlsx.OpenBinary(fileBytes)
+	if err != nil {
+		return nil, fmt.Errorf("error opening the xlsx file: %w", err)
+	}
+
+	info := XlsxInfo{Sheets: []SheetInfo{}}
+
+	for _, sheet := range xlFile.Sheets {
+		info.Sheets = append(info.Sheets, SheetInfo{
+			Name:    sheet.Name,
+			Rows:    sheet.MaxRow,
+			Columns: sheet.MaxCol,
+		})
+	}
+
+	return info, nil
+}
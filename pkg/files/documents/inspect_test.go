@@ -0,0 +1,60 @@
+package documents_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/danvergara/morphos/pkg/files"
+	"github.com/danvergara/morphos/pkg/files/documents"
+)
+
+func TestInspect(t *testing.T) {
+	var tests = []struct {
+		name      string
+		filename  string
+		inspector files.Inspector
+		expected  any
+	}{
+		{
+			name:      "pdf",
+			filename:  "testdata/bitcoin.pdf",
+			inspector: documents.NewPdf("bitcoin.pdf"),
+			expected: documents.PdfInfo{
+				Pages: 9,
+				PageSizes: []documents.PageSize{
+					{612, 792}, {612, 792}, {612, 792}, {612, 792}, {612, 792},
+					{612, 792}, {612, 792}, {612, 792}, {612, 792},
+				},
+				Producer: "OpenOffice.org 2.4",
+			},
+		},
+		{
+			name:      "xlsx",
+			filename:  "testdata/movies.xlsx",
+			inspector: documents.NewXlsx("movies.xlsx"),
+			expected: documents.XlsxInfo{
+				Sheets: []documents.SheetInfo{
+					{Name: "1900s", Rows: 1339, Columns: 26},
+					{Name: "2000s", Rows: 2101, Columns: 26},
+					{Name: "2010s", Rows: 1605, Columns: 26},
+				},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := os.Open(tc.filename)
+			require.NoError(t, err)
+			defer f.Close()
+
+			info, err := tc.inspector.Inspect(context.Background(), f)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, info)
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package documents
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/danvergara/morphos/pkg/packaging"
+	"github.com/danvergara/morphos/pkg/util"
+)
+
+// libreOfficeConvert stores the input file in a temporary directory and calls
+// libreoffice to convert it, given a convert-to argument. e.g. pdf:calc_pdf_Export.
+// Any other argument is passed to libreoffice before it, e.g. an input filter.
+// It returns the content of the converted file, whose extension is the output format.
+// Every call gets a user profile of its own in the temporary directory, otherwise
+// libreoffice processes running at the same time hand their work to the first one,
+// and exit without converting the file.
+func libreOfficeConvert(ctx context.Context, filename, convertTo, outputFormat string, fileBytes []byte, args ...string) ([]byte, error) {
+	var (
+		stdout bytes.Buffer
+		stderr bytes.Buffer
+	)
+
+	tmpDir, err := os.MkdirTemp("", "morphos-libreoffice-*")
+	if err != nil {
+		return nil, fmt.Errorf("error creating temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	inputPath := filepath.Join(tmpDir, filepath.Base(filename))
+	if err := os.WriteFile(inputPath, fileBytes, 0o600); err != nil {
+		return nil, fmt.Errorf(
+			"error storing the incoming file %s: %w",
+			filename,
+			err,
+		)
+	}
+
+	profile := url.URL{Scheme: "file", Path: filepath.ToSlash(filepath.Join(tmpDir, "profile"))}
+
+	// libreoffice is killed if the context is done or it takes too long.
+	args = append([]string{"-env:UserInstallation=" + profile.String(), "--headless"}, args...)
+	args = append(args, "--convert-to", convertTo, "--outdir", tmpDir, inputPath)
+
+	if err := util.RunCommand(ctx, util.LibreOffice, &stdout, &stderr, "libreoffice", args...); err != nil {
+		return nil, fmt.Errorf(
+			"error converting %s to %s using libreoffice: %w: %s",
+			filename,
+			outputFormat,
+			err,
+			stderr.String(),
+		)
+	}
+
+	log.Println(stdout.String())
+
+	outputPath := filepath.Join(tmpDir, fmt.Sprintf(
+		"%s.%s",
+		strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)),
+		outputFormat,
+	))
+
+	result, err := os.ReadFile(outputPath)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"error reading the file converted by libreoffice: %w",
+			err,
+		)
+	}
+
+	return result, nil
+}
+
+// zipSingleFile returns a zip file, as an slice of bytes,
+// that contains a single file with the given name and content.
+func zipSingleFile(filename string, content []byte) ([]byte, error) {
+	return packaging.Pack(
+		packaging.Zip,
+		packaging.DefaultCompression,
+		packaging.File{Name: filename, Content: content},
+	)
+}
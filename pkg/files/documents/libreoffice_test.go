@@ -0,0 +1,106 @@
+//go:build unix
+
+package documents_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/danvergara/morphos/pkg/files"
+	"github.com/danvergara/morphos/pkg/files/documents"
+)
+
+// fakeLibreOffice is a libreoffice that writes the input file and the user profile
+// it was given to the output file, slowly enough for the conversions to overlap.
+const fakeLibreOffice = `#!/bin/sh
+for arg; do
+	case "$prev" in --convert-to) ext="${arg%%:*}" ;; --outdir) outdir="$arg" ;; esac
+	case "$arg" in -env:UserInstallation=*) profile="${arg#*=}" ;; esac
+	prev="$arg"
+	input="$arg"
+done
+sleep 0.2
+name=$(basename "$input")
+{ cat "$input"; echo; echo "$profile"; } > "$outdir/${name%.*}.$ext"
+`
+
+func TestLibreOfficeConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "libreoffice"), []byte(fakeLibreOffice), 0o755))
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	const conversions = 4
+
+	var wg sync.WaitGroup
+	results := make([]string, conversions)
+	errs := make([]error, conversions)
+
+	for i := 0; i < conversions; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			// Every file has the same name, but not the same content.
+			r, err := documents.NewDocx("report.docx").ConvertTo(
+				context.Background(),
+				"Document",
+				documents.PDF,
+				strings.NewReader(fmt.Sprintf("report %d", i)),
+				files.ConvertOptions{},
+			)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+
+			content, err := io.ReadAll(r)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+
+			zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+			if err != nil {
+				errs[i] = err
+				return
+			}
+
+			f, err := zr.Open("report.pdf")
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			defer f.Close()
+
+			pdf, err := io.ReadAll(f)
+			results[i], errs[i] = string(pdf), err
+		}(i)
+	}
+
+	wg.Wait()
+
+	profiles := map[string]bool{}
+
+	for i := 0; i < conversions; i++ {
+		require.NoError(t, errs[i])
+
+		content, profile, ok := strings.Cut(strings.TrimSpace(results[i]), "\n")
+		require.True(t, ok)
+		require.Equal(t, fmt.Sprintf("report %d", i), content)
+		require.True(t, strings.HasPrefix(profile, "file:///"), profile)
+
+		profiles[profile] = true
+	}
+
+	require.Len(t, profiles, conversions)
+}
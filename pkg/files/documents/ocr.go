@@ -0,0 +1,113 @@
+package documents
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image/jpeg"
+	"image/png"
+	"regexp"
+	"strings"
+
+	"github.com/gen2brain/go-fitz"
+
+	"github.com/danvergara/morphos/pkg/files"
+	"github.com/danvergara/morphos/pkg/files/images"
+	"github.com/danvergara/morphos/pkg/progress"
+)
+
+// ocrJPEGQuality is the quality of the pages of searchable pdf files,
+// which are encoded as jpeg, since scanned pages are usually photos of paper.
+const ocrJPEGQuality = 90
+
+// blankLines separate the paragraphs of the text recognized by tesseract.
+var blankLines = regexp.MustCompile(`\n\s*\n`)
+
+// recognize recognizes the text of the pages of the pdf as the target format,
+// hocr or a searchable pdf. The pages are rendered at the resolution set in the options.
+func recognize(ctx context.Context, doc *fitz.Document, pages []int, target string, opts files.ConvertOptions) ([]byte, error) {
+	dpi := opts.Int(DPIOption, defaultDPI)
+
+	imgs := make([][]byte, len(pages))
+
+	for i, n := range pages {
+		// Stops rendering pages if the conversion was cancelled.
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		progress.Report(ctx, progress.Event{
+			Stage:   progress.Converting,
+			Current: i,
+			Total:   len(pages),
+			Message: fmt.Sprintf("rendering page %d of %d", i+1, len(pages)),
+		})
+
+		img, err := ocrPage(doc, n, dpi, target)
+		if err != nil {
+			return nil, err
+		}
+
+		imgs[i] = img
+	}
+
+	progress.Report(ctx, progress.Event{
+		Stage:   progress.Converting,
+		Message: fmt.Sprintf("recognizing the text of %d pages", len(pages)),
+	})
+
+	return images.OCR(ctx, target, dpi, imgs, opts)
+}
+
+// recognizePage recognizes the text of a page of the pdf, and splits it into paragraphs.
+// The size of the text is not known, so they are never headings.
+func recognizePage(ctx context.Context, doc *fitz.Document, n int, opts files.ConvertOptions) ([]textBlock, error) {
+	dpi := opts.Int(DPIOption, defaultDPI)
+
+	img, err := ocrPage(doc, n, dpi, TXT)
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := images.OCR(ctx, TXT, dpi, [][]byte{img}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var blocks []textBlock
+	for _, paragraph := range blankLines.Split(string(text), -1) {
+		var runs []textRun
+		for _, line := range strings.Split(paragraph, "\n") {
+			runs = joinRuns(runs, []textRun{{text: line}})
+		}
+
+		if len(runs) > 0 {
+			blocks = append(blocks, textBlock{runs: runs})
+		}
+	}
+
+	return blocks, nil
+}
+
+// ocrPage renders a page of the pdf to recognize its text. Pages of searchable pdf files
+// are encoded as jpeg files, which are much smaller, and the rest as png files.
+func ocrPage(doc *fitz.Document, n, dpi int, target string) ([]byte, error) {
+	img, err := doc.ImageDPI(n, float64(dpi))
+	if err != nil {
+		return nil, fmt.Errorf("error at rendering the pdf page number %d: %w", n+1, err)
+	}
+
+	buf := new(bytes.Buffer)
+
+	if target == OCRPDF {
+		err = jpeg.Encode(buf, img, &jpeg.Options{Quality: ocrJPEGQuality})
+	} else {
+		err = png.Encode(buf, img)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error at encoding the pdf page number %d: %w", n+1, err)
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,210 @@
+package documents
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/danvergara/morphos/pkg/files"
+	"github.com/danvergara/morphos/pkg/files/images"
+)
+
+const (
+	// DPIOption sets the resolution used to render the pages of a pdf.
+	DPIOption = "dpi"
+	// WidthOption sets the width, in pixels, the pages of a pdf are rendered at.
+	// It takes precedence over the resolution.
+	WidthOption = "width"
+	// PagesOption selects the pages of a pdf to convert. e.g. 1-3,7,10-
+	PagesOption = "pages"
+	// DelimiterOption sets the field delimiter of csv files.
+	DelimiterOption = "delimiter"
+	// PageBreaksOption separates the text extracted from every page of a pdf.
+	PageBreaksOption = "page_breaks"
+	// OCROption sets which pages of a pdf have their text recognized, rather than extracted.
+	OCROption = "ocr"
+
+	// OCRAuto recognizes the text of the pages without text, e.g. scanned pages.
+	OCRAuto = "auto"
+	// OCRAlways recognizes the text of every page, ignoring the text they have.
+	OCRAlways = "always"
+	// OCRNever only extracts the text of the pages.
+	OCRNever = "never"
+
+	defaultDPI = 300
+	// maxWidth is the widest a page of a pdf can be rendered.
+	maxWidth = 10000
+)
+
+// delimiters maps the choices of the delimiter option to the runes they stand for.
+var delimiters = map[string]rune{
+	"comma":     ',',
+	"semicolon": ';',
+	"tab":       '\t',
+	"pipe":      '|',
+}
+
+// pdfInputOptions are the options accepted when converting from pdf.
+var pdfInputOptions = files.Schema{
+	{
+		Name:    DPIOption,
+		Label:   "Resolution (DPI)",
+		Help:    "Resolution used to render the pages as images",
+		Type:    files.IntOption,
+		Default: strconv.Itoa(defaultDPI),
+		Min:     36,
+		Max:     1200,
+	},
+	{
+		Name:  WidthOption,
+		Label: "Width",
+		Help:  "Width of the rendered pages in pixels, instead of the resolution. The height keeps the aspect ratio",
+		Type:  files.IntOption,
+		Min:   1,
+		Max:   maxWidth,
+	},
+	{
+		Name:  PagesOption,
+		Label: "Pages",
+		Help:  "Pages to convert, e.g. 1-3,7,10-. All of them if empty",
+		Type:  files.StringOption,
+		Validate: func(spec string) error {
+			_, err := ParsePageRanges(spec)
+			return err
+		},
+	},
+}
+
+// csvOptions are the options accepted when converting from or to csv.
+var csvOptions = files.Schema{
+	{
+		Name:    DelimiterOption,
+		Label:   "CSV delimiter",
+		Type:    files.ChoiceOption,
+		Default: "comma",
+		Choices: []string{"comma", "semicolon", "tab", "pipe"},
+	},
+}
+
+// pdfTextOptions are the options accepted when extracting the text of a pdf.
+var pdfTextOptions = files.Schema{
+	{
+		Name:  PageBreaksOption,
+		Label: "Page breaks",
+		Help:  "Separates the text of every page, with a form feed in txt files, or a horizontal rule in md and html files",
+		Type:  files.BoolOption,
+	},
+	{
+		Name:    OCROption,
+		Label:   "OCR",
+		Help:    "Recognizes the text of the pages of a pdf without text, of every page, or of none of them",
+		Type:    files.ChoiceOption,
+		Default: OCRAuto,
+		Choices: []string{OCRAuto, OCRAlways, OCRNever},
+	},
+}
+
+// textOptions are the options accepted when extracting the text of a pdf, or recognizing it.
+var textOptions = pdfTextOptions.Merge(images.OCROptions)
+
+// delimiter returns the csv field delimiter set in the options.
+func delimiter(opts files.ConvertOptions) rune {
+	if d, ok := delimiters[opts.String(DelimiterOption, "")]; ok {
+		return d
+	}
+
+	return ','
+}
+
+// PageRange is a range of pages, numbered from 1.
+// A Last of 0 means the range goes up to the last page.
+type PageRange struct {
+	First int
+	Last  int
+}
+
+// ParsePageRanges parses a comma separated list of pages and ranges of pages.
+// e.g. 1-3,7,10- selects the pages 1, 2, 3, 7, and from 10 to the end.
+func ParsePageRanges(spec string) ([]PageRange, error) {
+	var ranges []PageRange
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		first, last, isRange := strings.Cut(part, "-")
+
+		var (
+			r   PageRange
+			err error
+		)
+
+		r.First, err = strconv.Atoi(strings.TrimSpace(first))
+		if err != nil || r.First < 1 {
+			return nil, fmt.Errorf("invalid page %q", part)
+		}
+
+		switch {
+		case !isRange:
+			r.Last = r.First
+		case strings.TrimSpace(last) != "":
+			r.Last, err = strconv.Atoi(strings.TrimSpace(last))
+			if err != nil || r.Last < r.First {
+				return nil, fmt.Errorf("invalid page range %q", part)
+			}
+		}
+
+		ranges = append(ranges, r)
+	}
+
+	return ranges, nil
+}
+
+// errNoPages is returned when a page selection leaves no page to convert.
+var errNoPages = errors.New("no pages selected")
+
+// SelectPages returns the zero-based indexes of the pages selected by the
+// given spec, in order, in a document of numPages pages.
+// An empty spec selects every page. Ranges going past the last page are clipped.
+func SelectPages(spec string, numPages int) ([]int, error) {
+	ranges, err := ParsePageRanges(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ranges) == 0 {
+		ranges = []PageRange{{First: 1}}
+	}
+
+	var (
+		pages []int
+		seen  = make(map[int]bool)
+	)
+
+	for _, r := range ranges {
+		if r.First > numPages {
+			return nil, fmt.Errorf("page %d out of range, the document has %d pages", r.First, numPages)
+		}
+
+		last := r.Last
+		if last == 0 || last > numPages {
+			last = numPages
+		}
+
+		for n := r.First; n <= last; n++ {
+			if !seen[n] {
+				seen[n] = true
+				pages = append(pages, n-1)
+			}
+		}
+	}
+
+	if len(pages) == 0 {
+		return nil, errNoPages
+	}
+
+	return pages, nil
+}
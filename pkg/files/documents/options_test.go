@@ -0,0 +1,43 @@
+package documents_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/danvergara/morphos/pkg/files/documents"
+)
+
+func TestSelectPages(t *testing.T) {
+	var tests = []struct {
+		name     string
+		spec     string
+		numPages int
+		expected []int
+		hasErr   bool
+	}{
+		{name: "every page", spec: "", numPages: 3, expected: []int{0, 1, 2}},
+		{name: "single pages", spec: "3,1", numPages: 3, expected: []int{2, 0}},
+		{name: "ranges", spec: "1-2, 4-", numPages: 6, expected: []int{0, 1, 3, 4, 5}},
+		{name: "overlapping ranges", spec: "1-3,2-4", numPages: 5, expected: []int{0, 1, 2, 3}},
+		{name: "clipped range", spec: "2-10", numPages: 3, expected: []int{1, 2}},
+		{name: "out of range", spec: "4", numPages: 3, hasErr: true},
+		{name: "reversed range", spec: "3-1", numPages: 3, hasErr: true},
+		{name: "page zero", spec: "0", numPages: 3, hasErr: true},
+		{name: "not a page", spec: "first", numPages: 3, hasErr: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			pages, err := documents.SelectPages(tc.spec, tc.numPages)
+			if tc.hasErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, pages)
+		})
+	}
+}
@@ -1,27 +1,22 @@
 package documents
 
 import (
-	"archive/zip"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
-	"image/gif"
-	"image/jpeg"
-	"image/png"
+	"image"
 	"io"
-	"log"
-	"os"
-	"os/exec"
 	"path/filepath"
 	"slices"
 	"strings"
 
-	"github.com/chai2010/webp"
 	"github.com/gen2brain/go-fitz"
-	"golang.org/x/image/bmp"
-	"golang.org/x/image/tiff"
 
+	"github.com/danvergara/morphos/pkg/files"
 	"github.com/danvergara/morphos/pkg/files/images"
+	"github.com/danvergara/morphos/pkg/packaging"
+	"github.com/danvergara/morphos/pkg/progress"
 	"github.com/danvergara/morphos/pkg/util"
 )
 
@@ -33,6 +28,16 @@ type Pdf struct {
 	OutDir              string
 }
 
+func init() {
+	files.Register(files.Format{
+		Name:         PDF,
+		Category:     files.Doc,
+		MIMETypes:    []string{"application/pdf", "application/x-pdf"},
+		Decoder:      func(filename string) files.File { return NewPdf(filename) },
+		InputOptions: pdfInputOptions,
+	})
+}
+
 // NewPdf returns a pointer to Pdf.
 func NewPdf(filename string) *Pdf {
 	p := Pdf{
@@ -49,6 +54,11 @@ func NewPdf(filename string) *Pdf {
 			},
 			"Document": {
 				DOCX,
+				TXT,
+				MD,
+				HTML,
+				HOCR,
+				OCRPDF,
 			},
 			"Ebook": {
 				EPUB,
@@ -67,6 +77,11 @@ func NewPdf(filename string) *Pdf {
 			},
 			"Document": {
 				DOCXMIMEType,
+				TXTMIMEType,
+				MDMIMEType,
+				HTML,
+				HOCR,
+				OCRPDF,
 			},
 			"Ebook": {
 				EpubMimeType,
@@ -93,7 +108,7 @@ func (p *Pdf) SupportedMIMETypes() map[string][]string {
 // ConvertTo converts the current PDF file to another given format.
 // This method receives the file type, the sub-type and the file as an slice of bytes.
 // Returns the converted file as an slice of bytes, if something wrong happens, an error is returned.
-func (p *Pdf) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, error) {
+func (p *Pdf) ConvertTo(ctx context.Context, fileType, subType string, file io.Reader, opts files.ConvertOptions) (io.Reader, error) {
 	// These are guard clauses that check if the target file type is valid.
 	compatibleFormats, ok := p.SupportedFormats()[fileType]
 	if !ok {
@@ -123,26 +138,34 @@ func (p *Pdf) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, er
 			return nil, fmt.Errorf("ConvertTo: error at opening the input pdf: %w", err)
 		}
 
-		// Parses the file name of the Zip file.
-		zipFileName := fmt.Sprintf(
-			"%s.zip",
-			strings.TrimSuffix(p.filename, filepath.Ext(p.filename)),
-		)
+		defer doc.Close()
 
-		// Creates the zip file that will be returned.
-		archive, err := os.CreateTemp("", zipFileName)
+		// Selects the pages to convert, all of them by default.
+		pages, err := SelectPages(opts.String(PagesOption, ""), doc.NumPage())
 		if err != nil {
-			return nil, fmt.Errorf(
-				"ConvertTo: error at creating the zip file to store the images: %w",
-				err,
-			)
+			return nil, fmt.Errorf("ConvertTo: %w", err)
+		}
+
+		// The images are packaged in memory, as they are encoded.
+		archive := new(bytes.Buffer)
+		zipWriter, err := packaging.NewWriter(archive, packaging.Zip, packaging.DefaultCompression)
+		if err != nil {
+			return nil, fmt.Errorf("ConvertTo: %w", err)
 		}
-		defer os.Remove(archive.Name())
 
-		// Creates a Zip Writer to add files later on.
-		zipWriter := zip.NewWriter(archive)
+		for i, n := range pages {
+			// Stops rendering pages if the conversion was cancelled.
+			if err := ctx.Err(); err != nil {
+				return nil, fmt.Errorf("ConvertTo: %w", err)
+			}
+
+			progress.Report(ctx, progress.Event{
+				Stage:   progress.Converting,
+				Current: i,
+				Total:   len(pages),
+				Message: fmt.Sprintf("converting page %d of %d", i+1, len(pages)),
+			})
 
-		for n := 0; n < doc.NumPage(); n++ {
 			// Parses the file name image.
 			imgFileName := fmt.Sprintf(
 				"%s_%d.%s",
@@ -152,7 +175,7 @@ func (p *Pdf) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, er
 			)
 
 			// Converts the current pdf page to an image.Image.
-			img, err := doc.Image(n)
+			img, err := renderPage(doc, n, opts)
 			if err != nil {
 				return nil, fmt.Errorf(
 					"ConvertTo: error at converting the pdf page number %d to image: %w",
@@ -161,239 +184,91 @@ func (p *Pdf) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, er
 				)
 			}
 
-			// Saves the image on disk.
-			imgFile, err := os.Create(fmt.Sprintf("/tmp/%s", imgFileName))
-			if err != nil {
-				return nil, fmt.Errorf(
-					"ConvertTo: error at storing the pdf image from the page #%d: %w",
-					n,
-					err,
-				)
-			}
-
-			// Encodes the image based on the sub-type of the file.
-			// e.g. png.
-			switch subType {
-			case images.PNG:
-				err = png.Encode(imgFile, img)
-				if err != nil {
-					return nil, fmt.Errorf(
-						"ConvertTo: error at encoding the pdf page %d as png: %w",
-						n,
-						err,
-					)
-				}
-			case images.JPG, images.JPEG:
-				err = jpeg.Encode(imgFile, img, nil)
-				if err != nil {
-					return nil, fmt.Errorf(
-						"ConvertTo: error at encoding the pdf page %d as jpeg: %w",
-						n,
-						err,
-					)
-				}
-			case images.GIF:
-				err = gif.Encode(imgFile, img, nil)
-				if err != nil {
-					return nil, fmt.Errorf(
-						"ConvertTo: error at encoding the pdf page %d as gif: %w",
-						n,
-						err,
-					)
-				}
-			case images.WEBP:
-				err = webp.Encode(imgFile, img, nil)
-				if err != nil {
-					return nil, fmt.Errorf(
-						"ConvertTo: error at encoding the pdf page %d as webp: %w",
-						n,
-						err,
-					)
-				}
-			case images.TIFF:
-				err = tiff.Encode(imgFile, img, nil)
-				if err != nil {
-					return nil, fmt.Errorf(
-						"ConvertTo: error at encoding the pdf page %d as tiff: %w",
-						n,
-						err,
-					)
-				}
-			case images.BMP:
-				err = bmp.Encode(imgFile, img)
-				if err != nil {
-					return nil, fmt.Errorf(
-						"ConvertTo: error at encoding the pdf page %d as bmp: %w",
-						n,
-						err,
-					)
-				}
-			}
-
-			imgFile.Close()
+			imgFile := new(bytes.Buffer)
 
-			// Opens the image to add it to the zip file.
-			imgFile, err = os.Open(imgFile.Name())
-			if err != nil {
+			// Encodes the image based on the sub-type of the file,
+			// with the encoding options of the conversion. e.g. png.
+			if err := images.Encode(imgFile, subType, img, opts); err != nil {
 				return nil, fmt.Errorf(
-					"ConvertTo: error at storing the pdf image from the page #%d: %w",
+					"ConvertTo: error at encoding the pdf page %d as %s: %w",
 					n,
+					subType,
 					err,
 				)
 			}
 
 			// Adds the image to the zip file.
-			w1, err := zipWriter.Create(filepath.Base(imgFile.Name()))
-			if err != nil {
-				return nil, fmt.Errorf(
-					"ConvertTo: error at creating a zip writer to store the page #%d: %w",
-					n,
-					err,
-				)
+			if err := zipWriter.Add(imgFileName, imgFile.Bytes()); err != nil {
+				return nil, fmt.Errorf("ConvertTo: %w", err)
 			}
-
-			if _, err := io.Copy(w1, imgFile); err != nil {
-				return nil, fmt.Errorf(
-					"ConvertTo: error at copying the content of the page #%d to the zipwriter: %w",
-					n,
-					err,
-				)
-			}
-
-			imgFile.Close()
-			os.Remove(imgFile.Name())
 		}
 
-		// Closes both zip writer and the zip file after its done with the writing.
-		zipWriter.Close()
-		archive.Close()
+		progress.Report(ctx, progress.Event{
+			Stage:   progress.Packaging,
+			Message: fmt.Sprintf("packaging %d images", len(pages)),
+		})
 
-		// Reads the zip file as an slice of bytes.
-		zipFile, err := os.ReadFile(archive.Name())
-		if err != nil {
-			return nil, fmt.Errorf("error reading zip file: %v", err)
+		if err := zipWriter.Close(); err != nil {
+			return nil, fmt.Errorf("ConvertTo: error closing the zip file: %w", err)
 		}
 
-		return bytes.NewReader(zipFile), nil
+		return archive, nil
 	case documentType:
 		switch subType {
-		case DOCX:
-			var (
-				stdout bytes.Buffer
-				stderr bytes.Buffer
-			)
-
-			docxFileName := fmt.Sprintf(
-				"%s.docx",
-				strings.TrimSuffix(p.filename, filepath.Ext(p.filename)),
-			)
-
-			// Parses the file name of the Zip file.
-			zipFileName := fmt.Sprintf(
-				"%s.zip",
-				strings.TrimSuffix(p.filename, filepath.Ext(p.filename)),
-			)
-
-			pdfFile, err := os.CreateTemp("", p.filename)
+		case TXT, MD, HTML, HOCR, OCRPDF:
+			doc, err := fitz.NewFromMemory(fileBytes)
 			if err != nil {
-				return nil, fmt.Errorf(
-					"error creating file to store the incoming pdf locally %s: %w",
-					p.filename,
-					err,
-				)
+				return nil, fmt.Errorf("ConvertTo: error at opening the input pdf: %w", err)
 			}
-			defer os.Remove(pdfFile.Name())
 
-			if _, err := pdfFile.Write(fileBytes); err != nil {
-				return nil, fmt.Errorf(
-					"error storing the incoming pdf file %s: %w",
-					p.filename,
-					err,
-				)
-			}
+			defer doc.Close()
 
-			tmpDocxFile, err := os.CreateTemp("", docxFileName)
+			// Selects the pages to extract, all of them by default.
+			pages, err := SelectPages(opts.String(PagesOption, ""), doc.NumPage())
 			if err != nil {
-				return nil, fmt.Errorf(
-					"error at creating the temporary docx file to store the docx content: %w",
-					err,
-				)
+				return nil, fmt.Errorf("ConvertTo: %w", err)
 			}
-			defer os.Remove(tmpDocxFile.Name())
 
-			cmdStr := "libreoffice --headless --infilter='writer_pdf_import' --convert-to %s --outdir %s %q"
-			cmd := exec.Command(
-				"bash",
-				"-c",
-				fmt.Sprintf(cmdStr, `docx:"MS Word 2007 XML"`, "/tmp", pdfFile.Name()),
-			)
-
-			cmd.Stdout = &stdout
-			cmd.Stderr = &stderr
-
-			if err := cmd.Run(); err != nil {
-				return nil, fmt.Errorf(
-					"error converting pdf to docx using libreoffice: %w",
-					err,
-				)
-			}
-
-			if stderr.String() != "" {
-				return nil, fmt.Errorf(
-					"error converting pdf to docx calling libreoffice: %s",
-					stderr.String(),
-				)
-			}
-
-			log.Println(stdout.String())
-
-			tmpDocxFile.Close()
+			var text []byte
 
-			tmpDocxFile, err = os.Open(tmpDocxFile.Name())
-			if err != nil {
-				return nil, fmt.Errorf(
-					"error at opening the docx file: %w",
-					err,
-				)
+			// The text is extracted, and recognized if the pages have none,
+			// but hocr and searchable pdf files are always recognized.
+			if subType == HOCR || subType == OCRPDF {
+				text, err = recognize(ctx, doc, pages, subType, opts)
+			} else {
+				text, err = p.extractText(ctx, doc, pages, subType, opts)
 			}
-			defer tmpDocxFile.Close()
 
-			// Creates the zip file that will be returned.
-			archive, err := os.CreateTemp("", zipFileName)
 			if err != nil {
-				return nil, fmt.Errorf(
-					"error at creating the zip file to store the docx file: %w",
-					err,
-				)
+				return nil, fmt.Errorf("ConvertTo: %w", err)
 			}
-			defer os.Remove(archive.Name())
 
-			// Creates a Zip Writer to add files later on.
-			zipWriter := zip.NewWriter(archive)
+			return bytes.NewReader(text), nil
+		case DOCX:
+			docxFileName := fmt.Sprintf(
+				"%s.docx",
+				strings.TrimSuffix(p.filename, filepath.Ext(p.filename)),
+			)
 
-			w1, err := zipWriter.Create(docxFileName)
+			docxBytes, err := libreOfficeConvert(
+				ctx,
+				p.filename,
+				`docx:MS Word 2007 XML`,
+				DOCX,
+				fileBytes,
+				"--infilter=writer_pdf_import",
+			)
 			if err != nil {
-				return nil, fmt.Errorf(
-					"eror at creating a zip file: %w",
-					err,
-				)
+				return nil, err
 			}
 
-			if _, err := io.Copy(w1, tmpDocxFile); err != nil {
-				return nil, fmt.Errorf(
-					"error at writing the docx file content to the zip writer: %w",
-					err,
-				)
-			}
-
-			// Closes both zip writer and the zip file after its done with the writing.
-			zipWriter.Close()
-			archive.Close()
-
-			// Reads the zip file as an slice of bytes.
-			zipFile, err := os.ReadFile(archive.Name())
+			zipFile, err := packaging.Pack(
+				packaging.Zip,
+				packaging.DefaultCompression,
+				packaging.File{Name: docxFileName, Content: docxBytes},
+			)
 			if err != nil {
-				return nil, fmt.Errorf("error reading zip file: %v", err)
+				return nil, fmt.Errorf("error at packaging the docx file: %w", err)
 			}
 
 			return bytes.NewReader(zipFile), nil
@@ -401,15 +276,55 @@ func (p *Pdf) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, er
 	case ebookType:
 		switch subType {
 		case EPUB:
-			return util.EbookConvert(p.filename, PDF, EPUB, fileBytes)
+			return util.EbookConvert(ctx, p.filename, PDF, EPUB, fileBytes)
 		case MOBI:
-			return util.EbookConvert(p.filename, PDF, MOBI, fileBytes)
+			return util.EbookConvert(ctx, p.filename, PDF, MOBI, fileBytes)
 		}
 	}
 
 	return nil, errors.New("not implemented")
 }
 
+// renderPage renders the page at the width set in the options,
+// or at their resolution otherwise.
+func renderPage(doc *fitz.Document, n int, opts files.ConvertOptions) (image.Image, error) {
+	if !opts.Has(WidthOption) {
+		return doc.ImageDPI(n, float64(opts.Int(DPIOption, defaultDPI)))
+	}
+
+	width := opts.Int(WidthOption, 0)
+
+	// The bounds of the page are in points, 72 of them per inch.
+	bounds, err := doc.Bound(n)
+	if err != nil {
+		return nil, err
+	}
+
+	if bounds.Dx() <= 0 {
+		return nil, fmt.Errorf("the page %d has no width", n+1)
+	}
+
+	img, err := doc.ImageDPI(n, float64(width)*72/float64(bounds.Dx()))
+	if err != nil {
+		return nil, err
+	}
+
+	// The bounds are rounded down to whole points, and the rendered
+	// page is rounded up to whole pixels, so it may be slightly wider.
+	rect := img.Bounds()
+	if rect.Dx() > width {
+		rect.Max.X = rect.Min.X + width
+
+		if cropper, ok := img.(interface {
+			SubImage(image.Rectangle) image.Image
+		}); ok {
+			img = cropper.SubImage(rect)
+		}
+	}
+
+	return img, nil
+}
+
 // DocumentType returns the type of ducument of Pdf.
 func (p *Pdf) DocumentType() string {
 	return PDF
@@ -0,0 +1,469 @@
+package documents
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gen2brain/go-fitz"
+	"golang.org/x/net/html"
+
+	"github.com/danvergara/morphos/pkg/files"
+	"github.com/danvergara/morphos/pkg/progress"
+)
+
+// textRun is a piece of text of a line, written in the same style.
+type textRun struct {
+	text   string
+	size   float64
+	bold   bool
+	italic bool
+	// mono tells if the font is monospaced, as the ones used to write code.
+	mono bool
+}
+
+// textLine is a line of text of a page, as laid out by MuPDF, in points.
+type textLine struct {
+	top    float64
+	left   float64
+	height float64
+	runs   []textRun
+}
+
+// textBlock is a paragraph or a heading, made of consecutive lines of the same size.
+// The size of text recognized in images is not known, so it's 0.
+type textBlock struct {
+	size float64
+	runs []textRun
+	// level is the level of the heading, from 1 to 6, or 0 for paragraphs.
+	level int
+	// code tells if the block is code, whose lines are kept as they are, in a single run.
+	code bool
+}
+
+const (
+	// headingMinSize is how much larger than the body text, in points, headings are.
+	headingMinSize = 1
+	// headingMaxLength is the longest a heading can be, longer blocks are paragraphs.
+	headingMaxLength = 200
+	// paragraphGap is the gap between lines, relative to their height,
+	// above which they belong to different paragraphs.
+	paragraphGap = 1.6
+)
+
+var (
+	// pageBreaks are the separators between the pages of every format.
+	pageBreaks = map[string]string{
+		TXT:  "\f",
+		MD:   "---",
+		HTML: "<hr>",
+	}
+
+	styleProperty   = regexp.MustCompile(`([a-z-]+):([0-9.]+)pt`)
+	whitespace      = regexp.MustCompile(`\s+`)
+	markdownSpecial = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`)
+	markdownBlock   = regexp.MustCompile(`^([#+-]|\d+\.)( |$)`)
+	monospaced      = regexp.MustCompile(`(?i)courier|mono|consol`)
+	// word is a word of three letters at least, which every heading has,
+	// so pieces of formulas written larger than the text are not headings.
+	word = regexp.MustCompile(`\p{L}{3,}`)
+)
+
+// extractText extracts the text of the pages of the pdf, in reading order,
+// as a txt, md or html file. Paragraphs are made of the lines MuPDF finds
+// close enough, and the ones written larger than the body of the document
+// are headings, whose level is given by their size.
+// The text of the pages without text, e.g. scanned pages, is recognized by tesseract,
+// or the text of every page, as the ocr option says.
+func (p *Pdf) extractText(ctx context.Context, doc *fitz.Document, pages []int, format string, opts files.ConvertOptions) ([]byte, error) {
+	blocks := make([][]textBlock, len(pages))
+
+	for i, n := range pages {
+		// Stops extracting pages if the conversion was cancelled.
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		progress.Report(ctx, progress.Event{
+			Stage:   progress.Converting,
+			Current: i,
+			Total:   len(pages),
+			Message: fmt.Sprintf("extracting the text of page %d of %d", i+1, len(pages)),
+		})
+
+		page, err := doc.HTML(n, false)
+		if err != nil {
+			return nil, fmt.Errorf("error extracting the text of the page %d: %w", n+1, err)
+		}
+
+		lines := parseLines(page)
+
+		switch mode := opts.String(OCROption, OCRAuto); {
+		case mode == OCRAlways || (mode == OCRAuto && len(lines) == 0):
+			blocks[i], err = recognizePage(ctx, doc, n, opts)
+			if err != nil {
+				return nil, fmt.Errorf("error recognizing the text of the page %d: %w", n+1, err)
+			}
+		default:
+			blocks[i] = groupLines(lines)
+		}
+	}
+
+	setHeadings(blocks)
+
+	var parts []string
+
+	for i, page := range blocks {
+		if i > 0 && opts.Bool(PageBreaksOption) {
+			parts = append(parts, pageBreaks[format])
+		}
+
+		for _, b := range page {
+			switch format {
+			case MD:
+				parts = append(parts, b.markdown())
+			case HTML:
+				parts = append(parts, b.html())
+			default:
+				parts = append(parts, b.text())
+			}
+		}
+	}
+
+	if format != HTML {
+		return []byte(strings.Join(parts, "\n\n") + "\n"), nil
+	}
+
+	title := strings.TrimSpace(strings.TrimRight(doc.Metadata()["title"], "\x00"))
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(p.filename), filepath.Ext(p.filename))
+	}
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n</head>\n<body>\n", html.EscapeString(title))
+	for _, part := range parts {
+		buf.WriteString(part + "\n")
+	}
+	buf.WriteString("</body>\n</html>\n")
+
+	return buf.Bytes(), nil
+}
+
+// parseLines reads the lines of a page from the html written by MuPDF,
+// where every line is an absolutely positioned paragraph, in reading order.
+// Pieces of a line MuPDF splits, e.g. the number of a heading and its title,
+// are joined back.
+func parseLines(page string) []textLine {
+	var (
+		lines          []textLine
+		current        *textLine
+		size           float64
+		mono           bool
+		bold, italic   int
+		z              = html.NewTokenizer(strings.NewReader(page))
+		lineProperties = func(t html.Token) map[string]float64 {
+			properties := map[string]float64{}
+			for _, a := range t.Attr {
+				if a.Key != "style" {
+					continue
+				}
+				for _, m := range styleProperty.FindAllStringSubmatch(a.Val, -1) {
+					properties[m[1]], _ = strconv.ParseFloat(m[2], 64)
+				}
+			}
+			return properties
+		}
+	)
+
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return lines
+		}
+
+		t := z.Token()
+
+		switch tt {
+		case html.StartTagToken:
+			switch t.Data {
+			case "p":
+				properties := lineProperties(t)
+				current = &textLine{top: properties["top"], left: properties["left"], height: properties["line-height"]}
+			case "span":
+				size = lineProperties(t)["font-size"]
+				mono = slices.ContainsFunc(t.Attr, func(a html.Attribute) bool {
+					return a.Key == "style" && monospaced.MatchString(a.Val)
+				})
+			case "b":
+				bold++
+			case "i":
+				italic++
+			}
+		case html.EndTagToken:
+			switch t.Data {
+			case "p":
+				if current == nil {
+					continue
+				}
+
+				last := len(lines) - 1
+				if last >= 0 && math.Abs(lines[last].top-current.top) < current.height/2 && current.left > lines[last].left {
+					lines[last].runs = append(lines[last].runs, textRun{text: " ", size: size})
+					lines[last].runs = append(lines[last].runs, current.runs...)
+				} else if strings.TrimSpace(current.text()) != "" {
+					lines = append(lines, *current)
+				}
+
+				current = nil
+			case "b":
+				bold = max(bold-1, 0)
+			case "i":
+				italic = max(italic-1, 0)
+			}
+		case html.TextToken:
+			if current != nil {
+				current.runs = append(current.runs, textRun{text: t.Data, size: size, bold: bold > 0, italic: italic > 0, mono: mono})
+			}
+		}
+	}
+}
+
+// groupLines groups the lines into blocks. A line starts a new block when its size is
+// not the one of the block, when it's far below the previous one or above it, e.g.
+// in another column, or when it's indented, like the first line of a paragraph.
+// Lines of code are grouped apart, and kept as they are, indentation included.
+func groupLines(lines []textLine) []textBlock {
+	var blocks []textBlock
+
+	for i, l := range lines {
+		size, code := l.size(), l.code()
+
+		if i > 0 {
+			prev := lines[i-1]
+			gap := l.top - prev.top
+			current := &blocks[len(blocks)-1]
+
+			if current.code == code && math.Abs(size-current.size) < 0.5 && gap > 0 &&
+				gap <= paragraphGap*max(l.height, prev.height) && (code || l.left <= prev.left+l.height) {
+				if code {
+					current.runs[0].text += "\n" + strings.TrimRightFunc(l.text(), unicode.IsSpace)
+				} else {
+					current.runs = joinRuns(current.runs, l.runs)
+				}
+				continue
+			}
+		}
+
+		if code {
+			blocks = append(blocks, textBlock{size: size, code: true, runs: []textRun{{text: strings.TrimRightFunc(l.text(), unicode.IsSpace)}}})
+		} else {
+			blocks = append(blocks, textBlock{size: size, runs: joinRuns(nil, l.runs)})
+		}
+	}
+
+	return blocks
+}
+
+// joinRuns appends the runs of a line to the runs of a block.
+// Whitespace is collapsed, and the words hyphenated at the end of a line are joined.
+func joinRuns(runs, line []textRun) []textRun {
+	for _, r := range line {
+		r.text = whitespace.ReplaceAllString(r.text, " ")
+
+		if len(runs) == 0 {
+			r.text = strings.TrimLeft(r.text, " ")
+		} else {
+			last := &runs[len(runs)-1]
+			if strings.HasSuffix(last.text, " ") {
+				r.text = strings.TrimLeft(r.text, " ")
+			}
+
+			if last.bold == r.bold && last.italic == r.italic {
+				last.text += r.text
+				continue
+			}
+		}
+
+		if r.text != "" {
+			runs = append(runs, r)
+		}
+	}
+
+	// The lines of a block are separated by a space, unless the line ends in a hyphen.
+	if n := len(runs); n > 0 {
+		runs[n-1].text = strings.TrimRight(runs[n-1].text, " ")
+		if !strings.HasSuffix(runs[n-1].text, "-") {
+			runs[n-1].text += " "
+		}
+	}
+
+	return runs
+}
+
+// setHeadings finds the body size, the one most of the text is written in,
+// and turns the short blocks written larger into headings.
+// The largest headings are of level 1, the next ones of level 2, and so on.
+func setHeadings(pages [][]textBlock) {
+	chars := map[float64]int{}
+	for _, page := range pages {
+		for _, b := range page {
+			if !b.code && b.size > 0 {
+				chars[roundSize(b.size)] += len(b.plain())
+			}
+		}
+	}
+
+	var body float64
+	for size, n := range chars {
+		if n > chars[body] || (n == chars[body] && size < body) {
+			body = size
+		}
+	}
+
+	var sizes []float64
+	for _, page := range pages {
+		for _, b := range page {
+			if size := roundSize(b.size); b.isHeading(body) && !slices.Contains(sizes, size) {
+				sizes = append(sizes, size)
+			}
+		}
+	}
+
+	sort.Sort(sort.Reverse(sort.Float64Slice(sizes)))
+
+	for _, page := range pages {
+		for i := range page {
+			if page[i].isHeading(body) {
+				page[i].level = min(slices.Index(sizes, roundSize(page[i].size))+1, 6)
+			}
+		}
+	}
+}
+
+// roundSize rounds a font size to half a point, so sizes that barely differ are the same.
+func roundSize(size float64) float64 {
+	return math.Round(size*2) / 2
+}
+
+// isHeading tells if the block is a heading, given the size of the body text.
+func (b textBlock) isHeading(body float64) bool {
+	return !b.code && roundSize(b.size) >= body+headingMinSize &&
+		len(b.plain()) <= headingMaxLength && word.MatchString(b.plain())
+}
+
+// plain returns the text of the block.
+func (b textBlock) plain() string {
+	var sb strings.Builder
+	for _, r := range b.runs {
+		sb.WriteString(r.text)
+	}
+
+	if b.code {
+		return strings.Trim(sb.String(), "\n")
+	}
+
+	return strings.TrimSpace(sb.String())
+}
+
+// text returns the block as plain text.
+func (b textBlock) text() string {
+	return b.plain()
+}
+
+// markdown returns the block as markdown, a heading or a paragraph with its bold and italic text.
+func (b textBlock) markdown() string {
+	if b.code {
+		return "```\n" + b.plain() + "\n```"
+	}
+
+	if b.level > 0 {
+		return strings.Repeat("#", b.level) + " " + markdownSpecial.Replace(b.plain())
+	}
+
+	var sb strings.Builder
+	for _, r := range b.runs {
+		sb.WriteString(styled(markdownSpecial.Replace(r.text), r, "**", "**", "*", "*"))
+	}
+
+	// Paragraphs that would be read as headings or lists are escaped.
+	return markdownBlock.ReplaceAllString(strings.TrimSpace(sb.String()), `\$1$2`)
+}
+
+// html returns the block as a html heading or paragraph.
+func (b textBlock) html() string {
+	if b.code {
+		return "<pre><code>" + html.EscapeString(b.plain()) + "</code></pre>"
+	}
+
+	if b.level > 0 {
+		return fmt.Sprintf("<h%d>%s</h%d>", b.level, html.EscapeString(b.plain()), b.level)
+	}
+
+	var sb strings.Builder
+	for _, r := range b.runs {
+		sb.WriteString(styled(html.EscapeString(r.text), r, "<b>", "</b>", "<i>", "</i>"))
+	}
+
+	return "<p>" + strings.TrimSpace(sb.String()) + "</p>"
+}
+
+// styled wraps the text of a run in the given marks if it's bold or italic.
+// The marks go around the words, since markdown doesn't allow spaces inside them.
+func styled(text string, r textRun, boldOpen, boldClose, italicOpen, italicClose string) string {
+	words := strings.TrimSpace(text)
+	if words == "" || (!r.bold && !r.italic) {
+		return text
+	}
+
+	if r.italic {
+		words = italicOpen + words + italicClose
+	}
+	if r.bold {
+		words = boldOpen + words + boldClose
+	}
+
+	leading := text[:len(text)-len(strings.TrimLeftFunc(text, unicode.IsSpace))]
+	trailing := text[len(strings.TrimRightFunc(text, unicode.IsSpace)):]
+
+	return leading + words + trailing
+}
+
+// text returns the text of the line.
+func (l textLine) text() string {
+	var sb strings.Builder
+	for _, r := range l.runs {
+		sb.WriteString(r.text)
+	}
+
+	return sb.String()
+}
+
+// code tells if the line is code, all of it written in a monospaced font.
+func (l textLine) code() bool {
+	return !slices.ContainsFunc(l.runs, func(r textRun) bool {
+		return !r.mono && strings.TrimSpace(r.text) != ""
+	})
+}
+
+// size returns the font size most of the text of the line is written in.
+func (l textLine) size() float64 {
+	chars := map[float64]int{}
+
+	var size float64
+	for _, r := range l.runs {
+		chars[r.size] += len(strings.TrimSpace(r.text))
+		if chars[r.size] > chars[size] {
+			size = r.size
+		}
+	}
+
+	return size
+}
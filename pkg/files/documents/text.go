@@ -0,0 +1,98 @@
+package documents
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/danvergara/morphos/pkg/files"
+	"github.com/danvergara/morphos/pkg/files/images"
+)
+
+// Text struct implements the File interface from the file package
+// for the text extracted or recognized from other files, as plain text, markdown,
+// html, hocr, or searchable pdf files.
+// They are only produced by other formats, e.g. when extracting the text of a pdf,
+// so they can't be converted to any format themselves.
+type Text struct {
+	filename            string
+	format              string
+	compatibleFormats   map[string][]string
+	compatibleMIMETypes map[string][]string
+}
+
+func init() {
+	// The text of images is recognized, the one of pdf files is extracted as well,
+	// so only the text extracted from pdf files takes the options of the extraction.
+	files.Register(files.Format{
+		Name:              TXT,
+		Category:          files.Doc,
+		MIMETypes:         []string{tesxtMimeType + TXTMIMEType},
+		Decoder:           func(filename string) files.File { return NewText(filename, TXT) },
+		OutputOptions:     images.OCROptions,
+		OutputOptionsFrom: map[string]files.Schema{PDF: pdfTextOptions},
+	})
+
+	files.Register(files.Format{
+		Name:          MD,
+		Category:      files.Doc,
+		Aliases:       []string{MDMIMEType},
+		MIMETypes:     []string{tesxtMimeType + MDMIMEType},
+		Decoder:       func(filename string) files.File { return NewText(filename, MD) },
+		OutputOptions: textOptions,
+	})
+
+	files.Register(files.Format{
+		Name:          HTML,
+		Category:      files.Doc,
+		Aliases:       []string{"htm"},
+		MIMETypes:     []string{tesxtMimeType + HTML},
+		Decoder:       func(filename string) files.File { return NewText(filename, HTML) },
+		OutputOptions: textOptions,
+	})
+
+	// The text recognized by tesseract, as hocr or searchable pdf files,
+	// whose MIME types are the ones of html and pdf files.
+	files.Register(files.Format{
+		Name:          HOCR,
+		Category:      files.Doc,
+		Decoder:       func(filename string) files.File { return NewText(filename, HOCR) },
+		OutputOptions: images.OCROptions,
+	})
+
+	files.Register(files.Format{
+		Name:          OCRPDF,
+		Category:      files.Doc,
+		Decoder:       func(filename string) files.File { return NewText(filename, OCRPDF) },
+		OutputOptions: images.OCROptions,
+	})
+}
+
+// NewText returns a pointer to Text, given the format of the file. e.g. md.
+func NewText(filename, format string) *Text {
+	t := Text{
+		filename:            filename,
+		format:              format,
+		compatibleFormats:   map[string][]string{},
+		compatibleMIMETypes: map[string][]string{},
+	}
+
+	return &t
+}
+
+// SupportedFormats returns a map witht the compatible formats that Text is
+// compatible to be converted to, none of them.
+func (t *Text) SupportedFormats() map[string][]string {
+	return t.compatibleFormats
+}
+
+// SupportedMIMETypes returns a map witht the compatible MIME types that Text is
+// compatible to be converted to, none of them.
+func (t *Text) SupportedMIMETypes() map[string][]string {
+	return t.compatibleMIMETypes
+}
+
+// ConvertTo always errors out, since text files can't be converted to other formats.
+func (t *Text) ConvertTo(ctx context.Context, fileType, subType string, file io.Reader, opts files.ConvertOptions) (io.Reader, error) {
+	return nil, fmt.Errorf("ConvertTo: %s files can't be converted to %s", t.format, subType)
+}
@@ -1,18 +1,20 @@
 package documents
 
 import (
-	"archive/zip"
 	"bytes"
+	"context"
 	"encoding/csv"
 	"errors"
 	"fmt"
 	"io"
-	"os"
 	"path/filepath"
 	"slices"
 	"strings"
 
 	"github.com/tealeg/xlsx/v3"
+
+	"github.com/danvergara/morphos/pkg/files"
+	"github.com/danvergara/morphos/pkg/packaging"
 )
 
 // Xlsx struct implements the File and Document interface from the file package.
@@ -22,6 +24,15 @@ type Xlsx struct {
 	compatibleMIMETypes map[string][]string
 }
 
+func init() {
+	files.Register(files.Format{
+		Name:      XLSX,
+		Category:  files.Doc,
+		MIMETypes: []string{documentMimeType + XLSXMIMEType},
+		Decoder:   func(filename string) files.File { return NewXlsx(filename) },
+	})
+}
+
 // NewXlsx returns a pointer to Xlsx.
 func NewXlsx(filename string) *Xlsx {
 	x := Xlsx{
@@ -29,11 +40,13 @@ func NewXlsx(filename string) *Xlsx {
 		compatibleFormats: map[string][]string{
 			"Document": {
 				CSV,
+				PDF,
 			},
 		},
 		compatibleMIMETypes: map[string][]string{
 			"Document": {
 				CSV,
+				PDF,
 			},
 		},
 	}
@@ -53,7 +66,7 @@ func (x *Xlsx) SupportedMIMETypes() map[string][]string {
 	return x.compatibleMIMETypes
 }
 
-func (x *Xlsx) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, error) {
+func (x *Xlsx) ConvertTo(ctx context.Context, fileType, subType string, file io.Reader, opts files.ConvertOptions) (io.Reader, error) {
 	compatibleFormats, ok := x.SupportedFormats()[fileType]
 	if !ok {
 		return nil, fmt.Errorf("file type not supported: %s", fileType)
@@ -82,24 +95,13 @@ func (x *Xlsx) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, e
 				return nil, fmt.Errorf("error trying to open the xlsx file based on bytes of file %w", err)
 			}
 
-			// Parses the file name of the Zip file.
-			zipFileName := filepath.Join("/tmp", fmt.Sprintf(
-				"%s.zip",
-				strings.TrimSuffix(x.filename, filepath.Ext(x.filename)),
-			))
-
-			// Creates the zip file that will be returned.
-			archive, err := os.Create(zipFileName)
+			// The sheets are packaged in memory, as they are converted.
+			archive := new(bytes.Buffer)
+			zipWriter, err := packaging.NewWriter(archive, packaging.Zip, packaging.DefaultCompression)
 			if err != nil {
-				return nil, fmt.Errorf(
-					"ConvertTo: error at creating the zip file to store the images: %w",
-					err,
-				)
+				return nil, err
 			}
 
-			// Creates a Zip Writer to add files later on.
-			zipWriter := zip.NewWriter(archive)
-
 			for i, sheet := range xlFile.Sheets {
 				csvFilename := fmt.Sprintf(
 					"%s_%d.%s",
@@ -108,19 +110,9 @@ func (x *Xlsx) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, e
 					subType,
 				)
 
-				tmpCsvFilename := filepath.Join("/tmp", csvFilename)
-
-				// Saves the image on disk.
-				csvFile, err := os.Create(tmpCsvFilename)
-				if err != nil {
-					return nil, fmt.Errorf(
-						"error at storing the tmp csv file from the xlsx sheet #%d: %w",
-						i+1,
-						err,
-					)
-				}
-
+				csvFile := new(bytes.Buffer)
 				cw := csv.NewWriter(csvFile)
+				cw.Comma = delimiter(opts)
 
 				var vals []string
 				err = sheet.ForEachRow(func(row *xlsx.Row) error {
@@ -146,51 +138,40 @@ func (x *Xlsx) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, e
 				}
 
 				cw.Flush()
-				if cw.Error() != nil {
+				if err := cw.Error(); err != nil {
 					return nil, fmt.Errorf("error at writing buffered data to a underlying csv %w", err)
 				}
 
-				csvFile.Close()
-
-				// Saves the image on disk.
-				csvFile, err = os.Open(tmpCsvFilename)
-				if err != nil {
+				// Adds the sheet to the zip file.
+				if err := zipWriter.Add(csvFilename, csvFile.Bytes()); err != nil {
 					return nil, fmt.Errorf(
-						"error at opening the csv file based off a xlsx sheet #%d: %w",
-						i+1,
-						err,
-					)
-				}
-
-				defer csvFile.Close()
-
-				// Adds the image to the zip file.
-				w1, err := zipWriter.Create(csvFilename)
-				if err != nil {
-					return nil, fmt.Errorf(
-						"error at creating a zip writer to store the xlsx sheet #%d: %w",
+						"error at storing the xlsx sheet #%d: %w",
 						i+1,
 						err,
 					)
 				}
+			}
 
-				if _, err := io.Copy(w1, csvFile); err != nil {
-					return nil, fmt.Errorf(
-						"error at copying the content of the xlsx sheet #%d to the zipwriter: %w",
-						i+1,
-						err,
-					)
-				}
+			if err := zipWriter.Close(); err != nil {
+				return nil, fmt.Errorf("error closing the zip file: %w", err)
 			}
 
-			// Closes both zip writer and the zip file after its done with the writing.
-			zipWriter.Close()
-			archive.Close()
+			return archive, nil
+		case PDF:
+			pdfBytes, err := libreOfficeConvert(ctx, x.filename, "pdf:calc_pdf_Export", PDF, fileBytes)
+			if err != nil {
+				return nil, err
+			}
 
-			// Reads the zip file as an slice of bytes.
-			zipFile, err := os.ReadFile(zipFileName)
+			zipFile, err := zipSingleFile(
+				fmt.Sprintf(
+					"%s.pdf",
+					strings.TrimSuffix(x.filename, filepath.Ext(x.filename)),
+				),
+				pdfBytes,
+			)
 			if err != nil {
-				return nil, fmt.Errorf("error reading zip file: %v", err)
+				return nil, err
 			}
 
 			return bytes.NewReader(zipFile), nil
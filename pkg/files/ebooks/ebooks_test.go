@@ -2,17 +2,20 @@ package ebooks
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"os"
 	"testing"
 
 	"github.com/gabriel-vasile/mimetype"
 	"github.com/stretchr/testify/require"
+
+	"github.com/danvergara/morphos/pkg/files"
 )
 
 type file interface {
 	SupportedFormats() map[string][]string
-	ConvertTo(string, string, io.Reader) (io.Reader, error)
+	ConvertTo(context.Context, string, string, io.Reader, files.ConvertOptions) (io.Reader, error)
 }
 
 type ebook interface {
@@ -88,9 +91,11 @@ func TestEbookTConvertTo(t *testing.T) {
 			require.Equal(t, tc.input.mimetype, detectedFileType.String())
 
 			outoutFile, err := tc.input.ebook.ConvertTo(
+				context.Background(),
 				tc.input.targetFileType,
 				tc.input.targetFormat,
 				bytes.NewReader(inputDoc),
+				files.ConvertOptions{},
 			)
 			require.NoError(t, err)
 
@@ -2,12 +2,14 @@ package ebooks
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"slices"
 	"strings"
 
+	"github.com/danvergara/morphos/pkg/files"
 	"github.com/danvergara/morphos/pkg/files/documents"
 	"github.com/danvergara/morphos/pkg/util"
 )
@@ -19,6 +21,15 @@ type Epub struct {
 	compatibleMIMETypes map[string][]string
 }
 
+func init() {
+	files.Register(files.Format{
+		Name:      EPUB,
+		Category:  files.Ebook,
+		MIMETypes: []string{"application/" + EpubMimeType},
+		Decoder:   func(filename string) files.File { return NewEpub(filename) },
+	})
+}
+
 func NewEpub(filename string) *Epub {
 	e := Epub{
 		filename: filename,
@@ -55,7 +66,7 @@ func (e *Epub) SupportedMIMETypes() map[string][]string {
 	return e.compatibleMIMETypes
 }
 
-func (e *Epub) ConvertTo(fileType, subtype string, file io.Reader) (io.Reader, error) {
+func (e *Epub) ConvertTo(ctx context.Context, fileType, subtype string, file io.Reader, opts files.ConvertOptions) (io.Reader, error) {
 	// These are guard clauses that check if the target file type is valid.
 	compatibleFormats, ok := e.SupportedFormats()[fileType]
 	if !ok {
@@ -80,12 +91,12 @@ func (e *Epub) ConvertTo(fileType, subtype string, file io.Reader) (io.Reader, e
 	case documentType:
 		switch subtype {
 		case PDF:
-			return util.EbookConvert(e.filename, EPUB, PDF, fileBytes)
+			return util.EbookConvert(ctx, e.filename, EPUB, PDF, fileBytes)
 		}
 	case ebookType:
 		switch subtype {
 		case MOBI:
-			return util.EbookConvert(e.filename, EPUB, MOBI, fileBytes)
+			return util.EbookConvert(ctx, e.filename, EPUB, MOBI, fileBytes)
 		}
 	}
 
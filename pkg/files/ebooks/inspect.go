@@ -0,0 +1,197 @@
+package ebooks
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// EbookInfo describes an ebook, through the metadata of its OPF package document.
+type EbookInfo struct {
+	Title       string   `json:"title,omitempty"`
+	Creators    []string `json:"creators,omitempty"`
+	Language    string   `json:"language,omitempty"`
+	Publisher   string   `json:"publisher,omitempty"`
+	Identifier  string   `json:"identifier,omitempty"`
+	Date        string   `json:"date,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Subjects    []string `json:"subjects,omitempty"`
+}
+
+// errNoMetadata is returned when the metadata of an ebook can't be found.
+var errNoMetadata = errors.New("the ebook has no metadata")
+
+// opfPackage is the OPF package document of an epub file.
+// Elements are matched by their local name, regardless of their namespace.
+type opfPackage struct {
+	Metadata struct {
+		Titles      []string `xml:"title"`
+		Creators    []string `xml:"creator"`
+		Languages   []string `xml:"language"`
+		Publishers  []string `xml:"publisher"`
+		Identifiers []string `xml:"identifier"`
+		Dates       []string `xml:"date"`
+		Description []string `xml:"description"`
+		Subjects    []string `xml:"subject"`
+	} `xml:"metadata"`
+}
+
+// Inspect describes the epub file.
+// This method implements the files.Inspector interface.
+func (e *Epub) Inspect(_ context.Context, file io.Reader) (any, error) {
+	fileBytes, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	zipReader, err := zip.NewReader(bytes.NewReader(fileBytes), int64(len(fileBytes)))
+	if err != nil {
+		return nil, fmt.Errorf("error opening the epub file: %w", err)
+	}
+
+	// The container lists the package documents, the first one is the default rendition.
+	var container struct {
+		Rootfiles []struct {
+			FullPath string `xml:"full-path,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+
+	if err := decodeZipXML(zipReader, "META-INF/container.xml", &container); err != nil {
+		return nil, err
+	}
+
+	if len(container.Rootfiles) == 0 {
+		return nil, errNoMetadata
+	}
+
+	var opf opfPackage
+	if err := decodeZipXML(zipReader, container.Rootfiles[0].FullPath, &opf); err != nil {
+		return nil, err
+	}
+
+	m := opf.Metadata
+
+	return EbookInfo{
+		Title:       first(m.Titles),
+		Creators:    m.Creators,
+		Language:    first(m.Languages),
+		Publisher:   first(m.Publishers),
+		Identifier:  first(m.Identifiers),
+		Date:        first(m.Dates),
+		Description: first(m.Description),
+		Subjects:    m.Subjects,
+	}, nil
+}
+
+// decodeZipXML decodes the xml file of the zip file with the given name into v.
+func decodeZipXML(zipReader *zip.Reader, name string, v any) error {
+	f, err := zipReader.Open(name)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %w", name, err)
+	}
+	defer f.Close()
+
+	if err := xml.NewDecoder(f).Decode(v); err != nil {
+		return fmt.Errorf("error decoding %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// first returns the first value, if any.
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	return strings.TrimSpace(values[0])
+}
+
+// The EXTH records of mobi files that hold the fields of the OPF metadata.
+const (
+	exthAuthor      = 100
+	exthPublisher   = 101
+	exthDescription = 103
+	exthISBN        = 104
+	exthSubject     = 105
+	exthDate        = 106
+	exthTitle       = 503
+	exthLanguage    = 524
+)
+
+// Inspect describes the mobi file.
+// Mobi files don't have an OPF package document, its fields are read
+// out of the EXTH header instead.
+// This method implements the files.Inspector interface.
+func (m *Mobi) Inspect(_ context.Context, file io.Reader) (any, error) {
+	fileBytes, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	// The first record of the Palm database holds the mobi header,
+	// right after the PalmDOC header.
+	if len(fileBytes) < 82 {
+		return nil, errNoMetadata
+	}
+
+	record := int(binary.BigEndian.Uint32(fileBytes[78:]))
+	if record+24 > len(fileBytes) || string(fileBytes[record+16:record+20]) != "MOBI" {
+		return nil, errNoMetadata
+	}
+
+	var info EbookInfo
+
+	// The full name is the title, unless the EXTH header holds another one.
+	if record+92 <= len(fileBytes) {
+		offset := record + int(binary.BigEndian.Uint32(fileBytes[record+84:]))
+		length := int(binary.BigEndian.Uint32(fileBytes[record+88:]))
+		if offset >= record && offset+length <= len(fileBytes) {
+			info.Title = string(fileBytes[offset : offset+length])
+		}
+	}
+
+	exth := record + 16 + int(binary.BigEndian.Uint32(fileBytes[record+20:]))
+	if exth+12 > len(fileBytes) || string(fileBytes[exth:exth+4]) != "EXTH" {
+		return info, nil
+	}
+
+	count := int(binary.BigEndian.Uint32(fileBytes[exth+8:]))
+	for i, offset := 0, exth+12; i < count && offset+8 <= len(fileBytes); i++ {
+		kind := binary.BigEndian.Uint32(fileBytes[offset:])
+		length := int(binary.BigEndian.Uint32(fileBytes[offset+4:]))
+		if length < 8 || offset+length > len(fileBytes) {
+			break
+		}
+
+		value := strings.TrimSpace(string(fileBytes[offset+8 : offset+length]))
+		offset += length
+
+		switch kind {
+		case exthAuthor:
+			info.Creators = append(info.Creators, value)
+		case exthPublisher:
+			info.Publisher = value
+		case exthDescription:
+			info.Description = value
+		case exthISBN:
+			info.Identifier = value
+		case exthSubject:
+			info.Subjects = append(info.Subjects, value)
+		case exthDate:
+			info.Date = value
+		case exthTitle:
+			info.Title = value
+		case exthLanguage:
+			info.Language = value
+		}
+	}
+
+	return info, nil
+}
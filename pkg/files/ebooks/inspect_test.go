@@ -0,0 +1,58 @@
+package ebooks
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/danvergara/morphos/pkg/files"
+)
+
+func TestInspect(t *testing.T) {
+	var tests = []struct {
+		name      string
+		filename  string
+		inspector files.Inspector
+		expected  EbookInfo
+	}{
+		{
+			name:      "epub",
+			filename:  "testdata/no-man-s-land.epub",
+			inspector: NewEpub("no-man-s-land.epub"),
+			expected: EbookInfo{
+				Title:      "No Man's Land",
+				Creators:   []string{"Sapper"},
+				Language:   "en",
+				Publisher:  "epubBooks Classics",
+				Identifier: "_f62e7ec3-ed0f-4e9d-a028-d9820b622690",
+				Date:       "2021-05-01",
+			},
+		},
+		{
+			name:      "mobi",
+			filename:  "testdata/basilleja.mobi",
+			inspector: NewMobi("basilleja.mobi"),
+			expected: EbookInfo{
+				Title:    "Basilleja",
+				Creators: []string{"Martti Wuori"},
+				Language: "fi",
+				Date:     "2024-09-04T00:00:00+00:00",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := os.Open(tc.filename)
+			require.NoError(t, err)
+			defer f.Close()
+
+			info, err := tc.inspector.Inspect(context.Background(), f)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, info)
+		})
+	}
+}
@@ -2,12 +2,14 @@ package ebooks
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"slices"
 	"strings"
 
+	"github.com/danvergara/morphos/pkg/files"
 	"github.com/danvergara/morphos/pkg/files/documents"
 	"github.com/danvergara/morphos/pkg/util"
 )
@@ -18,6 +20,15 @@ type Mobi struct {
 	compatibleMIMETypes map[string][]string
 }
 
+func init() {
+	files.Register(files.Format{
+		Name:      MOBI,
+		Category:  files.Ebook,
+		MIMETypes: []string{"application/" + MobiMimeType},
+		Decoder:   func(filename string) files.File { return NewMobi(filename) },
+	})
+}
+
 func NewMobi(filename string) *Mobi {
 	m := Mobi{
 		filename: filename,
@@ -54,7 +65,7 @@ func (m *Mobi) SupportedMIMETypes() map[string][]string {
 	return m.compatibleMIMETypes
 }
 
-func (m *Mobi) ConvertTo(fileType, subtype string, file io.Reader) (io.Reader, error) {
+func (m *Mobi) ConvertTo(ctx context.Context, fileType, subtype string, file io.Reader, opts files.ConvertOptions) (io.Reader, error) {
 	// These are guard clauses that check if the target file type is valid.
 	compatibleFormats, ok := m.SupportedFormats()[fileType]
 	if !ok {
@@ -79,12 +90,12 @@ func (m *Mobi) ConvertTo(fileType, subtype string, file io.Reader) (io.Reader, e
 	case documentType:
 		switch subtype {
 		case PDF:
-			return util.EbookConvert(m.filename, MOBI, PDF, fileBytes)
+			return util.EbookConvert(ctx, m.filename, MOBI, PDF, fileBytes)
 		}
 	case ebookType:
 		switch subtype {
 		case EPUB:
-			return util.EbookConvert(m.filename, MOBI, EPUB, fileBytes)
+			return util.EbookConvert(ctx, m.filename, MOBI, EPUB, fileBytes)
 		}
 	}
 
@@ -25,7 +25,7 @@ func BuildFactory(f string, filename string) (FileFactory, error) {
 	switch f {
 	case Img:
 		return new(ImageFactory), nil
-	case Doc, Application, Text:
+	case Doc, Application, Text, Ebook:
 		return NewDocumentFactory(filename), nil
 	default:
 		return nil, fmt.Errorf("factory with type file %s not recognized", f)
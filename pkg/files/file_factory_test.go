@@ -1,22 +1,23 @@
-package files
+package files_test
 
 import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/danvergara/morphos/pkg/files"
 	"github.com/danvergara/morphos/pkg/files/documents"
 	"github.com/danvergara/morphos/pkg/files/images"
 )
 
 func TestImageFactory(t *testing.T) {
-	imgF, err := BuildFactory(Img, "foo.png")
+	imgF, err := files.BuildFactory(files.Img, "foo.png")
 	require.NoError(t, err)
 
 	imageFile, err := imgF.NewFile(images.PNG)
 	require.NoError(t, err)
 
-	png, ok := imageFile.(Image)
+	png, ok := imageFile.(files.Image)
 	if !ok {
 		t.Fatal("struct assertion has failed")
 	}
@@ -25,13 +26,13 @@ func TestImageFactory(t *testing.T) {
 }
 
 func TestDocumentFactory(t *testing.T) {
-	docF, err := BuildFactory(Doc, "foo.pdf")
+	docF, err := files.BuildFactory(files.Doc, "foo.pdf")
 	require.NoError(t, err)
 
 	docFile, err := docF.NewFile(documents.PDF)
 	require.NoError(t, err)
 
-	pdf, ok := docFile.(Document)
+	pdf, ok := docFile.(files.Document)
 	if !ok {
 		t.Fatal("struct assertion has failed")
 	}
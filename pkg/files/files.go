@@ -1,6 +1,9 @@
 package files
 
-import "io"
+import (
+	"context"
+	"io"
+)
 
 // File interface is the main interface of the package,
 // that defines what a file is in this context.
@@ -12,29 +15,35 @@ import "io"
 // Kind of document: 	Microsoft Word (OpenXML)
 // Extension: docx
 // MIME Type: application/vnd.openxmlformats-officedocument.wordprocessingml.document
+// ConvertTo receives the options of the conversion as well, the ones that
+// are not meant for the file are ignored.
+// The conversion stops when the context is done.
 type File interface {
 	SupportedFormats() map[string][]string
 	SupportedMIMETypes() map[string][]string
-	ConvertTo(string, string, io.Reader) (io.Reader, error)
+	ConvertTo(context.Context, string, string, io.Reader, ConvertOptions) (io.Reader, error)
 }
 
 // SupportedFileTypes returns a map with the underlying file type,
 // given a sub-type.
+// It's built off the formats registered in the DefaultRegistry,
+// so every name and alias of a format is included.
 func SupportedFileTypes() map[string]string {
-	return map[string]string{
-		"avif": "image",
-		"png":  "image",
-		"jpg":  "image",
-		"jpeg": "image",
-		"gif":  "image",
-		"webp": "image",
-		"tiff": "image",
-		"bmp":  "image",
-		"docx": "document",
-		"pdf":  "document",
-		"xlsx": "document",
-		"csv":  "document",
-		"epub": "ebook",
-		"mobi": "ebook",
+	result := make(map[string]string)
+
+	for _, f := range Formats() {
+		result[f.Name] = f.Category
+		for _, alias := range f.Aliases {
+			result[alias] = f.Category
+		}
 	}
+
+	return result
+}
+
+// Inspector is implemented by the files that can describe their content,
+// e.g. the size of an image or the pages of a pdf, before converting them.
+// The description returned is meant to be marshalled to JSON.
+type Inspector interface {
+	Inspect(context.Context, io.Reader) (any, error)
 }
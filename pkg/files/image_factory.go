@@ -2,8 +2,6 @@ package files
 
 import (
 	"fmt"
-
-	"github.com/danvergara/morphos/pkg/files/images"
 )
 
 // ImageFactory implements the FileFactory interface.
@@ -13,22 +11,10 @@ type ImageFactory struct{}
 // given an image format as input.
 // If not supported, it will error out.
 func (i *ImageFactory) NewFile(f string) (File, error) {
-	switch f {
-	case images.PNG:
-		return images.NewPng(), nil
-	case images.JPEG:
-		return images.NewJpeg(), nil
-	case images.GIF:
-		return images.NewGif(), nil
-	case images.WEBP:
-		return images.NewWebp(), nil
-	case images.TIFF:
-		return images.NewTiff(), nil
-	case images.BMP:
-		return images.NewBmp(), nil
-	case images.AVIF:
-		return images.NewAvif(), nil
-	default:
+	format, ok := Lookup(f)
+	if !ok || format.Category != Img {
 		return nil, fmt.Errorf("type file %s not recognized", f)
 	}
+
+	return format.Decoder(""), nil
 }
@@ -0,0 +1,323 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danvergara/morphos/pkg/files"
+	"github.com/danvergara/morphos/pkg/packaging"
+)
+
+const (
+	// AnimationOption sets what's done with the frames of animated images.
+	AnimationOption = "animation"
+
+	// AnimationKeep keeps every frame: animated formats stay animated,
+	// pdf files get a page per frame, and the rest get the first frame.
+	AnimationKeep = "keep"
+	// AnimationFirst converts the first frame only.
+	AnimationFirst = "first"
+	// AnimationFrames converts every frame to a file of its own, returned in a zip file.
+	AnimationFrames = "frames"
+
+	// maxAnimationPixels is the largest number of pixels of every frame put together,
+	// since every frame is kept in memory, composed on the whole canvas.
+	maxAnimationPixels = 100_000_000
+)
+
+// animatedFormats are the formats that can hold animations.
+var animatedFormats = []string{GIF, WEBP, PNG, AVIF}
+
+// animationOptions are the options accepted when converting from formats that can be animated.
+var animationOptions = files.Schema{
+	{
+		Name:    AnimationOption,
+		Label:   "Animation",
+		Help:    "Keep every frame, convert the first one only, or every frame to an image of its own",
+		Type:    files.ChoiceOption,
+		Default: AnimationKeep,
+		Choices: []string{AnimationKeep, AnimationFirst, AnimationFrames},
+	},
+}
+
+// animatedInputOptions are the options accepted when converting from formats that can be animated.
+var animatedInputOptions = inputOptions.Merge(animationOptions)
+
+// animation is a sequence of frames, alongside how long every one of them is shown.
+// The frames are composed already, so every one of them covers the whole canvas.
+type animation struct {
+	frames []*image.NRGBA
+	delays []time.Duration
+	// loops is the number of times the animation is played, zero plays it forever.
+	loops int
+}
+
+// add adds a copy of the canvas as the next frame.
+func (a *animation) add(canvas *image.NRGBA, delay time.Duration) error {
+	size := canvas.Bounds().Size()
+	if (len(a.frames)+1)*size.X*size.Y > maxAnimationPixels {
+		return fmt.Errorf("the animation has more than %d pixels, counting every frame", maxAnimationPixels)
+	}
+
+	frame := image.NewNRGBA(image.Rectangle{Max: size})
+	copy(frame.Pix, canvas.Pix)
+
+	a.frames = append(a.frames, frame)
+	a.delays = append(a.delays, delay)
+
+	return nil
+}
+
+// readAnimation returns the animation of an image of the given format,
+// or nil if the image holds a single frame.
+func readAnimation(ctx context.Context, format string, b []byte) (*animation, error) {
+	var (
+		anim *animation
+		err  error
+	)
+
+	switch format {
+	case GIF:
+		anim, err = decodeGIFAnimation(b)
+	case WEBP:
+		anim, err = decodeWebPAnimation(b)
+	case PNG:
+		anim, err = decodeAPNG(b)
+	case AVIF:
+		anim, err = decodeAVIFAnimation(ctx, b)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error decoding the animation: %w", err)
+	}
+
+	if anim == nil || len(anim.frames) < 2 {
+		return nil, nil
+	}
+
+	return anim, nil
+}
+
+// convertAnimation converts an animation to the target format, as the animation option says.
+// The geometry operations are applied to every frame.
+func convertAnimation(ctx context.Context, fileType, target string, anim *animation, m metadata, opts files.ConvertOptions) (io.Reader, error) {
+	mode := opts.String(AnimationOption, AnimationKeep)
+
+	switch {
+	case strings.ToLower(fileType) == documentType:
+		frames := anim.frames
+		if mode == AnimationFirst {
+			frames = frames[:1]
+		}
+
+		var pages []image.Image
+		for _, frame := range frames {
+			page, err := applyGeometry(frame, m, opts)
+			if err != nil {
+				return nil, err
+			}
+
+			pages = append(pages, page)
+		}
+
+		result, err := toDocument(ctx, target, pages, opts)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"ConvertTo: error at converting image to another format: %w",
+				err,
+			)
+		}
+
+		return bytes.NewReader(result), nil
+	case mode == AnimationFrames:
+		return explodeAnimation(ctx, target, anim, m, opts)
+	case mode == AnimationFirst || !slices.Contains(animatedFormats, target):
+		return convertDecoded(ctx, target, anim.frames[0], m, opts)
+	}
+
+	for i, frame := range anim.frames {
+		img, err := applyGeometry(frame, m, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		anim.frames[i] = toNRGBA(img)
+	}
+
+	var (
+		result []byte
+		err    error
+	)
+
+	switch target {
+	case GIF:
+		result, err = encodeGIFAnimation(anim, opts)
+	case WEBP:
+		result, err = encodeWebPAnimation(anim, opts)
+	case PNG:
+		result, err = encodeAPNG(anim, opts)
+	case AVIF:
+		result, err = encodeAVIFAnimation(ctx, anim, opts)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error encoding the animation: %w", err)
+	}
+
+	// The metadata isn't written to animations, but png files,
+	// which are the only ones that keep it.
+	if target == PNG {
+		if autoOrient(opts) {
+			m = m.oriented()
+		}
+
+		result = applyMetadataPolicy(result, PNG, opts.String(MetadataOption, MetadataStrip), m)
+	}
+
+	return bytes.NewReader(result), nil
+}
+
+// explodeAnimation converts every frame of the animation to the target format,
+// and returns them in a zip file, numbered from one.
+func explodeAnimation(ctx context.Context, target string, anim *animation, m metadata, opts files.ConvertOptions) (io.Reader, error) {
+	digits := max(3, len(strconv.Itoa(len(anim.frames))))
+
+	var frames []packaging.File
+
+	for i, frame := range anim.frames {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		result, err := convertDecoded(ctx, target, frame, m, opts)
+		if err != nil {
+			return nil, fmt.Errorf("error converting the frame %d: %w", i+1, err)
+		}
+
+		content, err := io.ReadAll(result)
+		if err != nil {
+			return nil, err
+		}
+
+		frames = append(frames, packaging.File{
+			Name:    fmt.Sprintf("frame_%0*d.%s", digits, i+1, target),
+			Content: content,
+		})
+	}
+
+	archive, err := packaging.Pack(packaging.Zip, packaging.DefaultCompression, frames...)
+	if err != nil {
+		return nil, fmt.Errorf("error packaging the frames: %w", err)
+	}
+
+	return bytes.NewReader(archive), nil
+}
+
+// toNRGBA returns the image as an NRGBA image, with its origin at zero.
+func toNRGBA(img image.Image) *image.NRGBA {
+	if nrgba, ok := img.(*image.NRGBA); ok && nrgba.Bounds().Min == (image.Point{}) {
+		return nrgba
+	}
+
+	nrgba := image.NewNRGBA(image.Rectangle{Max: img.Bounds().Size()})
+	draw.Draw(nrgba, nrgba.Bounds(), img, img.Bounds().Min, draw.Src)
+
+	return nrgba
+}
+
+// decodeGIFAnimation decodes every frame of a gif image.
+// Frames only cover the part of the canvas that changes, so they are
+// drawn over the previous ones, and disposed as they say afterwards.
+func decodeGIFAnimation(b []byte) (*animation, error) {
+	g, err := gif.DecodeAll(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(g.Image) < 2 {
+		return nil, nil
+	}
+
+	anim := &animation{}
+
+	// Gif images are played once more than their loop count, or once if it's negative.
+	switch {
+	case g.LoopCount > 0:
+		anim.loops = g.LoopCount + 1
+	case g.LoopCount < 0:
+		anim.loops = 1
+	}
+
+	canvas := image.NewNRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+
+	for i, frame := range g.Image {
+		var previous *image.NRGBA
+		if g.Disposal[i] == gif.DisposalPrevious {
+			previous = image.NewNRGBA(canvas.Bounds())
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		// Delays are in hundredths of a second.
+		if err := anim.add(canvas, time.Duration(g.Delay[i])*10*time.Millisecond); err != nil {
+			return nil, err
+		}
+
+		switch g.Disposal[i] {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	return anim, nil
+}
+
+// encodeGIFAnimation encodes the animation as a gif image,
+// with a palette of its own for every frame.
+func encodeGIFAnimation(anim *animation, opts files.ConvertOptions) ([]byte, error) {
+	g := &gif.GIF{}
+
+	switch anim.loops {
+	case 0:
+		g.LoopCount = 0
+	case 1:
+		g.LoopCount = -1
+	default:
+		g.LoopCount = anim.loops - 1
+	}
+
+	colors := opts.Int(ColorsOption, maxColors)
+
+	for i, frame := range anim.frames {
+		palette := medianCut{}.Quantize(make(color.Palette, 0, colors), frame)
+
+		paletted := image.NewPaletted(frame.Bounds(), palette)
+		draw.FloydSteinberg.Draw(paletted, frame.Bounds(), frame, image.Point{})
+
+		g.Image = append(g.Image, paletted)
+		// Delays are rounded to hundredths of a second.
+		g.Delay = append(g.Delay, int((anim.delays[i]+5*time.Millisecond)/(10*time.Millisecond)))
+		// Frames cover the whole canvas, so the previous ones are left as they are.
+		g.Disposal = append(g.Disposal, gif.DisposalNone)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := gif.EncodeAll(buf, g); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
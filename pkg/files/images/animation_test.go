@@ -0,0 +1,161 @@
+package images_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"image/gif"
+	"image/png"
+	"io"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/gen2brain/go-fitz"
+	"github.com/stretchr/testify/require"
+
+	"github.com/danvergara/morphos/pkg/files"
+	"github.com/danvergara/morphos/pkg/files/images"
+)
+
+// convertAnimation converts the animated image to the target format, with the given input options.
+func convertAnimation(t *testing.T, source files.File, format, fileType, target string, input []byte, values url.Values) []byte {
+	f, ok := files.Lookup(format)
+	require.True(t, ok)
+
+	opts, err := f.InputOptions.Parse(values)
+	require.NoError(t, err)
+
+	result, err := source.ConvertTo(context.Background(), fileType, target, bytes.NewReader(input), opts)
+	require.NoError(t, err)
+
+	resultBytes, err := io.ReadAll(result)
+	require.NoError(t, err)
+
+	return resultBytes
+}
+
+func TestConvertAnimation(t *testing.T) {
+	input, err := os.ReadFile("testdata/dancing-gopher.gif")
+	require.NoError(t, err)
+
+	original, err := gif.DecodeAll(bytes.NewReader(input))
+	require.NoError(t, err)
+
+	var tests = []struct {
+		name   string
+		target string
+		// back converts the animation back to gif.
+		back      files.File
+		inspector files.Inspector
+	}{
+		{
+			name:      "gif to animated webp",
+			target:    images.WEBP,
+			back:      images.NewWebp(),
+			inspector: images.NewWebp(),
+		},
+		{
+			name:      "gif to animated png",
+			target:    images.PNG,
+			back:      images.NewPng(),
+			inspector: images.NewPng(),
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			animated := convertAnimation(t, images.NewGif(), images.GIF, "Image", tc.target, input, url.Values{})
+
+			info, err := tc.inspector.Inspect(context.Background(), bytes.NewReader(animated))
+			require.NoError(t, err)
+			require.Equal(t, len(original.Image), info.(images.ImageInfo).Frames)
+			require.Equal(t, 192, info.(images.ImageInfo).Width)
+
+			// The frames and their timing survive the way back.
+			result := convertAnimation(t, tc.back, tc.target, "Image", images.GIF, animated, url.Values{})
+
+			g, err := gif.DecodeAll(bytes.NewReader(result))
+			require.NoError(t, err)
+			require.Len(t, g.Image, len(original.Image))
+			require.Equal(t, original.Delay, g.Delay)
+			require.Equal(t, original.LoopCount, g.LoopCount)
+		})
+	}
+}
+
+func TestConvertAnimationFirstFrame(t *testing.T) {
+	input, err := os.ReadFile("testdata/dancing-gopher.gif")
+	require.NoError(t, err)
+
+	result := convertAnimation(t, images.NewGif(), images.GIF, "Image", images.WEBP, input, url.Values{"animation": {"first"}})
+
+	info, err := images.NewWebp().Inspect(context.Background(), bytes.NewReader(result))
+	require.NoError(t, err)
+	require.Equal(t, 1, info.(images.ImageInfo).Frames)
+}
+
+func TestConvertAnimationToFrames(t *testing.T) {
+	input, err := os.ReadFile("testdata/dancing-gopher.gif")
+	require.NoError(t, err)
+
+	result := convertAnimation(t, images.NewGif(), images.GIF, "Image", images.PNG, input, url.Values{
+		"animation": {"frames"},
+		"width":     {"96"},
+	})
+
+	zipReader, err := zip.NewReader(bytes.NewReader(result), int64(len(result)))
+	require.NoError(t, err)
+	require.Len(t, zipReader.File, 24)
+
+	for i, f := range zipReader.File {
+		require.Equal(t, fmt.Sprintf("frame_%03d.png", i+1), f.Name)
+
+		rc, err := f.Open()
+		require.NoError(t, err)
+
+		// Every frame covers the whole canvas, resized.
+		config, err := png.DecodeConfig(rc)
+		require.NoError(t, err)
+		require.Equal(t, 96, config.Width, f.Name)
+		require.Equal(t, 96, config.Height, f.Name)
+		require.NoError(t, rc.Close())
+	}
+}
+
+func TestConvertAnimationToPDF(t *testing.T) {
+	input, err := os.ReadFile("testdata/dancing-gopher.gif")
+	require.NoError(t, err)
+
+	var tests = []struct {
+		name     string
+		values   url.Values
+		expected int
+	}{
+		{
+			name:     "a page per frame",
+			values:   url.Values{},
+			expected: 24,
+		},
+		{
+			name:     "first frame",
+			values:   url.Values{"animation": {"first"}},
+			expected: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			pdfBytes := convertAnimation(t, images.NewGif(), images.GIF, "Document", images.PDF, input, tc.values)
+
+			doc, err := fitz.NewFromMemory(pdfBytes)
+			require.NoError(t, err)
+			defer doc.Close()
+
+			require.Equal(t, tc.expected, doc.NumPage())
+		})
+	}
+}
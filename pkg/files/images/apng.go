@@ -0,0 +1,340 @@
+package images
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"time"
+
+	"github.com/danvergara/morphos/pkg/files"
+)
+
+const (
+	// apngDisposeBackground and apngDisposePrevious are the dispose operations of the frames
+	// of animated png images, which clear the frame once it's shown, or restore what was under it.
+	apngDisposeBackground = 1
+	apngDisposePrevious   = 2
+
+	// apngBlendOver is the blend operation of the frames drawn over the canvas,
+	// rather than replacing it.
+	apngBlendOver = 1
+)
+
+// apngFrame is a frame of an animated png image, as its fcTL chunk describes it.
+type apngFrame struct {
+	bounds  image.Rectangle
+	delay   time.Duration
+	dispose byte
+	blend   byte
+	data    []byte
+}
+
+// decodeAPNG decodes every frame of an animated png image, or returns nil if it's a still one.
+// The png package only decodes the default image, so every frame is wrapped
+// in a png file of its own, with the header and the palette of the image.
+func decodeAPNG(b []byte) (*animation, error) {
+	if !bytes.HasPrefix(b, pngHeader) {
+		return nil, nil
+	}
+
+	var (
+		ihdr, actl []byte
+		// shared are the chunks every frame needs to be decoded.
+		shared = new(bytes.Buffer)
+		frames []*apngFrame
+	)
+
+	for _, c := range pngChunks(b) {
+		data := b[c.start+8 : c.end-4]
+
+		switch c.kind {
+		case "IHDR":
+			ihdr = data
+		case "acTL":
+			actl = data
+		case "PLTE", "tRNS":
+			shared.Write(b[c.start:c.end])
+		case "fcTL":
+			if len(data) < 26 {
+				return nil, fmt.Errorf("the fcTL chunk is too short")
+			}
+
+			x, y := int(binary.BigEndian.Uint32(data[12:])), int(binary.BigEndian.Uint32(data[16:]))
+			w, h := int(binary.BigEndian.Uint32(data[4:])), int(binary.BigEndian.Uint32(data[8:]))
+
+			// The delay is a fraction of a second, whose denominator defaults to 100.
+			num, den := binary.BigEndian.Uint16(data[20:]), binary.BigEndian.Uint16(data[22:])
+			if den == 0 {
+				den = 100
+			}
+
+			frames = append(frames, &apngFrame{
+				bounds:  image.Rect(x, y, x+w, y+h),
+				delay:   time.Duration(num) * time.Second / time.Duration(den),
+				dispose: data[24],
+				blend:   data[25],
+			})
+		case "IDAT":
+			// The default image is only a frame of the animation if a fcTL chunk goes before it.
+			if len(frames) > 0 {
+				frames[len(frames)-1].data = append(frames[len(frames)-1].data, data...)
+			}
+		case "fdAT":
+			// The frame data follows its sequence number.
+			if len(frames) > 0 && len(data) >= 4 {
+				frames[len(frames)-1].data = append(frames[len(frames)-1].data, data[4:]...)
+			}
+		}
+	}
+
+	if actl == nil || len(ihdr) < 13 || len(frames) < 2 {
+		return nil, nil
+	}
+
+	width, height := int(binary.BigEndian.Uint32(ihdr)), int(binary.BigEndian.Uint32(ihdr[4:]))
+	if width*height > maxAnimationPixels {
+		return nil, fmt.Errorf("the canvas has more than %d pixels", maxAnimationPixels)
+	}
+
+	anim := &animation{}
+	if len(actl) >= 8 {
+		anim.loops = int(binary.BigEndian.Uint32(actl[4:]))
+	}
+
+	canvas := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	for i, f := range frames {
+		if !f.bounds.In(canvas.Bounds()) || f.bounds.Empty() {
+			return nil, fmt.Errorf("the frame %d is out of the canvas", i+1)
+		}
+
+		header := bytes.Clone(ihdr)
+		binary.BigEndian.PutUint32(header, uint32(f.bounds.Dx()))
+		binary.BigEndian.PutUint32(header[4:], uint32(f.bounds.Dy()))
+
+		frameFile := bytes.NewBuffer(bytes.Clone(pngHeader))
+		writePNGChunk(frameFile, "IHDR", header)
+		frameFile.Write(shared.Bytes())
+		writePNGChunk(frameFile, "IDAT", f.data)
+		writePNGChunk(frameFile, "IEND", nil)
+
+		frame, err := png.Decode(frameFile)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding the frame %d: %w", i+1, err)
+		}
+
+		var previous *image.NRGBA
+		if f.dispose == apngDisposePrevious {
+			previous = image.NewNRGBA(canvas.Bounds())
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		op := draw.Src
+		if f.blend == apngBlendOver {
+			op = draw.Over
+		}
+
+		draw.Draw(canvas, f.bounds, frame, frame.Bounds().Min, op)
+
+		if err := anim.add(canvas, f.delay); err != nil {
+			return nil, err
+		}
+
+		switch {
+		// The first frame is cleared, rather than restored, since there's nothing under it.
+		case f.dispose == apngDisposeBackground, f.dispose == apngDisposePrevious && i == 0:
+			draw.Draw(canvas, f.bounds, image.Transparent, image.Point{}, draw.Src)
+		case f.dispose == apngDisposePrevious:
+			canvas = previous
+		}
+	}
+
+	return anim, nil
+}
+
+// encodeAPNG encodes the animation as an animated png image, whose frames
+// cover the whole canvas. The png package can't write animations, and picks
+// the color type of every image on its own, so the frames are written here,
+// as 8 bits RGBA pixels, filtered like the png package does.
+func encodeAPNG(anim *animation, opts files.ConvertOptions) ([]byte, error) {
+	size := anim.frames[0].Bounds().Size()
+
+	out := bytes.NewBuffer(bytes.Clone(pngHeader))
+
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr, uint32(size.X))
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(size.Y))
+	// 8 bits per sample, truecolor with alpha.
+	ihdr[8], ihdr[9] = 8, 6
+	writePNGChunk(out, "IHDR", ihdr)
+
+	actl := make([]byte, 8)
+	binary.BigEndian.PutUint32(actl, uint32(len(anim.frames)))
+	binary.BigEndian.PutUint32(actl[4:], uint32(anim.loops))
+	writePNGChunk(out, "acTL", actl)
+
+	level := apngCompressionLevel(opts)
+
+	// The fcTL and fdAT chunks share a sequence number.
+	var seq uint32
+
+	for i, frame := range anim.frames {
+		num, den := apngDelay(anim.delays[i])
+
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl, seq)
+		binary.BigEndian.PutUint32(fctl[4:], uint32(size.X))
+		binary.BigEndian.PutUint32(fctl[8:], uint32(size.Y))
+		binary.BigEndian.PutUint16(fctl[20:], num)
+		binary.BigEndian.PutUint16(fctl[22:], den)
+		writePNGChunk(out, "fcTL", fctl)
+		seq++
+
+		data, err := compressPNGPixels(frame, level)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding the frame %d: %w", i+1, err)
+		}
+
+		// The first frame is the default image as well.
+		if i == 0 {
+			writePNGChunk(out, "IDAT", data)
+			continue
+		}
+
+		fdat := binary.BigEndian.AppendUint32(make([]byte, 0, 4+len(data)), seq)
+		writePNGChunk(out, "fdAT", append(fdat, data...))
+		seq++
+	}
+
+	writePNGChunk(out, "IEND", nil)
+
+	return out.Bytes(), nil
+}
+
+// apngDelay returns the delay as a fraction of a second, in milliseconds,
+// or in hundredths of a second if it doesn't fit in 16 bits.
+func apngDelay(d time.Duration) (uint16, uint16) {
+	if ms := d / time.Millisecond; ms <= 0xffff {
+		return uint16(ms), 1000
+	}
+
+	return uint16(min(d/(10*time.Millisecond), 0xffff)), 100
+}
+
+// apngCompressionLevel maps the compression level of the png encoder to the one of zlib.
+func apngCompressionLevel(opts files.ConvertOptions) int {
+	switch pngCompressionLevel(opts) {
+	case png.NoCompression:
+		return zlib.NoCompression
+	case png.BestSpeed:
+		return zlib.BestSpeed
+	case png.BestCompression:
+		return zlib.BestCompression
+	default:
+		return zlib.DefaultCompression
+	}
+}
+
+// compressPNGPixels returns the pixels of the image as the zlib stream of a png image,
+// every row preceded by the filter that makes it the smallest.
+func compressPNGPixels(img *image.NRGBA, level int) ([]byte, error) {
+	buf := new(bytes.Buffer)
+
+	zw, err := zlib.NewWriterLevel(buf, level)
+	if err != nil {
+		return nil, err
+	}
+
+	b := img.Bounds()
+	rowLen := b.Dx() * 4
+
+	prev := make([]byte, rowLen)
+	filtered := make([][]byte, 5)
+	for i := range filtered {
+		filtered[i] = make([]byte, 1+rowLen)
+		filtered[i][0] = byte(i)
+	}
+
+	for y := 0; y < b.Dy(); y++ {
+		row := img.Pix[y*img.Stride : y*img.Stride+rowLen]
+		best := filtered[filterPNGRow(row, prev, filtered, level == zlib.NoCompression)]
+
+		if _, err := zw.Write(best); err != nil {
+			return nil, err
+		}
+
+		prev = row
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// filterPNGRow applies every png filter to the row, and returns the one whose sum
+// of absolute values is the smallest, the heuristic the png package uses.
+// Rows aren't filtered if the image isn't compressed.
+func filterPNGRow(row, prev []byte, filtered [][]byte, none bool) int {
+	const bpp = 4
+
+	copy(filtered[0][1:], row)
+	if none {
+		return 0
+	}
+
+	for i := range row {
+		var left, upLeft byte
+		if i >= bpp {
+			left, upLeft = row[i-bpp], prev[i-bpp]
+		}
+		up := prev[i]
+
+		filtered[1][1+i] = row[i] - left
+		filtered[2][1+i] = row[i] - up
+		filtered[3][1+i] = row[i] - byte((int(left)+int(up))/2)
+		filtered[4][1+i] = row[i] - paeth(left, up, upLeft)
+	}
+
+	best, bestSum := 0, -1
+	for f := range filtered {
+		sum := 0
+		for _, v := range filtered[f][1:] {
+			sum += abs(int(int8(v)))
+		}
+
+		if bestSum < 0 || sum < bestSum {
+			best, bestSum = f, sum
+		}
+	}
+
+	return best
+}
+
+// paeth is the predictor of the Paeth filter of png images.
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	default:
+		return c
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+
+	return v
+}
@@ -1,10 +1,19 @@
 package images
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"image/png"
 	"io"
+	"os"
 	"slices"
 	"strings"
+
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+
+	"github.com/danvergara/morphos/pkg/files"
+	"github.com/danvergara/morphos/pkg/util"
 )
 
 // Avif struct implements the File and Image interface from the files pkg.
@@ -13,6 +22,18 @@ type Avif struct {
 	compatibleMIMETypes map[string][]string
 }
 
+func init() {
+	files.Register(files.Format{
+		Name:          AVIF,
+		Category:      files.Img,
+		MIMETypes:     []string{"image/avif"},
+		Decoder:       func(string) files.File { return NewAvif() },
+		InputOptions:  animatedInputOptions,
+		OutputOptions: avifOutputOptions,
+		Cost:          2,
+	})
+}
+
 // NewAvif returns a pointer to a Avif instance.
 // The Avif object is set with a map with list of supported file formats.
 func NewAvif() *Avif {
@@ -26,6 +47,7 @@ func NewAvif() *Avif {
 				WEBP,
 				TIFF,
 				BMP,
+				JXL,
 			},
 		},
 
@@ -38,6 +60,7 @@ func NewAvif() *Avif {
 				WEBP,
 				TIFF,
 				BMP,
+				JXL,
 			},
 		},
 	}
@@ -58,7 +81,7 @@ func (a *Avif) SupportedMIMETypes() map[string][]string {
 
 // ConvertTo method converts a given file to a target format.
 // This method returns a file in form of a slice of bytes.
-func (a *Avif) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, error) {
+func (a *Avif) ConvertTo(ctx context.Context, fileType, subType string, file io.Reader, opts files.ConvertOptions) (io.Reader, error) {
 	compatibleFormats, ok := a.SupportedFormats()[fileType]
 	if !ok {
 		return nil, fmt.Errorf("ConvertTo: file type not supported: %s", fileType)
@@ -68,9 +91,26 @@ func (a *Avif) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, e
 		return nil, fmt.Errorf("ConvertTo: file sub-type not supported: %s", subType)
 	}
 
+	fileBytes, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading from the image file: %w", err)
+	}
+
+	// Animated images are converted frame by frame.
+	anim, err := readAnimation(ctx, AVIF, fileBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	if anim != nil {
+		return convertAnimation(ctx, fileType, subType, anim, readMetadata(fileBytes), opts)
+	}
+
+	file = bytes.NewReader(fileBytes)
+
 	switch strings.ToLower(fileType) {
 	case imageType:
-		convertedImage, err := convertToImage(subType, file)
+		convertedImage, err := convertToImage(ctx, subType, file, nil, opts)
 		if err != nil {
 			return nil, err
 		}
@@ -81,8 +121,96 @@ func (a *Avif) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, e
 	}
 }
 
+// Inspect describes the image.
+// This method implements the files.Inspector interface.
+func (a *Avif) Inspect(_ context.Context, file io.Reader) (any, error) {
+	return readAVIFInfo(file)
+}
+
 // ImageType returns the file format of the current image.
 // This method implements the Image interface.
 func (a *Avif) ImageType() string {
 	return AVIF
 }
+
+// isAVIFSequence tells if the avif file is an image sequence, rather than a still image,
+// by the brands of its ftyp box.
+func isAVIFSequence(b []byte) bool {
+	boxes := heifBoxes(b)
+	if len(boxes) == 0 || boxes[0].kind != "ftyp" || len(boxes[0].data) < 8 {
+		return false
+	}
+
+	// The major brand is followed by the minor version and the compatible brands.
+	brands := append(bytes.Clone(boxes[0].data[:4]), boxes[0].data[8:]...)
+	for i := 0; i+4 <= len(brands); i += 4 {
+		if string(brands[i:i+4]) == "avis" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// decodeAVIFAnimation decodes every frame of an avif image sequence, or returns nil if it's a still image.
+// ffmpeg turns the sequence into an animated png image, which keeps the delay of every frame.
+func decodeAVIFAnimation(ctx context.Context, b []byte) (*animation, error) {
+	// The go backend can't decode avif images, which is told by the conversion itself.
+	if !isAVIFSequence(b) || CurrentBackend() == BackendGo {
+		return nil, nil
+	}
+
+	apng, err := runFFmpegAnimation(ctx, b, AVIF, "apng", ffmpeg.KwArgs{"f": "apng", "plays": 0, "pix_fmt": "rgba"})
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeAPNG(apng)
+}
+
+// encodeAVIFAnimation encodes the animation as an avif image sequence,
+// handing ffmpeg an animated png image.
+func encodeAVIFAnimation(ctx context.Context, anim *animation, opts files.ConvertOptions) ([]byte, error) {
+	if _, err := useGo(AVIF, png.Decode); err != nil {
+		return nil, err
+	}
+
+	apng, err := encodeAPNG(anim, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return runFFmpegAnimation(ctx, apng, "apng", AVIF, ffmpegOutputArgs(AVIF, opts))
+}
+
+// runFFmpegAnimation converts an animation from a format to another with ffmpeg,
+// through temporary files named after the formats.
+func runFFmpegAnimation(ctx context.Context, input []byte, from, to string, outputArgs ffmpeg.KwArgs) ([]byte, error) {
+	tmpInput, err := os.CreateTemp("/tmp", fmt.Sprintf("*.%s", from))
+	if err != nil {
+		return nil, fmt.Errorf("error creating temporary image file: %w", err)
+	}
+	defer os.Remove(tmpInput.Name())
+
+	if _, err := tmpInput.Write(input); err != nil {
+		tmpInput.Close()
+		return nil, fmt.Errorf("error writting the input reader to the temporary image file: %w", err)
+	}
+	tmpInput.Close()
+
+	tmpOutput, cleanup, err := tempOutput(to)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	args := ffmpeg.Input(tmpInput.Name()).
+		Output(tmpOutput, outputArgs).
+		OverWriteOutput().GetArgs()
+
+	if err := util.RunCommand(ctx, util.FFmpeg, os.Stdout, os.Stdout, "ffmpeg", args...); err != nil {
+		return nil, err
+	}
+
+	return os.ReadFile(tmpOutput)
+}
@@ -0,0 +1,149 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"slices"
+	"sync"
+
+	"github.com/danvergara/morphos/pkg/files"
+)
+
+// Backend is the way images are converted to other image formats.
+type Backend string
+
+const (
+	// BackendAuto converts images in process when Go can decode the input
+	// and encode the output, and falls back to ffmpeg otherwise, e.g. for avif.
+	BackendAuto Backend = "auto"
+	// BackendGo converts images in process only, formats Go can't handle are rejected.
+	BackendGo Backend = "go"
+	// BackendFFmpeg converts every image with ffmpeg.
+	BackendFFmpeg Backend = "ffmpeg"
+)
+
+var (
+	backendMu sync.RWMutex
+	// backend is the backend used to convert images.
+	backend = BackendAuto
+)
+
+// goFormats are the formats Go can both decode and encode.
+var goFormats = []string{PNG, JPEG, JPG, GIF, BMP, TIFF, WEBP}
+
+// SetBackend sets the backend used to convert images to other image formats.
+func SetBackend(b Backend) error {
+	switch b {
+	case BackendAuto, BackendGo, BackendFFmpeg:
+	default:
+		return fmt.Errorf("unknown image backend %q, it must be one of %s, %s or %s", b, BackendAuto, BackendGo, BackendFFmpeg)
+	}
+
+	backendMu.Lock()
+	defer backendMu.Unlock()
+
+	backend = b
+
+	return nil
+}
+
+// CurrentBackend returns the backend used to convert images to other image formats.
+func CurrentBackend() Backend {
+	backendMu.RLock()
+	defer backendMu.RUnlock()
+
+	return backend
+}
+
+// useGo tells if the image is converted in process. decode is nil
+// if Go can't decode the input image.
+func useGo(target string, decode func(io.Reader) (image.Image, error)) (bool, error) {
+	// JPEG XL images are encoded by libjxl, whatever the backend.
+	if target == JXL {
+		return false, nil
+	}
+
+	switch CurrentBackend() {
+	case BackendFFmpeg:
+		return false, nil
+	case BackendGo:
+		if decode == nil {
+			return false, fmt.Errorf("decoding this image requires ffmpeg, which the %s image backend doesn't use", BackendGo)
+		}
+
+		if !slices.Contains(goFormats, target) {
+			return false, fmt.Errorf("encoding %s images requires ffmpeg, which the %s image backend doesn't use", target, BackendGo)
+		}
+	}
+
+	return decode != nil && slices.Contains(goFormats, target), nil
+}
+
+// encodeImage converts the image to the target format in process.
+func encodeImage(target string, fileBytes []byte, decode func(io.Reader) (image.Image, error), opts files.ConvertOptions) ([]byte, error) {
+	img, err := decode(bytes.NewReader(fileBytes))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding the image: %w", err)
+	}
+
+	return transcodeImage(target, img, readMetadata(fileBytes), opts)
+}
+
+// transcodeImage encodes the decoded image in the target format, alongside its metadata.
+// It's the counterpart of the ffmpeg conversion, so both of them
+// turn the image upright, apply the geometry operations and
+// write the metadata the same way.
+func transcodeImage(target string, img image.Image, m metadata, opts files.ConvertOptions) ([]byte, error) {
+	g, err := geometryFromOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if autoOrient(opts) {
+		g.orientation = m.orientation()
+		m = m.oriented()
+	}
+
+	img, err = g.Apply(img)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	if err := Encode(buf, target, img, opts); err != nil {
+		return nil, fmt.Errorf("error encoding the image: %w", err)
+	}
+
+	return applyMetadataPolicy(buf.Bytes(), target, opts.String(MetadataOption, MetadataStrip), m), nil
+}
+
+// convertDecoded converts an image decoded by its own format, rather than by
+// a decoder of the image package, e.g. a heic image, to the target format.
+// It's encoded in process, unless the backend says otherwise.
+func convertDecoded(ctx context.Context, target string, img image.Image, m metadata, opts files.ConvertOptions) (io.Reader, error) {
+	inProcess, err := useGo(target, png.Decode)
+	if err != nil {
+		return nil, err
+	}
+
+	if inProcess {
+		result, err := transcodeImage(target, img, m, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		return bytes.NewReader(result), nil
+	}
+
+	// ffmpeg reads the decoded image as a lossless png, alongside its metadata.
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		return nil, fmt.Errorf("error encoding the decoded image: %w", err)
+	}
+
+	return convertToImage(ctx, target, bytes.NewReader(writePNGMetadata(buf.Bytes(), m)), png.Decode, opts)
+}
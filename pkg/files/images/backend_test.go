@@ -0,0 +1,127 @@
+package images_test
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"io"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/danvergara/morphos/pkg/files"
+	"github.com/danvergara/morphos/pkg/files/images"
+)
+
+func TestBackend(t *testing.T) {
+	t.Cleanup(func() {
+		require.NoError(t, images.SetBackend(images.BackendAuto))
+	})
+
+	readFile := func(name string) func(t *testing.T) []byte {
+		return func(t *testing.T) []byte {
+			fileBytes, err := os.ReadFile(name)
+			require.NoError(t, err)
+			return fileBytes
+		}
+	}
+
+	var tests = []struct {
+		name    string
+		backend images.Backend
+		file    files.File
+		source  string
+		target  string
+		input   func(t *testing.T) []byte
+		// expected is the size of the output image, if the conversion succeeds.
+		expected image.Point
+		wantErr  bool
+	}{
+		{
+			name:     "webp to tiff",
+			backend:  images.BackendAuto,
+			file:     images.NewWebp(),
+			source:   images.WEBP,
+			target:   images.TIFF,
+			input:    readFile("testdata/gopher.webp"),
+			expected: image.Pt(960, 960),
+		},
+		{
+			name:     "png to gif",
+			backend:  images.BackendGo,
+			file:     images.NewPng(),
+			source:   images.PNG,
+			target:   images.GIF,
+			input:    readFile("testdata/gopher_pirate.png"),
+			expected: image.Pt(1300, 1392),
+		},
+		{
+			name:     "oriented jpeg to png",
+			backend:  images.BackendGo,
+			file:     images.NewJpeg(),
+			source:   images.JPEG,
+			target:   images.PNG,
+			input:    func(t *testing.T) []byte { return orientedJPEG(t, 6) },
+			expected: image.Pt(20, 40),
+		},
+		{
+			name:    "png to avif",
+			backend: images.BackendGo,
+			file:    images.NewPng(),
+			source:  images.PNG,
+			target:  images.AVIF,
+			input:   readFile("testdata/gopher_pirate.png"),
+			wantErr: true,
+		},
+		{
+			name:    "avif to jpeg",
+			backend: images.BackendGo,
+			file:    images.NewAvif(),
+			source:  images.AVIF,
+			target:  images.JPEG,
+			input:   readFile("testdata/fox.avif"),
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require.NoError(t, images.SetBackend(tc.backend))
+
+			schema, err := files.ConversionOptions(tc.source, tc.target)
+			require.NoError(t, err)
+
+			opts, err := schema.Parse(url.Values{})
+			require.NoError(t, err)
+
+			result, err := tc.file.ConvertTo(
+				context.Background(),
+				"Image",
+				tc.target,
+				bytes.NewReader(tc.input(t)),
+				opts,
+			)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			resultBytes, err := io.ReadAll(result)
+			require.NoError(t, err)
+
+			config, format, err := image.DecodeConfig(bytes.NewReader(resultBytes))
+			require.NoError(t, err)
+			require.Equal(t, tc.target, format)
+			require.Equal(t, tc.expected, image.Pt(config.Width, config.Height))
+		})
+	}
+}
+
+func TestSetBackend(t *testing.T) {
+	require.Error(t, images.SetBackend("imagemagick"))
+	require.Equal(t, images.BackendAuto, images.CurrentBackend())
+}
@@ -2,12 +2,15 @@ package images
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"slices"
 	"strings"
 
 	"golang.org/x/image/bmp"
+
+	"github.com/danvergara/morphos/pkg/files"
 )
 
 // Bmp struct implements the File and Image interface from the files pkg.
@@ -16,6 +19,17 @@ type Bmp struct {
 	compatibleMIMETypes map[string][]string
 }
 
+func init() {
+	files.Register(files.Format{
+		Name:          BMP,
+		Category:      files.Img,
+		MIMETypes:     []string{"image/bmp", "image/x-bmp", "image/x-ms-bmp"},
+		Decoder:       func(string) files.File { return NewBmp() },
+		InputOptions:  inputOptions,
+		OutputOptions: bmpOutputOptions,
+	})
+}
+
 // NewBmp returns a pointer to a Bmp instance.
 // The Bmp object is set with a map with list of supported file formats.
 func NewBmp() *Bmp {
@@ -29,9 +43,13 @@ func NewBmp() *Bmp {
 				GIF,
 				TIFF,
 				WEBP,
+				JXL,
 			},
 			"Document": {
 				PDF,
+				TXT,
+				HOCR,
+				OCRPDF,
 			},
 		},
 		compatibleMIMETypes: map[string][]string{
@@ -43,9 +61,13 @@ func NewBmp() *Bmp {
 				GIF,
 				TIFF,
 				WEBP,
+				JXL,
 			},
 			"Document": {
 				PDF,
+				TXT,
+				HOCR,
+				OCRPDF,
 			},
 		},
 	}
@@ -67,7 +89,7 @@ func (b *Bmp) SupportedMIMETypes() map[string][]string {
 // ConvertTo method converts a given file to a target format.
 // This method returns a file in form of a slice of bytes.
 // The methd receives a file type and the sub-type of the target format and the file as array of bytes.
-func (b *Bmp) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, error) {
+func (b *Bmp) ConvertTo(ctx context.Context, fileType, subType string, file io.Reader, opts files.ConvertOptions) (io.Reader, error) {
 	var result []byte
 
 	compatibleFormats, ok := b.SupportedFormats()[fileType]
@@ -81,19 +103,19 @@ func (b *Bmp) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, er
 
 	switch strings.ToLower(fileType) {
 	case imageType:
-		convertedImage, err := convertToImage(subType, file)
+		convertedImage, err := convertToImage(ctx, subType, file, bmp.Decode, opts)
 		if err != nil {
 			return nil, err
 		}
 
 		return convertedImage, nil
 	case documentType:
-		img, err := bmp.Decode(file)
+		img, m, err := decodeImage(file, bmp.Decode)
 		if err != nil {
 			return nil, err
 		}
 
-		result, err = convertToDocument(subType, img)
+		result, err = convertToDocument(ctx, subType, img, m, opts)
 		if err != nil {
 			return nil, fmt.Errorf(
 				"ConvertTo: error at converting image to another format: %w",
@@ -105,6 +127,13 @@ func (b *Bmp) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, er
 	return bytes.NewReader(result), nil
 }
 
+// Inspect describes the image.
+// This method implements the files.Inspector interface.
+func (b *Bmp) Inspect(_ context.Context, file io.Reader) (any, error) {
+	info, _, err := readInfo(file, bmp.DecodeConfig)
+	return info, err
+}
+
 // ImageType returns the file format of the current image.
 // This method implements the Image interface.
 func (b *Bmp) ImageType() string {
@@ -0,0 +1,333 @@
+package images
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"slices"
+
+	"github.com/chai2010/webp"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+
+	"github.com/danvergara/morphos/pkg/files"
+)
+
+// Encode encodes the image in the target format, with the encoding options
+// of the conversion, the same ones passed to ffmpeg.
+func Encode(w io.Writer, target string, img image.Image, opts files.ConvertOptions) error {
+	switch target {
+	case PNG:
+		encoder := png.Encoder{CompressionLevel: pngCompressionLevel(opts)}
+		return encoder.Encode(w, img)
+	case JPG, JPEG:
+		return jpeg.Encode(w, img, &jpeg.Options{
+			Quality: opts.Int(QualityOption, defaultJPEGQuality),
+		})
+	case GIF:
+		return gif.Encode(w, img, &gif.Options{
+			NumColors: opts.Int(ColorsOption, maxColors),
+			Quantizer: medianCut{},
+		})
+	case WEBP:
+		return webp.Encode(w, img, &webp.Options{
+			Lossless: opts.Bool(LosslessOption),
+			Quality:  float32(opts.Int(QualityOption, defaultWebPQuality)),
+		})
+	case TIFF:
+		switch opts.String(TIFFCompressionOption, TIFFLZW) {
+		case TIFFNone:
+			return tiff.Encode(w, img, nil)
+		case TIFFDeflate:
+			return tiff.Encode(w, img, &tiff.Options{Compression: tiff.Deflate})
+		default:
+			return encodeLZWTiff(w, img)
+		}
+	case BMP:
+		return bmp.Encode(w, img)
+	}
+
+	return fmt.Errorf("encoding %s images is not supported", target)
+}
+
+// pngCompressionLevel maps the compression level, from 0 to 9,
+// to the levels of the png encoder.
+func pngCompressionLevel(opts files.ConvertOptions) png.CompressionLevel {
+	if !opts.Has(PNGCompressionOption) {
+		return png.DefaultCompression
+	}
+
+	switch level := opts.Int(PNGCompressionOption, 0); {
+	case level == 0:
+		return png.NoCompression
+	case level <= 3:
+		return png.BestSpeed
+	case level <= 6:
+		return png.DefaultCompression
+	default:
+		return png.BestCompression
+	}
+}
+
+// encodeLZWTiff encodes the image as a tiff image compressed with LZW,
+// which the tiff package can't write. The pixels are written as 8 bits
+// RGBA samples, in a single strip, with the horizontal predictor.
+func encodeLZWTiff(w io.Writer, img image.Image) error {
+	b := img.Bounds()
+	rgba := image.NewNRGBA(image.Rectangle{Max: b.Size()})
+	draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+
+	width, height := b.Dx(), b.Dy()
+
+	// The horizontal predictor stores the difference of every sample
+	// with the same sample of the previous pixel, from right to left.
+	pixels := slices.Clone(rgba.Pix)
+	for y := 0; y < height; y++ {
+		row := pixels[y*rgba.Stride : y*rgba.Stride+width*4]
+		for i := len(row) - 1; i >= 4; i-- {
+			row[i] -= row[i-4]
+		}
+	}
+
+	data := lzwCompress(pixels)
+
+	const (
+		entries = 12
+		// The header, followed by the IFD, the bits per sample and the strip.
+		ifdOffset  = 8
+		bitsOffset = ifdOffset + 2 + entries*12 + 4
+		dataOffset = bitsOffset + 8
+	)
+
+	type entry struct {
+		tag, kind uint16
+		count     uint32
+		value     uint32
+	}
+
+	const (
+		short = 3
+		long  = 4
+	)
+
+	ifd := []entry{
+		{256, long, 1, uint32(width)},
+		{257, long, 1, uint32(height)},
+		{258, short, 4, bitsOffset},
+		// LZW.
+		{259, short, 1, 5},
+		// RGB.
+		{262, short, 1, 2},
+		{273, long, 1, dataOffset},
+		{277, short, 1, 4},
+		{278, long, 1, uint32(height)},
+		{279, long, 1, uint32(len(data))},
+		// Chunky.
+		{284, short, 1, 1},
+		// Horizontal differencing.
+		{317, short, 1, 2},
+		// Unassociated alpha.
+		{338, short, 1, 2},
+	}
+
+	bw := bufio.NewWriter(w)
+	le := binary.LittleEndian
+
+	bw.WriteString("II*\x00")
+	binary.Write(bw, le, uint32(ifdOffset))
+	binary.Write(bw, le, uint16(len(ifd)))
+
+	for _, e := range ifd {
+		binary.Write(bw, le, e)
+	}
+
+	// There's no next IFD.
+	binary.Write(bw, le, uint32(0))
+	binary.Write(bw, le, []uint16{8, 8, 8, 8})
+	bw.Write(data)
+
+	return bw.Flush()
+}
+
+// lzwCompress compresses the data with the LZW variant of tiff images,
+// whose codes get wider one code earlier than in the standard algorithm.
+func lzwCompress(data []byte) []byte {
+	const (
+		clearCode = 256
+		eoiCode   = 257
+		maxWidth  = 12
+	)
+
+	var (
+		out   []byte
+		acc   uint32
+		nbits uint
+		width uint = 9
+		// hi is the last code of the table, as the decoder sees it.
+		hi    = eoiCode
+		table = map[int]int{}
+	)
+
+	emit := func(code int) {
+		acc = acc<<width | uint32(code)
+		nbits += width
+		for nbits >= 8 {
+			out = append(out, byte(acc>>(nbits-8)))
+			nbits -= 8
+		}
+	}
+
+	// next mirrors the decoder, which adds a code to the table every time it reads one.
+	next := func() {
+		hi++
+		if hi+1 >= 1<<width && width < maxWidth {
+			width++
+		}
+	}
+
+	emit(clearCode)
+
+	prefix := -1
+	for _, c := range data {
+		if prefix < 0 {
+			prefix = int(c)
+			continue
+		}
+
+		key := prefix<<8 | int(c)
+		if code, ok := table[key]; ok {
+			prefix = code
+			continue
+		}
+
+		emit(prefix)
+		next()
+
+		// The table is full, so it starts over.
+		if hi >= 1<<maxWidth-3 {
+			emit(clearCode)
+			clear(table)
+			hi, width = eoiCode, 9
+		} else {
+			table[key] = hi
+		}
+
+		prefix = int(c)
+	}
+
+	if prefix >= 0 {
+		emit(prefix)
+		next()
+	}
+
+	emit(eoiCode)
+
+	if nbits > 0 {
+		out = append(out, byte(acc<<(8-nbits)))
+	}
+
+	return out
+}
+
+// medianCut is a draw.Quantizer that builds a palette by splitting
+// the colors of the image in boxes, the one with the widest range
+// of a channel first, and averaging the colors of every box.
+type medianCut struct{}
+
+// Quantize implements the draw.Quantizer interface.
+func (medianCut) Quantize(p color.Palette, m image.Image) color.Palette {
+	size := cap(p) - len(p)
+	if size <= 0 {
+		return p
+	}
+
+	b := m.Bounds()
+
+	// The colors are sampled, so large images are quantized fast.
+	step := max(1, b.Dx()*b.Dy()/(256*256))
+
+	var pixels [][4]uint8
+	for i := 0; i < b.Dx()*b.Dy(); i += step {
+		c := color.NRGBAModel.Convert(m.At(b.Min.X+i%b.Dx(), b.Min.Y+i/b.Dx())).(color.NRGBA)
+		pixels = append(pixels, [4]uint8{c.R, c.G, c.B, c.A})
+	}
+
+	boxes := []colorBox{newColorBox(pixels)}
+
+	for len(boxes) < size {
+		// Splits the box with the widest channel, by its median.
+		widest := -1
+		for i, box := range boxes {
+			if len(box.pixels) > 1 && box.spread > 0 && (widest < 0 || box.spread > boxes[widest].spread) {
+				widest = i
+			}
+		}
+
+		if widest < 0 {
+			break
+		}
+
+		box := boxes[widest]
+		slices.SortFunc(box.pixels, func(a, b [4]uint8) int {
+			return int(a[box.channel]) - int(b[box.channel])
+		})
+
+		half := len(box.pixels) / 2
+		boxes[widest] = newColorBox(box.pixels[:half])
+		boxes = append(boxes, newColorBox(box.pixels[half:]))
+	}
+
+	for _, box := range boxes {
+		if len(box.pixels) == 0 {
+			continue
+		}
+
+		var sum [4]int
+		for _, px := range box.pixels {
+			for ch := range sum {
+				sum[ch] += int(px[ch])
+			}
+		}
+
+		n := len(box.pixels)
+		p = append(p, color.NRGBA{
+			R: uint8(sum[0] / n),
+			G: uint8(sum[1] / n),
+			B: uint8(sum[2] / n),
+			A: uint8(sum[3] / n),
+		})
+	}
+
+	return p
+}
+
+// colorBox is a box of colors of the median cut, alongside its widest channel.
+type colorBox struct {
+	pixels  [][4]uint8
+	channel int
+	spread  int
+}
+
+func newColorBox(pixels [][4]uint8) colorBox {
+	box := colorBox{pixels: pixels}
+
+	for ch := 0; ch < 4; ch++ {
+		lo, hi := uint8(255), uint8(0)
+		for _, px := range pixels {
+			lo, hi = min(lo, px[ch]), max(hi, px[ch])
+		}
+
+		if r := int(hi) - int(lo); r > box.spread {
+			box.channel, box.spread = ch, r
+		}
+	}
+
+	return box
+}
@@ -0,0 +1,138 @@
+package images_test
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/gif"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/tiff"
+
+	"github.com/danvergara/morphos/pkg/files"
+	"github.com/danvergara/morphos/pkg/files/images"
+)
+
+func gopherPirate(t *testing.T) *image.NRGBA {
+	f, err := os.Open("testdata/gopher_pirate.png")
+	require.NoError(t, err)
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	require.NoError(t, err)
+
+	nrgba := image.NewNRGBA(img.Bounds())
+	draw.Draw(nrgba, nrgba.Bounds(), img, image.Point{}, draw.Src)
+
+	return nrgba
+}
+
+// encode encodes the image with the options of a conversion to the target format.
+func encode(t *testing.T, img image.Image, target string, values url.Values) []byte {
+	source := images.PNG
+	if target == images.PNG {
+		source = images.JPEG
+	}
+
+	schema, err := files.ConversionOptions(source, target)
+	require.NoError(t, err)
+
+	opts, err := schema.Parse(values)
+	require.NoError(t, err)
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, images.Encode(buf, target, img, opts))
+
+	return buf.Bytes()
+}
+
+func TestEncodeSize(t *testing.T) {
+	img := gopherPirate(t)
+
+	var tests = []struct {
+		name    string
+		target  string
+		smaller url.Values
+		larger  url.Values
+	}{
+		{
+			name:    "jpeg quality",
+			target:  images.JPEG,
+			smaller: url.Values{"quality": {"30"}},
+			larger:  url.Values{"quality": {"95"}},
+		},
+		{
+			name:    "webp quality",
+			target:  images.WEBP,
+			smaller: url.Values{"quality": {"30"}},
+			larger:  url.Values{"quality": {"95"}},
+		},
+		{
+			name:    "webp lossless",
+			target:  images.WEBP,
+			smaller: url.Values{},
+			larger:  url.Values{"lossless": {"true"}},
+		},
+		{
+			name:    "png compression",
+			target:  images.PNG,
+			smaller: url.Values{"png_compression": {"9"}},
+			larger:  url.Values{"png_compression": {"0"}},
+		},
+		{
+			name:    "tiff lzw",
+			target:  images.TIFF,
+			smaller: url.Values{"tiff_compression": {"lzw"}},
+			larger:  url.Values{"tiff_compression": {"none"}},
+		},
+		{
+			name:    "tiff deflate",
+			target:  images.TIFF,
+			smaller: url.Values{"tiff_compression": {"deflate"}},
+			larger:  url.Values{"tiff_compression": {"none"}},
+		},
+		{
+			name:    "gif colors",
+			target:  images.GIF,
+			smaller: url.Values{"colors": {"16"}},
+			larger:  url.Values{},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			smaller := encode(t, img, tc.target, tc.smaller)
+			larger := encode(t, img, tc.target, tc.larger)
+
+			require.Less(t, len(smaller), len(larger))
+		})
+	}
+}
+
+func TestEncodeLZWTiff(t *testing.T) {
+	img := gopherPirate(t)
+
+	decoded, err := tiff.Decode(bytes.NewReader(encode(t, img, images.TIFF, url.Values{})))
+	require.NoError(t, err)
+
+	// LZW is lossless, so every pixel is the same.
+	result := image.NewNRGBA(decoded.Bounds())
+	draw.Draw(result, result.Bounds(), decoded, image.Point{}, draw.Src)
+	require.Equal(t, img.Bounds(), result.Bounds())
+	require.Equal(t, img.Pix, result.Pix)
+}
+
+func TestEncodeGIFColors(t *testing.T) {
+	img := gopherPirate(t)
+
+	decoded, err := gif.Decode(bytes.NewReader(encode(t, img, images.GIF, url.Values{"colors": {"8"}})))
+	require.NoError(t, err)
+
+	paletted, ok := decoded.(*image.Paletted)
+	require.True(t, ok)
+	require.LessOrEqual(t, len(paletted.Palette), 8)
+}
@@ -0,0 +1,250 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"regexp"
+	"strconv"
+
+	"golang.org/x/image/draw"
+
+	"github.com/danvergara/morphos/pkg/files"
+	"github.com/danvergara/morphos/pkg/packaging"
+)
+
+const (
+	// AppNameOption sets the name of the web app in its manifest.
+	AppNameOption = "app_name"
+	// ThemeColorOption sets the color of the toolbar of the web app, e.g. #336699.
+	ThemeColorOption = "theme_color"
+	// BackgroundColorOption sets the color of the splash screen of the web app,
+	// and the background of the apple touch icon, which can't be transparent.
+	BackgroundColorOption = "background_color"
+
+	defaultFaviconColor = "#ffffff"
+)
+
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// validateColor validates a color written as #rgb or #rrggbb.
+func validateColor(c string) error {
+	if !hexColor.MatchString(c) {
+		return fmt.Errorf("invalid color %q, expected #rgb or #rrggbb", c)
+	}
+
+	return nil
+}
+
+// faviconOutputOptions are the options accepted when converting to a favicon bundle.
+// The sizes of the icon apply to the favicon.ico file of the bundle.
+var faviconOutputOptions = icoOutputOptions.Merge(files.Schema{
+	{
+		Name:  AppNameOption,
+		Label: "App name",
+		Help:  "Name of the web app in its manifest",
+		Type:  files.StringOption,
+	},
+	{
+		Name:     ThemeColorOption,
+		Label:    "Theme color",
+		Help:     "Color of the toolbar of the web app, e.g. #336699",
+		Type:     files.StringOption,
+		Default:  defaultFaviconColor,
+		Validate: validateColor,
+	},
+	{
+		Name:     BackgroundColorOption,
+		Label:    "Background color",
+		Help:     "Color of the splash screen of the web app and the background of the apple touch icon",
+		Type:     files.StringOption,
+		Default:  defaultFaviconColor,
+		Validate: validateColor,
+	},
+})
+
+// Favicon struct implements the File interface from the files pkg.
+// It's the bundle of icons a website needs, alongside its web app manifest,
+// which is made out of any image, but can't be converted to other formats.
+type Favicon struct {
+	compatibleFormats   map[string][]string
+	compatibleMIMETypes map[string][]string
+}
+
+func init() {
+	files.Register(files.Format{
+		Name:          FAVICON,
+		Category:      files.Img,
+		Decoder:       func(string) files.File { return NewFavicon() },
+		OutputOptions: faviconOutputOptions,
+		Encoder:       encodeFavicon,
+		EncodesFrom:   []string{PNG},
+	})
+}
+
+// NewFavicon returns a pointer to a Favicon instance.
+// The Favicon object is set with empty maps, since it's only a target format.
+func NewFavicon() *Favicon {
+	f := Favicon{
+		compatibleFormats:   map[string][]string{},
+		compatibleMIMETypes: map[string][]string{},
+	}
+
+	return &f
+}
+
+// SupportedFormats returns a map with a slice of supported files.
+// Every key of the map represents the kind of a file.
+func (f *Favicon) SupportedFormats() map[string][]string {
+	return f.compatibleFormats
+}
+
+// SupportedMIMETypes returns a map with a slice of supported MIME types.
+func (f *Favicon) SupportedMIMETypes() map[string][]string {
+	return f.compatibleMIMETypes
+}
+
+// ConvertTo method errors out, since a favicon bundle can't be converted to other formats.
+func (f *Favicon) ConvertTo(_ context.Context, fileType, _ string, _ io.Reader, _ files.ConvertOptions) (io.Reader, error) {
+	return nil, fmt.Errorf("ConvertTo: file type not supported: %s", fileType)
+}
+
+// ImageType method returns the file format of the current image.
+// This method implements the Image interface.
+func (f *Favicon) ImageType() string {
+	return FAVICON
+}
+
+// faviconIcon is a png icon of the bundle.
+type faviconIcon struct {
+	name string
+	size int
+	// opaque icons are drawn on the background color.
+	opaque bool
+	// manifest tells if the icon is listed in the web app manifest.
+	manifest bool
+}
+
+// faviconIcons are the png icons of the bundle, besides the favicon.ico file.
+var faviconIcons = []faviconIcon{
+	{name: "favicon-16x16.png", size: 16},
+	{name: "favicon-32x32.png", size: 32},
+	{name: "apple-touch-icon.png", size: 180, opaque: true},
+	{name: "android-chrome-192x192.png", size: 192, manifest: true},
+	{name: "android-chrome-512x512.png", size: 512, manifest: true},
+}
+
+// webManifest is the web app manifest of the bundle, site.webmanifest.
+type webManifest struct {
+	Name            string            `json:"name"`
+	ShortName       string            `json:"short_name"`
+	Icons           []webManifestIcon `json:"icons"`
+	ThemeColor      string            `json:"theme_color"`
+	BackgroundColor string            `json:"background_color"`
+	Display         string            `json:"display"`
+}
+
+// webManifestIcon is an icon listed by the web app manifest.
+type webManifestIcon struct {
+	Src   string `json:"src"`
+	Sizes string `json:"sizes"`
+	Type  string `json:"type"`
+}
+
+// encodeFavicon converts a png image to a favicon bundle, a zip file with
+// favicon.ico, the png icons used by browsers, iOS and Android, and site.webmanifest.
+// It's the Encoder of the favicon format.
+func encodeFavicon(_ context.Context, _ string, file io.Reader, opts files.ConvertOptions) (io.Reader, error) {
+	sizes, err := parseIconSizes(opts.String(IconSizesOption, joinSizes(defaultIconSizes)))
+	if err != nil {
+		return nil, err
+	}
+
+	background, err := parseHexColor(opts.String(BackgroundColorOption, defaultFaviconColor))
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := decodeIconSource(file, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ico, err := writeICO(img, sizes, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding the ico file: %w", err)
+	}
+
+	bundle := []packaging.File{{Name: "favicon.ico", Content: ico}}
+
+	manifest := webManifest{
+		Name:            opts.String(AppNameOption, ""),
+		ShortName:       opts.String(AppNameOption, ""),
+		ThemeColor:      opts.String(ThemeColorOption, defaultFaviconColor),
+		BackgroundColor: opts.String(BackgroundColorOption, defaultFaviconColor),
+		Display:         "standalone",
+	}
+
+	for _, i := range faviconIcons {
+		icon := squareIcon(img, i.size, opts)
+
+		if i.opaque {
+			opaque := image.NewNRGBA(icon.Bounds())
+			draw.Draw(opaque, opaque.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+			draw.Draw(opaque, opaque.Bounds(), icon, image.Point{}, draw.Over)
+			icon = opaque
+		}
+
+		buf := new(bytes.Buffer)
+		if err := Encode(buf, PNG, icon, opts); err != nil {
+			return nil, fmt.Errorf("error encoding %s: %w", i.name, err)
+		}
+
+		bundle = append(bundle, packaging.File{Name: i.name, Content: buf.Bytes()})
+
+		if i.manifest {
+			manifest.Icons = append(manifest.Icons, webManifestIcon{
+				Src:   "/" + i.name,
+				Sizes: fmt.Sprintf("%dx%d", i.size, i.size),
+				Type:  "image/png",
+			})
+		}
+	}
+
+	manifestBytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error encoding site.webmanifest: %w", err)
+	}
+
+	bundle = append(bundle, packaging.File{Name: "site.webmanifest", Content: manifestBytes})
+
+	result, err := packaging.Pack(packaging.Zip, packaging.DefaultCompression, bundle...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating the favicon bundle: %w", err)
+	}
+
+	return bytes.NewReader(result), nil
+}
+
+// parseHexColor parses a color written as #rgb or #rrggbb.
+func parseHexColor(c string) (color.NRGBA, error) {
+	if err := validateColor(c); err != nil {
+		return color.NRGBA{}, err
+	}
+
+	hex := c[1:]
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, err
+	}
+
+	return color.NRGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
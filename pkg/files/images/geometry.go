@@ -0,0 +1,522 @@
+package images
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/math/f64"
+
+	"github.com/danvergara/morphos/pkg/files"
+)
+
+const (
+	// WidthOption and HeightOption set the size of the output image, in pixels.
+	// If only one of them is set, the other one keeps the aspect ratio.
+	WidthOption  = "width"
+	HeightOption = "height"
+	// ResizeOption sets how the image is resized when both width and height are set.
+	ResizeOption = "resize"
+	// KernelOption sets the resampling kernel used to resize the image.
+	KernelOption = "kernel"
+	// CropOption crops the image, either to a rectangle, as x,y,width,height,
+	// or to an aspect ratio, as width:height, centered.
+	CropOption = "crop"
+	// RotateOption rotates the image clockwise, in degrees.
+	RotateOption = "rotate"
+	// FlipOption mirrors the image.
+	FlipOption = "flip"
+
+	// ResizeFit fits the image within the size, keeping the aspect ratio.
+	ResizeFit = "fit"
+	// ResizeFill fills the size, keeping the aspect ratio and cropping the excess.
+	ResizeFill = "fill"
+	// ResizeExact stretches the image to the size.
+	ResizeExact = "exact"
+
+	KernelNearest  = "nearest"
+	KernelBilinear = "bilinear"
+	KernelBicubic  = "bicubic"
+	KernelLanczos  = "lanczos"
+
+	FlipNone       = "none"
+	FlipHorizontal = "horizontal"
+	FlipVertical   = "vertical"
+	FlipBoth       = "both"
+
+	maxDimension = 20000
+)
+
+// geometryOptions are the options accepted when converting from any image format.
+var geometryOptions = files.Schema{
+	{
+		Name:  WidthOption,
+		Label: "Width",
+		Help:  "In pixels. The original width, or the one that keeps the aspect ratio, if empty",
+		Type:  files.IntOption,
+		Min:   1,
+		Max:   maxDimension,
+	},
+	{
+		Name:  HeightOption,
+		Label: "Height",
+		Help:  "In pixels. The original height, or the one that keeps the aspect ratio, if empty",
+		Type:  files.IntOption,
+		Min:   1,
+		Max:   maxDimension,
+	},
+	{
+		Name:    ResizeOption,
+		Label:   "Resize mode",
+		Help:    "Fit within the size, fill it cropping the excess, or stretch to the exact size",
+		Type:    files.ChoiceOption,
+		Default: ResizeFit,
+		Choices: []string{ResizeFit, ResizeFill, ResizeExact},
+	},
+	{
+		Name:    KernelOption,
+		Label:   "Resampling",
+		Type:    files.ChoiceOption,
+		Default: KernelLanczos,
+		Choices: []string{KernelNearest, KernelBilinear, KernelBicubic, KernelLanczos},
+	},
+	{
+		Name:  CropOption,
+		Label: "Crop",
+		Help:  "A rectangle as x,y,width,height, or an aspect ratio as width:height, e.g. 16:9",
+		Type:  files.StringOption,
+		Validate: func(spec string) error {
+			_, err := parseCrop(spec)
+			return err
+		},
+	},
+	{
+		Name:  RotateOption,
+		Label: "Rotate",
+		Help:  "Degrees clockwise, e.g. 90",
+		Type:  files.FloatOption,
+		Min:   -360,
+		Max:   360,
+	},
+	{
+		Name:    FlipOption,
+		Label:   "Flip",
+		Type:    files.ChoiceOption,
+		Default: FlipNone,
+		Choices: []string{FlipNone, FlipHorizontal, FlipVertical, FlipBoth},
+	},
+}
+
+var errEmptyCrop = errors.New("the crop is outside of the image")
+
+// crop is either a rectangle or an aspect ratio.
+type crop struct {
+	rect image.Rectangle
+	// ratioW and ratioH are set if the crop is an aspect ratio.
+	ratioW, ratioH int
+}
+
+// parseCrop parses a crop, either x,y,width,height or width:height.
+func parseCrop(spec string) (crop, error) {
+	if w, h, ok := strings.Cut(spec, ":"); ok {
+		rw, err1 := strconv.Atoi(strings.TrimSpace(w))
+		rh, err2 := strconv.Atoi(strings.TrimSpace(h))
+		if err1 != nil || err2 != nil || rw <= 0 || rh <= 0 {
+			return crop{}, fmt.Errorf("invalid aspect ratio %q", spec)
+		}
+
+		return crop{ratioW: rw, ratioH: rh}, nil
+	}
+
+	parts := strings.Split(spec, ",")
+	if len(parts) != 4 {
+		return crop{}, fmt.Errorf("invalid crop %q, expected x,y,width,height or width:height", spec)
+	}
+
+	var n [4]int
+	for i, p := range parts {
+		v, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil || v < 0 {
+			return crop{}, fmt.Errorf("invalid crop %q, expected x,y,width,height or width:height", spec)
+		}
+		n[i] = v
+	}
+
+	if n[2] == 0 || n[3] == 0 {
+		return crop{}, fmt.Errorf("invalid crop %q, the width and the height can't be zero", spec)
+	}
+
+	return crop{rect: image.Rect(n[0], n[1], n[0]+n[2], n[1]+n[3])}, nil
+}
+
+// isRatio tells if the crop is an aspect ratio.
+func (c crop) isRatio() bool {
+	return c.ratioW > 0
+}
+
+// within returns the rectangle cropped out of an image of the given size.
+func (c crop) within(size image.Point) (image.Rectangle, error) {
+	if !c.isRatio() {
+		r := c.rect.Intersect(image.Rectangle{Max: size})
+		if r.Empty() {
+			return image.Rectangle{}, errEmptyCrop
+		}
+		return r, nil
+	}
+
+	w, h := size.X, size.X*c.ratioH/c.ratioW
+	if h > size.Y {
+		w, h = size.Y*c.ratioW/c.ratioH, size.Y
+	}
+
+	x, y := (size.X-w)/2, (size.Y-h)/2
+
+	return image.Rect(x, y, x+w, y+h), nil
+}
+
+// geometry holds the operations applied to an image before it's encoded,
+// in the order they are applied: orient, crop, rotate, flip and resize.
+type geometry struct {
+	// orientation is the EXIF orientation the image is turned upright from.
+	orientation int
+	crop        *crop
+	rotate      float64
+	flipH       bool
+	flipV       bool
+	width       int
+	height      int
+	resize      string
+	kernel      string
+}
+
+// geometryFromOptions returns the operations set in the options of a conversion.
+func geometryFromOptions(opts files.ConvertOptions) (geometry, error) {
+	g := geometry{
+		rotate: math.Mod(opts.Float(RotateOption, 0)+360, 360),
+		width:  opts.Int(WidthOption, 0),
+		height: opts.Int(HeightOption, 0),
+		resize: opts.String(ResizeOption, ResizeFit),
+		kernel: opts.String(KernelOption, KernelLanczos),
+	}
+
+	switch opts.String(FlipOption, FlipNone) {
+	case FlipHorizontal:
+		g.flipH = true
+	case FlipVertical:
+		g.flipV = true
+	case FlipBoth:
+		g.flipH, g.flipV = true, true
+	}
+
+	if spec := opts.String(CropOption, ""); spec != "" {
+		c, err := parseCrop(spec)
+		if err != nil {
+			return geometry{}, err
+		}
+		g.crop = &c
+	}
+
+	return g, nil
+}
+
+// isZero tells if there are no operations to apply.
+func (g geometry) isZero() bool {
+	return g.orientation <= 1 && g.crop == nil && g.rotate == 0 && !g.flipH && !g.flipV &&
+		g.width == 0 && g.height == 0
+}
+
+// size returns the size of an image of the given size once resized, and the size
+// it's scaled to before being cropped, which only differs if the mode is fill.
+func (g geometry) size(src image.Point) (image.Point, image.Point) {
+	switch {
+	case g.width == 0 && g.height == 0:
+		return src, src
+	case g.height == 0:
+		p := image.Pt(g.width, max(1, src.Y*g.width/src.X))
+		return p, p
+	case g.width == 0:
+		p := image.Pt(max(1, src.X*g.height/src.Y), g.height)
+		return p, p
+	}
+
+	target := image.Pt(g.width, g.height)
+
+	// Scales by the width or by the height, whichever keeps the image
+	// within the target size for fit, or covers it for fill.
+	byWidth := image.Pt(g.width, max(1, src.Y*g.width/src.X))
+	byHeight := image.Pt(max(1, src.X*g.height/src.Y), g.height)
+
+	switch g.resize {
+	case ResizeExact:
+		return target, target
+	case ResizeFill:
+		if byWidth.Y >= g.height {
+			return target, byWidth
+		}
+		return target, byHeight
+	default:
+		if byWidth.Y <= g.height {
+			return byWidth, byWidth
+		}
+		return byHeight, byHeight
+	}
+}
+
+// Apply applies the operations to the image.
+func (g geometry) Apply(img image.Image) (image.Image, error) {
+	if g.isZero() {
+		return img, nil
+	}
+
+	if o, ok := orientations[g.orientation]; ok {
+		if o.rotate != 0 {
+			img = rotate(img, o.rotate)
+		}
+		if o.flip {
+			img = flip(img, true, false)
+		}
+	}
+
+	if g.crop != nil {
+		r, err := g.crop.within(img.Bounds().Size())
+		if err != nil {
+			return nil, err
+		}
+
+		cropped := image.NewRGBA(image.Rectangle{Max: r.Size()})
+		draw.Draw(cropped, cropped.Bounds(), img, img.Bounds().Min.Add(r.Min), draw.Src)
+		img = cropped
+	}
+
+	if g.rotate != 0 {
+		img = rotate(img, g.rotate)
+	}
+
+	if g.flipH || g.flipV {
+		img = flip(img, g.flipH, g.flipV)
+	}
+
+	size, scaled := g.size(img.Bounds().Size())
+	if scaled != img.Bounds().Size() {
+		dst := image.NewRGBA(image.Rectangle{Max: scaled})
+		g.interpolator().Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Src, nil)
+		img = dst
+	}
+
+	// Crops the excess, keeping the center, if the image was scaled to fill the size.
+	if scaled != size {
+		offset := image.Pt((scaled.X-size.X)/2, (scaled.Y-size.Y)/2)
+		filled := image.NewRGBA(image.Rectangle{Max: size})
+		draw.Draw(filled, filled.Bounds(), img, img.Bounds().Min.Add(offset), draw.Src)
+		return filled, nil
+	}
+
+	return img, nil
+}
+
+// lanczos is the Lanczos kernel with a support of 3.
+var lanczos = &draw.Kernel{
+	Support: 3,
+	At: func(t float64) float64 {
+		if t == 0 {
+			return 1
+		}
+
+		x := math.Pi * t
+		return 3 * math.Sin(x) * math.Sin(x/3) / (x * x)
+	},
+}
+
+// interpolator returns the resampling kernel of the geometry.
+func (g geometry) interpolator() draw.Interpolator {
+	switch g.kernel {
+	case KernelNearest:
+		return draw.NearestNeighbor
+	case KernelBilinear:
+		return draw.BiLinear
+	case KernelBicubic:
+		return draw.CatmullRom
+	default:
+		return lanczos
+	}
+}
+
+// rotate rotates the image clockwise by the given degrees.
+// Multiples of 90 degrees are exact, other angles are interpolated
+// and the corners left uncovered are transparent.
+func rotate(img image.Image, degrees float64) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	switch degrees {
+	case 90, 180, 270:
+		size := image.Pt(h, w)
+		if degrees == 180 {
+			size = image.Pt(w, h)
+		}
+
+		dst := image.NewRGBA(image.Rectangle{Max: size})
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				c := img.At(b.Min.X+x, b.Min.Y+y)
+				switch degrees {
+				case 90:
+					dst.Set(h-1-y, x, c)
+				case 180:
+					dst.Set(w-1-x, h-1-y, c)
+				case 270:
+					dst.Set(y, w-1-x, c)
+				}
+			}
+		}
+
+		return dst
+	}
+
+	rad := degrees * math.Pi / 180
+	sin, cos := math.Sin(rad), math.Cos(rad)
+
+	dw := int(math.Ceil(math.Abs(float64(w)*cos) + math.Abs(float64(h)*sin)))
+	dh := int(math.Ceil(math.Abs(float64(w)*sin) + math.Abs(float64(h)*cos)))
+
+	// Moves the center of the source to the origin, rotates it,
+	// and moves it to the center of the destination.
+	cx, cy := float64(b.Min.X)+float64(w)/2, float64(b.Min.Y)+float64(h)/2
+	tx, ty := float64(dw)/2, float64(dh)/2
+
+	s2d := f64.Aff3{
+		cos, -sin, tx - cos*cx + sin*cy,
+		sin, cos, ty - sin*cx - cos*cy,
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.Transparent), image.Point{}, draw.Src)
+	draw.BiLinear.Transform(dst, s2d, img, b, draw.Over, nil)
+
+	return dst
+}
+
+// flip mirrors the image horizontally, vertically or both.
+func flip(img image.Image, horizontal, vertical bool) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	dst := image.NewRGBA(image.Rectangle{Max: image.Pt(w, h)})
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dx, dy := x, y
+			if horizontal {
+				dx = w - 1 - x
+			}
+			if vertical {
+				dy = h - 1 - y
+			}
+			dst.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+
+	return dst
+}
+
+// ffmpegFilter returns the filter graph that applies the operations through ffmpeg,
+// or an empty string if there are no operations to apply.
+func (g geometry) ffmpegFilter() string {
+	var filters []string
+
+	if o, ok := orientations[g.orientation]; ok {
+		filters = append(filters, ffmpegRotate(o.rotate)...)
+		if o.flip {
+			filters = append(filters, "hflip")
+		}
+	}
+
+	if g.crop != nil {
+		if g.crop.isRatio() {
+			// The largest centered rectangle of the aspect ratio.
+			// Commas within expressions are escaped, since they separate filters.
+			filters = append(filters, fmt.Sprintf(
+				"crop=min(iw\\,ih*%[1]d/%[2]d):min(ih\\,iw*%[2]d/%[1]d)",
+				g.crop.ratioW,
+				g.crop.ratioH,
+			))
+		} else {
+			r := g.crop.rect
+			filters = append(filters, fmt.Sprintf("crop=%d:%d:%d:%d", r.Dx(), r.Dy(), r.Min.X, r.Min.Y))
+		}
+	}
+
+	filters = append(filters, ffmpegRotate(g.rotate)...)
+
+	if g.flipH {
+		filters = append(filters, "hflip")
+	}
+
+	if g.flipV {
+		filters = append(filters, "vflip")
+	}
+
+	if g.width != 0 || g.height != 0 {
+		filters = append(filters, g.ffmpegScale()...)
+	}
+
+	return strings.Join(filters, ",")
+}
+
+// ffmpegRotate returns the filters that rotate the image clockwise by the given degrees.
+func ffmpegRotate(degrees float64) []string {
+	switch degrees {
+	case 0:
+		return nil
+	case 90:
+		return []string{"transpose=clock"}
+	case 180:
+		return []string{"hflip", "vflip"}
+	case 270:
+		return []string{"transpose=cclock"}
+	}
+
+	rad := strconv.FormatFloat(degrees*math.Pi/180, 'f', 6, 64)
+
+	return []string{fmt.Sprintf("rotate=%[1]s:ow=rotw(%[1]s):oh=roth(%[1]s):c=none", rad)}
+}
+
+// ffmpegScale returns the filters that resize the image through ffmpeg.
+func (g geometry) ffmpegScale() []string {
+	flags := map[string]string{
+		KernelNearest:  "neighbor",
+		KernelBilinear: "bilinear",
+		KernelBicubic:  "bicubic",
+		KernelLanczos:  "lanczos",
+	}[g.kernel]
+
+	if flags == "" {
+		flags = "lanczos"
+	}
+
+	// -1 keeps the aspect ratio.
+	w, h := g.width, g.height
+	if w == 0 {
+		w = -1
+	}
+	if h == 0 {
+		h = -1
+	}
+
+	if w == -1 || h == -1 || g.resize == ResizeExact {
+		return []string{fmt.Sprintf("scale=%d:%d:flags=%s", w, h, flags)}
+	}
+
+	if g.resize == ResizeFill {
+		return []string{
+			fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=increase:flags=%s", w, h, flags),
+			fmt.Sprintf("crop=%d:%d", w, h),
+		}
+	}
+
+	return []string{fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease:flags=%s", w, h, flags)}
+}
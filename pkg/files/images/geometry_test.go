@@ -0,0 +1,100 @@
+package images_test
+
+import (
+	"context"
+	"image"
+	"io"
+	"math"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/gen2brain/go-fitz"
+	"github.com/stretchr/testify/require"
+
+	"github.com/danvergara/morphos/pkg/files"
+	"github.com/danvergara/morphos/pkg/files/images"
+)
+
+func TestGeometry(t *testing.T) {
+	// The image is 1300x1392, and every pixel becomes a point of the pdf page.
+	rotated45 := int(math.Ceil((1300 + 1392) * math.Sqrt2 / 2))
+
+	var tests = []struct {
+		name     string
+		values   url.Values
+		expected image.Point
+		hasErr   bool
+	}{
+		{name: "no operations", values: url.Values{}, expected: image.Pt(1300, 1392)},
+		{name: "width", values: url.Values{"width": {"650"}}, expected: image.Pt(650, 696)},
+		{name: "height", values: url.Values{"height": {"696"}}, expected: image.Pt(650, 696)},
+		{name: "fit", values: url.Values{"width": {"200"}, "height": {"200"}}, expected: image.Pt(186, 200)},
+		{name: "fill", values: url.Values{"width": {"200"}, "height": {"200"}, "resize": {"fill"}}, expected: image.Pt(200, 200)},
+		{name: "fill without scaling", values: url.Values{"width": {"650"}, "height": {"1392"}, "resize": {"fill"}}, expected: image.Pt(650, 1392)},
+		{name: "exact", values: url.Values{"width": {"300"}, "height": {"100"}, "resize": {"exact"}, "kernel": {"nearest"}}, expected: image.Pt(300, 100)},
+		{name: "crop rectangle", values: url.Values{"crop": {"10,20,100,50"}}, expected: image.Pt(100, 50)},
+		{name: "crop aspect ratio", values: url.Values{"crop": {"16:9"}}, expected: image.Pt(1300, 731)},
+		{name: "rotate 90", values: url.Values{"rotate": {"90"}}, expected: image.Pt(1392, 1300)},
+		{name: "rotate -90", values: url.Values{"rotate": {"-90"}}, expected: image.Pt(1392, 1300)},
+		{name: "rotate 180", values: url.Values{"rotate": {"180"}}, expected: image.Pt(1300, 1392)},
+		{name: "rotate 45", values: url.Values{"rotate": {"45"}}, expected: image.Pt(rotated45, rotated45)},
+		{name: "flip", values: url.Values{"flip": {"both"}}, expected: image.Pt(1300, 1392)},
+		{
+			name:     "crop, rotate and resize",
+			values:   url.Values{"crop": {"16:9"}, "rotate": {"270"}, "width": {"100"}, "kernel": {"bicubic"}},
+			expected: image.Pt(100, 177),
+		},
+		{name: "crop outside of the image", values: url.Values{"crop": {"2000,2000,10,10"}}, hasErr: true},
+	}
+
+	schema, err := files.ConversionOptions(images.PNG, images.JPEG)
+	require.NoError(t, err)
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			opts, err := schema.Parse(tc.values)
+			require.NoError(t, err)
+
+			f, err := os.Open("testdata/gopher_pirate.png")
+			require.NoError(t, err)
+			defer f.Close()
+
+			result, err := images.NewPng().ConvertTo(context.Background(), "Document", images.PDF, f, opts)
+			if tc.hasErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			pdfBytes, err := io.ReadAll(result)
+			require.NoError(t, err)
+
+			doc, err := fitz.NewFromMemory(pdfBytes)
+			require.NoError(t, err)
+			defer doc.Close()
+
+			bounds, err := doc.Bound(0)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, bounds.Size())
+		})
+	}
+}
+
+func TestGeometryOptions(t *testing.T) {
+	schema, err := files.ConversionOptions(images.PNG, images.JPEG)
+	require.NoError(t, err)
+
+	for _, values := range []url.Values{
+		{"crop": {"16:0"}},
+		{"crop": {"1,2,3"}},
+		{"crop": {"0,0,0,10"}},
+		{"width": {"0"}},
+		{"rotate": {"720"}},
+		{"resize": {"stretch"}},
+	} {
+		_, err := schema.Parse(values)
+		require.ErrorIs(t, err, files.ErrInvalidOption, values.Encode())
+	}
+}
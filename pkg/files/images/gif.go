@@ -2,11 +2,14 @@ package images
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"image/gif"
 	"io"
 	"slices"
 	"strings"
+
+	"github.com/danvergara/morphos/pkg/files"
 )
 
 // Gif struct implements the File and Image interface from the files pkg.
@@ -15,6 +18,18 @@ type Gif struct {
 	compatibleMIMETypes map[string][]string
 }
 
+func init() {
+	files.Register(files.Format{
+		Name:          GIF,
+		Category:      files.Img,
+		MIMETypes:     []string{"image/gif"},
+		Decoder:       func(string) files.File { return NewGif() },
+		InputOptions:  animatedInputOptions,
+		OutputOptions: gifOutputOptions,
+		Cost:          1,
+	})
+}
+
 // NewGif returns a pointer to a Gif instance.
 // The Gif object is set with a map with list of supported file formats.
 func NewGif() *Gif {
@@ -28,9 +43,13 @@ func NewGif() *Gif {
 				WEBP,
 				TIFF,
 				BMP,
+				JXL,
 			},
 			"Document": {
 				PDF,
+				TXT,
+				HOCR,
+				OCRPDF,
 			},
 		},
 		compatibleMIMETypes: map[string][]string{
@@ -42,9 +61,13 @@ func NewGif() *Gif {
 				WEBP,
 				TIFF,
 				BMP,
+				JXL,
 			},
 			"Document": {
 				PDF,
+				TXT,
+				HOCR,
+				OCRPDF,
 			},
 		},
 	}
@@ -66,7 +89,7 @@ func (g *Gif) SupportedMIMETypes() map[string][]string {
 // ConvertTo method converts a given file to a target format.
 // This method returns a file in form of a slice of bytes.
 // The methd receives a file type and the sub-type of the target format and the file as array of bytes.
-func (g *Gif) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, error) {
+func (g *Gif) ConvertTo(ctx context.Context, fileType, subType string, file io.Reader, opts files.ConvertOptions) (io.Reader, error) {
 	var result []byte
 
 	compatibleFormats, ok := g.SupportedFormats()[fileType]
@@ -78,21 +101,38 @@ func (g *Gif) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, er
 		return nil, fmt.Errorf("ConvertTo: file sub-type not supported: %s", subType)
 	}
 
+	fileBytes, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading from the image file: %w", err)
+	}
+
+	// Animated images are converted frame by frame.
+	anim, err := readAnimation(ctx, GIF, fileBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	if anim != nil {
+		return convertAnimation(ctx, fileType, subType, anim, readMetadata(fileBytes), opts)
+	}
+
+	file = bytes.NewReader(fileBytes)
+
 	switch strings.ToLower(fileType) {
 	case imageType:
-		convertedImage, err := convertToImage(subType, file)
+		convertedImage, err := convertToImage(ctx, subType, file, gif.Decode, opts)
 		if err != nil {
 			return nil, err
 		}
 
 		return convertedImage, nil
 	case documentType:
-		img, err := gif.Decode(file)
+		img, m, err := decodeImage(file, gif.Decode)
 		if err != nil {
 			return nil, err
 		}
 
-		result, err = convertToDocument(subType, img)
+		result, err = convertToDocument(ctx, subType, img, m, opts)
 		if err != nil {
 			return nil, fmt.Errorf(
 				"ConvertTo: error at converting image to another format: %w",
@@ -104,6 +144,24 @@ func (g *Gif) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, er
 	return bytes.NewReader(result), nil
 }
 
+// Inspect describes the image.
+// This method implements the files.Inspector interface.
+func (g *Gif) Inspect(_ context.Context, file io.Reader) (any, error) {
+	info, fileBytes, err := readInfo(file, gif.DecodeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := gif.DecodeAll(bytes.NewReader(fileBytes))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding the image: %w", err)
+	}
+
+	info.Frames = len(img.Image)
+
+	return info, nil
+}
+
 // ImageType returns the file format of the current image.
 // This method implements the Image interface.
 func (g *Gif) ImageType() string {
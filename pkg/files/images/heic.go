@@ -0,0 +1,147 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/danvergara/morphos/pkg/files"
+)
+
+// Heic struct implements the File and Image interface from the files pkg.
+// It reads heic and heif images, the format of the photos taken by most phones.
+type Heic struct {
+	compatibleFormats   map[string][]string
+	compatibleMIMETypes map[string][]string
+}
+
+func init() {
+	files.Register(files.Format{
+		Name:     HEIC,
+		Category: files.Img,
+		Aliases:  []string{HEIF},
+		MIMETypes: []string{
+			"image/heic",
+			"image/heif",
+			"image/heic-sequence",
+			"image/heif-sequence",
+		},
+		Decoder:      func(string) files.File { return NewHeic() },
+		InputOptions: inputOptions,
+	})
+}
+
+// NewHeic returns a pointer to a Heic instance.
+// The Heic object is set with a map with list of supported file formats.
+func NewHeic() *Heic {
+	h := Heic{
+		compatibleFormats: map[string][]string{
+			"Image": {
+				JPG,
+				JPEG,
+				PNG,
+				GIF,
+				WEBP,
+				TIFF,
+				BMP,
+				AVIF,
+				JXL,
+			},
+			"Document": {
+				PDF,
+				TXT,
+				HOCR,
+				OCRPDF,
+			},
+		},
+
+		compatibleMIMETypes: map[string][]string{
+			"Image": {
+				JPG,
+				JPEG,
+				PNG,
+				GIF,
+				WEBP,
+				TIFF,
+				BMP,
+				AVIF,
+				JXL,
+			},
+			"Document": {
+				PDF,
+				TXT,
+				HOCR,
+				OCRPDF,
+			},
+		},
+	}
+
+	return &h
+}
+
+// SupportedFormats returns a map with a slice of supported files.
+// Every key of the map represents the kind of a file.
+func (h *Heic) SupportedFormats() map[string][]string {
+	return h.compatibleFormats
+}
+
+// SupportedMIMETypes returns a map with a slice of supported MIME types.
+func (h *Heic) SupportedMIMETypes() map[string][]string {
+	return h.compatibleMIMETypes
+}
+
+// ConvertTo method converts a given file to a target format.
+// This method returns a file in form of a slice of bytes.
+// The primary image of the file is the one converted.
+func (h *Heic) ConvertTo(ctx context.Context, fileType, subType string, file io.Reader, opts files.ConvertOptions) (io.Reader, error) {
+	var result []byte
+
+	compatibleFormats, ok := h.SupportedFormats()[fileType]
+	if !ok {
+		return nil, fmt.Errorf("ConvertTo: file type not supported: %s", fileType)
+	}
+
+	if !slices.Contains(compatibleFormats, subType) {
+		return nil, fmt.Errorf("ConvertTo: file sub-type not supported: %s", subType)
+	}
+
+	fileBytes, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	img, m, err := decodeHEIF(ctx, fileBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(fileType) {
+	case imageType:
+		return convertDecoded(ctx, subType, img, m, opts)
+	case documentType:
+		result, err = convertToDocument(ctx, subType, img, m, opts)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"ConvertTo: error at converting image to another format: %w",
+				err,
+			)
+		}
+	}
+
+	return bytes.NewReader(result), nil
+}
+
+// Inspect describes the primary image of the file.
+// This method implements the files.Inspector interface.
+func (h *Heic) Inspect(_ context.Context, file io.Reader) (any, error) {
+	return readHEIFInfo(file)
+}
+
+// ImageType method returns the file format of the current image.
+// This method implements the Image interface.
+func (h *Heic) ImageType() string {
+	return HEIC
+}
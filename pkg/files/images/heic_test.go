@@ -0,0 +1,307 @@
+package images_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/danvergara/morphos/pkg/files"
+	"github.com/danvergara/morphos/pkg/files/images"
+)
+
+// box returns an ISO base media box of the given kind.
+func box(kind string, payload ...[]byte) []byte {
+	data := bytes.Join(payload, nil)
+	b := binary.BigEndian.AppendUint32(nil, uint32(8+len(data)))
+	return append(append(b, kind...), data...)
+}
+
+// fullBox returns a box that starts with a version, followed by flags.
+func fullBox(kind string, version byte, payload ...[]byte) []byte {
+	return box(kind, append([][]byte{{version, 0, 0, 0}}, payload...)...)
+}
+
+func u16(v int) []byte { return binary.BigEndian.AppendUint16(nil, uint16(v)) }
+func u32(v int) []byte { return binary.BigEndian.AppendUint32(nil, uint32(v)) }
+
+// heifItem is an item of the heif files built by the tests.
+type heifItem struct {
+	id   int
+	kind string
+	data []byte
+	// properties are the indexes of the properties of the item, starting at one.
+	properties []int
+}
+
+// heifRef is a reference between items of the heif files built by the tests.
+type heifRef struct {
+	kind string
+	from int
+	to   []int
+}
+
+// heifFile returns a heif file with the given items, properties and references.
+// The data of the items is stored in the mdat box, after the meta box.
+func heifFile(primary int, items []heifItem, properties [][]byte, refs []heifRef) []byte {
+	ftyp := box("ftyp", []byte("heic"), u32(0), []byte("mif1heic"))
+
+	meta := func(dataOffset int) []byte {
+		var infe, iloc, ipma, iref [][]byte
+
+		offset := dataOffset
+		for _, item := range items {
+			infe = append(infe, fullBox("infe", 2, u16(item.id), u16(0), []byte(item.kind), []byte{0}))
+			iloc = append(iloc, u16(item.id), u16(0), u16(1), u32(offset), u32(len(item.data)))
+			offset += len(item.data)
+
+			ipma = append(ipma, u16(item.id), []byte{byte(len(item.properties))})
+			for _, p := range item.properties {
+				ipma = append(ipma, []byte{byte(p)})
+			}
+		}
+
+		for _, ref := range refs {
+			to := [][]byte{u16(ref.from), u16(len(ref.to))}
+			for _, id := range ref.to {
+				to = append(to, u16(id))
+			}
+			iref = append(iref, box(ref.kind, to...))
+		}
+
+		return fullBox("meta", 0,
+			fullBox("hdlr", 0, u32(0), []byte("pict"), make([]byte, 13)),
+			fullBox("pitm", 0, u16(primary)),
+			fullBox("iinf", 0, append([][]byte{u16(len(items))}, infe...)...),
+			fullBox("iloc", 0, append([][]byte{{0x44, 0x00}, u16(len(items))}, iloc...)...),
+			box("iprp",
+				box("ipco", properties...),
+				fullBox("ipma", 0, append([][]byte{u32(len(items))}, ipma...)...),
+			),
+			fullBox("iref", 0, iref...),
+		)
+	}
+
+	// The size of the meta box doesn't depend on the offsets.
+	dataOffset := len(ftyp) + len(meta(0)) + 8
+
+	var mdat [][]byte
+	for _, item := range items {
+		mdat = append(mdat, item.data)
+	}
+
+	return bytes.Join([][]byte{ftyp, meta(dataOffset), box("mdat", mdat...)}, nil)
+}
+
+// ispe returns the spatial extents property of an image of the given size.
+func ispe(width, height int) []byte {
+	return fullBox("ispe", 0, u32(width), u32(height))
+}
+
+// hvcC returns a HEVC decoder configuration of the given bit depth, without parameter sets.
+func hvcC(bitDepth int) []byte {
+	config := make([]byte, 23)
+	config[0] = 1
+	config[17] = 0xf8 | byte(bitDepth-8)
+	config[21] = 3
+
+	return box("hvcC", config)
+}
+
+// exifItem returns the data of an EXIF item, with the given camera make.
+func exifItem(cameraMake string) []byte {
+	value := append([]byte(cameraMake), 0)
+
+	tiff := []byte("II*\x00\x08\x00\x00\x00\x01\x00")
+	tiff = binary.LittleEndian.AppendUint16(tiff, 0x010f)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 2)
+	tiff = binary.LittleEndian.AppendUint32(tiff, uint32(len(value)))
+	// The value goes right after the IFD.
+	tiff = binary.LittleEndian.AppendUint32(tiff, 26)
+	tiff = binary.LittleEndian.AppendUint32(tiff, 0)
+	tiff = append(tiff, value...)
+
+	// The offset of the tiff header goes first, past the EXIF identifier.
+	return bytes.Join([][]byte{u32(6), []byte("Exif\x00\x00"), tiff}, nil)
+}
+
+func TestInspectHEIC(t *testing.T) {
+	var tests = []struct {
+		name     string
+		file     []byte
+		expected images.ImageInfo
+	}{
+		{
+			name: "rotated image",
+			file: heifFile(
+				1,
+				[]heifItem{
+					{id: 1, kind: "hvc1", data: []byte{0, 0, 0, 0}, properties: []int{1, 2, 3}},
+					{id: 2, kind: "Exif", data: exifItem("Apple")},
+				},
+				[][]byte{hvcC(10), ispe(64, 48), box("irot", []byte{3})},
+				[]heifRef{{kind: "cdsc", from: 2, to: []int{1}}},
+			),
+			expected: images.ImageInfo{
+				Width:      48,
+				Height:     64,
+				ColorModel: "YCbCr",
+				BitDepth:   10,
+				Frames:     1,
+				// The image is turned upright by its rotation, rather than by the EXIF orientation.
+				Metadata: images.MetadataInfo{EXIF: true, Orientation: 1, Make: "Apple"},
+			},
+		},
+		{
+			name: "grid with a thumbnail",
+			file: heifFile(
+				1,
+				[]heifItem{
+					// The grid is 2x2, 1000x700 pixels.
+					{id: 1, kind: "grid", data: []byte{0, 0, 1, 1, 0x03, 0xe8, 0x02, 0xbc}, properties: []int{2}},
+					{id: 2, kind: "hvc1", data: []byte{0, 0, 0, 0}, properties: []int{1, 3}},
+					{id: 3, kind: "hvc1", data: []byte{0, 0, 0, 0}, properties: []int{1, 3}},
+					{id: 4, kind: "hvc1", data: []byte{0, 0, 0, 0}, properties: []int{1, 3}},
+					{id: 5, kind: "hvc1", data: []byte{0, 0, 0, 0}, properties: []int{1, 3}},
+					{id: 6, kind: "hvc1", data: []byte{0, 0, 0, 0}, properties: []int{1, 4}},
+				},
+				[][]byte{hvcC(8), ispe(1000, 700), ispe(512, 512), ispe(160, 112)},
+				[]heifRef{
+					{kind: "dimg", from: 1, to: []int{2, 3, 4, 5}},
+					{kind: "thmb", from: 6, to: []int{1}},
+				},
+			),
+			expected: images.ImageInfo{
+				Width:      1000,
+				Height:     700,
+				ColorModel: "YCbCr",
+				BitDepth:   8,
+				Frames:     1,
+			},
+		},
+		{
+			name: "multiple images",
+			file: heifFile(
+				2,
+				[]heifItem{
+					{id: 1, kind: "hvc1", data: []byte{0, 0, 0, 0}, properties: []int{1, 2}},
+					{id: 2, kind: "hvc1", data: []byte{0, 0, 0, 0}, properties: []int{1, 3}},
+				},
+				[][]byte{hvcC(8), ispe(640, 480), ispe(320, 240)},
+				nil,
+			),
+			expected: images.ImageInfo{
+				Width:      320,
+				Height:     240,
+				ColorModel: "YCbCr",
+				BitDepth:   8,
+				Frames:     2,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			info, err := images.NewHeic().Inspect(context.Background(), bytes.NewReader(tc.file))
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, info)
+		})
+	}
+}
+
+func TestInspectHEICWithoutPrimaryImage(t *testing.T) {
+	file := heifFile(
+		7,
+		[]heifItem{{id: 1, kind: "hvc1", data: []byte{0, 0, 0, 0}, properties: []int{1, 2}}},
+		[][]byte{hvcC(8), ispe(64, 48)},
+		nil,
+	)
+
+	_, err := images.NewHeic().Inspect(context.Background(), bytes.NewReader(file))
+	require.Error(t, err)
+}
+
+func TestHEICFactory(t *testing.T) {
+	require.Equal(t, files.Img, files.SupportedFileTypes()[images.HEIC])
+	require.Equal(t, files.Img, files.SupportedFileTypes()[images.HEIF])
+
+	for _, subType := range []string{"heic", "heif", "heic-sequence", "heif-sequence"} {
+		file, err := new(files.ImageFactory).NewFile(subType)
+		require.NoError(t, err)
+		require.IsType(t, &images.Heic{}, file)
+	}
+}
+
+func TestConvertHEICWithGoBackend(t *testing.T) {
+	require.NoError(t, images.SetBackend(images.BackendGo))
+	t.Cleanup(func() {
+		require.NoError(t, images.SetBackend(images.BackendAuto))
+	})
+
+	schema, err := files.ConversionOptions(images.HEIC, images.PNG)
+	require.NoError(t, err)
+
+	opts, err := schema.Parse(url.Values{})
+	require.NoError(t, err)
+
+	file := heifFile(
+		1,
+		[]heifItem{{id: 1, kind: "hvc1", data: []byte{0, 0, 0, 0}, properties: []int{1, 2}}},
+		[][]byte{hvcC(8), ispe(64, 48)},
+		nil,
+	)
+
+	// HEVC images are decoded by ffmpeg, which the go backend doesn't use.
+	_, err = images.NewHeic().ConvertTo(context.Background(), "Image", images.PNG, bytes.NewReader(file), opts)
+	require.ErrorContains(t, err, "requires ffmpeg")
+}
+
+func TestConvertHEICWithOversizedGrid(t *testing.T) {
+	var tests = []struct {
+		name string
+		grid []byte
+		err  string
+	}{
+		{
+			// A single tile, in a grid of 4294967295x4294967295 pixels, with 32 bits sizes.
+			name: "huge grid",
+			grid: bytes.Join([][]byte{{0, 1, 0, 0}, u32(0xffffffff), u32(0xffffffff)}, nil),
+			err:  "invalid size: 4294967295x4294967295",
+		},
+		{
+			// A single tile of 64x48 pixels, in a grid of 640x480 pixels.
+			name: "grid larger than its tiles",
+			grid: bytes.Join([][]byte{{0, 0, 0, 0}, u16(640), u16(480)}, nil),
+			err:  "larger than its 1x1 tiles of 64x48 pixels",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			schema, err := files.ConversionOptions(images.HEIC, images.PNG)
+			require.NoError(t, err)
+
+			opts, err := schema.Parse(url.Values{})
+			require.NoError(t, err)
+
+			file := heifFile(
+				1,
+				[]heifItem{
+					{id: 1, kind: "grid", data: tc.grid},
+					{id: 2, kind: "hvc1", data: []byte{0, 0, 0, 0}, properties: []int{1, 2}},
+				},
+				[][]byte{hvcC(8), ispe(64, 48)},
+				[]heifRef{{kind: "dimg", from: 1, to: []int{2}}},
+			)
+
+			// The grid is rejected before the tiles are decoded by ffmpeg.
+			_, err = images.NewHeic().ConvertTo(context.Background(), "Image", images.PNG, bytes.NewReader(file), opts)
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
@@ -0,0 +1,676 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"os"
+	"slices"
+
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+
+	"github.com/danvergara/morphos/pkg/util"
+)
+
+// errNoPrimaryImage is returned when a heif file doesn't tell which of its images is the main one.
+var errNoPrimaryImage = errors.New("the heif file has no primary image")
+
+// heifBox is a box of an ISO base media file, the container of heif images.
+type heifBox struct {
+	kind string
+	// data is the payload of the box, after its header.
+	data []byte
+}
+
+// heifBoxes returns the boxes found one after the other in b.
+func heifBoxes(b []byte) []heifBox {
+	var boxes []heifBox
+
+	for len(b) >= 8 {
+		size, header := uint64(binary.BigEndian.Uint32(b)), uint64(8)
+		kind := string(b[4:8])
+
+		switch size {
+		case 0:
+			// The box goes on up to the end of the file.
+			size = uint64(len(b))
+		case 1:
+			if len(b) < 16 {
+				return boxes
+			}
+			size, header = binary.BigEndian.Uint64(b[8:]), 16
+		}
+
+		if size < header || size > uint64(len(b)) {
+			return boxes
+		}
+
+		boxes = append(boxes, heifBox{kind: kind, data: b[header:size]})
+		b = b[size:]
+	}
+
+	return boxes
+}
+
+// heifReader reads the fields of a box, one after the other.
+// Once a field goes past the end of the box, every field read is zero
+// and the reader is marked as failed.
+type heifReader struct {
+	b      []byte
+	failed bool
+}
+
+// uint reads a big endian unsigned integer of n bytes.
+func (r *heifReader) uint(n int) uint64 {
+	if n > len(r.b) {
+		r.b, r.failed = nil, true
+		return 0
+	}
+
+	var v uint64
+	for _, c := range r.b[:n] {
+		v = v<<8 | uint64(c)
+	}
+	r.b = r.b[n:]
+
+	return v
+}
+
+// id reads an item id, which is 16 bits long in the first version of most boxes.
+func (r *heifReader) id(version byte) uint32 {
+	if version == 0 {
+		return uint32(r.uint(2))
+	}
+
+	return uint32(r.uint(4))
+}
+
+// heifExtent is a chunk of the data of an item.
+type heifExtent struct {
+	offset, length uint64
+}
+
+// heifItem is an item of a heif file, e.g. an image, a tile of an image or its EXIF data.
+type heifItem struct {
+	id uint32
+	// kind is the type of the item. e.g. hvc1 for HEVC images, grid for images made of tiles.
+	kind   string
+	hidden bool
+	// idat tells if the extents are offsets of the idat box, rather than of the file.
+	idat       bool
+	baseOffset uint64
+	extents    []heifExtent
+	// properties are the properties associated with the item, in order.
+	properties []heifBox
+}
+
+// heifRef is a reference from an item to other items. e.g. from a grid to its tiles.
+type heifRef struct {
+	kind string
+	from uint32
+	to   []uint32
+}
+
+// heifFile is the structure of a heif file, read out of its meta box.
+type heifFile struct {
+	data    []byte
+	idat    []byte
+	primary uint32
+	items   map[uint32]*heifItem
+	refs    []heifRef
+}
+
+// parseHEIF reads the structure of a heif file.
+func parseHEIF(b []byte) (*heifFile, error) {
+	f := &heifFile{data: b, items: make(map[uint32]*heifItem)}
+
+	var meta []byte
+	for _, box := range heifBoxes(b) {
+		if box.kind == "meta" && len(box.data) >= 4 {
+			// The version and flags go before the boxes of meta.
+			meta = box.data[4:]
+		}
+	}
+
+	if meta == nil {
+		return nil, errors.New("the heif file has no meta box")
+	}
+
+	boxes := heifBoxes(meta)
+
+	// The items are declared before anything else refers to them.
+	for _, box := range boxes {
+		if box.kind == "iinf" {
+			f.parseItemInfo(box.data)
+		}
+	}
+
+	var primary bool
+	for _, box := range boxes {
+		r := &heifReader{b: box.data}
+
+		switch box.kind {
+		case "pitm":
+			version := byte(r.uint(4) >> 24)
+			f.primary = r.id(version)
+			primary = !r.failed
+		case "iloc":
+			f.parseItemLocations(box.data)
+		case "iprp":
+			f.parseItemProperties(box.data)
+		case "iref":
+			version := byte(r.uint(4) >> 24)
+			for _, ref := range heifBoxes(r.b) {
+				rr := &heifReader{b: ref.data}
+				from := rr.id(version)
+				to := make([]uint32, rr.uint(2))
+				for i := range to {
+					to[i] = rr.id(version)
+				}
+
+				if !rr.failed {
+					f.refs = append(f.refs, heifRef{kind: ref.kind, from: from, to: to})
+				}
+			}
+		case "idat":
+			f.idat = box.data
+		}
+	}
+
+	if _, ok := f.items[f.primary]; !ok || !primary {
+		return nil, errNoPrimaryImage
+	}
+
+	return f, nil
+}
+
+// parseItemInfo reads the ids and types of the items.
+func (f *heifFile) parseItemInfo(b []byte) {
+	r := &heifReader{b: b}
+
+	version := byte(r.uint(4) >> 24)
+	if version == 0 {
+		r.uint(2)
+	} else {
+		r.uint(4)
+	}
+
+	for _, box := range heifBoxes(r.b) {
+		if box.kind != "infe" {
+			continue
+		}
+
+		r := &heifReader{b: box.data}
+		versionAndFlags := r.uint(4)
+
+		// Only the versions 2 and 3 tell the type of the items.
+		version := byte(versionAndFlags >> 24)
+		if version < 2 {
+			continue
+		}
+
+		item := &heifItem{hidden: versionAndFlags&1 == 1}
+		item.id = r.id(version - 2)
+		// The protection index.
+		r.uint(2)
+		item.kind = string(binary.BigEndian.AppendUint32(nil, uint32(r.uint(4))))
+
+		if !r.failed {
+			f.items[item.id] = item
+		}
+	}
+}
+
+// parseItemLocations reads where the data of every item is.
+func (f *heifFile) parseItemLocations(b []byte) {
+	r := &heifReader{b: b}
+
+	version := byte(r.uint(4) >> 24)
+	sizes := r.uint(2)
+	offsetSize, lengthSize := int(sizes>>12&0xf), int(sizes>>8&0xf)
+	baseOffsetSize, indexSize := int(sizes>>4&0xf), int(sizes&0xf)
+
+	if version == 0 {
+		indexSize = 0
+	}
+
+	var count uint64
+	if version < 2 {
+		count = r.uint(2)
+	} else {
+		count = r.uint(4)
+	}
+
+	for i := uint64(0); i < count && !r.failed; i++ {
+		id := r.id(version / 2)
+
+		var idat bool
+		if version > 0 {
+			idat = r.uint(2)&0xf == 1
+		}
+
+		// The data reference index, only the data of the file itself is supported.
+		r.uint(2)
+		baseOffset := r.uint(baseOffsetSize)
+
+		extents := make([]heifExtent, r.uint(2))
+		for j := range extents {
+			r.uint(indexSize)
+			extents[j].offset = r.uint(offsetSize)
+			extents[j].length = r.uint(lengthSize)
+		}
+
+		if item, ok := f.items[id]; ok && !r.failed {
+			item.idat, item.baseOffset, item.extents = idat, baseOffset, extents
+		}
+	}
+}
+
+// parseItemProperties reads the properties of the items, e.g. their size or rotation.
+func (f *heifFile) parseItemProperties(b []byte) {
+	var properties []heifBox
+
+	boxes := heifBoxes(b)
+	for _, box := range boxes {
+		if box.kind == "ipco" {
+			properties = heifBoxes(box.data)
+		}
+	}
+
+	for _, box := range boxes {
+		if box.kind != "ipma" {
+			continue
+		}
+
+		r := &heifReader{b: box.data}
+		versionAndFlags := r.uint(4)
+		version := byte(versionAndFlags >> 24)
+
+		// The index of the properties is 15 bits long if the first flag is set, or 7 bits long otherwise.
+		indexSize, indexMask := 1, uint64(0x7f)
+		if versionAndFlags&1 == 1 {
+			indexSize, indexMask = 2, 0x7fff
+		}
+
+		count := r.uint(4)
+		for i := uint64(0); i < count && !r.failed; i++ {
+			item := f.items[r.id(version)]
+
+			associations := r.uint(1)
+			for j := uint64(0); j < associations; j++ {
+				// The indexes start at one, zero means no property.
+				index := int(r.uint(indexSize) & indexMask)
+				if item != nil && index > 0 && index <= len(properties) {
+					item.properties = append(item.properties, properties[index-1])
+				}
+			}
+		}
+	}
+}
+
+// property returns the first property of the item of the given kind, if any.
+func (item *heifItem) property(kind string) ([]byte, bool) {
+	for _, p := range item.properties {
+		if p.kind == kind {
+			return p.data, true
+		}
+	}
+
+	return nil, false
+}
+
+// size returns the size of the image, before its rotation, out of its spatial extents.
+func (item *heifItem) size() (image.Point, bool) {
+	ispe, ok := item.property("ispe")
+	if !ok || len(ispe) < 12 {
+		return image.Point{}, false
+	}
+
+	return image.Pt(int(binary.BigEndian.Uint32(ispe[4:])), int(binary.BigEndian.Uint32(ispe[8:]))), true
+}
+
+// orientation returns the EXIF orientation equivalent to the rotation
+// and the mirroring of the image, in the order they are applied.
+func (item *heifItem) orientation() int {
+	var (
+		degrees float64
+		flipped bool
+	)
+
+	for _, p := range item.properties {
+		if len(p.data) < 1 {
+			continue
+		}
+
+		switch p.kind {
+		case "irot":
+			// The image is rotated anticlockwise, in steps of 90 degrees.
+			clockwise := float64(360 - int(p.data[0]&3)*90)
+			// Rotating a mirrored image is rotating it the other way around before mirroring it.
+			if flipped {
+				degrees -= clockwise
+			} else {
+				degrees += clockwise
+			}
+		case "imir":
+			flipped = !flipped
+			// Mirroring on the horizontal axis is mirroring on the vertical one, upside down.
+			if p.data[0]&1 == 1 {
+				degrees += 180
+			}
+		}
+	}
+
+	degrees = float64((int(degrees)%360 + 360) % 360)
+
+	for o, op := range orientations {
+		if op.rotate == degrees && op.flip == flipped {
+			return o
+		}
+	}
+
+	return 1
+}
+
+// refsFrom returns the items the item refers to, with references of the given kind.
+func (f *heifFile) refsFrom(id uint32, kind string) []uint32 {
+	var ids []uint32
+	for _, ref := range f.refs {
+		if ref.from == id && ref.kind == kind {
+			ids = append(ids, ref.to...)
+		}
+	}
+
+	return ids
+}
+
+// itemData returns the data of the item, put together out of its extents.
+func (f *heifFile) itemData(item *heifItem) ([]byte, error) {
+	source := f.data
+	if item.idat {
+		source = f.idat
+	}
+
+	var data []byte
+	for _, e := range item.extents {
+		start := item.baseOffset + e.offset
+		end := start + e.length
+		// A zero length means the data goes on up to the end.
+		if e.length == 0 {
+			end = uint64(len(source))
+		}
+
+		if start > end || end > uint64(len(source)) {
+			return nil, fmt.Errorf("the data of the heif item %d is out of bounds", item.id)
+		}
+
+		data = append(data, source[start:end]...)
+	}
+
+	return data, nil
+}
+
+// images returns the number of images of the file that are meant to be shown,
+// leaving out their tiles, thumbnails and auxiliary images, e.g. the alpha channel.
+func (f *heifFile) images() int {
+	parts := make(map[uint32]bool)
+	for _, ref := range f.refs {
+		switch ref.kind {
+		case "dimg":
+			for _, id := range ref.to {
+				parts[id] = true
+			}
+		case "thmb", "auxl":
+			parts[ref.from] = true
+		}
+	}
+
+	var count int
+	for id, item := range f.items {
+		if !item.hidden && !parts[id] && slices.Contains([]string{"hvc1", "grid", "iden", "av01", "jpeg"}, item.kind) {
+			count++
+		}
+	}
+
+	return count
+}
+
+// metadata returns the metadata of the primary image. Its EXIF orientation
+// is informative only, the image is turned upright by its properties instead,
+// so it's set back to the default one.
+func (f *heifFile) metadata() metadata {
+	primary := f.items[f.primary]
+
+	var m metadata
+
+	// The EXIF data describes the primary image, or any image if it doesn't tell.
+	var exifItem *heifItem
+	for _, ref := range f.refs {
+		if item, ok := f.items[ref.from]; ok && item.kind == "Exif" && ref.kind == "cdsc" {
+			if exifItem == nil || slices.Contains(ref.to, f.primary) {
+				exifItem = item
+			}
+		}
+	}
+
+	if exifItem != nil {
+		// The data starts with the offset of the tiff header.
+		data, err := f.itemData(exifItem)
+		if err == nil && len(data) >= 4 {
+			if offset := 4 + uint64(binary.BigEndian.Uint32(data)); offset < uint64(len(data)) {
+				m.exif = data[offset:]
+			}
+		}
+	}
+
+	// The color profile is either restricted or unrestricted ICC.
+	if colr, ok := primary.property("colr"); ok && len(colr) > 4 {
+		if kind := string(colr[:4]); kind == "prof" || kind == "rICC" {
+			m.icc = colr[4:]
+		}
+	}
+
+	return m.oriented()
+}
+
+// tiles returns the images the primary image is made of, alongside
+// the size of the primary image and the number of columns of tiles.
+func (f *heifFile) tiles() ([]*heifItem, image.Point, int, error) {
+	primary := f.items[f.primary]
+
+	switch primary.kind {
+	case "hvc1":
+		size, ok := primary.size()
+		if !ok {
+			return nil, image.Point{}, 0, fmt.Errorf("the heif image %d has no size", primary.id)
+		}
+
+		return []*heifItem{primary}, size, 1, nil
+	case "grid":
+		data, err := f.itemData(primary)
+		if err != nil {
+			return nil, image.Point{}, 0, err
+		}
+
+		r := &heifReader{b: data}
+		// The version, followed by the flags, which tell if the size is 16 or 32 bits long.
+		r.uint(1)
+		fieldSize := 2
+		if r.uint(1)&1 == 1 {
+			fieldSize = 4
+		}
+
+		rows, columns := int(r.uint(1))+1, int(r.uint(1))+1
+		size := image.Pt(int(r.uint(fieldSize)), int(r.uint(fieldSize)))
+		if r.failed {
+			return nil, image.Point{}, 0, fmt.Errorf("the grid of the heif image %d is truncated", primary.id)
+		}
+
+		var tiles []*heifItem
+		for _, id := range f.refsFrom(primary.id, "dimg") {
+			tile, ok := f.items[id]
+			if !ok || tile.kind != "hvc1" {
+				return nil, image.Point{}, 0, fmt.Errorf("the tile %d of the heif image is not a HEVC image", id)
+			}
+			tiles = append(tiles, tile)
+		}
+
+		if len(tiles) != rows*columns {
+			return nil, image.Point{}, 0, fmt.Errorf("the heif image has %d tiles, instead of %d", len(tiles), rows*columns)
+		}
+
+		return tiles, size, columns, nil
+	}
+
+	return nil, image.Point{}, 0, fmt.Errorf("heif images of type %s are not supported", primary.kind)
+}
+
+// hevcStream returns the HEVC bitstream of the image, as a sequence of NAL units
+// that start with a start code, led by the parameter sets of its decoder configuration.
+func (f *heifFile) hevcStream(item *heifItem) ([]byte, error) {
+	hvcC, ok := item.property("hvcC")
+	if !ok || len(hvcC) < 23 {
+		return nil, fmt.Errorf("the heif image %d has no HEVC decoder configuration", item.id)
+	}
+
+	startCode := []byte{0, 0, 0, 1}
+	var stream []byte
+
+	// The parameter sets are grouped in arrays, by the type of their NAL units.
+	r := &heifReader{b: hvcC[22:]}
+	arrays := r.uint(1)
+	for i := uint64(0); i < arrays; i++ {
+		r.uint(1)
+		units := r.uint(2)
+		for j := uint64(0); j < units; j++ {
+			length := int(r.uint(2))
+			if length > len(r.b) {
+				return nil, fmt.Errorf("the HEVC decoder configuration of the heif image %d is truncated", item.id)
+			}
+
+			stream = append(append(stream, startCode...), r.b[:length]...)
+			r.b = r.b[length:]
+		}
+	}
+
+	data, err := f.itemData(item)
+	if err != nil {
+		return nil, err
+	}
+
+	// The NAL units of the image are preceded by their length, instead of a start code.
+	lengthSize := int(hvcC[21]&3) + 1
+	r = &heifReader{b: data}
+	for len(r.b) > 0 {
+		length := int(r.uint(lengthSize))
+		if r.failed || length > len(r.b) {
+			return nil, fmt.Errorf("the data of the heif image %d is truncated", item.id)
+		}
+
+		stream = append(append(stream, startCode...), r.b[:length]...)
+		r.b = r.b[length:]
+	}
+
+	return stream, nil
+}
+
+// decodeHEIF decodes the primary image of a heif file, and returns it alongside
+// its metadata. The tiles of the image are decoded by the HEVC decoder of ffmpeg,
+// and put together afterwards.
+func decodeHEIF(ctx context.Context, fileBytes []byte) (image.Image, metadata, error) {
+	if CurrentBackend() == BackendGo {
+		return nil, metadata{}, fmt.Errorf("decoding heic images requires ffmpeg, which the %s image backend doesn't use", BackendGo)
+	}
+
+	f, err := parseHEIF(fileBytes)
+	if err != nil {
+		return nil, metadata{}, err
+	}
+
+	tiles, size, columns, err := f.tiles()
+	if err != nil {
+		return nil, metadata{}, err
+	}
+
+	tileSize, ok := tiles[0].size()
+	if !ok {
+		return nil, metadata{}, fmt.Errorf("the heif image %d has no size", tiles[0].id)
+	}
+
+	// The sizes are read from the file as they are, so they are checked before
+	// allocating the image: the tiles have to cover it, and neither can be too large.
+	rows := len(tiles) / columns
+	switch {
+	case tileSize.X <= 0 || tileSize.Y <= 0 || tileSize.X > maxDimension || tileSize.Y > maxDimension:
+		return nil, metadata{}, fmt.Errorf("the tiles of the heif image have an invalid size: %dx%d", tileSize.X, tileSize.Y)
+	case size.X <= 0 || size.Y <= 0 || size.X > maxDimension || size.Y > maxDimension:
+		return nil, metadata{}, fmt.Errorf("the heif image has an invalid size: %dx%d", size.X, size.Y)
+	case size.X > columns*tileSize.X || size.Y > rows*tileSize.Y:
+		return nil, metadata{}, fmt.Errorf(
+			"the heif image is %dx%d pixels, larger than its %dx%d tiles of %dx%d pixels",
+			size.X, size.Y, columns, rows, tileSize.X, tileSize.Y,
+		)
+	}
+
+	// Every tile is a frame of the same stream.
+	var stream []byte
+	for _, tile := range tiles {
+		tileStream, err := f.hevcStream(tile)
+		if err != nil {
+			return nil, metadata{}, err
+		}
+		stream = append(stream, tileStream...)
+	}
+
+	tmpStream, err := os.CreateTemp("/tmp", "*.hevc")
+	if err != nil {
+		return nil, metadata{}, fmt.Errorf("error creating temporary HEVC file: %w", err)
+	}
+	defer os.Remove(tmpStream.Name())
+
+	if _, err := tmpStream.Write(stream); err != nil {
+		tmpStream.Close()
+		return nil, metadata{}, fmt.Errorf("error writting the temporary HEVC file: %w", err)
+	}
+	tmpStream.Close()
+
+	// The frames are written to the standard output as they are, pixel by pixel.
+	args := ffmpeg.Input(tmpStream.Name(), ffmpeg.KwArgs{"f": "hevc"}).
+		Output("pipe:1", ffmpeg.KwArgs{"f": "rawvideo", "pix_fmt": "rgba"}).
+		GetArgs()
+
+	frames := new(bytes.Buffer)
+	if err := util.RunCommand(ctx, util.FFmpeg, frames, os.Stdout, "ffmpeg", args...); err != nil {
+		return nil, metadata{}, err
+	}
+
+	frameSize := tileSize.X * tileSize.Y * 4
+	if frames.Len() != frameSize*len(tiles) {
+		return nil, metadata{}, fmt.Errorf("error decoding the heif image: expected %d tiles of %dx%d pixels", len(tiles), tileSize.X, tileSize.Y)
+	}
+
+	// The tiles are laid out row by row, and the ones on the edges are cropped by the size of the image.
+	img := image.NewNRGBA(image.Rectangle{Max: size})
+	for i := range tiles {
+		tile := &image.NRGBA{
+			Pix:    frames.Bytes()[i*frameSize : (i+1)*frameSize],
+			Stride: tileSize.X * 4,
+			Rect:   image.Rectangle{Max: tileSize},
+		}
+
+		origin := image.Pt(i%columns*tileSize.X, i/columns*tileSize.Y)
+		draw.Draw(img, tile.Bounds().Add(origin), tile, image.Point{}, draw.Src)
+	}
+
+	// The rotation and the mirroring are part of the image, rather than metadata.
+	oriented, err := geometry{orientation: f.items[f.primary].orientation()}.Apply(img)
+	if err != nil {
+		return nil, metadata{}, err
+	}
+
+	return oriented, f.metadata(), nil
+}
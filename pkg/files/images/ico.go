@@ -0,0 +1,522 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+
+	"github.com/danvergara/morphos/pkg/files"
+)
+
+const (
+	// IconSizesOption sets the sizes of the images of ico files, in pixels.
+	IconSizesOption = "icon_sizes"
+
+	// maxIconSize is the size of the largest image an ico file can list.
+	maxIconSize = 256
+)
+
+// defaultIconSizes are the sizes of the images of ico files, from the smallest
+// one used by browser tabs to the largest one used by Windows.
+var defaultIconSizes = []int{16, 32, 48, 64, 128, 256}
+
+// icoOutputOptions are the options accepted when converting to ico.
+var icoOutputOptions = files.Schema{
+	{
+		Name:    IconSizesOption,
+		Label:   "Icon sizes",
+		Help:    "Comma separated sizes of the images of the icon, in pixels, up to 256",
+		Type:    files.StringOption,
+		Default: joinSizes(defaultIconSizes),
+		Validate: func(spec string) error {
+			_, err := parseIconSizes(spec)
+			return err
+		},
+	},
+	stripMetadataOption,
+}
+
+// Ico struct implements the File and Image interface from the files pkg.
+// Ico files hold several images of the same icon, at different sizes,
+// either as png images or as bitmaps.
+type Ico struct {
+	compatibleFormats   map[string][]string
+	compatibleMIMETypes map[string][]string
+}
+
+func init() {
+	files.Register(files.Format{
+		Name:          ICO,
+		Category:      files.Img,
+		MIMETypes:     []string{"image/x-icon", "image/vnd.microsoft.icon"},
+		Decoder:       func(string) files.File { return NewIco() },
+		InputOptions:  inputOptions,
+		OutputOptions: icoOutputOptions,
+		Encoder:       encodeICO,
+		EncodesFrom:   []string{PNG},
+		// Icons are scaled down, so they are avoided as intermediate steps.
+		Cost: 2,
+	})
+}
+
+// NewIco returns a pointer to an Ico instance.
+// The Ico object is set with a map with list of supported file formats.
+func NewIco() *Ico {
+	i := Ico{
+		compatibleFormats: map[string][]string{
+			"Image": {
+				PNG,
+				JPG,
+				JPEG,
+				GIF,
+				WEBP,
+				TIFF,
+				BMP,
+				AVIF,
+				JXL,
+			},
+			"Document": {
+				PDF,
+				TXT,
+				HOCR,
+				OCRPDF,
+			},
+		},
+
+		compatibleMIMETypes: map[string][]string{
+			"Image": {
+				PNG,
+				JPG,
+				JPEG,
+				GIF,
+				WEBP,
+				TIFF,
+				BMP,
+				AVIF,
+				JXL,
+			},
+			"Document": {
+				PDF,
+				TXT,
+				HOCR,
+				OCRPDF,
+			},
+		},
+	}
+
+	return &i
+}
+
+// SupportedFormats returns a map with a slice of supported files.
+// Every key of the map represents the kind of a file.
+func (i *Ico) SupportedFormats() map[string][]string {
+	return i.compatibleFormats
+}
+
+// SupportedMIMETypes returns a map with a slice of supported MIME types.
+func (i *Ico) SupportedMIMETypes() map[string][]string {
+	return i.compatibleMIMETypes
+}
+
+// ConvertTo method converts a given file to a target format.
+// This method returns a file in form of a slice of bytes.
+// The largest image of the file is the one converted.
+func (i *Ico) ConvertTo(ctx context.Context, fileType, subType string, file io.Reader, opts files.ConvertOptions) (io.Reader, error) {
+	var result []byte
+
+	compatibleFormats, ok := i.SupportedFormats()[fileType]
+	if !ok {
+		return nil, fmt.Errorf("ConvertTo: file type not supported: %s", fileType)
+	}
+
+	if !slices.Contains(compatibleFormats, subType) {
+		return nil, fmt.Errorf("ConvertTo: file sub-type not supported: %s", subType)
+	}
+
+	img, err := decodeICO(file)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(fileType) {
+	case imageType:
+		return convertDecoded(ctx, subType, img, metadata{}, opts)
+	case documentType:
+		result, err = convertToDocument(ctx, subType, img, metadata{}, opts)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"ConvertTo: error at converting image to another format: %w",
+				err,
+			)
+		}
+	}
+
+	return bytes.NewReader(result), nil
+}
+
+// Inspect describes the largest image of the file.
+// This method implements the files.Inspector interface.
+func (i *Ico) Inspect(_ context.Context, file io.Reader) (any, error) {
+	return readICOInfo(file)
+}
+
+// ImageType method returns the file format of the current image.
+// This method implements the Image interface.
+func (i *Ico) ImageType() string {
+	return ICO
+}
+
+// icoEntry is an image of an ico file, as listed by its directory.
+type icoEntry struct {
+	width    int
+	height   int
+	bitCount int
+	data     []byte
+}
+
+var errNoIcons = errors.New("the ico file holds no images")
+
+// parseICO returns the images listed by the directory of an ico file.
+func parseICO(b []byte) ([]icoEntry, error) {
+	if len(b) < 6 || binary.LittleEndian.Uint16(b[0:]) != 0 || binary.LittleEndian.Uint16(b[2:]) != 1 {
+		return nil, errors.New("not an ico file")
+	}
+
+	count := int(binary.LittleEndian.Uint16(b[4:]))
+	if len(b) < 6+count*16 {
+		return nil, errors.New("the directory of the ico file is truncated")
+	}
+
+	entries := make([]icoEntry, 0, count)
+
+	for n := 0; n < count; n++ {
+		d := b[6+n*16:]
+
+		size := int(binary.LittleEndian.Uint32(d[8:]))
+		offset := int(binary.LittleEndian.Uint32(d[12:]))
+		if offset < 0 || size < 0 || offset > len(b) || size > len(b)-offset {
+			return nil, fmt.Errorf("the image %d of the ico file is out of bounds", n)
+		}
+
+		// A size of zero stands for 256 pixels.
+		e := icoEntry{
+			width:    int(d[0]),
+			height:   int(d[1]),
+			bitCount: int(binary.LittleEndian.Uint16(d[6:])),
+			data:     b[offset : offset+size],
+		}
+		if e.width == 0 {
+			e.width = maxIconSize
+		}
+		if e.height == 0 {
+			e.height = maxIconSize
+		}
+
+		entries = append(entries, e)
+	}
+
+	if len(entries) == 0 {
+		return nil, errNoIcons
+	}
+
+	return entries, nil
+}
+
+// largestIcon returns the largest image of the file,
+// the one with the most colors if there are several of the same size.
+func largestIcon(entries []icoEntry) icoEntry {
+	return slices.MaxFunc(entries, func(a, b icoEntry) int {
+		if d := a.width*a.height - b.width*b.height; d != 0 {
+			return d
+		}
+		return a.bitCount - b.bitCount
+	})
+}
+
+// decodeICO decodes the largest image of an ico file.
+func decodeICO(file io.Reader) (image.Image, error) {
+	fileBytes, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := parseICO(fileBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	data := largestIcon(entries).data
+
+	if bytes.HasPrefix(data, pngHeader) {
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("error decoding the png image of the ico file: %w", err)
+		}
+
+		return img, nil
+	}
+
+	return decodeDIB(data)
+}
+
+// decodeDIB decodes the bitmaps of ico files, a bmp image without its file header,
+// whose height is doubled, since the color of the pixels is followed by a mask
+// of one bit per pixel that tells which ones are transparent.
+func decodeDIB(b []byte) (image.Image, error) {
+	if len(b) < 40 {
+		return nil, errors.New("the bitmap of the ico file is truncated")
+	}
+
+	headerSize := int(binary.LittleEndian.Uint32(b[0:]))
+	width := int(int32(binary.LittleEndian.Uint32(b[4:])))
+	height := int(int32(binary.LittleEndian.Uint32(b[8:]))) / 2
+	bitCount := int(binary.LittleEndian.Uint16(b[14:]))
+	compression := binary.LittleEndian.Uint32(b[16:])
+	colorsUsed := int(binary.LittleEndian.Uint32(b[32:]))
+
+	if headerSize < 40 || headerSize > len(b) {
+		return nil, errors.New("the bitmap of the ico file has an invalid header")
+	}
+
+	if width <= 0 || height <= 0 || width > maxDimension || height > maxDimension {
+		return nil, fmt.Errorf("the bitmap of the ico file has an invalid size: %dx%d", width, height)
+	}
+
+	if !slices.Contains([]int{1, 4, 8, 24, 32}, bitCount) {
+		return nil, fmt.Errorf("bitmaps of %d bits per pixel are not supported", bitCount)
+	}
+
+	// Only uncompressed bitmaps are found in ico files, 32 bits ones
+	// may be stored as bit fields, with the masks after the header.
+	offset := headerSize
+	switch {
+	case compression == 0:
+	case compression == 3 && bitCount == 32:
+		if headerSize == 40 {
+			offset += 12
+		}
+	default:
+		return nil, fmt.Errorf("compressed bitmaps are not supported")
+	}
+
+	var palette []color.NRGBA
+	if bitCount <= 8 {
+		n := 1 << bitCount
+		if colorsUsed > 0 && colorsUsed < n {
+			n = colorsUsed
+		}
+
+		if len(b) < offset+n*4 {
+			return nil, errors.New("the palette of the bitmap is truncated")
+		}
+
+		for i := 0; i < n; i++ {
+			p := b[offset+i*4:]
+			palette = append(palette, color.NRGBA{R: p[2], G: p[1], B: p[0], A: 0xff})
+		}
+		offset += n * 4
+	}
+
+	// Rows are padded to 4 bytes, and stored from the bottom up.
+	stride := (width*bitCount + 31) / 32 * 4
+	maskStride := (width + 31) / 32 * 4
+
+	pixels := b[offset:]
+	if len(pixels) < stride*height {
+		return nil, errors.New("the pixels of the bitmap are truncated")
+	}
+
+	// Some encoders leave the mask out of 32 bits bitmaps.
+	var mask []byte
+	if len(pixels) >= stride*height+maskStride*height {
+		mask = pixels[stride*height:]
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	hasAlpha := false
+
+	for y := 0; y < height; y++ {
+		row := pixels[(height-1-y)*stride:]
+
+		for x := 0; x < width; x++ {
+			var c color.NRGBA
+
+			switch bitCount {
+			case 32:
+				c = color.NRGBA{R: row[x*4+2], G: row[x*4+1], B: row[x*4], A: row[x*4+3]}
+				hasAlpha = hasAlpha || c.A != 0
+			case 24:
+				c = color.NRGBA{R: row[x*3+2], G: row[x*3+1], B: row[x*3], A: 0xff}
+			default:
+				perByte := 8 / bitCount
+				shift := 8 - bitCount*(x%perByte+1)
+				index := int(row[x/perByte]>>shift) & (1<<bitCount - 1)
+				if index < len(palette) {
+					c = palette[index]
+				}
+			}
+
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	// The alpha channel of 32 bits bitmaps takes precedence over the mask,
+	// unless it's left empty.
+	if bitCount == 32 && hasAlpha {
+		return img, nil
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := img.NRGBAAt(x, y)
+			c.A = 0xff
+
+			if mask != nil && mask[(height-1-y)*maskStride+x/8]&(0x80>>(x%8)) != 0 {
+				c = color.NRGBA{}
+			}
+
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	return img, nil
+}
+
+// encodeICO converts a png image to an ico file, with an image per size set in
+// the options. Every image is a png image, which every browser and every version
+// of Windows since Vista reads.
+// It's the Encoder of the ico format.
+func encodeICO(_ context.Context, _ string, file io.Reader, opts files.ConvertOptions) (io.Reader, error) {
+	sizes, err := parseIconSizes(opts.String(IconSizesOption, joinSizes(defaultIconSizes)))
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := decodeIconSource(file, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := writeICO(img, sizes, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding the ico file: %w", err)
+	}
+
+	return bytes.NewReader(result), nil
+}
+
+// decodeIconSource decodes the png image icons are made of,
+// once it's turned upright and the geometry operations set in the options are applied.
+func decodeIconSource(file io.Reader, opts files.ConvertOptions) (image.Image, error) {
+	img, m, err := decodeImage(file, png.Decode)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding the image: %w", err)
+	}
+
+	g, err := geometryFromOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if autoOrient(opts) {
+		g.orientation = m.orientation()
+	}
+
+	return g.Apply(img)
+}
+
+// writeICO returns an ico file with an image of every size.
+func writeICO(img image.Image, sizes []int, opts files.ConvertOptions) ([]byte, error) {
+	var images [][]byte
+
+	for _, size := range sizes {
+		buf := new(bytes.Buffer)
+		if err := Encode(buf, PNG, squareIcon(img, size, opts), opts); err != nil {
+			return nil, err
+		}
+
+		images = append(images, buf.Bytes())
+	}
+
+	// The directory, an entry per image, is followed by the images.
+	b := binary.LittleEndian.AppendUint16(nil, 0)
+	b = binary.LittleEndian.AppendUint16(b, 1)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(images)))
+
+	offset := 6 + 16*len(images)
+
+	for n, size := range sizes {
+		// The size is stored in a byte, where zero stands for 256 pixels.
+		b = append(b, byte(size), byte(size), 0, 0)
+		b = binary.LittleEndian.AppendUint16(b, 1)
+		b = binary.LittleEndian.AppendUint16(b, 32)
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(images[n])))
+		b = binary.LittleEndian.AppendUint32(b, uint32(offset))
+
+		offset += len(images[n])
+	}
+
+	return append(b, bytes.Join(images, nil)...), nil
+}
+
+// squareIcon returns the image scaled to fit a square of the given size,
+// centered on a transparent background.
+func squareIcon(img image.Image, size int, opts files.ConvertOptions) *image.NRGBA {
+	g := geometry{
+		width:  size,
+		height: size,
+		resize: ResizeFit,
+		kernel: opts.String(KernelOption, KernelLanczos),
+	}
+
+	// The geometry is only resized, which doesn't fail.
+	scaled, _ := g.Apply(img)
+
+	b := scaled.Bounds()
+	offset := image.Pt((size-b.Dx())/2, (size-b.Dy())/2)
+
+	icon := image.NewNRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(icon, b.Sub(b.Min).Add(offset), scaled, b.Min, draw.Src)
+
+	return icon
+}
+
+// parseIconSizes parses a comma separated list of sizes, e.g. 16,32,48.
+// The sizes are returned sorted, without duplicates.
+func parseIconSizes(spec string) ([]int, error) {
+	var sizes []int
+
+	for _, s := range strings.Split(spec, ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || size < 1 || size > maxIconSize {
+			return nil, fmt.Errorf("invalid icon size %q, expected a number from 1 to %d", s, maxIconSize)
+		}
+
+		sizes = append(sizes, size)
+	}
+
+	slices.Sort(sizes)
+
+	return slices.Compact(sizes), nil
+}
+
+// joinSizes returns the sizes as a comma separated list.
+func joinSizes(sizes []int) string {
+	s := make([]string, len(sizes))
+	for i, size := range sizes {
+		s[i] = strconv.Itoa(size)
+	}
+
+	return strings.Join(s, ",")
+}
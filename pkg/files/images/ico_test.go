@@ -0,0 +1,243 @@
+package images_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/danvergara/morphos/pkg/files"
+	"github.com/danvergara/morphos/pkg/files/images"
+)
+
+// convertPNG converts the png image to the target format, through the encoder of the format.
+func convertPNG(t *testing.T, target string, values url.Values) []byte {
+	schema, err := files.ConversionOptions(images.PNG, target)
+	require.NoError(t, err)
+
+	opts, err := schema.Parse(values)
+	require.NoError(t, err)
+
+	input, err := os.ReadFile("testdata/gopher_pirate.png")
+	require.NoError(t, err)
+
+	result, err := files.ConvertTo(context.Background(), images.NewPng(), images.PNG, target, bytes.NewReader(input), opts)
+	require.NoError(t, err)
+
+	resultBytes, err := io.ReadAll(result)
+	require.NoError(t, err)
+
+	return resultBytes
+}
+
+// bitmapIcon returns an ico file with a single 2x2 bitmap of the given bits per pixel.
+// The pixels go from the bottom row up, and the mask makes the top right pixel transparent.
+func bitmapIcon(bitCount int, palette, pixels []byte) []byte {
+	header := u32le(40, 2, 4)
+	header = binary.LittleEndian.AppendUint16(header, 1)
+	header = binary.LittleEndian.AppendUint16(header, uint16(bitCount))
+	header = append(header, make([]byte, 24)...)
+
+	// Every row of the mask is padded to 4 bytes.
+	mask := []byte{0, 0, 0, 0, 0x40, 0, 0, 0}
+	dib := bytes.Join([][]byte{header, palette, pixels, mask}, nil)
+
+	dir := []byte{0, 0, 1, 0, 1, 0, 2, 2, 0, 0, 1, 0}
+	dir = binary.LittleEndian.AppendUint16(dir, uint16(bitCount))
+	dir = append(dir, u32le(len(dib), 22)...)
+
+	return append(dir, dib...)
+}
+
+// u32le returns the values as little endian 32 bits integers.
+func u32le(values ...int) []byte {
+	var b []byte
+	for _, v := range values {
+		b = binary.LittleEndian.AppendUint32(b, uint32(v))
+	}
+	return b
+}
+
+func TestConvertToICO(t *testing.T) {
+	var tests = []struct {
+		name     string
+		values   url.Values
+		expected images.ImageInfo
+	}{
+		{
+			name:   "default sizes",
+			values: url.Values{},
+			expected: images.ImageInfo{
+				Width:      256,
+				Height:     256,
+				ColorModel: "NRGBA",
+				BitDepth:   8,
+				Frames:     6,
+			},
+		},
+		{
+			name:   "custom sizes",
+			values: url.Values{"icon_sizes": {"32, 16,32"}},
+			expected: images.ImageInfo{
+				Width:      32,
+				Height:     32,
+				ColorModel: "NRGBA",
+				BitDepth:   8,
+				Frames:     2,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ico := convertPNG(t, images.ICO, tc.values)
+
+			info, err := images.NewIco().Inspect(context.Background(), bytes.NewReader(ico))
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, info)
+		})
+	}
+}
+
+func TestConvertICO(t *testing.T) {
+	var tests = []struct {
+		name string
+		file []byte
+		// expected are the pixels, from the top row down.
+		expected []color.NRGBA
+	}{
+		{
+			name: "32 bits with alpha",
+			file: bitmapIcon(32, nil, []byte{
+				0, 0, 255, 255, 0, 255, 0, 128,
+				255, 0, 0, 255, 0, 0, 0, 0,
+			}),
+			expected: []color.NRGBA{
+				{B: 255, A: 255}, {},
+				{R: 255, A: 255}, {G: 255, A: 128},
+			},
+		},
+		{
+			name: "1 bit with mask",
+			file: bitmapIcon(1, []byte{0, 0, 0, 0, 255, 255, 255, 0}, []byte{
+				0x40, 0, 0, 0,
+				0x80, 0, 0, 0,
+			}),
+			expected: []color.NRGBA{
+				{R: 255, G: 255, B: 255, A: 255}, {},
+				{A: 255}, {R: 255, G: 255, B: 255, A: 255},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			schema, err := files.ConversionOptions(images.ICO, images.PNG)
+			require.NoError(t, err)
+
+			opts, err := schema.Parse(url.Values{})
+			require.NoError(t, err)
+
+			result, err := images.NewIco().ConvertTo(context.Background(), "Image", images.PNG, bytes.NewReader(tc.file), opts)
+			require.NoError(t, err)
+
+			img, err := png.Decode(result)
+			require.NoError(t, err)
+			require.Equal(t, image.Pt(2, 2), img.Bounds().Size())
+
+			var pixels []color.NRGBA
+			for y := 0; y < 2; y++ {
+				for x := 0; x < 2; x++ {
+					pixels = append(pixels, color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA))
+				}
+			}
+			require.Equal(t, tc.expected, pixels)
+		})
+	}
+}
+
+func TestConvertToFavicon(t *testing.T) {
+	bundle := convertPNG(t, images.FAVICON, url.Values{
+		"app_name":         {"Gopher"},
+		"background_color": {"#036"},
+	})
+
+	zipReader, err := zip.NewReader(bytes.NewReader(bundle), int64(len(bundle)))
+	require.NoError(t, err)
+
+	contents := make(map[string][]byte)
+	for _, f := range zipReader.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+
+		contents[f.Name], err = io.ReadAll(rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+	}
+
+	sizes := map[string]int{
+		"favicon-16x16.png":          16,
+		"favicon-32x32.png":          32,
+		"apple-touch-icon.png":       180,
+		"android-chrome-192x192.png": 192,
+		"android-chrome-512x512.png": 512,
+	}
+
+	require.Len(t, contents, len(sizes)+2)
+
+	for name, size := range sizes {
+		config, err := png.DecodeConfig(bytes.NewReader(contents[name]))
+		require.NoError(t, err, name)
+		require.Equal(t, size, config.Width, name)
+		require.Equal(t, size, config.Height, name)
+	}
+
+	info, err := images.NewIco().Inspect(context.Background(), bytes.NewReader(contents["favicon.ico"]))
+	require.NoError(t, err)
+	require.Equal(t, 6, info.(images.ImageInfo).Frames)
+
+	// The gopher is taller than wide, so the sides of the icons are left transparent,
+	// except for the apple touch icon, which is drawn on the background color.
+	appleTouchIcon, err := png.Decode(bytes.NewReader(contents["apple-touch-icon.png"]))
+	require.NoError(t, err)
+	require.Equal(t, color.NRGBA{G: 0x33, B: 0x66, A: 255}, color.NRGBAModel.Convert(appleTouchIcon.At(0, 0)))
+
+	androidIcon, err := png.Decode(bytes.NewReader(contents["android-chrome-512x512.png"]))
+	require.NoError(t, err)
+	require.Equal(t, uint8(0), color.NRGBAModel.Convert(androidIcon.At(0, 0)).(color.NRGBA).A)
+
+	var manifest struct {
+		Name            string `json:"name"`
+		BackgroundColor string `json:"background_color"`
+		Icons           []struct {
+			Src   string `json:"src"`
+			Sizes string `json:"sizes"`
+		} `json:"icons"`
+	}
+	require.NoError(t, json.Unmarshal(contents["site.webmanifest"], &manifest))
+	require.Equal(t, "Gopher", manifest.Name)
+	require.Equal(t, "#036", manifest.BackgroundColor)
+	require.Len(t, manifest.Icons, 2)
+	require.Equal(t, "/android-chrome-512x512.png", manifest.Icons[1].Src)
+	require.Equal(t, "512x512", manifest.Icons[1].Sizes)
+}
+
+func TestConvertToFaviconWithInvalidColor(t *testing.T) {
+	schema, err := files.ConversionOptions(images.PNG, images.FAVICON)
+	require.NoError(t, err)
+
+	_, err = schema.Parse(url.Values{"theme_color": {"blue"}})
+	require.Error(t, err)
+}
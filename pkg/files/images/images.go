@@ -2,16 +2,19 @@ package images
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"image"
 	"io"
-	"math/rand"
 	"os"
+	"path/filepath"
 	"strings"
-	"time"
 
 	"github.com/signintech/gopdf"
 	ffmpeg "github.com/u2takey/ffmpeg-go"
+
+	"github.com/danvergara/morphos/pkg/files"
+	"github.com/danvergara/morphos/pkg/util"
 )
 
 const (
@@ -24,49 +27,60 @@ const (
 	TIFF = "tiff"
 	BMP  = "bmp"
 	AVIF = "avif"
+	HEIC = "heic"
+	HEIF = "heif"
+	SVG  = "svg"
+	ICO  = "ico"
+	JXL  = "jxl"
+	// FAVICON is the bundle of icons and the web app manifest of a website.
+	FAVICON = "favicon"
 
 	imageMimeType = "image/"
 	imageType     = "image"
 
 	// Documents.
 	PDF = "pdf"
+	// TXT and HOCR are the text recognized in images, as plain text or as hocr,
+	// the html that holds where every word is.
+	TXT  = "txt"
+	HOCR = "hocr"
+	// OCRPDF is a searchable pdf, made of the images with the text recognized over them.
+	OCRPDF = "ocr.pdf"
 
 	documentMimeType = "application/"
 	documentType     = "document"
-
-	// letters is constant that used as a pool of letters to generate a random string.
-	letters = "abcdefghijklmnopqrstuvwxyz" +
-		"ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 )
 
-var seededRand *rand.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
-
 // toPDF returns pdf file as an slice of bytes.
-// Receives an image.Image as a parameter.
-func toPDF(img image.Image) ([]byte, error) {
-	// Sets a Rectangle based on the size of the image.
-	imgRect := gopdf.Rect{
-		W: float64(img.Bounds().Dx()),
-		H: float64(img.Bounds().Dy()),
-	}
-
+// Receives the images as parameters, every one of them drawn on a page of its own.
+func toPDF(imgs ...image.Image) ([]byte, error) {
 	// Init the pdf obkect.
 	pdf := gopdf.GoPdf{}
 
-	// Sets the size of the every pdf page,
-	// based on the dimensions of the image.
-	pdf.Start(
-		gopdf.Config{
-			PageSize: imgRect,
-		},
-	)
+	for i, img := range imgs {
+		// Sets a Rectangle based on the size of the image.
+		imgRect := gopdf.Rect{
+			W: float64(img.Bounds().Dx()),
+			H: float64(img.Bounds().Dy()),
+		}
+
+		// Sets the size of the every pdf page,
+		// based on the dimensions of the first image.
+		if i == 0 {
+			pdf.Start(
+				gopdf.Config{
+					PageSize: imgRect,
+				},
+			)
+		}
 
-	// Add a page to the PDF.
-	pdf.AddPage()
+		// Add a page to the PDF, as large as the image.
+		pdf.AddPageWithOption(gopdf.PageOption{PageSize: &imgRect})
 
-	// Draws the image on the rectangle on the page above created.
-	if err := pdf.ImageFrom(img, 0, 0, &imgRect); err != nil {
-		return nil, err
+		// Draws the image on the rectangle on the page above created.
+		if err := pdf.ImageFrom(img, 0, 0, &imgRect); err != nil {
+			return nil, err
+		}
 	}
 
 	// Creates a bytes.Buffer and writes the pdf data to it.
@@ -87,23 +101,25 @@ func ParseMimeType(mimetype string) string {
 	return strings.TrimPrefix(mimetype, imageMimeType)
 }
 
-// stringWithCharset returns a random string based a length and a charset.
-func stringWithCharset(length int, charset string) string {
-	b := make([]byte, length)
-	for i := range b {
-		b[i] = charset[seededRand.Intn(len(charset))]
+// tempOutput creates a temporary directory, where an external tool writes a file
+// of the given format. It returns the name of the file, and a function that removes it.
+// Every conversion gets a directory of its own, so conversions running at the same time
+// never write to the same file.
+func tempOutput(format string) (string, func(), error) {
+	tmpDir, err := os.MkdirTemp("", "morphos-image-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("error creating temporary directory: %w", err)
 	}
-	return string(b)
-}
 
-// randString returns a random string calling stringWithCharset and using the letters constant.
-func randString(length int) string {
-	return stringWithCharset(length, letters)
+	return filepath.Join(tmpDir, "output."+format), func() { os.RemoveAll(tmpDir) }, nil
 }
 
 // convertToImage retuns an image as io.Reader and error if something goes wrong.
-// It gets the target format as input alongside the image to be converted to that format.
-func convertToImage(target string, file io.Reader) (io.Reader, error) {
+// It gets the target format as input alongside the image to be converted to that format,
+// and the options used to encode the output image.
+// The image is converted in process with the decoder of its format, unless
+// the backend says otherwise. decode is nil if Go can't decode the image.
+func convertToImage(ctx context.Context, target string, file io.Reader, decode func(io.Reader) (image.Image, error), opts files.ConvertOptions) (io.Reader, error) {
 	// Create a buffer meant to store the input file data.
 	buf := new(bytes.Buffer)
 	if _, err := buf.ReadFrom(file); err != nil {
@@ -116,6 +132,30 @@ func convertToImage(target string, file io.Reader) (io.Reader, error) {
 	// Get the bytes off the input image.
 	inputReaderBytes := buf.Bytes()
 
+	// JPEG XL images are encoded by libjxl, whatever the backend.
+	if target == JXL {
+		fileBytes, err := encodeJXL(ctx, inputReaderBytes, decode, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		return bytes.NewReader(fileBytes), nil
+	}
+
+	inProcess, err := useGo(target, decode)
+	if err != nil {
+		return nil, err
+	}
+
+	if inProcess {
+		fileBytes, err := encodeImage(target, inputReaderBytes, decode, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		return bytes.NewReader(fileBytes), nil
+	}
+
 	// Create a temporary empty file where the input image is gonna be stored.
 	tmpInputImage, err := os.CreateTemp("/tmp", fmt.Sprintf("*.%s", target))
 	if err != nil {
@@ -128,15 +168,52 @@ func convertToImage(target string, file io.Reader) (io.Reader, error) {
 		return nil, fmt.Errorf("error writting the input reader to the temporary image file")
 	}
 
-	tmpConvertedFilename := fmt.Sprintf("/tmp/%s.%s", randString(10), target)
+	tmpConvertedFilename, cleanup, err := tempOutput(target)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
 
 	// Convert the input image to the target format.
 	// This is calling the ffmpeg command under the hood.
 	// The reason behind this is that we could avoid using different libraries,
 	// when we can use a use a single tool for multiple things.
-	if err = ffmpeg.Input(tmpInputImage.Name()).
-		Output(tmpConvertedFilename).
-		OverWriteOutput().ErrorToStdOut().Run(); err != nil {
+	// ffmpeg is killed if the context is done or it takes too long.
+	outputArgs := ffmpegOutputArgs(target, opts)
+
+	// The geometry operations are applied by ffmpeg in the same pass.
+	g, err := geometryFromOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// The image is turned upright by the filters, rather than by ffmpeg,
+	// since not every version of ffmpeg reads the orientation of images.
+	m := readMetadata(inputReaderBytes)
+	if autoOrient(opts) {
+		g.orientation = m.orientation()
+		m = m.oriented()
+	}
+
+	var filters []string
+	for _, filter := range []string{g.ffmpegFilter(), ffmpegEncodeFilter(target, opts)} {
+		if filter != "" {
+			filters = append(filters, filter)
+		}
+	}
+
+	if len(filters) > 0 {
+		outputArgs["vf"] = strings.Join(filters, ",")
+	}
+
+	// The metadata is written afterwards, as the policy says.
+	outputArgs["map_metadata"] = -1
+
+	args := ffmpeg.Input(tmpInputImage.Name(), ffmpeg.KwArgs{"noautorotate": ""}).
+		Output(tmpConvertedFilename, outputArgs).
+		OverWriteOutput().GetArgs()
+
+	if err = util.RunCommand(ctx, util.FFmpeg, os.Stdout, os.Stdout, "ffmpeg", args...); err != nil {
 		return nil, err
 	}
 
@@ -146,27 +223,68 @@ func convertToImage(target string, file io.Reader) (io.Reader, error) {
 	if err != nil {
 		return nil, err
 	}
-	defer os.Remove(cf.Name())
+	defer cf.Close()
 
 	fileBytes, err := io.ReadAll(cf)
 	if err != nil {
 		return nil, err
 	}
 
+	fileBytes = applyMetadataPolicy(fileBytes, target, opts.String(MetadataOption, MetadataStrip), m)
+
 	return bytes.NewReader(fileBytes), nil
 }
 
-func convertToDocument(target string, img image.Image) ([]byte, error) {
-	var err error
-	var result []byte
+// decodeImage decodes the image read from file with the given decoder,
+// and returns it alongside its metadata.
+func decodeImage(file io.Reader, decode func(io.Reader) (image.Image, error)) (image.Image, metadata, error) {
+	fileBytes, err := io.ReadAll(file)
+	if err != nil {
+		return nil, metadata{}, err
+	}
+
+	img, err := decode(bytes.NewReader(fileBytes))
+	if err != nil {
+		return nil, metadata{}, err
+	}
+
+	return img, readMetadata(fileBytes), nil
+}
 
+// convertToDocument returns the image as a document of the target format,
+// once it's turned upright and the geometry operations set in the options are applied.
+func convertToDocument(ctx context.Context, target string, img image.Image, m metadata, opts files.ConvertOptions) ([]byte, error) {
+	img, err := applyGeometry(img, m, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return toDocument(ctx, target, []image.Image{img}, opts)
+}
+
+// toDocument returns the images as a document of the target format, a page per image.
+// e.g. a pdf, or the text recognized in them.
+func toDocument(ctx context.Context, target string, pages []image.Image, opts files.ConvertOptions) ([]byte, error) {
 	switch target {
 	case PDF:
-		result, err = toPDF(img)
-		if err != nil {
-			return nil, err
-		}
+		return toPDF(pages...)
+	case TXT, HOCR, OCRPDF:
+		return recognize(ctx, target, pages, opts)
+	}
+
+	return nil, fmt.Errorf("document format not supported: %s", target)
+}
+
+// applyGeometry turns the image upright and applies the geometry operations set in the options.
+func applyGeometry(img image.Image, m metadata, opts files.ConvertOptions) (image.Image, error) {
+	g, err := geometryFromOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if autoOrient(opts) {
+		g.orientation = m.orientation()
 	}
 
-	return result, nil
+	return g.Apply(img)
 }
@@ -2,6 +2,7 @@ package images_test
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"os"
 	"testing"
@@ -9,12 +10,13 @@ import (
 	"github.com/gabriel-vasile/mimetype"
 	"github.com/stretchr/testify/require"
 
+	"github.com/danvergara/morphos/pkg/files"
 	"github.com/danvergara/morphos/pkg/files/images"
 )
 
 type filer interface {
 	SupportedFormats() map[string][]string
-	ConvertTo(string, string, io.Reader) (io.Reader, error)
+	ConvertTo(context.Context, string, string, io.Reader, files.ConvertOptions) (io.Reader, error)
 }
 
 type imager interface {
@@ -59,6 +61,7 @@ func TestConvertImage(t *testing.T) {
 						images.WEBP,
 						images.TIFF,
 						images.BMP,
+						images.JXL,
 					},
 				},
 			},
@@ -83,9 +86,13 @@ func TestConvertImage(t *testing.T) {
 						images.WEBP,
 						images.TIFF,
 						images.BMP,
+						images.JXL,
 					},
 					"Document": {
 						images.PDF,
+						images.TXT,
+						images.HOCR,
+						images.OCRPDF,
 					},
 				},
 			},
@@ -109,9 +116,13 @@ func TestConvertImage(t *testing.T) {
 						images.WEBP,
 						images.TIFF,
 						images.BMP,
+						images.JXL,
 					},
 					"Document": {
 						images.PDF,
+						images.TXT,
+						images.HOCR,
+						images.OCRPDF,
 					},
 				},
 			},
@@ -136,9 +147,13 @@ func TestConvertImage(t *testing.T) {
 						images.GIF,
 						images.TIFF,
 						images.BMP,
+						images.JXL,
 					},
 					"Document": {
 						images.PDF,
+						images.TXT,
+						images.HOCR,
+						images.OCRPDF,
 					},
 				},
 			},
@@ -163,9 +178,13 @@ func TestConvertImage(t *testing.T) {
 						images.WEBP,
 						images.TIFF,
 						images.BMP,
+						images.JXL,
 					},
 					"Document": {
 						images.PDF,
+						images.TXT,
+						images.HOCR,
+						images.OCRPDF,
 					},
 				},
 			},
@@ -190,9 +209,13 @@ func TestConvertImage(t *testing.T) {
 						images.GIF,
 						images.TIFF,
 						images.BMP,
+						images.JXL,
 					},
 					"Document": {
 						images.PDF,
+						images.TXT,
+						images.HOCR,
+						images.OCRPDF,
 					},
 				},
 			},
@@ -217,9 +240,13 @@ func TestConvertImage(t *testing.T) {
 						images.GIF,
 						images.TIFF,
 						images.WEBP,
+						images.JXL,
 					},
 					"Document": {
 						images.PDF,
+						images.TXT,
+						images.HOCR,
+						images.OCRPDF,
 					},
 				},
 			},
@@ -243,9 +270,13 @@ func TestConvertImage(t *testing.T) {
 						images.WEBP,
 						images.TIFF,
 						images.BMP,
+						images.JXL,
 					},
 					"Document": {
 						images.PDF,
+						images.TXT,
+						images.HOCR,
+						images.OCRPDF,
 					},
 				},
 			},
@@ -264,9 +295,11 @@ func TestConvertImage(t *testing.T) {
 			require.Equal(t, tc.input.mimetype, detectedFileType.String())
 
 			convertedImg, err := tc.input.imager.ConvertTo(
+				context.Background(),
 				tc.input.targetFileType,
 				tc.input.targetFormat,
 				bytes.NewReader(inputImg),
+				files.ConvertOptions{},
 			)
 
 			require.NoError(t, err)
@@ -323,9 +356,13 @@ func TestConvertImageToDocument(t *testing.T) {
 						images.WEBP,
 						images.TIFF,
 						images.BMP,
+						images.JXL,
 					},
 					"Document": {
 						images.PDF,
+						images.TXT,
+						images.HOCR,
+						images.OCRPDF,
 					},
 				},
 			},
@@ -349,9 +386,13 @@ func TestConvertImageToDocument(t *testing.T) {
 						images.WEBP,
 						images.TIFF,
 						images.BMP,
+						images.JXL,
 					},
 					"Document": {
 						images.PDF,
+						images.TXT,
+						images.HOCR,
+						images.OCRPDF,
 					},
 				},
 			},
@@ -376,9 +417,13 @@ func TestConvertImageToDocument(t *testing.T) {
 						images.GIF,
 						images.TIFF,
 						images.WEBP,
+						images.JXL,
 					},
 					"Document": {
 						images.PDF,
+						images.TXT,
+						images.HOCR,
+						images.OCRPDF,
 					},
 				},
 			},
@@ -403,9 +448,13 @@ func TestConvertImageToDocument(t *testing.T) {
 						images.WEBP,
 						images.TIFF,
 						images.BMP,
+						images.JXL,
 					},
 					"Document": {
 						images.PDF,
+						images.TXT,
+						images.HOCR,
+						images.OCRPDF,
 					},
 				},
 			},
@@ -430,9 +479,13 @@ func TestConvertImageToDocument(t *testing.T) {
 						images.GIF,
 						images.TIFF,
 						images.BMP,
+						images.JXL,
 					},
 					"Document": {
 						images.PDF,
+						images.TXT,
+						images.HOCR,
+						images.OCRPDF,
 					},
 				},
 			},
@@ -451,9 +504,11 @@ func TestConvertImageToDocument(t *testing.T) {
 			require.Equal(t, tc.input.mimetype, detectedFileType.String())
 
 			convertedImg, err := tc.input.imager.ConvertTo(
+				context.Background(),
 				tc.input.targetFileType,
 				tc.input.targetFormat,
 				bytes.NewReader(inputImg),
+				files.ConvertOptions{},
 			)
 
 			require.NoError(t, err)
@@ -0,0 +1,256 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// ImageInfo describes an image.
+type ImageInfo struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// ColorModel is the color model the image is decoded into. e.g. YCbCr.
+	ColorModel string `json:"colorModel,omitempty"`
+	// BitDepth is the number of bits per channel.
+	BitDepth int `json:"bitDepth,omitempty"`
+	// Frames is the number of frames of animated images.
+	Frames   int          `json:"frames,omitempty"`
+	Metadata MetadataInfo `json:"metadata"`
+}
+
+// MetadataInfo tells which metadata an image holds,
+// alongside the EXIF fields that matter the most.
+type MetadataInfo struct {
+	EXIF bool `json:"exif"`
+	XMP  bool `json:"xmp"`
+	ICC  bool `json:"icc"`
+	// GPS tells if the image holds the location where it was taken.
+	GPS         bool   `json:"gps"`
+	Orientation int    `json:"orientation,omitempty"`
+	Make        string `json:"make,omitempty"`
+	Model       string `json:"model,omitempty"`
+	Software    string `json:"software,omitempty"`
+	DateTime    string `json:"dateTime,omitempty"`
+}
+
+// colorModels are the names and bits per channel of the color models of the image package.
+var colorModels = []struct {
+	model    color.Model
+	name     string
+	bitDepth int
+}{
+	{color.RGBAModel, "RGBA", 8},
+	{color.RGBA64Model, "RGBA64", 16},
+	{color.NRGBAModel, "NRGBA", 8},
+	{color.NRGBA64Model, "NRGBA64", 16},
+	{color.AlphaModel, "Alpha", 8},
+	{color.Alpha16Model, "Alpha16", 16},
+	{color.GrayModel, "Gray", 8},
+	{color.Gray16Model, "Gray16", 16},
+	{color.YCbCrModel, "YCbCr", 8},
+	{color.NYCbCrAModel, "NYCbCrA", 8},
+	{color.CMYKModel, "CMYK", 8},
+}
+
+// readInfo reads the info of the image read from file, with the given decoder.
+// It returns the bytes of the image as well, for the formats that read more details out of them.
+func readInfo(file io.Reader, decodeConfig func(io.Reader) (image.Config, error)) (ImageInfo, []byte, error) {
+	fileBytes, err := io.ReadAll(file)
+	if err != nil {
+		return ImageInfo{}, nil, err
+	}
+
+	info := ImageInfo{Metadata: readMetadata(fileBytes).info()}
+
+	if decodeConfig == nil {
+		return info, fileBytes, nil
+	}
+
+	config, err := decodeConfig(bytes.NewReader(fileBytes))
+	if err != nil {
+		return ImageInfo{}, nil, fmt.Errorf("error decoding the image: %w", err)
+	}
+
+	info.Width, info.Height = config.Width, config.Height
+
+	if _, ok := config.ColorModel.(color.Palette); ok {
+		info.ColorModel, info.BitDepth = "Paletted", 8
+	}
+
+	for _, m := range colorModels {
+		if config.ColorModel == m.model {
+			info.ColorModel, info.BitDepth = m.name, m.bitDepth
+		}
+	}
+
+	return info, fileBytes, nil
+}
+
+// info returns the description of the metadata.
+func (m metadata) info() MetadataInfo {
+	info := MetadataInfo{
+		EXIF:     m.exif != nil,
+		XMP:      m.xmp != nil,
+		ICC:      m.icc != nil,
+		Make:     exifString(m.exif, makeTag),
+		Model:    exifString(m.exif, modelTag),
+		Software: exifString(m.exif, softwareTag),
+		DateTime: exifString(m.exif, dateTimeTag),
+	}
+
+	if info.EXIF {
+		info.Orientation = m.orientation()
+	}
+
+	_, _, info.GPS = exifEntry(m.exif, gpsTag)
+
+	return info
+}
+
+// readWebPInfo reads the info of a webp image, animated ones included,
+// which can't be decoded by the webp package.
+func readWebPInfo(file io.Reader, decodeConfig func(io.Reader) (image.Config, error)) (ImageInfo, error) {
+	info, fileBytes, err := readInfo(file, nil)
+	if err != nil {
+		return ImageInfo{}, err
+	}
+
+	chunks := webpChunks(fileBytes)
+
+	// The extended format holds the size of the canvas, minus one, in 24 bits.
+	if len(chunks) > 0 && chunks[0].kind == "VP8X" && len(chunks[0].data) >= 10 {
+		data := chunks[0].data
+		info.Width = 1 + uint24(data[4:])
+		info.Height = 1 + uint24(data[7:])
+	}
+
+	for _, c := range chunks {
+		if c.kind == "ANMF" {
+			info.Frames++
+		}
+	}
+
+	if info.Frames > 0 {
+		// Animated images hold frames of their own, without a color model to tell.
+		info.ColorModel, info.BitDepth = "NRGBA", 8
+		return info, nil
+	}
+
+	config, _, err := readInfo(bytes.NewReader(fileBytes), decodeConfig)
+	if err != nil {
+		return ImageInfo{}, err
+	}
+	config.Frames = 1
+
+	return config, nil
+}
+
+// readAVIFInfo reads the info of an avif image out of its boxes,
+// since there's no decoder for it.
+func readAVIFInfo(file io.Reader) (ImageInfo, error) {
+	info, fileBytes, err := readInfo(file, nil)
+	if err != nil {
+		return ImageInfo{}, err
+	}
+
+	// The image spatial extents property holds the size of the image,
+	// after the version and the flags.
+	if i := bytes.Index(fileBytes, []byte("ispe")); i >= 0 && i+16 <= len(fileBytes) {
+		info.Width = int(binary.BigEndian.Uint32(fileBytes[i+8:]))
+		info.Height = int(binary.BigEndian.Uint32(fileBytes[i+12:]))
+	}
+
+	// The pixel information property holds the number of channels
+	// and the bits of each of them.
+	if i := bytes.Index(fileBytes, []byte("pixi")); i >= 0 && i+10 <= len(fileBytes) {
+		info.BitDepth = int(fileBytes[i+9])
+	}
+
+	if info.Width == 0 {
+		return ImageInfo{}, fmt.Errorf("error decoding the image: the avif file has no size")
+	}
+
+	info.ColorModel = "YCbCr"
+
+	return info, nil
+}
+
+// readHEIFInfo reads the info of the primary image of a heif file,
+// out of the structure of the file, without decoding the image.
+func readHEIFInfo(file io.Reader) (ImageInfo, error) {
+	fileBytes, err := io.ReadAll(file)
+	if err != nil {
+		return ImageInfo{}, err
+	}
+
+	f, err := parseHEIF(fileBytes)
+	if err != nil {
+		return ImageInfo{}, fmt.Errorf("error decoding the image: %w", err)
+	}
+
+	tiles, size, _, err := f.tiles()
+	if err != nil {
+		return ImageInfo{}, fmt.Errorf("error decoding the image: %w", err)
+	}
+
+	// The size is the one of the image once it's rotated.
+	if o := f.items[f.primary].orientation(); o >= 5 {
+		size.X, size.Y = size.Y, size.X
+	}
+
+	info := ImageInfo{
+		Width:      size.X,
+		Height:     size.Y,
+		ColorModel: "YCbCr",
+		Frames:     f.images(),
+		Metadata:   f.metadata().info(),
+	}
+
+	// The decoder configuration holds the bit depth of the luma, minus 8.
+	if hvcC, ok := tiles[0].property("hvcC"); ok && len(hvcC) > 17 {
+		info.BitDepth = int(hvcC[17]&7) + 8
+	}
+
+	return info, nil
+}
+
+// readICOInfo reads the info of the largest image of an ico file.
+// Every image of the file is counted as a frame.
+func readICOInfo(file io.Reader) (ImageInfo, error) {
+	fileBytes, err := io.ReadAll(file)
+	if err != nil {
+		return ImageInfo{}, err
+	}
+
+	entries, err := parseICO(fileBytes)
+	if err != nil {
+		return ImageInfo{}, fmt.Errorf("error decoding the image: %w", err)
+	}
+
+	img, err := decodeICO(bytes.NewReader(fileBytes))
+	if err != nil {
+		return ImageInfo{}, fmt.Errorf("error decoding the image: %w", err)
+	}
+
+	info := ImageInfo{
+		Width:  img.Bounds().Dx(),
+		Height: img.Bounds().Dy(),
+		Frames: len(entries),
+	}
+
+	if _, ok := img.ColorModel().(color.Palette); ok {
+		info.ColorModel, info.BitDepth = "Paletted", 8
+	}
+
+	for _, m := range colorModels {
+		if img.ColorModel() == m.model {
+			info.ColorModel, info.BitDepth = m.name, m.bitDepth
+		}
+	}
+
+	return info, nil
+}
@@ -0,0 +1,85 @@
+package images_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/danvergara/morphos/pkg/files"
+	"github.com/danvergara/morphos/pkg/files/images"
+)
+
+func TestInspect(t *testing.T) {
+	open := func(filename string) func(t *testing.T) io.Reader {
+		return func(t *testing.T) io.Reader {
+			fileBytes, err := os.ReadFile(filename)
+			require.NoError(t, err)
+			return bytes.NewReader(fileBytes)
+		}
+	}
+
+	var tests = []struct {
+		name      string
+		file      func(t *testing.T) io.Reader
+		inspector files.Inspector
+		expected  images.ImageInfo
+	}{
+		{
+			name:      "png",
+			file:      open("testdata/gopher_pirate.png"),
+			inspector: images.NewPng(),
+			expected:  images.ImageInfo{Width: 1300, Height: 1392, ColorModel: "NRGBA", BitDepth: 8},
+		},
+		{
+			name:      "animated gif",
+			file:      open("testdata/dancing-gopher.gif"),
+			inspector: images.NewGif(),
+			expected:  images.ImageInfo{Width: 192, Height: 192, ColorModel: "Paletted", BitDepth: 8, Frames: 24},
+		},
+		{
+			name:      "webp",
+			file:      open("testdata/gopher.webp"),
+			inspector: images.NewWebp(),
+			expected:  images.ImageInfo{Width: 960, Height: 960, ColorModel: "YCbCr", BitDepth: 8, Frames: 1},
+		},
+		{
+			name:      "avif",
+			file:      open("testdata/fox.avif"),
+			inspector: images.NewAvif(),
+			expected:  images.ImageInfo{Width: 1204, Height: 800, ColorModel: "YCbCr", BitDepth: 10},
+		},
+		{
+			name:      "svg",
+			file:      open("testdata/logo.svg"),
+			inspector: images.NewSvg(),
+			expected:  images.ImageInfo{Width: 120, Height: 60},
+		},
+		{
+			name: "jpeg with exif",
+			file: func(t *testing.T) io.Reader {
+				return bytes.NewReader(orientedJPEG(t, 6))
+			},
+			inspector: images.NewJpeg(),
+			expected: images.ImageInfo{
+				Width:      40,
+				Height:     20,
+				ColorModel: "YCbCr",
+				BitDepth:   8,
+				Metadata:   images.MetadataInfo{EXIF: true, Orientation: 6},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			info, err := tc.inspector.Inspect(context.Background(), tc.file(t))
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, info)
+		})
+	}
+}
@@ -2,6 +2,7 @@ package images
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"image"
 	"image/draw"
@@ -9,6 +10,8 @@ import (
 	"io"
 	"slices"
 	"strings"
+
+	"github.com/danvergara/morphos/pkg/files"
 )
 
 // Jpeg struct implements the File and Image interface from the files pkg.
@@ -17,6 +20,19 @@ type Jpeg struct {
 	compatibleMIMETypes map[string][]string
 }
 
+func init() {
+	files.Register(files.Format{
+		Name:          JPEG,
+		Category:      files.Img,
+		Aliases:       []string{JPG},
+		MIMETypes:     []string{"image/jpeg"},
+		Decoder:       func(string) files.File { return NewJpeg() },
+		InputOptions:  inputOptions,
+		Cost:          1,
+		OutputOptions: jpegOutputOptions,
+	})
+}
+
 // NewJpeg returns a pointer to a Jpeg instance.
 // The Jpeg object is set with a map with list of supported file formats.
 func NewJpeg() *Jpeg {
@@ -29,9 +45,13 @@ func NewJpeg() *Jpeg {
 				WEBP,
 				TIFF,
 				BMP,
+				JXL,
 			},
 			"Document": {
 				PDF,
+				TXT,
+				HOCR,
+				OCRPDF,
 			},
 		},
 
@@ -43,9 +63,13 @@ func NewJpeg() *Jpeg {
 				WEBP,
 				TIFF,
 				BMP,
+				JXL,
 			},
 			"Document": {
 				PDF,
+				TXT,
+				HOCR,
+				OCRPDF,
 			},
 		},
 	}
@@ -67,7 +91,7 @@ func (j *Jpeg) SupportedMIMETypes() map[string][]string {
 // ConvertTo method converts a given file to a target format.
 // This method returns a file in form of a slice of bytes.
 // The methd receives a file type and the sub-type of the target format and the file as array of bytes.
-func (j *Jpeg) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, error) {
+func (j *Jpeg) ConvertTo(ctx context.Context, fileType, subType string, file io.Reader, opts files.ConvertOptions) (io.Reader, error) {
 	var result []byte
 
 	compatibleFormats, ok := j.SupportedFormats()[fileType]
@@ -81,14 +105,14 @@ func (j *Jpeg) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, e
 
 	switch strings.ToLower(fileType) {
 	case imageType:
-		convertedImage, err := convertToImage(subType, file)
+		convertedImage, err := convertToImage(ctx, subType, file, jpeg.Decode, opts)
 		if err != nil {
 			return nil, err
 		}
 
 		return convertedImage, nil
 	case documentType:
-		img, err := jpeg.Decode(file)
+		img, m, err := decodeImage(file, jpeg.Decode)
 		if err != nil {
 			return nil, err
 		}
@@ -96,7 +120,7 @@ func (j *Jpeg) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, e
 		rgba := image.NewRGBA(img.Bounds())
 		draw.Draw(rgba, img.Bounds(), img, image.Point{}, draw.Src)
 
-		result, err = convertToDocument(subType, rgba)
+		result, err = convertToDocument(ctx, subType, rgba, m, opts)
 		if err != nil {
 			return nil, fmt.Errorf(
 				"ConvertTo: error at converting image to another format: %w",
@@ -108,6 +132,13 @@ func (j *Jpeg) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, e
 	return bytes.NewReader(result), nil
 }
 
+// Inspect describes the image.
+// This method implements the files.Inspector interface.
+func (j *Jpeg) Inspect(_ context.Context, file io.Reader) (any, error) {
+	info, _, err := readInfo(file, jpeg.DecodeConfig)
+	return info, err
+}
+
 // ImageType returns the file format of the current image.
 // This method implements the Image interface.
 func (j *Jpeg) ImageType() string {
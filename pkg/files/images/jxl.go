@@ -0,0 +1,438 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/danvergara/morphos/pkg/files"
+	"github.com/danvergara/morphos/pkg/util"
+)
+
+const (
+	// EffortOption sets how hard the jxl encoder tries to make the file smaller, from 1 to 9.
+	EffortOption = "effort"
+
+	// defaultJXLEffort is the effort used by cjxl by default.
+	defaultJXLEffort = 7
+)
+
+// jxlOutputOptions are the options accepted when converting to jxl.
+var jxlOutputOptions = files.Schema{
+	qualityOption("JPEG XL", ""),
+	{
+		Name:  LosslessOption,
+		Label: "JPEG XL lossless",
+		Help:  "Encodes the image without losing quality, jpeg images are recompressed so they can be restored as they were",
+		Type:  files.BoolOption,
+	},
+	{
+		Name:    EffortOption,
+		Label:   "JPEG XL effort",
+		Help:    "From 1 (fastest) to 9 (smallest file)",
+		Type:    files.IntOption,
+		Default: strconv.Itoa(defaultJXLEffort),
+		Min:     1,
+		Max:     9,
+	},
+	metadataOption,
+}
+
+// Jxl struct implements the File and Image interface from the files pkg.
+// JPEG XL images are encoded and decoded by cjxl and djxl, the tools of libjxl.
+type Jxl struct {
+	compatibleFormats   map[string][]string
+	compatibleMIMETypes map[string][]string
+}
+
+func init() {
+	files.Register(files.Format{
+		Name:          JXL,
+		Category:      files.Img,
+		MIMETypes:     []string{"image/jxl"},
+		Decoder:       func(string) files.File { return NewJxl() },
+		InputOptions:  inputOptions,
+		OutputOptions: jxlOutputOptions,
+		Cost:          2,
+	})
+}
+
+// NewJxl returns a pointer to a Jxl instance.
+// The Jxl object is set with a map with list of supported file formats.
+func NewJxl() *Jxl {
+	j := Jxl{
+		compatibleFormats: map[string][]string{
+			"Image": {
+				JPG,
+				JPEG,
+				PNG,
+				GIF,
+				WEBP,
+				TIFF,
+				BMP,
+				AVIF,
+			},
+			"Document": {
+				PDF,
+				TXT,
+				HOCR,
+				OCRPDF,
+			},
+		},
+
+		compatibleMIMETypes: map[string][]string{
+			"Image": {
+				JPG,
+				JPEG,
+				PNG,
+				GIF,
+				WEBP,
+				TIFF,
+				BMP,
+				AVIF,
+			},
+			"Document": {
+				PDF,
+				TXT,
+				HOCR,
+				OCRPDF,
+			},
+		},
+	}
+
+	return &j
+}
+
+// SupportedFormats returns a map with a slice of supported files.
+// Every key of the map represents the kind of a file.
+func (j *Jxl) SupportedFormats() map[string][]string {
+	return j.compatibleFormats
+}
+
+// SupportedMIMETypes returns a map with a slice of supported MIME types.
+func (j *Jxl) SupportedMIMETypes() map[string][]string {
+	return j.compatibleMIMETypes
+}
+
+// ConvertTo method converts a given file to a target format.
+// This method returns a file in form of a slice of bytes.
+// Jpeg images recompressed losslessly are restored as they were,
+// when they are converted back to jpeg.
+func (j *Jxl) ConvertTo(ctx context.Context, fileType, subType string, file io.Reader, opts files.ConvertOptions) (io.Reader, error) {
+	var result []byte
+
+	compatibleFormats, ok := j.SupportedFormats()[fileType]
+	if !ok {
+		return nil, fmt.Errorf("ConvertTo: file type not supported: %s", fileType)
+	}
+
+	if !slices.Contains(compatibleFormats, subType) {
+		return nil, fmt.Errorf("ConvertTo: file sub-type not supported: %s", subType)
+	}
+
+	fileBytes, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(fileType) {
+	case imageType:
+		if (subType == JPG || subType == JPEG) && hasJPEGReconstruction(fileBytes) {
+			jpegBytes, err := decodeJXL(ctx, fileBytes, JPEG)
+			if err != nil {
+				return nil, err
+			}
+
+			// The jpeg image is only re-encoded if it has to be changed.
+			if !isLosslessJPEG(jpegBytes, opts) {
+				return convertToImage(ctx, subType, bytes.NewReader(jpegBytes), jpeg.Decode, opts)
+			}
+
+			m := readMetadata(jpegBytes)
+			jpegBytes = applyMetadataPolicy(jpegBytes, JPEG, opts.String(MetadataOption, MetadataStrip), m)
+
+			return bytes.NewReader(jpegBytes), nil
+		}
+
+		pngBytes, err := decodeJXL(ctx, fileBytes, PNG)
+		if err != nil {
+			return nil, err
+		}
+
+		return convertToImage(ctx, subType, bytes.NewReader(pngBytes), png.Decode, opts)
+	case documentType:
+		pngBytes, err := decodeJXL(ctx, fileBytes, PNG)
+		if err != nil {
+			return nil, err
+		}
+
+		img, m, err := decodeImage(bytes.NewReader(pngBytes), png.Decode)
+		if err != nil {
+			return nil, err
+		}
+
+		result, err = convertToDocument(ctx, subType, img, m, opts)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"ConvertTo: error at converting image to another format: %w",
+				err,
+			)
+		}
+	}
+
+	return bytes.NewReader(result), nil
+}
+
+// Inspect describes the image.
+// This method implements the files.Inspector interface.
+func (j *Jxl) Inspect(_ context.Context, file io.Reader) (any, error) {
+	return readJXLInfo(file)
+}
+
+// ImageType method returns the file format of the current image.
+// This method implements the Image interface.
+func (j *Jxl) ImageType() string {
+	return JXL
+}
+
+var (
+	// jxlCodestream is the signature of a bare jxl codestream.
+	jxlCodestream = []byte{0xff, 0x0a}
+	// jxlContainer is the signature box of a jxl file, whose codestream is stored in boxes.
+	jxlContainer = []byte("\x00\x00\x00\x0cJXL \x0d\x0a\x87\x0a")
+)
+
+// jxlBoxes returns the boxes of a jxl container, or nil if the file is a bare codestream.
+func jxlBoxes(b []byte) []heifBox {
+	if !bytes.HasPrefix(b, jxlContainer) {
+		return nil
+	}
+
+	return heifBoxes(b)
+}
+
+// hasJPEGReconstruction tells if the jxl file holds the data needed to restore
+// the jpeg image it was recompressed from.
+func hasJPEGReconstruction(b []byte) bool {
+	return slices.ContainsFunc(jxlBoxes(b), func(box heifBox) bool {
+		return box.kind == "jbrd"
+	})
+}
+
+// isLosslessJPEG tells if the jpeg image is converted without decoding it:
+// the image is left as it is if it's lossless and there's nothing to change,
+// besides its metadata, which is written without touching the pixels.
+func isLosslessJPEG(b []byte, opts files.ConvertOptions) bool {
+	if !bytes.HasPrefix(b, jpegSOI) {
+		return false
+	}
+
+	g, err := geometryFromOptions(opts)
+	if err != nil {
+		return false
+	}
+
+	if autoOrient(opts) {
+		g.orientation = readMetadata(b).orientation()
+	}
+
+	return g.isZero()
+}
+
+// encodeJXL converts the image to jxl with cjxl.
+// Jpeg images are recompressed losslessly if the lossless option is set and the
+// image isn't changed, so they can be restored as they were. Any other image is
+// converted to png first, since cjxl reads a handful of formats, and png keeps every
+// pixel of the source, alongside the metadata set by the options.
+func encodeJXL(ctx context.Context, fileBytes []byte, decode func(io.Reader) (image.Image, error), opts files.ConvertOptions) ([]byte, error) {
+	args := []string{"--effort", strconv.Itoa(opts.Int(EffortOption, defaultJXLEffort))}
+
+	if opts.Bool(LosslessOption) && isLosslessJPEG(fileBytes, opts) {
+		m := readMetadata(fileBytes)
+		fileBytes = applyMetadataPolicy(fileBytes, JPEG, opts.String(MetadataOption, MetadataStrip), m)
+
+		args = append(args, "--lossless_jpeg=1")
+
+		return runLibJXL(ctx, "cjxl", fileBytes, JPEG, JXL, args...)
+	}
+
+	pngImage, err := convertToImage(ctx, PNG, bytes.NewReader(fileBytes), decode, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	pngBytes, err := io.ReadAll(pngImage)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case opts.Bool(LosslessOption):
+		args = append(args, "--distance", "0")
+	case opts.Has(QualityOption):
+		args = append(args, "--quality", strconv.Itoa(opts.Int(QualityOption, 0)))
+	}
+
+	return runLibJXL(ctx, "cjxl", pngBytes, PNG, JXL, args...)
+}
+
+// decodeJXL converts the jxl image to the target format, either png or jpeg, with djxl.
+// A jpeg image recompressed losslessly is restored as it was, rather than re-encoded.
+func decodeJXL(ctx context.Context, fileBytes []byte, target string) ([]byte, error) {
+	return runLibJXL(ctx, "djxl", fileBytes, JXL, target)
+}
+
+// runLibJXL runs one of the tools of libjxl, which read and write files,
+// from the input, of the format given by ext, to a file of the target format.
+func runLibJXL(ctx context.Context, tool string, input []byte, ext, target string, args ...string) ([]byte, error) {
+	tmpInput, err := os.CreateTemp("/tmp", fmt.Sprintf("*.%s", ext))
+	if err != nil {
+		return nil, fmt.Errorf("error creating temporary image file: %w", err)
+	}
+	defer os.Remove(tmpInput.Name())
+
+	if _, err := tmpInput.Write(input); err != nil {
+		tmpInput.Close()
+		return nil, fmt.Errorf("error writting the input reader to the temporary image file: %w", err)
+	}
+
+	if err := tmpInput.Close(); err != nil {
+		return nil, err
+	}
+
+	tmpOutput, cleanup, err := tempOutput(target)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	args = append([]string{tmpInput.Name(), tmpOutput}, args...)
+
+	if err := util.RunCommand(ctx, util.LibJXL, os.Stdout, os.Stdout, tool, args...); err != nil {
+		return nil, err
+	}
+
+	return os.ReadFile(tmpOutput)
+}
+
+// readJXLInfo reads the info of a jxl image out of the header of its codestream,
+// and its metadata out of the boxes of its container, if any.
+func readJXLInfo(file io.Reader) (ImageInfo, error) {
+	fileBytes, err := io.ReadAll(file)
+	if err != nil {
+		return ImageInfo{}, err
+	}
+
+	codestream := fileBytes
+
+	var info ImageInfo
+
+	if boxes := jxlBoxes(fileBytes); boxes != nil {
+		codestream = nil
+
+		var m metadata
+		for _, box := range boxes {
+			switch box.kind {
+			case "jxlc":
+				codestream = box.data
+			case "jxlp":
+				// Partial codestreams start with their index.
+				if len(box.data) >= 4 {
+					codestream = append(codestream, box.data[4:]...)
+				}
+			case "Exif":
+				// The data starts with the offset of the tiff header.
+				if len(box.data) >= 4 {
+					if offset := 4 + uint64(binary.BigEndian.Uint32(box.data)); offset < uint64(len(box.data)) {
+						m.exif = box.data[offset:]
+					}
+				}
+			case "xml ":
+				m.xmp = box.data
+			}
+		}
+
+		info.Metadata = m.info()
+	}
+
+	size, err := jxlSize(codestream)
+	if err != nil {
+		return ImageInfo{}, fmt.Errorf("error decoding the image: %w", err)
+	}
+
+	info.Width, info.Height = size[0], size[1]
+
+	return info, nil
+}
+
+// jxlRatios are the aspect ratios a jxl image can declare, instead of its width.
+var jxlRatios = [][2]uint64{{1, 1}, {12, 10}, {4, 3}, {3, 2}, {16, 9}, {5, 4}, {2, 1}}
+
+// jxlSize reads the size of the image out of the header of the codestream,
+// which is packed in bits, starting at the least significant one.
+func jxlSize(codestream []byte) ([2]int, error) {
+	if !bytes.HasPrefix(codestream, jxlCodestream) {
+		return [2]int{}, errors.New("the jxl file has no codestream")
+	}
+
+	r := jxlBitReader{b: codestream[2:]}
+
+	// Sizes are either multiples of 8, or one of four ranges of bits.
+	readSize := func(div8 bool) uint64 {
+		if div8 {
+			return (r.bits(5) + 1) * 8
+		}
+
+		return r.bits([]int{9, 13, 18, 30}[r.bits(2)]) + 1
+	}
+
+	div8 := r.bits(1) == 1
+	height := readSize(div8)
+
+	width := height
+	if ratio := r.bits(3); ratio == 0 {
+		width = readSize(div8)
+	} else {
+		width = height * jxlRatios[ratio-1][0] / jxlRatios[ratio-1][1]
+	}
+
+	if r.failed {
+		return [2]int{}, errors.New("the header of the jxl codestream is truncated")
+	}
+
+	return [2]int{int(width), int(height)}, nil
+}
+
+// jxlBitReader reads the bits of a jxl codestream, from the least significant one.
+type jxlBitReader struct {
+	b      []byte
+	pos    int
+	failed bool
+}
+
+// bits reads an unsigned integer of n bits.
+func (r *jxlBitReader) bits(n int) uint64 {
+	var v uint64
+
+	for i := 0; i < n; i++ {
+		if r.pos/8 >= len(r.b) {
+			r.failed = true
+			return 0
+		}
+
+		v |= uint64(r.b[r.pos/8]>>(r.pos%8)&1) << i
+		r.pos++
+	}
+
+	return v
+}
@@ -0,0 +1,97 @@
+package images_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/danvergara/morphos/pkg/files"
+	"github.com/danvergara/morphos/pkg/files/images"
+)
+
+// jxlCodestream returns a jxl codestream whose header holds the given fields,
+// every one of them a value and its number of bits, packed from the least significant bit.
+func jxlCodestream(fields ...[2]int) []byte {
+	b := []byte{0xff, 0x0a}
+
+	pos := 0
+	for _, f := range fields {
+		for i := 0; i < f[1]; i++ {
+			if pos%8 == 0 {
+				b = append(b, 0)
+			}
+			b[len(b)-1] |= byte(f[0]>>i&1) << (pos % 8)
+			pos++
+		}
+	}
+
+	return b
+}
+
+// jxlContainer returns a jxl file whose codestream is stored in boxes.
+func jxlContainer(boxes ...[]byte) []byte {
+	signature := []byte("\x00\x00\x00\x0cJXL \x0d\x0a\x87\x0a")
+	return bytes.Join(append([][]byte{signature, box("ftyp", []byte("jxl "), u32(0), []byte("jxl "))}, boxes...), nil)
+}
+
+func TestInspectJXL(t *testing.T) {
+	var tests = []struct {
+		name     string
+		file     []byte
+		expected images.ImageInfo
+	}{
+		{
+			name: "multiple of 8",
+			// 48 pixels high and 64 pixels wide, as multiples of 8.
+			file:     jxlCodestream([2]int{1, 1}, [2]int{5, 5}, [2]int{0, 3}, [2]int{7, 5}),
+			expected: images.ImageInfo{Width: 64, Height: 48},
+		},
+		{
+			name: "aspect ratio",
+			// 1080 pixels high, in 13 bits, with an aspect ratio of 16:9.
+			file:     jxlCodestream([2]int{0, 1}, [2]int{1, 2}, [2]int{1079, 13}, [2]int{5, 3}),
+			expected: images.ImageInfo{Width: 1920, Height: 1080},
+		},
+		{
+			name: "container with metadata",
+			file: jxlContainer(
+				box("Exif", u32(0), exifItem("Canon")[10:]),
+				box("jxlp", u32(0), jxlCodestream([2]int{1, 1}, [2]int{0, 5}, [2]int{1, 3})),
+				box("jbrd", []byte{0}),
+			),
+			expected: images.ImageInfo{
+				Width:    8,
+				Height:   8,
+				Metadata: images.MetadataInfo{EXIF: true, Orientation: 1, Make: "Canon"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			info, err := images.NewJxl().Inspect(context.Background(), bytes.NewReader(tc.file))
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, info)
+		})
+	}
+}
+
+func TestInspectTruncatedJXL(t *testing.T) {
+	_, err := images.NewJxl().Inspect(context.Background(), bytes.NewReader(jxlCodestream([2]int{0, 1})))
+	require.Error(t, err)
+}
+
+func TestJXLTargets(t *testing.T) {
+	for _, f := range files.Formats(files.Img) {
+		if f.Name == images.JXL || f.Name == images.FAVICON {
+			continue
+		}
+
+		targets, err := files.Targets(f.Name)
+		require.NoError(t, err)
+		require.Contains(t, targets["Image"], images.JXL, f.Name)
+	}
+}
@@ -0,0 +1,277 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/signintech/gopdf"
+
+	"github.com/danvergara/morphos/pkg/files"
+	"github.com/danvergara/morphos/pkg/packaging"
+)
+
+const (
+	// PageSizeOption sets the size of the pages of a pdf made out of images.
+	PageSizeOption = "page_size"
+	// OrientationOption sets the orientation of the pages of a pdf made out of images,
+	// when they have a fixed size.
+	OrientationOption = "orientation"
+	// MarginOption sets the margins of the pages of a pdf made out of images, in points.
+	MarginOption = "margin"
+	// JPEGQualityOption recompresses the images of a pdf as jpeg, with the given quality.
+	JPEGQualityOption = "jpeg_quality"
+
+	// PageSizeFit makes every page as large as its image.
+	PageSizeFit = "fit"
+	// PageSizeA4 and PageSizeLetter are the paper sizes images are scaled to fit in.
+	PageSizeA4     = "a4"
+	PageSizeLetter = "letter"
+
+	// OrientationAuto turns the pages landscape for images wider than tall.
+	OrientationAuto      = "auto"
+	OrientationPortrait  = "portrait"
+	OrientationLandscape = "landscape"
+
+	// maxMargin is the widest margin of the pages, two inches.
+	maxMargin = 144
+)
+
+// paperSizes are the sizes of the pages, in points, in portrait.
+var paperSizes = map[string]gopdf.Rect{
+	PageSizeA4:     *gopdf.PageSizeA4,
+	PageSizeLetter: *gopdf.PageSizeLetter,
+}
+
+// MergeOptions are the options accepted when merging images into a pdf.
+var MergeOptions = files.Schema{
+	{
+		Name:    PageSizeOption,
+		Label:   "Page size",
+		Help:    "Fit every page to its image, or scale the images to fit in the paper size",
+		Type:    files.ChoiceOption,
+		Default: PageSizeFit,
+		Choices: []string{PageSizeFit, PageSizeA4, PageSizeLetter},
+	},
+	{
+		Name:    OrientationOption,
+		Label:   "Orientation",
+		Help:    "Orientation of the A4 and Letter pages. Auto turns them landscape for images wider than tall",
+		Type:    files.ChoiceOption,
+		Default: OrientationAuto,
+		Choices: []string{OrientationAuto, OrientationPortrait, OrientationLandscape},
+	},
+	{
+		Name:    MarginOption,
+		Label:   "Margin",
+		Help:    "Margin around the images in points, 72 of them per inch",
+		Type:    files.IntOption,
+		Default: "0",
+		Min:     0,
+		Max:     maxMargin,
+	},
+	{
+		Name:  JPEGQualityOption,
+		Label: "JPEG quality",
+		Help:  "Recompresses the images as jpeg, from 1 (smallest file) to 100 (best quality). Kept as they are if empty",
+		Type:  files.IntOption,
+		Min:   1,
+		Max:   100,
+	},
+	autoOrientOption,
+}
+
+// pdfImage is an image ready to be drawn on a page of a pdf.
+type pdfImage struct {
+	holder gopdf.ImageHolder
+	// size is the size of the image as it's stored, before turning it upright.
+	size        image.Point
+	orientation int
+}
+
+// MergePDF returns a pdf with a page per image, in the order they are given.
+// Jpeg images are embedded as they are, unless they are recompressed, and the rest of them losslessly.
+// Images that Go can't decode, e.g. heic, are converted to png first.
+func MergePDF(ctx context.Context, imgs []packaging.File, opts files.ConvertOptions) ([]byte, error) {
+	if len(imgs) == 0 {
+		return nil, fmt.Errorf("there are no images to merge")
+	}
+
+	pdf := gopdf.GoPdf{}
+
+	for i, f := range imgs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		img, err := readPDFImage(ctx, f, opts)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", f.Name, err)
+		}
+
+		page, options := layoutPDFImage(img, opts)
+
+		if i == 0 {
+			pdf.Start(gopdf.Config{PageSize: page})
+		}
+
+		pdf.AddPageWithOption(gopdf.PageOption{PageSize: &page})
+
+		if err := pdf.ImageByHolderWithOptions(img.holder, options); err != nil {
+			return nil, fmt.Errorf("error drawing %s: %w", f.Name, err)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if _, err := pdf.WriteTo(buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// readPDFImage reads an image of any format, and encodes it the way it's embedded into the pdf.
+func readPDFImage(ctx context.Context, f packaging.File, opts files.ConvertOptions) (pdfImage, error) {
+	format, ok := files.LookupMIME(mimetype.Detect(f.Content).String())
+	if !ok || format.Category != files.Img {
+		return pdfImage{}, fmt.Errorf("not an image")
+	}
+
+	content := f.Content
+
+	img := pdfImage{orientation: 1}
+	if autoOrient(opts) {
+		img.orientation = readMetadata(content).orientation()
+	}
+
+	if format.Name == JPEG && !opts.Has(JPEGQualityOption) {
+		config, err := jpeg.DecodeConfig(bytes.NewReader(content))
+		if err != nil {
+			return pdfImage{}, fmt.Errorf("error decoding the image: %w", err)
+		}
+
+		img.size = image.Pt(config.Width, config.Height)
+		img.holder, err = gopdf.ImageHolderByBytes(content)
+
+		return img, err
+	}
+
+	var (
+		decoded image.Image
+		err     error
+	)
+
+	switch format.Name {
+	case JPEG:
+		decoded, err = jpeg.Decode(bytes.NewReader(content))
+	case PNG:
+		decoded, err = png.Decode(bytes.NewReader(content))
+	default:
+		// The conversion turns the image upright already.
+		var converted io.Reader
+		converted, _, err = files.Convert(ctx, f.Name, format.Name, PNG, bytes.NewReader(content), files.ConvertOptions{})
+		if err != nil {
+			return pdfImage{}, err
+		}
+
+		img.orientation = 1
+		decoded, err = png.Decode(converted)
+	}
+
+	if err != nil {
+		return pdfImage{}, fmt.Errorf("error decoding the image: %w", err)
+	}
+
+	img.size = decoded.Bounds().Size()
+
+	buf := new(bytes.Buffer)
+
+	if opts.Has(JPEGQualityOption) {
+		// Jpeg images can't be transparent, so they are drawn on white, like the page.
+		opaque := image.NewRGBA(image.Rectangle{Max: img.size})
+		draw.Draw(opaque, opaque.Bounds(), image.White, image.Point{}, draw.Src)
+		draw.Draw(opaque, opaque.Bounds(), decoded, decoded.Bounds().Min, draw.Over)
+
+		err = jpeg.Encode(buf, opaque, &jpeg.Options{Quality: opts.Int(JPEGQualityOption, defaultJPEGQuality)})
+	} else {
+		// The pdf library only reads png images of 8 bits per sample.
+		err = png.Encode(buf, toNRGBA(decoded))
+	}
+
+	if err != nil {
+		return pdfImage{}, fmt.Errorf("error encoding the image: %w", err)
+	}
+
+	img.holder, err = gopdf.ImageHolderByBytes(buf.Bytes())
+
+	return img, err
+}
+
+// layoutPDFImage returns the size of the page of the image, and where the image is drawn on it,
+// centered and turned upright. Images are scaled to fit in paper sizes, and drawn at a point per pixel otherwise.
+func layoutPDFImage(img pdfImage, opts files.ConvertOptions) (gopdf.Rect, gopdf.ImageOptions) {
+	margin := float64(opts.Int(MarginOption, 0))
+
+	// The size of the image once it's upright, the orientations from 5 to 8 swap its sides.
+	upright := img.size
+	swapped := img.orientation >= 5 && img.orientation <= 8
+	if swapped {
+		upright = image.Pt(upright.Y, upright.X)
+	}
+
+	width, height := float64(upright.X), float64(upright.Y)
+
+	page := gopdf.Rect{W: width + 2*margin, H: height + 2*margin}
+	scale := 1.0
+
+	if paper, ok := paperSizes[opts.String(PageSizeOption, PageSizeFit)]; ok {
+		page = paper
+
+		switch opts.String(OrientationOption, OrientationAuto) {
+		case OrientationLandscape:
+			page.W, page.H = paper.H, paper.W
+		case OrientationAuto:
+			if width > height {
+				page.W, page.H = paper.H, paper.W
+			}
+		}
+
+		scale = min((page.W-2*margin)/width, (page.H-2*margin)/height)
+	}
+
+	// The image is drawn as it's stored, and turned around its center.
+	rect := gopdf.Rect{W: float64(img.size.X) * scale, H: float64(img.size.Y) * scale}
+	options := gopdf.ImageOptions{
+		X:    (page.W - rect.W) / 2,
+		Y:    (page.H - rect.H) / 2,
+		Rect: &rect,
+	}
+
+	// Rotations are counterclockwise, and applied after flipping the image.
+	switch img.orientation {
+	case 2:
+		options.HorizontalFlip = true
+	case 3:
+		options.DegreeAngle = 180
+	case 4:
+		options.VerticalFlip = true
+	case 5:
+		options.HorizontalFlip = true
+		options.DegreeAngle = 90
+	case 6:
+		options.DegreeAngle = -90
+	case 7:
+		options.HorizontalFlip = true
+		options.DegreeAngle = -90
+	case 8:
+		options.DegreeAngle = 90
+	}
+
+	return page, options
+}
@@ -0,0 +1,133 @@
+package images
+
+import (
+	"image"
+	"net/url"
+	"testing"
+
+	"github.com/signintech/gopdf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLayoutPDFImage(t *testing.T) {
+	var tests = []struct {
+		name        string
+		size        image.Point
+		orientation int
+		values      url.Values
+		page        gopdf.Rect
+		// x, y, width and height are where the image is drawn, as it's stored.
+		x, y, width, height float64
+		hFlip, vFlip        bool
+		angle               float64
+	}{
+		{
+			name:        "fit",
+			size:        image.Pt(100, 50),
+			orientation: 1,
+			values:      url.Values{},
+			page:        gopdf.Rect{W: 100, H: 50},
+			width:       100,
+			height:      50,
+		},
+		{
+			name:        "fit with margins",
+			size:        image.Pt(100, 50),
+			orientation: 1,
+			values:      url.Values{"margin": {"10"}},
+			page:        gopdf.Rect{W: 120, H: 70},
+			x:           10,
+			y:           10,
+			width:       100,
+			height:      50,
+		},
+		{
+			name:        "fit turned upright",
+			size:        image.Pt(100, 50),
+			orientation: 6,
+			values:      url.Values{},
+			page:        gopdf.Rect{W: 50, H: 100},
+			x:           -25,
+			y:           25,
+			width:       100,
+			height:      50,
+			angle:       -90,
+		},
+		{
+			name:        "fit mirrored",
+			size:        image.Pt(100, 50),
+			orientation: 2,
+			values:      url.Values{},
+			page:        gopdf.Rect{W: 100, H: 50},
+			width:       100,
+			height:      50,
+			hFlip:       true,
+		},
+		{
+			name:        "a4 turned as the image",
+			size:        image.Pt(200, 100),
+			orientation: 1,
+			values:      url.Values{"page_size": {"a4"}},
+			page:        gopdf.Rect{W: 842, H: 595},
+			x:           0,
+			y:           87,
+			width:       842,
+			height:      421,
+		},
+		{
+			name:        "a4 portrait with margins",
+			size:        image.Pt(200, 100),
+			orientation: 1,
+			values:      url.Values{"page_size": {"a4"}, "orientation": {"portrait"}, "margin": {"36"}},
+			page:        gopdf.Rect{W: 595, H: 842},
+			x:           36,
+			y:           290.25,
+			width:       523,
+			height:      261.5,
+		},
+		{
+			name:        "letter turned as the upright image",
+			size:        image.Pt(300, 100),
+			orientation: 8,
+			values:      url.Values{"page_size": {"letter"}},
+			page:        gopdf.Rect{W: 612, H: 792},
+			x:           -90,
+			y:           264,
+			width:       792,
+			height:      264,
+			angle:       90,
+		},
+		{
+			name:        "letter mirrored upside down",
+			size:        image.Pt(100, 300),
+			orientation: 4,
+			values:      url.Values{"page_size": {"letter"}, "orientation": {"landscape"}},
+			page:        gopdf.Rect{W: 792, H: 612},
+			x:           294,
+			y:           0,
+			width:       204,
+			height:      612,
+			vFlip:       true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			opts, err := MergeOptions.Parse(tc.values)
+			require.NoError(t, err)
+
+			page, options := layoutPDFImage(pdfImage{size: tc.size, orientation: tc.orientation}, opts)
+			require.InDelta(t, tc.page.W, page.W, 0.01)
+			require.InDelta(t, tc.page.H, page.H, 0.01)
+
+			require.InDelta(t, tc.x, options.X, 0.01)
+			require.InDelta(t, tc.y, options.Y, 0.01)
+			require.InDelta(t, tc.width, options.Rect.W, 0.01)
+			require.InDelta(t, tc.height, options.Rect.H, 0.01)
+
+			require.Equal(t, tc.hFlip, options.HorizontalFlip)
+			require.Equal(t, tc.vFlip, options.VerticalFlip)
+			require.Equal(t, tc.angle, options.DegreeAngle)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package images_test
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/gen2brain/go-fitz"
+	"github.com/stretchr/testify/require"
+
+	"github.com/danvergara/morphos/pkg/files/images"
+	"github.com/danvergara/morphos/pkg/packaging"
+)
+
+func TestMergePDF(t *testing.T) {
+	var imgs []packaging.File
+	var sizes []image.Point
+
+	for _, name := range []string{"Golang_Gopher.jpg", "gopher_pirate.png", "gopher.webp"} {
+		content, err := os.ReadFile("testdata/" + name)
+		require.NoError(t, err)
+
+		config, _, err := image.DecodeConfig(bytes.NewReader(content))
+		require.NoError(t, err)
+
+		imgs = append(imgs, packaging.File{Name: name, Content: content})
+		sizes = append(sizes, image.Pt(config.Width, config.Height))
+	}
+
+	// pageSize returns the expected size of the page of the image, in points.
+	type pageSize func(img image.Point) image.Point
+
+	var tests = []struct {
+		name     string
+		values   url.Values
+		expected pageSize
+	}{
+		{
+			name:   "fit",
+			values: url.Values{},
+			expected: func(img image.Point) image.Point {
+				return img
+			},
+		},
+		{
+			name:   "fit with margins",
+			values: url.Values{"margin": {"36"}},
+			expected: func(img image.Point) image.Point {
+				return img.Add(image.Pt(72, 72))
+			},
+		},
+		{
+			name:   "a4 turned as the images",
+			values: url.Values{"page_size": {"a4"}, "jpeg_quality": {"60"}},
+			expected: func(img image.Point) image.Point {
+				if img.X > img.Y {
+					return image.Pt(842, 595)
+				}
+				return image.Pt(595, 842)
+			},
+		},
+		{
+			name:   "letter landscape",
+			values: url.Values{"page_size": {"letter"}, "orientation": {"landscape"}, "margin": {"72"}},
+			expected: func(image.Point) image.Point {
+				return image.Pt(792, 612)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			opts, err := images.MergeOptions.Parse(tc.values)
+			require.NoError(t, err)
+
+			result, err := images.MergePDF(context.Background(), imgs, opts)
+			require.NoError(t, err)
+
+			doc, err := fitz.NewFromMemory(result)
+			require.NoError(t, err)
+			defer doc.Close()
+
+			require.Equal(t, len(imgs), doc.NumPage())
+
+			for i, size := range sizes {
+				bounds, err := doc.Bound(i)
+				require.NoError(t, err)
+				require.Equal(t, tc.expected(size), bounds.Size(), imgs[i].Name)
+			}
+		})
+	}
+}
+
+func TestMergePDFNotAnImage(t *testing.T) {
+	opts, err := images.MergeOptions.Parse(url.Values{})
+	require.NoError(t, err)
+
+	_, err = images.MergePDF(context.Background(), []packaging.File{{Name: "notes.txt", Content: []byte("not an image")}}, opts)
+	require.Error(t, err)
+}
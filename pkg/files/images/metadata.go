@@ -0,0 +1,531 @@
+package images
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/danvergara/morphos/pkg/files"
+)
+
+const (
+	// AutoOrientOption rotates the pixels according to the EXIF orientation of the image.
+	AutoOrientOption = "auto_orient"
+	// MetadataOption sets what happens to the metadata of the image.
+	MetadataOption = "metadata"
+
+	// MetadataStrip removes the metadata, location included.
+	MetadataStrip = "strip"
+	// MetadataKeep keeps the EXIF metadata and the color profile.
+	MetadataKeep = "keep"
+	// MetadataKeepColorProfile only keeps the color profile.
+	MetadataKeepColorProfile = "keep-color-profile-only"
+
+	makeTag        = 0x010f
+	modelTag       = 0x0110
+	orientationTag = 0x0112
+	softwareTag    = 0x0131
+	dateTimeTag    = 0x0132
+	gpsTag         = 0x8825
+)
+
+// autoOrientOption is the option that turns the image upright.
+var autoOrientOption = files.Option{
+	Name:    AutoOrientOption,
+	Label:   "Auto-orient",
+	Help:    "Rotates the image as the camera that took it says",
+	Type:    files.BoolOption,
+	Default: "true",
+}
+
+// metadataOption sets what happens to the metadata of the image,
+// for the formats whose metadata is written: jpeg, png and jxl.
+var metadataOption = files.Option{
+	Name:    MetadataOption,
+	Label:   "Metadata",
+	Help:    "Strip it (location included), keep it, or only keep the color profile",
+	Type:    files.ChoiceOption,
+	Default: MetadataStrip,
+	Choices: []string{MetadataStrip, MetadataKeep, MetadataKeepColorProfile},
+}
+
+// stripMetadataOption is the metadata option of the rest of the formats, whose
+// metadata is always stripped, so keeping it is rejected rather than ignored.
+var stripMetadataOption = files.Option{
+	Name:    MetadataOption,
+	Label:   "Metadata",
+	Help:    "Always stripped (location included) from these images",
+	Type:    files.ChoiceOption,
+	Default: MetadataStrip,
+	Choices: []string{MetadataStrip},
+}
+
+// orientations are the clockwise rotation, and the horizontal flip after it,
+// that turn upright the images of every EXIF orientation but the default one.
+var orientations = map[int]struct {
+	rotate float64
+	flip   bool
+}{
+	2: {0, true},
+	3: {180, false},
+	4: {180, true},
+	5: {90, true},
+	6: {90, false},
+	7: {270, true},
+	8: {270, false},
+}
+
+// autoOrient tells if the image should be rotated according to its EXIF orientation,
+// which is the default.
+func autoOrient(opts files.ConvertOptions) bool {
+	if !opts.Has(AutoOrientOption) {
+		return true
+	}
+
+	return opts.Bool(AutoOrientOption)
+}
+
+// metadata is the metadata of an image.
+type metadata struct {
+	// exif is the EXIF data, in the TIFF format, without the Exif header.
+	exif []byte
+	// icc is the ICC color profile.
+	icc []byte
+	// xmp is the XMP packet. It's only read, to tell if the image has it,
+	// it's never written back.
+	xmp []byte
+}
+
+var (
+	jpegSOI   = []byte{0xff, 0xd8}
+	pngHeader = []byte("\x89PNG\r\n\x1a\n")
+	exifID    = []byte("Exif\x00\x00")
+	xmpID     = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	iccID     = []byte("ICC_PROFILE\x00")
+)
+
+// readMetadata returns the metadata of a jpeg, png, webp or tiff image.
+// Metadata that can't be read is ignored, as it's not required to convert the image.
+func readMetadata(b []byte) metadata {
+	switch {
+	case bytes.HasPrefix(b, jpegSOI):
+		return readJPEGMetadata(b)
+	case bytes.HasPrefix(b, pngHeader):
+		return readPNGMetadata(b)
+	case len(b) > 12 && string(b[:4]) == "RIFF" && string(b[8:12]) == "WEBP":
+		return readWebPMetadata(b)
+	case bytes.HasPrefix(b, []byte("II*\x00")) || bytes.HasPrefix(b, []byte("MM\x00*")):
+		// The metadata of tiff images is part of the image itself,
+		// so only the orientation is read out of it.
+		return metadata{exif: orientationEXIF(metadata{exif: b}.orientation())}
+	}
+
+	return metadata{}
+}
+
+// jpegSegment is a marker segment of a jpeg file.
+type jpegSegment struct {
+	marker byte
+	// start and end are the offsets of the segment, marker included.
+	start, end int
+}
+
+// jpegSegments returns the segments of a jpeg file found before the image data.
+func jpegSegments(b []byte) []jpegSegment {
+	var segments []jpegSegment
+
+	for i := 2; i+4 <= len(b) && b[i] == 0xff; {
+		marker := b[i+1]
+		// The image data starts after the start of scan segment.
+		if marker == 0xda {
+			break
+		}
+
+		end := i + 2 + int(binary.BigEndian.Uint16(b[i+2:]))
+		if end > len(b) {
+			break
+		}
+
+		segments = append(segments, jpegSegment{marker: marker, start: i, end: end})
+		i = end
+	}
+
+	return segments
+}
+
+func readJPEGMetadata(b []byte) metadata {
+	var (
+		m   metadata
+		icc = map[byte][]byte{}
+	)
+
+	for _, s := range jpegSegments(b) {
+		payload := b[s.start+4 : s.end]
+
+		switch {
+		case s.marker == 0xe1 && bytes.HasPrefix(payload, exifID) && m.exif == nil:
+			m.exif = payload[len(exifID):]
+		case s.marker == 0xe1 && bytes.HasPrefix(payload, xmpID):
+			m.xmp = payload[len(xmpID):]
+		case s.marker == 0xe2 && bytes.HasPrefix(payload, iccID) && len(payload) > len(iccID)+2:
+			// The profile may be split in several segments, numbered from 1.
+			icc[payload[len(iccID)]] = payload[len(iccID)+2:]
+		}
+	}
+
+	seqs := make([]int, 0, len(icc))
+	for seq := range icc {
+		seqs = append(seqs, int(seq))
+	}
+	sort.Ints(seqs)
+
+	for _, seq := range seqs {
+		m.icc = append(m.icc, icc[byte(seq)]...)
+	}
+
+	return m
+}
+
+// pngChunk is a chunk of a png file.
+type pngChunk struct {
+	kind string
+	// start and end are the offsets of the chunk, length and crc included.
+	start, end int
+}
+
+// pngChunks returns the chunks of a png file.
+func pngChunks(b []byte) []pngChunk {
+	var chunks []pngChunk
+
+	for i := len(pngHeader); i+12 <= len(b); {
+		end := i + 12 + int(binary.BigEndian.Uint32(b[i:]))
+		if end > len(b) || end < i {
+			break
+		}
+
+		chunks = append(chunks, pngChunk{kind: string(b[i+4 : i+8]), start: i, end: end})
+		i = end
+	}
+
+	return chunks
+}
+
+func readPNGMetadata(b []byte) metadata {
+	var m metadata
+
+	for _, c := range pngChunks(b) {
+		data := b[c.start+8 : c.end-4]
+
+		switch c.kind {
+		case "eXIf":
+			m.exif = data
+		case "iTXt":
+			if bytes.HasPrefix(data, []byte("XML:com.adobe.xmp\x00")) {
+				m.xmp = data
+			}
+		case "iCCP":
+			// The profile name is followed by a null byte and the compression method.
+			i := bytes.IndexByte(data, 0)
+			if i < 0 || i+2 > len(data) {
+				continue
+			}
+
+			r, err := zlib.NewReader(bytes.NewReader(data[i+2:]))
+			if err != nil {
+				continue
+			}
+
+			if icc, err := io.ReadAll(r); err == nil {
+				m.icc = icc
+			}
+		}
+	}
+
+	return m
+}
+
+// webpChunk is a chunk of a webp file.
+type webpChunk struct {
+	kind string
+	data []byte
+}
+
+// webpChunks returns the chunks of a webp file.
+func webpChunks(b []byte) []webpChunk {
+	var chunks []webpChunk
+
+	for i := 12; i+8 <= len(b); {
+		size := int(binary.LittleEndian.Uint32(b[i+4:]))
+		end := i + 8 + size
+		if end > len(b) || end < i {
+			break
+		}
+
+		chunks = append(chunks, webpChunk{kind: string(b[i : i+4]), data: b[i+8 : end]})
+
+		// Chunks are padded to an even size.
+		i = end + size%2
+	}
+
+	return chunks
+}
+
+func readWebPMetadata(b []byte) metadata {
+	var m metadata
+
+	for _, c := range webpChunks(b) {
+		switch c.kind {
+		case "EXIF":
+			m.exif = bytes.TrimPrefix(c.data, exifID)
+		case "ICCP":
+			m.icc = c.data
+		case "XMP ":
+			m.xmp = c.data
+		}
+	}
+
+	return m
+}
+
+// exifByteOrder returns the byte order of EXIF data, and the offset of its first IFD.
+func exifByteOrder(exif []byte) (binary.ByteOrder, int, bool) {
+	if len(exif) < 8 {
+		return nil, 0, false
+	}
+
+	var order binary.ByteOrder
+	switch string(exif[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, 0, false
+	}
+
+	return order, int(order.Uint32(exif[4:])), true
+}
+
+// exifEntry returns the offset of the entry of a tag of the first IFD of the EXIF data.
+// The value of the tag starts 8 bytes after it.
+func exifEntry(exif []byte, tag uint16) (binary.ByteOrder, int, bool) {
+	order, ifd, ok := exifByteOrder(exif)
+	if !ok || ifd+2 > len(exif) {
+		return nil, 0, false
+	}
+
+	count := int(order.Uint16(exif[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(exif) {
+			break
+		}
+
+		if order.Uint16(exif[entry:]) == tag {
+			return order, entry, true
+		}
+	}
+
+	return nil, 0, false
+}
+
+// exifString returns the value of an ASCII tag of the first IFD of the EXIF data.
+func exifString(exif []byte, tag uint16) string {
+	order, entry, ok := exifEntry(exif, tag)
+	if !ok || order.Uint16(exif[entry+2:]) != 2 {
+		return ""
+	}
+
+	count := int(order.Uint32(exif[entry+4:]))
+	offset := entry + 8
+	// Values longer than 4 bytes are stored elsewhere.
+	if count > 4 {
+		offset = int(order.Uint32(exif[offset:]))
+	}
+
+	if count < 0 || offset < 0 || offset+count > len(exif) {
+		return ""
+	}
+
+	return strings.TrimRight(string(exif[offset:offset+count]), "\x00 ")
+}
+
+// orientationEXIF returns EXIF data that only holds the given orientation,
+// or nil if it's the default one.
+func orientationEXIF(orientation int) []byte {
+	if orientation == 1 {
+		return nil
+	}
+
+	// The header, and the first IFD with a single entry of type SHORT.
+	exif := []byte("II*\x00\x08\x00\x00\x00\x01\x00")
+	exif = binary.LittleEndian.AppendUint16(exif, orientationTag)
+	exif = binary.LittleEndian.AppendUint16(exif, 3)
+	exif = binary.LittleEndian.AppendUint32(exif, 1)
+	exif = binary.LittleEndian.AppendUint32(exif, uint32(orientation))
+
+	// There's no next IFD.
+	return binary.LittleEndian.AppendUint32(exif, 0)
+}
+
+// orientation returns the EXIF orientation, from 1 to 8, or 1 if it's not set.
+func (m metadata) orientation() int {
+	order, entry, ok := exifEntry(m.exif, orientationTag)
+	if !ok {
+		return 1
+	}
+
+	o := int(order.Uint16(m.exif[entry+8:]))
+	if o < 1 || o > 8 {
+		return 1
+	}
+
+	return o
+}
+
+// oriented returns the metadata of the image once its pixels are rotated
+// according to its orientation, which is set back to the default one.
+func (m metadata) oriented() metadata {
+	order, entry, ok := exifEntry(m.exif, orientationTag)
+	if !ok {
+		return m
+	}
+
+	exif := bytes.Clone(m.exif)
+	order.PutUint16(exif[entry+8:], 1)
+
+	return metadata{exif: exif, icc: m.icc, xmp: m.xmp}
+}
+
+// applyMetadataPolicy strips the metadata of an encoded image,
+// and adds back the metadata of the source image that the policy keeps.
+// Only jpeg and png images are changed, the rest are returned as they are.
+func applyMetadataPolicy(b []byte, target, policy string, m metadata) []byte {
+	switch policy {
+	case MetadataKeep:
+	case MetadataKeepColorProfile:
+		m.exif = nil
+	default:
+		m = metadata{}
+	}
+
+	switch target {
+	case JPG, JPEG:
+		return writeJPEGMetadata(b, m)
+	case PNG:
+		return writePNGMetadata(b, m)
+	}
+
+	return b
+}
+
+// writeJPEGMetadata replaces the metadata segments of a jpeg file with the given metadata.
+// The JFIF segment, if any, is kept as the first one.
+func writeJPEGMetadata(b []byte, m metadata) []byte {
+	if !bytes.HasPrefix(b, jpegSOI) {
+		return b
+	}
+
+	segments := jpegSegments(b)
+
+	out := bytes.NewBuffer(append([]byte{}, jpegSOI...))
+	rest := 2
+
+	if len(segments) > 0 && segments[0].marker == 0xe0 {
+		out.Write(b[segments[0].start:segments[0].end])
+		rest = segments[0].end
+		segments = segments[1:]
+	}
+
+	// EXIF data must fit in a single segment.
+	if m.exif != nil && len(exifID)+len(m.exif) <= 65533 {
+		writeJPEGSegment(out, 0xe1, exifID, m.exif)
+	}
+
+	// Every segment holds up to 65533 bytes, minus the ICC header.
+	const chunkSize = 65533 - 14
+	chunks := (len(m.icc) + chunkSize - 1) / chunkSize
+	for i := 0; i < chunks && chunks < 256; i++ {
+		chunk := m.icc[i*chunkSize : min(len(m.icc), (i+1)*chunkSize)]
+		writeJPEGSegment(out, 0xe2, append(bytes.Clone(iccID), byte(i+1), byte(chunks)), chunk)
+	}
+
+	for _, s := range segments {
+		// Drops the application segments, but the JFIF and Adobe ones,
+		// and the comments, since they may hold metadata.
+		if (s.marker >= 0xe1 && s.marker <= 0xed) || s.marker == 0xef || s.marker == 0xfe {
+			continue
+		}
+
+		out.Write(b[s.start:s.end])
+	}
+
+	if len(segments) > 0 {
+		rest = segments[len(segments)-1].end
+	}
+
+	out.Write(b[rest:])
+
+	return out.Bytes()
+}
+
+func writeJPEGSegment(w *bytes.Buffer, marker byte, header, data []byte) {
+	w.Write([]byte{0xff, marker})
+	binary.Write(w, binary.BigEndian, uint16(2+len(header)+len(data)))
+	w.Write(header)
+	w.Write(data)
+}
+
+// writePNGMetadata replaces the metadata chunks of a png file with the given metadata.
+func writePNGMetadata(b []byte, m metadata) []byte {
+	if !bytes.HasPrefix(b, pngHeader) {
+		return b
+	}
+
+	out := bytes.NewBuffer(append([]byte{}, pngHeader...))
+
+	for _, c := range pngChunks(b) {
+		switch c.kind {
+		case "eXIf", "iCCP", "tEXt", "zTXt", "iTXt", "tIME":
+			continue
+		}
+
+		out.Write(b[c.start:c.end])
+
+		// The metadata goes right after the header, since the color profile
+		// must be before the image data.
+		if c.kind == "IHDR" {
+			if m.icc != nil {
+				var profile bytes.Buffer
+				profile.WriteString("ICC Profile\x00\x00")
+				zw := zlib.NewWriter(&profile)
+				zw.Write(m.icc)
+				zw.Close()
+
+				writePNGChunk(out, "iCCP", profile.Bytes())
+			}
+
+			if m.exif != nil {
+				writePNGChunk(out, "eXIf", m.exif)
+			}
+		}
+	}
+
+	return out.Bytes()
+}
+
+func writePNGChunk(w *bytes.Buffer, kind string, data []byte) {
+	binary.Write(w, binary.BigEndian, uint32(len(data)))
+
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(kind))
+	crc.Write(data)
+
+	w.WriteString(kind)
+	w.Write(data)
+	binary.Write(w, binary.BigEndian, crc.Sum32())
+}
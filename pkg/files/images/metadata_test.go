@@ -0,0 +1,165 @@
+package images_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"net/url"
+	"testing"
+
+	"github.com/gen2brain/go-fitz"
+	"github.com/stretchr/testify/require"
+
+	"github.com/danvergara/morphos/pkg/files"
+	"github.com/danvergara/morphos/pkg/files/images"
+)
+
+// orientedJPEG returns a 40x20 jpeg image, red on the left half and blue on the right one,
+// with the given EXIF orientation.
+func orientedJPEG(t *testing.T, orientation uint16) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 40; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= 20 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, jpeg.Encode(buf, img, nil))
+
+	// The EXIF data has a single IFD, with the orientation tag.
+	exif := []byte("Exif\x00\x00II*\x00\x08\x00\x00\x00\x01\x00")
+	exif = binary.LittleEndian.AppendUint16(exif, 0x0112)
+	exif = binary.LittleEndian.AppendUint16(exif, 3)
+	exif = binary.LittleEndian.AppendUint32(exif, 1)
+	exif = binary.LittleEndian.AppendUint32(exif, uint32(orientation))
+	exif = binary.LittleEndian.AppendUint32(exif, 0)
+
+	// The APP1 segment goes right after the start of image marker.
+	segment := []byte{0xff, 0xe1}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(exif)+2))
+	segment = append(segment, exif...)
+
+	jpegBytes := buf.Bytes()
+
+	return append(append([]byte{0xff, 0xd8}, segment...), jpegBytes[2:]...)
+}
+
+func TestAutoOrient(t *testing.T) {
+	var tests = []struct {
+		name        string
+		orientation uint16
+		values      url.Values
+		// expected is the size of the image and the color of its top left corner.
+		expected image.Point
+		topLeft  string
+	}{
+		{name: "upright", orientation: 1, values: url.Values{}, expected: image.Pt(40, 20), topLeft: "red"},
+		{name: "upside down", orientation: 3, values: url.Values{}, expected: image.Pt(40, 20), topLeft: "blue"},
+		{name: "rotated 90", orientation: 6, values: url.Values{}, expected: image.Pt(20, 40), topLeft: "red"},
+		{name: "rotated 270", orientation: 8, values: url.Values{}, expected: image.Pt(20, 40), topLeft: "blue"},
+		{name: "mirrored", orientation: 2, values: url.Values{}, expected: image.Pt(40, 20), topLeft: "blue"},
+		{name: "transposed", orientation: 5, values: url.Values{}, expected: image.Pt(20, 40), topLeft: "red"},
+		{
+			name:        "auto-orient disabled",
+			orientation: 6,
+			values:      url.Values{"auto_orient": {"false"}},
+			expected:    image.Pt(40, 20),
+			topLeft:     "red",
+		},
+		{
+			name:        "oriented before the crop",
+			orientation: 6,
+			values:      url.Values{"crop": {"0,0,20,20"}},
+			expected:    image.Pt(20, 20),
+			topLeft:     "red",
+		},
+	}
+
+	schema, err := files.ConversionOptions(images.JPEG, images.PNG)
+	require.NoError(t, err)
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			opts, err := schema.Parse(tc.values)
+			require.NoError(t, err)
+
+			result, err := images.NewJpeg().ConvertTo(
+				context.Background(),
+				"Document",
+				images.PDF,
+				bytes.NewReader(orientedJPEG(t, tc.orientation)),
+				opts,
+			)
+			require.NoError(t, err)
+
+			pdfBytes, err := io.ReadAll(result)
+			require.NoError(t, err)
+
+			doc, err := fitz.NewFromMemory(pdfBytes)
+			require.NoError(t, err)
+			defer doc.Close()
+
+			bounds, err := doc.Bound(0)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, bounds.Size())
+
+			page, err := doc.Image(0)
+			require.NoError(t, err)
+
+			// The corner is sampled a bit inside, away from the edges.
+			r, _, b, _ := page.At(page.Bounds().Dx()/10, page.Bounds().Dy()/10).RGBA()
+			topLeft := "red"
+			if b > r {
+				topLeft = "blue"
+			}
+			require.Equal(t, tc.topLeft, topLeft)
+		})
+	}
+}
+
+func TestMetadataOption(t *testing.T) {
+	var tests = []struct {
+		name   string
+		source string
+		target string
+		policy string
+		hasErr bool
+	}{
+		{name: "keep in jpeg", source: images.PNG, target: images.JPEG, policy: images.MetadataKeep},
+		{name: "keep the color profile in png", source: images.JPEG, target: images.PNG, policy: images.MetadataKeepColorProfile},
+		{name: "keep in jxl", source: images.JPEG, target: images.JXL, policy: images.MetadataKeep},
+		{name: "strip in webp", source: images.JPEG, target: images.WEBP, policy: images.MetadataStrip},
+		// The metadata of the rest of the formats is always stripped.
+		{name: "keep in webp", source: images.JPEG, target: images.WEBP, policy: images.MetadataKeep, hasErr: true},
+		{name: "keep in tiff", source: images.JPEG, target: images.TIFF, policy: images.MetadataKeep, hasErr: true},
+		{name: "keep in avif", source: images.JPEG, target: images.AVIF, policy: images.MetadataKeepColorProfile, hasErr: true},
+		{name: "keep in bmp", source: images.JPEG, target: images.BMP, policy: images.MetadataKeep, hasErr: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			schema, err := files.ConversionOptions(tc.source, tc.target)
+			require.NoError(t, err)
+
+			opts, err := schema.Parse(url.Values{images.MetadataOption: {tc.policy}})
+			if tc.hasErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.policy, opts.String(images.MetadataOption, ""))
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"regexp"
+
+	"github.com/danvergara/morphos/pkg/files"
+	"github.com/danvergara/morphos/pkg/util"
+)
+
+const (
+	// LanguageOption sets the language of the text recognized, e.g. eng, or several of them, e.g. eng+spa.
+	LanguageOption = "language"
+
+	// defaultLanguage is the language tesseract recognizes by default.
+	defaultLanguage = "eng"
+)
+
+// languages matches the names of the languages of tesseract, joined by plus signs. e.g. chi_sim+eng
+var languages = regexp.MustCompile(`^[A-Za-z0-9_]+(\+[A-Za-z0-9_]+)*$`)
+
+// OCROptions are the options accepted when recognizing the text of images.
+var OCROptions = files.Schema{
+	{
+		Name:    LanguageOption,
+		Label:   "Language",
+		Help:    "Language of the text, e.g. eng, deu or chi_sim, or several of them joined by plus signs, e.g. eng+spa",
+		Type:    files.StringOption,
+		Default: defaultLanguage,
+		Validate: func(language string) error {
+			if !languages.MatchString(language) {
+				return fmt.Errorf("must be the names of tesseract languages joined by plus signs")
+			}
+			return nil
+		},
+	},
+}
+
+// ocrFormats maps the targets of the recognition to the output formats of tesseract.
+var ocrFormats = map[string]string{
+	TXT:    "txt",
+	HOCR:   "hocr",
+	OCRPDF: "pdf",
+}
+
+// OCR recognizes the text of the pages, encoded as images, with tesseract.
+// It returns the text as a txt or a hocr file, or a searchable pdf that holds the pages under their text.
+// The dpi is the resolution of the pages, or 0 if it's not known.
+func OCR(ctx context.Context, target string, dpi int, pages [][]byte, opts files.ConvertOptions) ([]byte, error) {
+	format, ok := ocrFormats[target]
+	if !ok {
+		return nil, fmt.Errorf("the text can't be recognized as %s", target)
+	}
+
+	return util.OCR(ctx, format, opts.String(LanguageOption, defaultLanguage), dpi, pages...)
+}
+
+// recognize recognizes the text of the images, every one of them a page, as the target format.
+// The images are encoded as png files, so they are kept as they are in searchable pdf files.
+func recognize(ctx context.Context, target string, imgs []image.Image, opts files.ConvertOptions) ([]byte, error) {
+	pages := make([][]byte, len(imgs))
+
+	for i, img := range imgs {
+		buf := new(bytes.Buffer)
+		if err := png.Encode(buf, img); err != nil {
+			return nil, fmt.Errorf("error encoding the image to recognize its text: %w", err)
+		}
+
+		pages[i] = buf.Bytes()
+	}
+
+	result, err := OCR(ctx, target, 0, pages, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// tesseract ends every page with a form feed, the one of the last page is not needed.
+	if target == TXT {
+		result = bytes.TrimRight(result, "\f")
+	}
+
+	return result, nil
+}
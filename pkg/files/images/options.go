@@ -0,0 +1,196 @@
+package images
+
+import (
+	"fmt"
+	"strconv"
+
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+
+	"github.com/danvergara/morphos/pkg/files"
+)
+
+const (
+	// QualityOption sets the quality of lossy formats, from 1 to 100.
+	QualityOption = "quality"
+	// LosslessOption enables the lossless mode of the formats that support it.
+	LosslessOption = "lossless"
+	// PNGCompressionOption sets the compression level of png images, from 0 to 9.
+	PNGCompressionOption = "png_compression"
+	// TIFFCompressionOption sets the compression of tiff images.
+	TIFFCompressionOption = "tiff_compression"
+	// ColorsOption sets the size of the palette of gif images.
+	ColorsOption = "colors"
+
+	// TIFFNone stores the pixels of tiff images as they are.
+	TIFFNone = "none"
+	// TIFFLZW compresses tiff images with LZW, the most supported compression.
+	TIFFLZW = "lzw"
+	// TIFFDeflate compresses tiff images with deflate, the smallest of them.
+	TIFFDeflate = "deflate"
+
+	// defaultJPEGQuality is the quality used by the image/jpeg package by default.
+	defaultJPEGQuality = 75
+	// defaultWebPQuality is the quality used by libwebp by default.
+	defaultWebPQuality = 75
+	// maxColors is the size of the largest palette of gif images.
+	maxColors = 256
+)
+
+// inputOptions are the options accepted when converting from any image format.
+// What happens to the metadata depends on the target, so it's an output option.
+var inputOptions = geometryOptions.Merge(files.Schema{autoOrientOption})
+
+// qualityOption returns the quality option of a format, with the given default.
+// The default of the encoder is used if it's empty.
+func qualityOption(format, defaultQuality string) files.Option {
+	return files.Option{
+		Name:    QualityOption,
+		Label:   format + " quality",
+		Help:    "From 1 (smallest file) to 100 (best quality)",
+		Type:    files.IntOption,
+		Default: defaultQuality,
+		Min:     1,
+		Max:     100,
+	}
+}
+
+// losslessOption returns the lossless option of a format.
+func losslessOption(format string) files.Option {
+	return files.Option{
+		Name:  LosslessOption,
+		Label: format + " lossless",
+		Help:  "Encodes the image without losing quality, the quality is ignored",
+		Type:  files.BoolOption,
+	}
+}
+
+// jpegOutputOptions are the options accepted when converting to jpeg.
+var jpegOutputOptions = files.Schema{
+	qualityOption("JPEG", strconv.Itoa(defaultJPEGQuality)),
+	metadataOption,
+}
+
+// webpOutputOptions are the options accepted when converting to webp.
+var webpOutputOptions = files.Schema{
+	qualityOption("WebP", strconv.Itoa(defaultWebPQuality)),
+	losslessOption("WebP"),
+	stripMetadataOption,
+}
+
+// avifOutputOptions are the options accepted when converting to avif.
+var avifOutputOptions = files.Schema{
+	qualityOption("AVIF", ""),
+	losslessOption("AVIF"),
+	stripMetadataOption,
+}
+
+// pngOutputOptions are the options accepted when converting to png.
+var pngOutputOptions = files.Schema{
+	{
+		Name:  PNGCompressionOption,
+		Label: "PNG compression level",
+		Help:  "From 0 (none) to 9 (smallest file). The default of the encoder if empty",
+		Type:  files.IntOption,
+		Min:   0,
+		Max:   9,
+	},
+	metadataOption,
+}
+
+// tiffOutputOptions are the options accepted when converting to tiff.
+var tiffOutputOptions = files.Schema{
+	{
+		Name:    TIFFCompressionOption,
+		Label:   "TIFF compression",
+		Help:    "Both lzw and deflate are lossless",
+		Type:    files.ChoiceOption,
+		Default: TIFFLZW,
+		Choices: []string{TIFFNone, TIFFLZW, TIFFDeflate},
+	},
+	stripMetadataOption,
+}
+
+// gifOutputOptions are the options accepted when converting to gif.
+var gifOutputOptions = files.Schema{
+	{
+		Name:    ColorsOption,
+		Label:   "GIF colors",
+		Help:    "Size of the palette, fewer colors make smaller files",
+		Type:    files.IntOption,
+		Default: strconv.Itoa(maxColors),
+		Min:     2,
+		Max:     maxColors,
+	},
+	stripMetadataOption,
+}
+
+// bmpOutputOptions are the options accepted when converting to bmp.
+var bmpOutputOptions = files.Schema{
+	stripMetadataOption,
+}
+
+// ffmpegOutputArgs returns the arguments passed to ffmpeg to encode the
+// output image, based on the target format and the options of the conversion.
+func ffmpegOutputArgs(target string, opts files.ConvertOptions) ffmpeg.KwArgs {
+	args := ffmpeg.KwArgs{}
+
+	switch target {
+	case JPG, JPEG:
+		if opts.Has(QualityOption) {
+			args["q:v"] = jpegQScale(opts.Int(QualityOption, defaultJPEGQuality))
+		}
+	case WEBP:
+		if opts.Bool(LosslessOption) {
+			args["lossless"] = 1
+		} else if opts.Has(QualityOption) {
+			args["quality"] = opts.Int(QualityOption, defaultWebPQuality)
+		}
+	case AVIF:
+		// The options are those of libaom, the encoder ffmpeg picks by default.
+		if opts.Bool(LosslessOption) {
+			args["c:v"] = "libaom-av1"
+			args["aom-params"] = "lossless=1"
+		} else if opts.Has(QualityOption) {
+			args["c:v"] = "libaom-av1"
+			args["crf"] = avifCRF(opts.Int(QualityOption, 0))
+		}
+	case PNG:
+		if opts.Has(PNGCompressionOption) {
+			args["compression_level"] = opts.Int(PNGCompressionOption, 0)
+		}
+	case TIFF:
+		algo := opts.String(TIFFCompressionOption, TIFFLZW)
+		if algo == TIFFNone {
+			algo = "raw"
+		}
+		args["compression_algo"] = algo
+	}
+
+	return args
+}
+
+// ffmpegEncodeFilter returns the filters needed to encode the output image,
+// which go after the geometry ones, or an empty string if there are none.
+func ffmpegEncodeFilter(target string, opts files.ConvertOptions) string {
+	if target != GIF {
+		return ""
+	}
+
+	// Builds an optimal palette of the colors set, instead of using a generic one.
+	return fmt.Sprintf(
+		"split[a][b];[a]palettegen=max_colors=%d[p];[b][p]paletteuse",
+		opts.Int(ColorsOption, maxColors),
+	)
+}
+
+// jpegQScale maps a quality from 1 to 100 to the scale of the ffmpeg jpeg encoder,
+// which goes from 2 (best) to 31 (worst).
+func jpegQScale(quality int) int {
+	return 2 + (100-quality)*29/99
+}
+
+// avifCRF maps a quality from 1 to 100 to the constant rate factor of libaom,
+// which goes from 0 (best) to 63 (worst).
+func avifCRF(quality int) int {
+	return (100 - quality) * 63 / 99
+}
@@ -2,6 +2,8 @@ package images
 
 import (
 	"bytes"
+	"context"
+	"encoding/binary"
 	"fmt"
 	"image"
 	"image/draw"
@@ -9,6 +11,8 @@ import (
 	"io"
 	"slices"
 	"strings"
+
+	"github.com/danvergara/morphos/pkg/files"
 )
 
 // Png struct implements the File and Image interface from the files pkg.
@@ -17,6 +21,17 @@ type Png struct {
 	compatibleMIMETypes map[string][]string
 }
 
+func init() {
+	files.Register(files.Format{
+		Name:          PNG,
+		Category:      files.Img,
+		MIMETypes:     []string{"image/png", "image/vnd.mozilla.apng"},
+		Decoder:       func(string) files.File { return NewPng() },
+		InputOptions:  animatedInputOptions,
+		OutputOptions: pngOutputOptions,
+	})
+}
+
 // NewPng returns a pointer to a Png instance.
 // The Png object is set with a map with list of supported file formats.
 func NewPng() *Png {
@@ -30,9 +45,13 @@ func NewPng() *Png {
 				WEBP,
 				TIFF,
 				BMP,
+				JXL,
 			},
 			"Document": {
 				PDF,
+				TXT,
+				HOCR,
+				OCRPDF,
 			},
 		},
 		compatibleMIMETypes: map[string][]string{
@@ -44,9 +63,13 @@ func NewPng() *Png {
 				WEBP,
 				TIFF,
 				BMP,
+				JXL,
 			},
 			"Document": {
 				PDF,
+				TXT,
+				HOCR,
+				OCRPDF,
 			},
 		},
 	}
@@ -67,7 +90,7 @@ func (p *Png) SupportedMIMETypes() map[string][]string {
 
 // ConvertTo method converts a given file to a target format.
 // This method returns a file in form of a slice of bytes.
-func (p *Png) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, error) {
+func (p *Png) ConvertTo(ctx context.Context, fileType, subType string, file io.Reader, opts files.ConvertOptions) (io.Reader, error) {
 	var result []byte
 
 	compatibleFormats, ok := p.SupportedFormats()[fileType]
@@ -79,16 +102,33 @@ func (p *Png) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, er
 		return nil, fmt.Errorf("ConvertTo: file sub-type not supported: %s", subType)
 	}
 
+	fileBytes, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading from the image file: %w", err)
+	}
+
+	// Animated images are converted frame by frame.
+	anim, err := readAnimation(ctx, PNG, fileBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	if anim != nil {
+		return convertAnimation(ctx, fileType, subType, anim, readMetadata(fileBytes), opts)
+	}
+
+	file = bytes.NewReader(fileBytes)
+
 	switch strings.ToLower(fileType) {
 	case imageType:
-		convertedImage, err := convertToImage(subType, file)
+		convertedImage, err := convertToImage(ctx, subType, file, png.Decode, opts)
 		if err != nil {
 			return nil, err
 		}
 
 		return convertedImage, nil
 	case documentType:
-		img, err := png.Decode(file)
+		img, m, err := decodeImage(file, png.Decode)
 		if err != nil {
 			return nil, err
 		}
@@ -96,7 +136,7 @@ func (p *Png) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, er
 		rgba := image.NewRGBA(img.Bounds())
 		draw.Draw(rgba, img.Bounds(), img, image.Point{}, draw.Src)
 
-		result, err = convertToDocument(subType, rgba)
+		result, err = convertToDocument(ctx, subType, rgba, m, opts)
 		if err != nil {
 			return nil, fmt.Errorf(
 				"ConvertTo: error at converting image to another format: %w",
@@ -108,6 +148,24 @@ func (p *Png) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, er
 	return bytes.NewReader(result), nil
 }
 
+// Inspect describes the image.
+// This method implements the files.Inspector interface.
+func (p *Png) Inspect(_ context.Context, file io.Reader) (any, error) {
+	info, fileBytes, err := readInfo(file, png.DecodeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// The acTL chunk of animated png images holds their number of frames.
+	for _, c := range pngChunks(fileBytes) {
+		if c.kind == "acTL" && c.end-c.start >= 16 {
+			info.Frames = int(binary.BigEndian.Uint32(fileBytes[c.start+8:]))
+		}
+	}
+
+	return info, nil
+}
+
 // ImageType returns the file format of the current image.
 // This method implements the Image interface.
 func (p *Png) ImageType() string {
@@ -0,0 +1,376 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gen2brain/go-fitz"
+
+	"github.com/danvergara/morphos/pkg/files"
+)
+
+const (
+	// DPIOption sets the resolution svg images are rendered at.
+	DPIOption = "dpi"
+
+	// defaultSVGDPI is the resolution of CSS pixels, the units of svg images.
+	defaultSVGDPI = 96
+)
+
+// svgInputOptions are the options accepted when converting from svg.
+// The metadata options don't apply, since svg images are rendered, rather than decoded.
+var svgInputOptions = geometryOptions.Merge(files.Schema{
+	{
+		Name:    DPIOption,
+		Label:   "Resolution (DPI)",
+		Help:    "Resolution the image is rendered at, ignored if the width or the height are set",
+		Type:    files.IntOption,
+		Default: strconv.Itoa(defaultSVGDPI),
+		Min:     10,
+		Max:     1200,
+	},
+})
+
+// Svg struct implements the File and Image interface from the files pkg.
+// Svg images are rendered by MuPDF, the same library that renders pdf files.
+type Svg struct {
+	compatibleFormats   map[string][]string
+	compatibleMIMETypes map[string][]string
+}
+
+func init() {
+	files.Register(files.Format{
+		Name:         SVG,
+		Category:     files.Img,
+		MIMETypes:    []string{"image/svg+xml"},
+		Decoder:      func(string) files.File { return NewSvg() },
+		InputOptions: svgInputOptions,
+	})
+}
+
+// NewSvg returns a pointer to a Svg instance.
+// The Svg object is set with a map with list of supported file formats.
+func NewSvg() *Svg {
+	s := Svg{
+		compatibleFormats: map[string][]string{
+			"Image": {
+				JPG,
+				JPEG,
+				PNG,
+				GIF,
+				WEBP,
+				TIFF,
+				BMP,
+				AVIF,
+				JXL,
+			},
+			"Document": {
+				PDF,
+				TXT,
+				HOCR,
+				OCRPDF,
+			},
+		},
+
+		compatibleMIMETypes: map[string][]string{
+			"Image": {
+				JPG,
+				JPEG,
+				PNG,
+				GIF,
+				WEBP,
+				TIFF,
+				BMP,
+				AVIF,
+				JXL,
+			},
+			"Document": {
+				PDF,
+				TXT,
+				HOCR,
+				OCRPDF,
+			},
+		},
+	}
+
+	return &s
+}
+
+// SupportedFormats returns a map with a slice of supported files.
+// Every key of the map represents the kind of a file.
+func (s *Svg) SupportedFormats() map[string][]string {
+	return s.compatibleFormats
+}
+
+// SupportedMIMETypes returns a map with a slice of supported MIME types.
+func (s *Svg) SupportedMIMETypes() map[string][]string {
+	return s.compatibleMIMETypes
+}
+
+// ConvertTo method converts a given file to a target format.
+// This method returns a file in form of a slice of bytes.
+func (s *Svg) ConvertTo(ctx context.Context, fileType, subType string, file io.Reader, opts files.ConvertOptions) (io.Reader, error) {
+	var result []byte
+
+	compatibleFormats, ok := s.SupportedFormats()[fileType]
+	if !ok {
+		return nil, fmt.Errorf("ConvertTo: file type not supported: %s", fileType)
+	}
+
+	if !slices.Contains(compatibleFormats, subType) {
+		return nil, fmt.Errorf("ConvertTo: file sub-type not supported: %s", subType)
+	}
+
+	fileBytes, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	// Formats without transparency get the white background of the render.
+	transparent := !slices.Contains([]string{JPG, JPEG, PDF}, subType)
+
+	img, err := rasterizeSVG(fileBytes, transparent, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(fileType) {
+	case imageType:
+		return convertDecoded(ctx, subType, img, metadata{}, opts)
+	case documentType:
+		result, err = convertToDocument(ctx, subType, img, metadata{}, opts)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"ConvertTo: error at converting image to another format: %w",
+				err,
+			)
+		}
+	}
+
+	return bytes.NewReader(result), nil
+}
+
+// Inspect describes the image, with its size in CSS pixels.
+// This method implements the files.Inspector interface.
+func (s *Svg) Inspect(_ context.Context, file io.Reader) (any, error) {
+	fileBytes, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := openSVG(fileBytes, false)
+	if err != nil {
+		return nil, err
+	}
+	defer doc.Close()
+
+	bounds, err := doc.Bound(0)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the size of the svg image: %w", err)
+	}
+
+	return ImageInfo{Width: bounds.Dx(), Height: bounds.Dy()}, nil
+}
+
+// ImageType method returns the file format of the current image.
+// This method implements the Image interface.
+func (s *Svg) ImageType() string {
+	return SVG
+}
+
+// rasterizeSVG renders the svg image at the resolution set in the options,
+// or at the one that matches the width or the height, if they are set,
+// so it's not scaled up afterwards.
+// MuPDF renders images on a white background, so transparent images are rendered
+// once more on a black one, to tell how transparent every pixel is
+// out of the difference between both.
+func rasterizeSVG(b []byte, transparent bool, opts files.ConvertOptions) (image.Image, error) {
+	doc, err := openSVG(b, false)
+	if err != nil {
+		return nil, err
+	}
+	defer doc.Close()
+
+	bounds, err := doc.Bound(0)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the size of the svg image: %w", err)
+	}
+
+	if bounds.Empty() {
+		return nil, errors.New("the svg image has no size")
+	}
+
+	g, err := geometryFromOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// The bounds of the image are in CSS pixels.
+	scale := float64(opts.Int(DPIOption, defaultSVGDPI)) / defaultSVGDPI
+
+	// The crop is set in pixels of the rendered image, so the resolution is kept as it is.
+	if g.crop == nil && (g.width > 0 || g.height > 0) {
+		_, scaled := g.size(bounds.Size())
+		scale = max(float64(scaled.X)/float64(bounds.Dx()), float64(scaled.Y)/float64(bounds.Dy()))
+	}
+
+	if w, h := float64(bounds.Dx())*scale, float64(bounds.Dy())*scale; w > maxDimension || h > maxDimension {
+		return nil, fmt.Errorf("the svg image would be rendered at %.0fx%.0f pixels, larger than %d pixels", w, h, maxDimension)
+	}
+
+	// MuPDF renders an image of 72 DPI, one pixel per CSS pixel.
+	onWhite, err := doc.ImageDPI(0, 72*scale)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering the svg image: %w", err)
+	}
+
+	if !transparent {
+		return onWhite, nil
+	}
+
+	blackDoc, err := openSVG(b, true)
+	if err != nil {
+		return nil, err
+	}
+	defer blackDoc.Close()
+
+	onBlack, err := blackDoc.ImageDPI(0, 72*scale)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering the svg image: %w", err)
+	}
+
+	white, black := toRGBA(onWhite), toRGBA(onBlack)
+	if white.Bounds() != black.Bounds() {
+		return nil, errors.New("error rendering the svg image: the renders don't match")
+	}
+
+	// A pixel is as transparent as it's lighter on white than on black,
+	// and its color, on black, is already multiplied by its alpha.
+	img := image.NewRGBA(white.Bounds())
+	for i := 0; i < len(img.Pix); i += 4 {
+		var diff int
+		for ch := 0; ch < 3; ch++ {
+			diff += int(white.Pix[i+ch]) - int(black.Pix[i+ch])
+		}
+
+		alpha := uint8(255 - min(255, max(0, diff/3)))
+		for ch := 0; ch < 3; ch++ {
+			img.Pix[i+ch] = min(black.Pix[i+ch], alpha)
+		}
+		img.Pix[i+3] = alpha
+	}
+
+	return img, nil
+}
+
+// toRGBA returns the image as an RGBA image, which MuPDF renders already.
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Bounds().Min == (image.Point{}) {
+		return rgba
+	}
+
+	rgba := image.NewRGBA(image.Rectangle{Max: img.Bounds().Size()})
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+
+	return rgba
+}
+
+// openSVG opens the svg image with MuPDF, once it's sanitized.
+// If onBlack is true, the image is drawn on a black background.
+func openSVG(b []byte, onBlack bool) (*fitz.Document, error) {
+	sanitized, err := sanitizeSVG(b, onBlack)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := fitz.NewFromMemory(sanitized)
+	if err != nil {
+		return nil, fmt.Errorf("error opening the svg image: %w", err)
+	}
+
+	return doc, nil
+}
+
+// blackBackground is drawn behind the elements of the svg, covering any view box.
+const blackBackground = `<rect x="-1000000" y="-1000000" width="2000000" height="2000000" fill="black"/>`
+
+// sanitizeSVG returns the svg image without anything that could make the renderer
+// read other files or reach the network: the document type, which declares entities,
+// processing instructions, e.g. stylesheets, and links to anything but the elements
+// of the image itself or data URIs. If onBlack is true, a black background is added.
+func sanitizeSVG(b []byte, onBlack bool) ([]byte, error) {
+	d := xml.NewDecoder(bytes.NewReader(b))
+
+	buf := new(bytes.Buffer)
+	depth := 0
+
+	for {
+		// The raw tokens keep the prefixes of the names as they are.
+		token, err := d.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error parsing the svg image: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			buf.WriteString("<" + rawName(t.Name))
+			for _, attr := range t.Attr {
+				if attr.Name.Local == "href" && !isLocalRef(attr.Value) {
+					continue
+				}
+
+				buf.WriteString(" " + rawName(attr.Name) + `="` + attrEscaper.Replace(attr.Value) + `"`)
+			}
+			buf.WriteString(">")
+
+			if depth == 0 && onBlack {
+				buf.WriteString(blackBackground)
+			}
+			depth++
+		case xml.EndElement:
+			buf.WriteString("</" + rawName(t.Name) + ">")
+			depth--
+		case xml.CharData:
+			// The text outside of the root element is left out, it's only white space.
+			if depth > 0 {
+				buf.WriteString(textEscaper.Replace(string(t)))
+			}
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+var (
+	// textEscaper escapes the characters that can't be part of the text of an element.
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	// attrEscaper escapes the characters that can't be part of the value of an attribute.
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+)
+
+// rawName returns the name of an element or an attribute, with its prefix.
+func rawName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+
+	return name.Space + ":" + name.Local
+}
+
+// isLocalRef tells if the link points to an element of the svg itself, or to a data URI.
+func isLocalRef(ref string) bool {
+	ref = strings.TrimSpace(ref)
+	return strings.HasPrefix(ref, "#") || strings.HasPrefix(strings.ToLower(ref), "data:")
+}
@@ -0,0 +1,145 @@
+package images_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/danvergara/morphos/pkg/files"
+	"github.com/danvergara/morphos/pkg/files/images"
+)
+
+// convertSVG converts the svg image to the target format, and decodes the result.
+func convertSVG(t *testing.T, svg []byte, target string, values url.Values) (image.Image, error) {
+	schema, err := files.ConversionOptions(images.SVG, target)
+	require.NoError(t, err)
+
+	opts, err := schema.Parse(values)
+	require.NoError(t, err)
+
+	result, err := images.NewSvg().ConvertTo(context.Background(), "Image", target, bytes.NewReader(svg), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	resultBytes, err := io.ReadAll(result)
+	require.NoError(t, err)
+
+	img, format, err := image.Decode(bytes.NewReader(resultBytes))
+	require.NoError(t, err)
+	require.Equal(t, target, format)
+
+	return img, nil
+}
+
+func TestConvertSVG(t *testing.T) {
+	logo, err := os.ReadFile("testdata/logo.svg")
+	require.NoError(t, err)
+
+	var tests = []struct {
+		name     string
+		target   string
+		values   url.Values
+		expected image.Point
+		// corner is the color of the top left corner, outside of the shapes.
+		corner color.NRGBA
+	}{
+		{
+			name:     "png",
+			target:   images.PNG,
+			values:   url.Values{},
+			expected: image.Pt(120, 60),
+			corner:   color.NRGBA{},
+		},
+		{
+			name:     "png at a width",
+			target:   images.PNG,
+			values:   url.Values{"width": {"480"}},
+			expected: image.Pt(480, 240),
+			corner:   color.NRGBA{},
+		},
+		{
+			name:     "png at a dpi",
+			target:   images.PNG,
+			values:   url.Values{"dpi": {"192"}},
+			expected: image.Pt(240, 120),
+			corner:   color.NRGBA{},
+		},
+		{
+			name:     "jpeg on white",
+			target:   images.JPEG,
+			values:   url.Values{"height": {"30"}},
+			expected: image.Pt(60, 30),
+			corner:   color.NRGBA{R: 255, G: 255, B: 255, A: 255},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			img, err := convertSVG(t, logo, tc.target, tc.values)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, img.Bounds().Size())
+
+			// Jpeg images are lossy, so the colors are close, rather than the same.
+			corner := color.NRGBAModel.Convert(img.At(0, 0)).(color.NRGBA)
+			require.InDelta(t, tc.corner.R, corner.R, 8)
+			require.InDelta(t, tc.corner.G, corner.G, 8)
+			require.InDelta(t, tc.corner.B, corner.B, 8)
+			require.Equal(t, tc.corner.A, corner.A)
+		})
+	}
+}
+
+func TestConvertSVGReferences(t *testing.T) {
+	red := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for i := range red.Pix {
+		red.Pix[i] = []byte{255, 0, 0, 255}[i%4]
+	}
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, png.Encode(buf, red))
+	dataURI := "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	gopher, err := filepath.Abs("testdata/gopher_pirate.png")
+	require.NoError(t, err)
+
+	withImage := func(href string) []byte {
+		return []byte(fmt.Sprintf(
+			`<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="40" height="40">`+
+				`<image xlink:href="%s" x="0" y="0" width="40" height="40"/></svg>`,
+			href,
+		))
+	}
+
+	t.Run("data URI", func(t *testing.T) {
+		img, err := convertSVG(t, withImage(dataURI), images.PNG, url.Values{})
+		require.NoError(t, err)
+		require.Equal(t, color.NRGBA{R: 255, A: 255}, color.NRGBAModel.Convert(img.At(20, 20)))
+	})
+
+	t.Run("external file", func(t *testing.T) {
+		img, err := convertSVG(t, withImage("file://"+gopher), images.PNG, url.Values{})
+		require.NoError(t, err)
+		require.Equal(t, color.NRGBA{}, color.NRGBAModel.Convert(img.At(20, 20)))
+	})
+
+	t.Run("external entity", func(t *testing.T) {
+		svg := []byte(`<!DOCTYPE svg [<!ENTITY secret SYSTEM "file:///etc/passwd">]>` +
+			`<svg xmlns="http://www.w3.org/2000/svg" width="40" height="40"><text>&secret;</text></svg>`)
+
+		_, err := convertSVG(t, svg, images.PNG, url.Values{})
+		require.Error(t, err)
+	})
+}
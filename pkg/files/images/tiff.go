@@ -2,12 +2,15 @@ package images
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"slices"
 	"strings"
 
 	"golang.org/x/image/tiff"
+
+	"github.com/danvergara/morphos/pkg/files"
 )
 
 // Tiff struct implements the File and Image interface from the files pkg.
@@ -16,6 +19,17 @@ type Tiff struct {
 	compatibleMIMETypes map[string][]string
 }
 
+func init() {
+	files.Register(files.Format{
+		Name:          TIFF,
+		Category:      files.Img,
+		MIMETypes:     []string{"image/tiff"},
+		Decoder:       func(string) files.File { return NewTiff() },
+		InputOptions:  inputOptions,
+		OutputOptions: tiffOutputOptions,
+	})
+}
+
 // NewTiff returns a pointer to a Tiff instance.
 // The Tiff object is set with a map with list of supported file formats.
 func NewTiff() *Tiff {
@@ -29,9 +43,13 @@ func NewTiff() *Tiff {
 				GIF,
 				WEBP,
 				BMP,
+				JXL,
 			},
 			"Document": {
 				PDF,
+				TXT,
+				HOCR,
+				OCRPDF,
 			},
 		},
 		compatibleMIMETypes: map[string][]string{
@@ -43,9 +61,13 @@ func NewTiff() *Tiff {
 				GIF,
 				WEBP,
 				BMP,
+				JXL,
 			},
 			"Document": {
 				PDF,
+				TXT,
+				HOCR,
+				OCRPDF,
 			},
 		},
 	}
@@ -66,7 +88,7 @@ func (t *Tiff) SupportedMIMETypes() map[string][]string {
 
 // ConvertTo method converts a given file to a target format.
 // This method returns a file in form of a slice of bytes.
-func (t *Tiff) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, error) {
+func (t *Tiff) ConvertTo(ctx context.Context, fileType, subType string, file io.Reader, opts files.ConvertOptions) (io.Reader, error) {
 
 	var result []byte
 
@@ -81,19 +103,19 @@ func (t *Tiff) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, e
 
 	switch strings.ToLower(fileType) {
 	case imageType:
-		convertedImage, err := convertToImage(subType, file)
+		convertedImage, err := convertToImage(ctx, subType, file, tiff.Decode, opts)
 		if err != nil {
 			return nil, err
 		}
 
 		return convertedImage, nil
 	case documentType:
-		img, err := tiff.Decode(file)
+		img, m, err := decodeImage(file, tiff.Decode)
 		if err != nil {
 			return nil, err
 		}
 
-		result, err = convertToDocument(subType, img)
+		result, err = convertToDocument(ctx, subType, img, m, opts)
 		if err != nil {
 			return nil, fmt.Errorf(
 				"ConvertTo: error at converting image to another format: %w",
@@ -105,6 +127,13 @@ func (t *Tiff) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, e
 	return bytes.NewReader(result), nil
 }
 
+// Inspect describes the image.
+// This method implements the files.Inspector interface.
+func (t *Tiff) Inspect(_ context.Context, file io.Reader) (any, error) {
+	info, _, err := readInfo(file, tiff.DecodeConfig)
+	return info, err
+}
+
 // ImageType returns the file format of the current image.
 // This method implements the Image interface.
 func (t *Tiff) ImageType() string {
@@ -2,14 +2,19 @@ package images
 
 import (
 	"bytes"
+	"context"
+	"encoding/binary"
 	"fmt"
 	"image"
 	"image/draw"
 	"io"
 	"slices"
 	"strings"
+	"time"
 
 	"golang.org/x/image/webp"
+
+	"github.com/danvergara/morphos/pkg/files"
 )
 
 // Webp struct implements the File and Image interface from the files pkg.
@@ -18,6 +23,18 @@ type Webp struct {
 	compatibleMIMETypes map[string][]string
 }
 
+func init() {
+	files.Register(files.Format{
+		Name:          WEBP,
+		Category:      files.Img,
+		MIMETypes:     []string{"image/webp"},
+		Decoder:       func(string) files.File { return NewWebp() },
+		InputOptions:  animatedInputOptions,
+		Cost:          1,
+		OutputOptions: webpOutputOptions,
+	})
+}
+
 // NewWebp returns a pointer to a Webp instance.
 // The Webp object is set with a map with list of supported file formats.
 func NewWebp() *Webp {
@@ -31,9 +48,13 @@ func NewWebp() *Webp {
 				GIF,
 				TIFF,
 				BMP,
+				JXL,
 			},
 			"Document": {
 				PDF,
+				TXT,
+				HOCR,
+				OCRPDF,
 			},
 		},
 		compatibleMIMETypes: map[string][]string{
@@ -45,9 +66,13 @@ func NewWebp() *Webp {
 				GIF,
 				TIFF,
 				BMP,
+				JXL,
 			},
 			"Document": {
 				PDF,
+				TXT,
+				HOCR,
+				OCRPDF,
 			},
 		},
 	}
@@ -68,7 +93,7 @@ func (w *Webp) SupportedMIMETypes() map[string][]string {
 
 // ConvertTo method converts a given file to a target format.
 // This method returns a file in form of a slice of bytes.
-func (w *Webp) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, error) {
+func (w *Webp) ConvertTo(ctx context.Context, fileType, subType string, file io.Reader, opts files.ConvertOptions) (io.Reader, error) {
 
 	var result []byte
 
@@ -81,16 +106,33 @@ func (w *Webp) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, e
 		return nil, fmt.Errorf("ConvertTo: file sub-type not supported: %s", subType)
 	}
 
+	fileBytes, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading from the image file: %w", err)
+	}
+
+	// Animated images are converted frame by frame.
+	anim, err := readAnimation(ctx, WEBP, fileBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	if anim != nil {
+		return convertAnimation(ctx, fileType, subType, anim, readMetadata(fileBytes), opts)
+	}
+
+	file = bytes.NewReader(fileBytes)
+
 	switch strings.ToLower(fileType) {
 	case imageType:
-		convertedImage, err := convertToImage(subType, file)
+		convertedImage, err := convertToImage(ctx, subType, file, webp.Decode, opts)
 		if err != nil {
 			return nil, err
 		}
 
 		return convertedImage, nil
 	case documentType:
-		img, err := webp.Decode(file)
+		img, m, err := decodeImage(file, webp.Decode)
 		if err != nil {
 			return nil, err
 		}
@@ -98,7 +140,7 @@ func (w *Webp) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, e
 		rgba := image.NewRGBA(img.Bounds())
 		draw.Draw(rgba, img.Bounds(), img, image.Point{}, draw.Src)
 
-		result, err = convertToDocument(subType, rgba)
+		result, err = convertToDocument(ctx, subType, rgba, m, opts)
 		if err != nil {
 			return nil, fmt.Errorf(
 				"ConvertTo: error at converting image to another format: %w",
@@ -110,8 +152,188 @@ func (w *Webp) ConvertTo(fileType, subType string, file io.Reader) (io.Reader, e
 	return bytes.NewReader(result), nil
 }
 
+// Inspect describes the image.
+// This method implements the files.Inspector interface.
+func (w *Webp) Inspect(_ context.Context, file io.Reader) (any, error) {
+	return readWebPInfo(file, webp.DecodeConfig)
+}
+
 // ImageType method returns the file format of the current image.
 // This method implements the Image interface.
 func (w *Webp) ImageType() string {
 	return WEBP
 }
+
+const (
+	// webpAnimationFlag and webpAlphaFlag are the flags of the VP8X chunk
+	// telling the image is animated, and has transparency.
+	webpAnimationFlag = 0x02
+	webpAlphaFlag     = 0x10
+
+	// webpNoBlending and webpDisposeBackground are the flags of an ANMF chunk
+	// telling the frame replaces the canvas, and is cleared once it's shown.
+	webpNoBlending        = 0x02
+	webpDisposeBackground = 0x01
+)
+
+// decodeWebPAnimation decodes every frame of an animated webp image, or returns nil if it's a still one.
+// The webp package can't decode animations, so every frame is wrapped in a webp file of its own.
+func decodeWebPAnimation(b []byte) (*animation, error) {
+	chunks := webpChunks(b)
+	if len(chunks) == 0 || chunks[0].kind != "VP8X" || len(chunks[0].data) < 10 || chunks[0].data[0]&webpAnimationFlag == 0 {
+		return nil, nil
+	}
+
+	header := chunks[0].data
+	width := 1 + uint24(header[4:])
+	height := 1 + uint24(header[7:])
+
+	if width*height > maxAnimationPixels {
+		return nil, fmt.Errorf("the canvas has more than %d pixels", maxAnimationPixels)
+	}
+
+	anim := &animation{}
+	canvas := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	for _, c := range chunks {
+		switch {
+		case c.kind == "ANIM" && len(c.data) >= 6:
+			anim.loops = int(binary.LittleEndian.Uint16(c.data[4:]))
+		case c.kind == "ANMF" && len(c.data) >= 16:
+			// The offset of the frame is stored halved.
+			x, y := 2*uint24(c.data), 2*uint24(c.data[3:])
+			delay := time.Duration(uint24(c.data[12:])) * time.Millisecond
+			flags := c.data[15]
+
+			frame, err := decodeWebPFrame(c.data[16:], 1+uint24(c.data[6:]), 1+uint24(c.data[9:]))
+			if err != nil {
+				return nil, fmt.Errorf("error decoding the frame %d: %w", len(anim.frames)+1, err)
+			}
+
+			bounds := frame.Bounds().Add(image.Pt(x, y))
+
+			op := draw.Over
+			if flags&webpNoBlending != 0 {
+				op = draw.Src
+			}
+
+			draw.Draw(canvas, bounds, frame, frame.Bounds().Min, op)
+
+			if err := anim.add(canvas, delay); err != nil {
+				return nil, err
+			}
+
+			if flags&webpDisposeBackground != 0 {
+				draw.Draw(canvas, bounds, image.Transparent, image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	return anim, nil
+}
+
+// decodeWebPFrame decodes the chunks of a frame of an animated webp image,
+// its alpha channel and its bitstream.
+func decodeWebPFrame(data []byte, width, height int) (image.Image, error) {
+	// The frame data is a sequence of chunks, just like the ones of a webp file.
+	frame := webpChunks(append(make([]byte, 12), data...))
+
+	body := new(bytes.Buffer)
+
+	for _, c := range frame {
+		if c.kind == "ALPH" {
+			// The alpha channel is only read by the extended format.
+			vp8x := make([]byte, 10)
+			vp8x[0] = webpAlphaFlag
+			putUint24(vp8x[4:], width-1)
+			putUint24(vp8x[7:], height-1)
+			writeWebPChunk(body, "VP8X", vp8x)
+			break
+		}
+	}
+
+	for _, c := range frame {
+		switch c.kind {
+		case "ALPH", "VP8 ", "VP8L":
+			writeWebPChunk(body, c.kind, c.data)
+		}
+	}
+
+	return webp.Decode(bytes.NewReader(webpFile(body.Bytes())))
+}
+
+// encodeWebPAnimation encodes the animation as an animated webp image.
+// Every frame is encoded as a still webp image, whose chunks go into an ANMF chunk.
+func encodeWebPAnimation(anim *animation, opts files.ConvertOptions) ([]byte, error) {
+	size := anim.frames[0].Bounds().Size()
+
+	vp8x := make([]byte, 10)
+	vp8x[0] = webpAnimationFlag | webpAlphaFlag
+	putUint24(vp8x[4:], size.X-1)
+	putUint24(vp8x[7:], size.Y-1)
+
+	body := new(bytes.Buffer)
+	writeWebPChunk(body, "VP8X", vp8x)
+
+	// The background is transparent, and the loop count takes 16 bits.
+	animChunk := make([]byte, 6)
+	binary.LittleEndian.PutUint16(animChunk[4:], uint16(min(anim.loops, 0xffff)))
+	writeWebPChunk(body, "ANIM", animChunk)
+
+	for i, frame := range anim.frames {
+		buf := new(bytes.Buffer)
+		if err := Encode(buf, WEBP, frame, opts); err != nil {
+			return nil, fmt.Errorf("error encoding the frame %d: %w", i+1, err)
+		}
+
+		// The frame covers the whole canvas, and replaces the previous one.
+		anmf := make([]byte, 16)
+		putUint24(anmf[6:], size.X-1)
+		putUint24(anmf[9:], size.Y-1)
+		putUint24(anmf[12:], min(int(anim.delays[i]/time.Millisecond), 0xffffff))
+		anmf[15] = webpNoBlending
+
+		frameData := bytes.NewBuffer(anmf)
+		for _, c := range webpChunks(buf.Bytes()) {
+			switch c.kind {
+			case "ALPH", "VP8 ", "VP8L":
+				writeWebPChunk(frameData, c.kind, c.data)
+			}
+		}
+
+		writeWebPChunk(body, "ANMF", frameData.Bytes())
+	}
+
+	return webpFile(body.Bytes()), nil
+}
+
+// webpFile returns the chunks wrapped in the RIFF header of a webp file.
+func webpFile(chunks []byte) []byte {
+	b := make([]byte, 12, 12+len(chunks))
+	copy(b, "RIFF")
+	binary.LittleEndian.PutUint32(b[4:], uint32(4+len(chunks)))
+	copy(b[8:], "WEBP")
+
+	return append(b, chunks...)
+}
+
+// writeWebPChunk writes a chunk of a webp file, padded to an even size.
+func writeWebPChunk(w *bytes.Buffer, kind string, data []byte) {
+	w.WriteString(kind)
+	binary.Write(w, binary.LittleEndian, uint32(len(data)))
+	w.Write(data)
+
+	if len(data)%2 == 1 {
+		w.WriteByte(0)
+	}
+}
+
+// uint24 reads a little endian 24 bits integer.
+func uint24(b []byte) int {
+	return int(b[0]) | int(b[1])<<8 | int(b[2])<<16
+}
+
+// putUint24 writes a little endian 24 bits integer.
+func putUint24(b []byte, v int) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
@@ -0,0 +1,210 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidOption is returned when the value of a conversion option is not valid.
+var ErrInvalidOption = errors.New("invalid option")
+
+// OptionType is the kind of value a conversion option holds.
+type OptionType string
+
+const (
+	IntOption    OptionType = "int"
+	FloatOption  OptionType = "float"
+	BoolOption   OptionType = "bool"
+	StringOption OptionType = "string"
+	ChoiceOption OptionType = "choice"
+)
+
+// Option describes a conversion option, so it can be validated
+// and rendered as a form field.
+type Option struct {
+	// Name is the name of the form field. e.g. quality.
+	Name string
+	// Label is a human friendly name of the option.
+	Label string
+	// Help is a short description of the option.
+	Help string
+	Type OptionType
+	// Default is the value used if the option is not set.
+	// An empty default means the option is not set at all.
+	Default string
+	// Min and Max bound the value of numeric options, if Min is lower than Max.
+	Min float64
+	Max float64
+	// Choices are the values accepted by choice options.
+	Choices []string
+	// Validate is optional. It checks the raw value of the option,
+	// after the checks based on its type.
+	Validate func(string) error
+}
+
+// parse returns the typed value of the option, given its raw value.
+func (o Option) parse(raw string) (any, error) {
+	var (
+		value any
+		err   error
+	)
+
+	switch o.Type {
+	case IntOption:
+		var n int
+		n, err = strconv.Atoi(raw)
+		if err == nil {
+			err = o.checkRange(float64(n))
+		}
+		value = n
+	case FloatOption:
+		var n float64
+		n, err = strconv.ParseFloat(raw, 64)
+		if err == nil {
+			err = o.checkRange(n)
+		}
+		value = n
+	case BoolOption:
+		// Checkboxes send "on" when no value is set.
+		if raw == "on" {
+			raw = "true"
+		}
+		value, err = strconv.ParseBool(raw)
+	case ChoiceOption:
+		if !slices.Contains(o.Choices, raw) {
+			err = fmt.Errorf("must be one of %s", strings.Join(o.Choices, ", "))
+		}
+		value = raw
+	default:
+		value = raw
+	}
+
+	if err == nil && o.Validate != nil {
+		err = o.Validate(raw)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w %s=%q: %v", ErrInvalidOption, o.Name, raw, err)
+	}
+
+	return value, nil
+}
+
+func (o Option) checkRange(n float64) error {
+	if o.Min < o.Max && (n < o.Min || n > o.Max) {
+		return fmt.Errorf("must be between %v and %v", o.Min, o.Max)
+	}
+
+	return nil
+}
+
+// Schema is the set of options a format accepts.
+type Schema []Option
+
+// Merge returns a schema with the options of both schemas.
+// If an option is in both of them, the one in s is kept.
+func (s Schema) Merge(other Schema) Schema {
+	result := slices.Clone(s)
+
+	for _, o := range other {
+		if !slices.ContainsFunc(result, func(e Option) bool { return e.Name == o.Name }) {
+			result = append(result, o)
+		}
+	}
+
+	return result
+}
+
+// Parse validates the raw values, usually the fields of a form,
+// and returns the typed options. Values that are not part of the
+// schema are ignored, options without value take their default.
+func (s Schema) Parse(values url.Values) (ConvertOptions, error) {
+	opts := ConvertOptions{values: make(map[string]any)}
+
+	for _, o := range s {
+		raw := strings.TrimSpace(values.Get(o.Name))
+		if raw == "" {
+			raw = o.Default
+		}
+
+		if raw == "" {
+			continue
+		}
+
+		value, err := o.parse(raw)
+		if err != nil {
+			return ConvertOptions{}, err
+		}
+
+		opts.values[o.Name] = value
+	}
+
+	return opts, nil
+}
+
+// ConvertOptions holds the typed values of the options of a conversion.
+// The zero value has no options set, so every getter returns the
+// default value passed to it.
+type ConvertOptions struct {
+	values map[string]any
+}
+
+// Has tells if the option is set.
+func (c ConvertOptions) Has(name string) bool {
+	_, ok := c.values[name]
+	return ok
+}
+
+// Int returns the value of an int option, or def if not set.
+func (c ConvertOptions) Int(name string, def int) int {
+	if v, ok := c.values[name].(int); ok {
+		return v
+	}
+
+	return def
+}
+
+// Float returns the value of a float option, or def if not set.
+// The values of int options are returned as floats as well.
+func (c ConvertOptions) Float(name string, def float64) float64 {
+	switch v := c.values[name].(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	}
+
+	return def
+}
+
+// Bool returns the value of a bool option, or false if not set.
+func (c ConvertOptions) Bool(name string) bool {
+	v, _ := c.values[name].(bool)
+	return v
+}
+
+// String returns the value of a string or choice option, or def if not set.
+func (c ConvertOptions) String(name string, def string) string {
+	if v, ok := c.values[name].(string); ok {
+		return v
+	}
+
+	return def
+}
+
+// Without returns a copy of the options, without the given ones.
+func (c ConvertOptions) Without(names ...string) ConvertOptions {
+	result := ConvertOptions{values: make(map[string]any, len(c.values))}
+
+	for k, v := range c.values {
+		if !slices.Contains(names, k) {
+			result.values[k] = v
+		}
+	}
+
+	return result
+}
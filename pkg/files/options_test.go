@@ -0,0 +1,138 @@
+package files_test
+
+import (
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/danvergara/morphos/pkg/files"
+)
+
+var testSchema = files.Schema{
+	{Name: "quality", Type: files.IntOption, Default: "75", Min: 1, Max: 100},
+	{Name: "scale", Type: files.FloatOption},
+	{Name: "lossless", Type: files.BoolOption},
+	{Name: "delimiter", Type: files.ChoiceOption, Default: "comma", Choices: []string{"comma", "tab"}},
+	{Name: "title", Type: files.StringOption},
+}
+
+func TestSchemaParse(t *testing.T) {
+	var tests = []struct {
+		name   string
+		values url.Values
+		check  func(t *testing.T, opts files.ConvertOptions)
+		hasErr bool
+	}{
+		{
+			name:   "defaults",
+			values: url.Values{},
+			check: func(t *testing.T, opts files.ConvertOptions) {
+				require.Equal(t, 75, opts.Int("quality", 0))
+				require.Equal(t, "comma", opts.String("delimiter", ""))
+				require.False(t, opts.Has("scale"))
+				require.False(t, opts.Bool("lossless"))
+				require.Equal(t, 1.5, opts.Float("scale", 1.5))
+			},
+		},
+		{
+			name: "typed values",
+			values: url.Values{
+				"quality":   {"90"},
+				"scale":     {"0.5"},
+				"lossless":  {"on"},
+				"delimiter": {"tab"},
+				"title":     {"foo"},
+				"unknown":   {"bar"},
+			},
+			check: func(t *testing.T, opts files.ConvertOptions) {
+				require.Equal(t, 90, opts.Int("quality", 0))
+				require.Equal(t, 90.0, opts.Float("quality", 0))
+				require.Equal(t, 0.5, opts.Float("scale", 0))
+				require.True(t, opts.Bool("lossless"))
+				require.Equal(t, "tab", opts.String("delimiter", ""))
+				require.Equal(t, "foo", opts.String("title", ""))
+				require.False(t, opts.Has("unknown"))
+			},
+		},
+		{name: "out of range", values: url.Values{"quality": {"101"}}, hasErr: true},
+		{name: "not a number", values: url.Values{"quality": {"best"}}, hasErr: true},
+		{name: "not a bool", values: url.Values{"lossless": {"maybe"}}, hasErr: true},
+		{name: "unknown choice", values: url.Values{"delimiter": {"space"}}, hasErr: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			opts, err := testSchema.Parse(tc.values)
+			if tc.hasErr {
+				require.ErrorIs(t, err, files.ErrInvalidOption)
+				return
+			}
+
+			require.NoError(t, err)
+			tc.check(t, opts)
+		})
+	}
+}
+
+func TestSchemaMerge(t *testing.T) {
+	a := files.Schema{{Name: "quality", Default: "75"}}
+	b := files.Schema{{Name: "quality", Default: "90"}, {Name: "dpi"}}
+
+	merged := a.Merge(b)
+	require.Len(t, merged, 2)
+	require.Equal(t, "75", merged[0].Default)
+	require.Equal(t, "dpi", merged[1].Name)
+}
+
+func TestConversionOptions(t *testing.T) {
+	image := []string{"width", "height", "resize", "kernel", "crop", "rotate", "flip", "auto_orient"}
+	archive := []string{"archive", "archive_format", "compression"}
+	// Png images may be animated.
+	animated := append(slices.Clone(image), "animation")
+
+	var tests = []struct {
+		name     string
+		source   string
+		target   string
+		expected []string
+	}{
+		{name: "pdf to jpeg", source: "pdf", target: "jpeg", expected: append([]string{"dpi", "width", "pages", "quality", "metadata"}, archive...)},
+		{name: "png to webp", source: "png", target: "webp", expected: slices.Concat(animated, []string{"quality", "lossless", "metadata"}, archive)},
+		{name: "csv to pdf", source: "csv", target: "pdf", expected: append([]string{"delimiter"}, archive...)},
+		{name: "png to gif", source: "png", target: "gif", expected: slices.Concat(animated, []string{"colors", "metadata"}, archive)},
+		// Only the text extracted from pdf files can be split into pages, or recognized page by page.
+		{name: "pdf to txt", source: "pdf", target: "txt", expected: append([]string{"dpi", "width", "pages", "language", "page_breaks", "ocr"}, archive...)},
+		{name: "png to txt", source: "png", target: "txt", expected: slices.Concat(animated, []string{"language"}, archive)},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			schema, err := files.ConversionOptions(tc.source, tc.target)
+			require.NoError(t, err)
+
+			var names []string
+			for _, o := range schema {
+				names = append(names, o.Name)
+			}
+
+			require.Equal(t, tc.expected, names)
+		})
+	}
+}
+
+func TestConvertOptionsWithout(t *testing.T) {
+	opts, err := testSchema.Parse(url.Values{"quality": {"90"}, "lossless": {"true"}})
+	require.NoError(t, err)
+
+	without := opts.Without("quality")
+	require.False(t, without.Has("quality"))
+	require.True(t, without.Bool("lossless"))
+	require.Equal(t, "comma", without.String("delimiter", ""))
+
+	// The original options are left as they are.
+	require.Equal(t, 90, opts.Int("quality", 0))
+}
@@ -0,0 +1,404 @@
+package files
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+
+	"github.com/danvergara/morphos/pkg/progress"
+)
+
+// ErrNoPlan is returned when there is no chain of conversions
+// from a source format to a target one.
+var ErrNoPlan = errors.New("no conversion path found")
+
+// Plan is a chain of conversions, from a source format to a target format.
+// The first element is the sub-type of the source,
+// the last one is the sub-type of the target.
+// e.g. avif -> png -> pdf
+type Plan []string
+
+// String returns the chain of conversions separated by arrows.
+func (p Plan) String() string {
+	return strings.Join(p, " -> ")
+}
+
+// edge is a one-step conversion from a format to another one.
+type edge struct {
+	// target is the format the file is converted to.
+	target Format
+	// subType is the sub-type passed to the conversion,
+	// which could be an alias of the target format.
+	subType string
+}
+
+// Planner finds chains of conversions over the graph of formats
+// of a Registry, where every format is a node and the formats
+// it can be converted to in a single step are its edges.
+type Planner struct {
+	registry *Registry
+}
+
+// NewPlanner returns a Planner that works with the formats of the given Registry.
+func NewPlanner(r *Registry) *Planner {
+	return &Planner{registry: r}
+}
+
+// edges returns the one-step conversions of a format,
+// in the order they are listed by the format.
+func (p *Planner) edges(f Format) []edge {
+	targets, err := p.registry.Targets(f.Name)
+	if err != nil {
+		return nil
+	}
+
+	fileTypes := make([]string, 0, len(targets))
+	for k := range targets {
+		fileTypes = append(fileTypes, k)
+	}
+	sort.Strings(fileTypes)
+
+	var result []edge
+	seen := make(map[string]int)
+
+	for _, fileType := range fileTypes {
+		for _, subType := range targets[fileType] {
+			target, ok := p.registry.Lookup(subType)
+			if !ok || target.Name == f.Name {
+				continue
+			}
+
+			// Keep a single edge per target, the canonical name is preferred over aliases.
+			if i, ok := seen[target.Name]; ok {
+				if subType == target.Name {
+					result[i].subType = subType
+				}
+				continue
+			}
+
+			seen[target.Name] = len(result)
+			result = append(result, edge{target: target, subType: subType})
+		}
+	}
+
+	return result
+}
+
+// node is the state of a format while searching the graph.
+type node struct {
+	format  Format
+	cost    int
+	prev    string
+	subType string
+	order   int
+	done    bool
+}
+
+// search walks the graph from the source format, finding the cheapest chain
+// to every reachable format. The cost of a chain is the sum of the conversions,
+// where every conversion costs one plus the cost of the format it produces.
+// Ties are broken by the order the formats are listed as targets.
+func (p *Planner) search(source Format) map[string]*node {
+	nodes := map[string]*node{
+		source.Name: {format: source, subType: source.Name},
+	}
+
+	for {
+		var current *node
+		for _, n := range nodes {
+			if n.done {
+				continue
+			}
+
+			if current == nil || n.cost < current.cost || (n.cost == current.cost && n.order < current.order) {
+				current = n
+			}
+		}
+
+		if current == nil {
+			return nodes
+		}
+
+		current.done = true
+
+		for _, e := range p.edges(current.format) {
+			cost := current.cost + 1 + e.target.Cost
+
+			n, ok := nodes[e.target.Name]
+			if !ok {
+				n = &node{format: e.target, order: len(nodes)}
+				nodes[e.target.Name] = n
+			} else if n.done || n.cost <= cost {
+				continue
+			}
+
+			n.cost = cost
+			n.prev = current.format.Name
+			n.subType = e.subType
+		}
+	}
+}
+
+// Plan returns the cheapest chain of conversions from the source sub-type
+// to the target one. It errors out with ErrNoPlan if the target can't be reached.
+func (p *Planner) Plan(source, target string) (Plan, error) {
+	sourceFormat, ok := p.registry.Lookup(source)
+	if !ok {
+		return nil, fmt.Errorf("format %s not registered", source)
+	}
+
+	targetFormat, ok := p.registry.Lookup(target)
+	if !ok {
+		return nil, fmt.Errorf("format %s not registered", target)
+	}
+
+	nodes := p.search(sourceFormat)
+
+	n, ok := nodes[targetFormat.Name]
+	if !ok || targetFormat.Name == sourceFormat.Name {
+		return nil, fmt.Errorf("%w: from %s to %s", ErrNoPlan, source, target)
+	}
+
+	// The last conversion uses the requested sub-type, if it's listed
+	// by the previous format. e.g. jpg instead of jpeg.
+	last := n.subType
+	if p.lists(nodes[n.prev].format, target) {
+		last = target
+	}
+
+	plan := Plan{last}
+
+	for n.prev != sourceFormat.Name {
+		n = nodes[n.prev]
+		plan = append(Plan{n.subType}, plan...)
+	}
+
+	return append(Plan{source}, plan...), nil
+}
+
+// lists tells if the sub-type is listed as a target of the format.
+func (p *Planner) lists(f Format, subType string) bool {
+	targets, err := p.registry.Targets(f.Name)
+	if err != nil {
+		return false
+	}
+
+	for _, v := range targets {
+		if slices.Contains(v, subType) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reachable returns every format the source sub-type can be converted to,
+// through one or more conversions.
+// Every key of the map represents the kind of a file, just like the
+// File.SupportedFormats method. The formats supported by the file itself
+// are listed first, followed by the ones that need more conversions,
+// from the cheapest to the most expensive.
+func (p *Planner) Reachable(source string) (map[string][]string, error) {
+	sourceFormat, ok := p.registry.Lookup(source)
+	if !ok {
+		return nil, fmt.Errorf("format %s not registered", source)
+	}
+
+	result, err := p.registry.Targets(sourceFormat.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	var indirect []*node
+	for _, n := range p.search(sourceFormat) {
+		if n.prev != "" && n.prev != sourceFormat.Name {
+			indirect = append(indirect, n)
+		}
+	}
+
+	sort.Slice(indirect, func(i, j int) bool {
+		if indirect[i].cost != indirect[j].cost {
+			return indirect[i].cost < indirect[j].cost
+		}
+		return indirect[i].order < indirect[j].order
+	})
+
+	for _, n := range indirect {
+		fileType := FileType(n.format.Category)
+		result[fileType] = append(result[fileType], n.format.Name)
+	}
+
+	return result, nil
+}
+
+// Options returns the schema of the options accepted by the conversion
+// from the source sub-type to the target one. It's made of the input options
+// of every format converted along the chain, and the output options of every
+// format produced along the chain, besides the archive option.
+func (p *Planner) Options(source, target string) (Schema, error) {
+	plan, err := p.Plan(source, target)
+	if err != nil {
+		return nil, err
+	}
+
+	var schema Schema
+
+	for i, subType := range plan {
+		format, ok := p.registry.Lookup(subType)
+		if !ok {
+			return nil, fmt.Errorf("format %s not registered", subType)
+		}
+
+		if i < len(plan)-1 {
+			schema = schema.Merge(format.InputOptions)
+		}
+
+		if i > 0 {
+			schema = schema.Merge(format.OutputOptions)
+
+			if from, ok := p.registry.Lookup(plan[i-1]); ok {
+				schema = schema.Merge(format.OutputOptionsFrom[from.Name])
+			}
+		}
+	}
+
+	return schema.Merge(ArchiveOptions), nil
+}
+
+// Convert converts a file from the source sub-type to the target one,
+// following the cheapest chain of conversions.
+// The output of every intermediate conversion is the input of the next one,
+// and the options are passed to every conversion, except for the input options,
+// which are only passed to the first conversion from a format that accepts them,
+// so they are not applied twice. e.g. the crop of an image.
+// The result is wrapped in an archive or not, based on the archive options.
+// It returns the converted file and the plan that was followed.
+func (p *Planner) Convert(ctx context.Context, filename, source, target string, file io.Reader, opts ConvertOptions) (io.Reader, Plan, error) {
+	plan, err := p.Plan(source, target)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// consumed are the input options already passed to a conversion.
+	var consumed []string
+
+	for i := 1; i < len(plan); i++ {
+		from, to := plan[i-1], plan[i]
+
+		// Stops before the next step if the conversion was cancelled.
+		if err := ctx.Err(); err != nil {
+			return nil, plan, err
+		}
+
+		format, ok := p.registry.Lookup(from)
+		if !ok {
+			return nil, plan, fmt.Errorf("format %s not registered", from)
+		}
+
+		progress.Report(ctx, progress.Event{
+			Stage:   progress.Converting,
+			Current: i - 1,
+			Total:   len(plan) - 1,
+			Message: fmt.Sprintf("converting from %s to %s", from, to),
+		})
+
+		file, err = p.registry.ConvertTo(ctx, format.Decoder(filename), from, to, file, opts.Without(consumed...))
+		if err != nil {
+			return nil, plan, fmt.Errorf("error converting from %s to %s: %w", from, to, err)
+		}
+
+		for _, o := range format.InputOptions {
+			consumed = append(consumed, o.Name)
+		}
+
+		filename = fmt.Sprintf(
+			"%s.%s",
+			strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)),
+			to,
+		)
+
+		// The output of the last conversion is archived according to the options.
+		if i == len(plan)-1 {
+			break
+		}
+
+		file, err = unwrapSingleFile(file)
+		if err != nil {
+			return nil, plan, fmt.Errorf("error converting from %s to %s: %w", from, to, err)
+		}
+	}
+
+	file, err = Archive(opts, filename, file)
+	if err != nil {
+		return nil, plan, err
+	}
+
+	return file, plan, nil
+}
+
+// unwrapSingleFile returns the content of the file compressed in a zip file,
+// since some conversions wrap their output in a zip file.
+// If the file is not a zip file, it's returned as is.
+// It errors out if the zip file contains more than one file,
+// because only one file can be passed to the next conversion.
+func unwrapSingleFile(file io.Reader) (io.Reader, error) {
+	fileBytes, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if !mimetype.Detect(fileBytes).Is("application/zip") {
+		return bytes.NewReader(fileBytes), nil
+	}
+
+	zipReader, err := zip.NewReader(bytes.NewReader(fileBytes), int64(len(fileBytes)))
+	if err != nil {
+		return nil, fmt.Errorf("error opening the intermediate zip file: %w", err)
+	}
+
+	if len(zipReader.File) != 1 {
+		return nil, fmt.Errorf(
+			"the intermediate conversion produced %d files, only one can be converted further",
+			len(zipReader.File),
+		)
+	}
+
+	content, err := readZipFile(zipReader.File[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(content), nil
+}
+
+// PlanConversion returns a chain of conversions using the formats of the DefaultRegistry.
+func PlanConversion(source, target string) (Plan, error) {
+	return NewPlanner(DefaultRegistry).Plan(source, target)
+}
+
+// Reachable returns the formats reachable from the source sub-type,
+// using the formats of the DefaultRegistry.
+func Reachable(source string) (map[string][]string, error) {
+	return NewPlanner(DefaultRegistry).Reachable(source)
+}
+
+// ConversionOptions returns the schema of the options of a conversion,
+// using the formats of the DefaultRegistry.
+func ConversionOptions(source, target string) (Schema, error) {
+	return NewPlanner(DefaultRegistry).Options(source, target)
+}
+
+// Convert converts a file following a chain of conversions,
+// using the formats of the DefaultRegistry.
+func Convert(ctx context.Context, filename, source, target string, file io.Reader, opts ConvertOptions) (io.Reader, Plan, error) {
+	return NewPlanner(DefaultRegistry).Convert(ctx, filename, source, target, file, opts)
+}
@@ -0,0 +1,237 @@
+package files_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/danvergara/morphos/pkg/files"
+	_ "github.com/danvergara/morphos/pkg/files/documents"
+	_ "github.com/danvergara/morphos/pkg/files/images"
+)
+
+// chainFile is a fake File that writes the chain of conversions it took part in.
+// Its conversions to zip-wrapped formats return a zip file with a single file.
+type chainFile struct {
+	formats map[string][]string
+	zipped  []string
+}
+
+func (c *chainFile) SupportedFormats() map[string][]string   { return c.formats }
+func (c *chainFile) SupportedMIMETypes() map[string][]string { return c.formats }
+func (c *chainFile) ConvertTo(_ context.Context, fileType, subType string, file io.Reader, _ files.ConvertOptions) (io.Reader, error) {
+	b, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	content := string(b) + "," + subType
+
+	for _, z := range c.zipped {
+		if z != subType {
+			continue
+		}
+
+		buf := new(bytes.Buffer)
+		zw := zip.NewWriter(buf)
+		w, err := zw.Create("file." + subType)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+
+		return buf, nil
+	}
+
+	return strings.NewReader(content), nil
+}
+
+func newChainRegistry(t *testing.T) *files.Registry {
+	t.Helper()
+
+	r := files.NewRegistry()
+
+	register := func(name string, cost int, formats map[string][]string, zipped ...string) {
+		require.NoError(t, r.Register(files.Format{
+			Name:     name,
+			Category: files.Doc,
+			Cost:     cost,
+			Decoder: func(string) files.File {
+				return &chainFile{formats: formats, zipped: zipped}
+			},
+		}))
+	}
+
+	register("a", 0, map[string][]string{"Document": {"b", "c"}})
+	register("b", 1, map[string][]string{"Document": {"d"}})
+	register("c", 0, map[string][]string{"Document": {"d"}}, "d")
+	register("d", 0, map[string][]string{"Document": {"e"}})
+	register("e", 0, map[string][]string{})
+
+	return r
+}
+
+func TestPlannerPlan(t *testing.T) {
+	p := files.NewPlanner(newChainRegistry(t))
+
+	var tests = []struct {
+		name     string
+		source   string
+		target   string
+		expected files.Plan
+		hasErr   bool
+	}{
+		{name: "single step", source: "a", target: "b", expected: files.Plan{"a", "b"}},
+		{name: "cheapest intermediate", source: "a", target: "d", expected: files.Plan{"a", "c", "d"}},
+		{name: "three steps", source: "a", target: "e", expected: files.Plan{"a", "c", "d", "e"}},
+		{name: "unreachable", source: "e", target: "a", hasErr: true},
+		{name: "same format", source: "a", target: "a", hasErr: true},
+		{name: "unknown format", source: "a", target: "z", hasErr: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			plan, err := p.Plan(tc.source, tc.target)
+			if tc.hasErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, plan)
+		})
+	}
+}
+
+func TestPlannerReachable(t *testing.T) {
+	p := files.NewPlanner(newChainRegistry(t))
+
+	reachable, err := p.Reachable("a")
+	require.NoError(t, err)
+	require.Equal(t, map[string][]string{"Document": {"b", "c", "d", "e"}}, reachable)
+}
+
+func TestPlannerConvert(t *testing.T) {
+	p := files.NewPlanner(newChainRegistry(t))
+
+	// The intermediate zip file produced by c -> d is unwrapped before d -> e.
+	result, plan, err := p.Convert(context.Background(), "foo.a", "a", "e", strings.NewReader("a"), files.ConvertOptions{})
+	require.NoError(t, err)
+	require.Equal(t, files.Plan{"a", "c", "d", "e"}, plan)
+
+	b, err := io.ReadAll(result)
+	require.NoError(t, err)
+	require.Equal(t, "a,c,d,e", string(b))
+}
+
+func TestPlannerConvertArchive(t *testing.T) {
+	p := files.NewPlanner(newChainRegistry(t))
+
+	// The zip file produced by c -> d holds a single file, so it's unwrapped by default.
+	result, _, err := p.Convert(context.Background(), "foo.a", "a", "d", strings.NewReader("a"), files.ConvertOptions{})
+	require.NoError(t, err)
+
+	b, err := io.ReadAll(result)
+	require.NoError(t, err)
+	require.Equal(t, "a,c,d", string(b))
+
+	// The file is wrapped in a zip file named after the input file, if asked for.
+	opts, err := files.Schema{{Name: files.ArchiveOption, Type: files.ChoiceOption, Choices: []string{files.ArchiveAlways}}}.
+		Parse(map[string][]string{files.ArchiveOption: {files.ArchiveAlways}})
+	require.NoError(t, err)
+
+	result, _, err = p.Convert(context.Background(), "foo.a", "a", "b", strings.NewReader("a"), opts)
+	require.NoError(t, err)
+
+	b, err = io.ReadAll(result)
+	require.NoError(t, err)
+	require.Equal(t, []string{"foo.b"}, zipNames(t, b))
+}
+
+// markFile is a fake File that writes the chain of conversions it took part in,
+// and whether the mark option was passed to the conversion.
+type markFile struct {
+	chainFile
+}
+
+func (m *markFile) ConvertTo(ctx context.Context, fileType, subType string, file io.Reader, opts files.ConvertOptions) (io.Reader, error) {
+	if opts.Has("mark") {
+		subType += "+mark"
+	}
+
+	return m.chainFile.ConvertTo(ctx, fileType, subType, file, opts)
+}
+
+func TestPlannerConvertInputOptions(t *testing.T) {
+	r := files.NewRegistry()
+	mark := files.Schema{{Name: "mark", Type: files.BoolOption}}
+
+	register := func(name string, formats map[string][]string) {
+		require.NoError(t, r.Register(files.Format{
+			Name:         name,
+			Category:     files.Doc,
+			InputOptions: mark,
+			Decoder: func(string) files.File {
+				return &markFile{chainFile{formats: formats}}
+			},
+		}))
+	}
+
+	register("x", map[string][]string{"Document": {"y"}})
+	register("y", map[string][]string{"Document": {"z"}})
+	register("z", map[string][]string{})
+
+	opts, err := mark.Parse(map[string][]string{"mark": {"true"}})
+	require.NoError(t, err)
+
+	// Both x and y accept the mark, but it's only passed to the first conversion.
+	result, _, err := files.NewPlanner(r).Convert(context.Background(), "foo.x", "x", "z", strings.NewReader("x"), opts)
+	require.NoError(t, err)
+
+	b, err := io.ReadAll(result)
+	require.NoError(t, err)
+	require.Equal(t, "x,y+mark,z", string(b))
+}
+
+func TestPlannerConvertCancelled(t *testing.T) {
+	p := files.NewPlanner(newChainRegistry(t))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, err := p.Convert(ctx, "foo.a", "a", "e", strings.NewReader("a"), files.ConvertOptions{})
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestPlanConversion(t *testing.T) {
+	var tests = []struct {
+		name     string
+		source   string
+		target   string
+		expected files.Plan
+	}{
+		{name: "avif to pdf", source: "avif", target: "pdf", expected: files.Plan{"avif", "png", "pdf"}},
+		{name: "csv to pdf", source: "csv", target: "pdf", expected: files.Plan{"csv", "xlsx", "pdf"}},
+		{name: "png to jpg", source: "png", target: "jpg", expected: files.Plan{"png", "jpg"}},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			plan, err := files.PlanConversion(tc.source, tc.target)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, plan)
+		})
+	}
+}
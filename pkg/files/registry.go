@@ -0,0 +1,288 @@
+package files
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+)
+
+// Decoder returns the File able to read a given format
+// and convert it to the formats it supports.
+type Decoder func(filename string) File
+
+// Encoder converts a file, whose format is the given source sub-type,
+// to the format the encoder was registered for.
+// It lets a format become a target of the other formats without editing
+// their compatible formats.
+type Encoder func(ctx context.Context, source string, file io.Reader, opts ConvertOptions) (io.Reader, error)
+
+// Format describes a file format known to morphos.
+// Every format package registers its formats once, and the rest of the
+// application reads them from the registry.
+type Format struct {
+	// Name is the canonical sub-type of the format. e.g. png.
+	Name string
+	// Category is the kind of file the format belongs to. e.g. image.
+	Category string
+	// Aliases are other sub-types the format is known by. e.g. jpg.
+	Aliases []string
+	// MIMETypes are the MIME types detected for files of this format.
+	// e.g. application/vnd.openxmlformats-officedocument.wordprocessingml.document
+	MIMETypes []string
+	// Decoder builds the File that converts this format to others.
+	Decoder Decoder
+	// Encoder is optional. It turns files of other formats into this one.
+	Encoder Encoder
+	// EncodesFrom lists the source formats the Encoder accepts.
+	// If empty, every format of the same category is accepted.
+	EncodesFrom []string
+	// InputOptions are the options accepted when converting from this format.
+	InputOptions Schema
+	// OutputOptions are the options accepted when converting to this format.
+	OutputOptions Schema
+	// OutputOptionsFrom are the options accepted when converting to this format
+	// from the given source formats only, besides the OutputOptions.
+	// e.g. the options that apply to the text extracted from pdf files, but not from images.
+	OutputOptionsFrom map[string]Schema
+	// Cost is the relative cost of producing this format, used by the Planner
+	// to pick between chains of conversions. Lossy or slow formats should have
+	// a higher cost, so they are avoided as intermediate steps.
+	Cost int
+}
+
+// names returns every sub-type the format is known by,
+// including the sub-types of its MIME types.
+func (f Format) names() []string {
+	names := append([]string{f.Name}, f.Aliases...)
+
+	for _, m := range f.MIMETypes {
+		if _, subType, err := TypeAndSupType(m); err == nil {
+			names = append(names, subType)
+		}
+	}
+
+	return names
+}
+
+// encodes tells if the Encoder of the format accepts the source format.
+func (f Format) encodes(source Format) bool {
+	if f.Encoder == nil || f.Name == source.Name {
+		return false
+	}
+
+	if len(f.EncodesFrom) == 0 {
+		return f.Category == source.Category
+	}
+
+	return slices.ContainsFunc(source.names(), func(n string) bool {
+		return slices.Contains(f.EncodesFrom, n)
+	})
+}
+
+// Registry keeps track of the formats morphos is able to work with.
+type Registry struct {
+	mu      sync.RWMutex
+	formats []Format
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// DefaultRegistry is the Registry used by the package level functions.
+// Format packages register their formats into it at init time.
+var DefaultRegistry = NewRegistry()
+
+// Register adds a format to the registry.
+// It errors out if the format is not valid or if any of its names
+// was already registered by another format.
+func (r *Registry) Register(f Format) error {
+	if f.Name == "" {
+		return fmt.Errorf("format name is required")
+	}
+
+	if f.Category == "" {
+		return fmt.Errorf("format %s: category is required", f.Name)
+	}
+
+	if f.Decoder == nil {
+		return fmt.Errorf("format %s: decoder is required", f.Name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, n := range f.names() {
+		if existing, ok := r.lookup(n); ok {
+			return fmt.Errorf("format %s: %s already registered by %s", f.Name, n, existing.Name)
+		}
+	}
+
+	r.formats = append(r.formats, f)
+
+	return nil
+}
+
+// Lookup returns the format known by the given sub-type.
+// The sub-type can be the name of the format, one of its aliases,
+// or the sub-type of one of its MIME types.
+func (r *Registry) Lookup(subType string) (Format, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.lookup(subType)
+}
+
+func (r *Registry) lookup(subType string) (Format, bool) {
+	subType = strings.ToLower(subType)
+
+	for _, f := range r.formats {
+		if slices.Contains(f.names(), subType) {
+			return f, true
+		}
+	}
+
+	return Format{}, false
+}
+
+// LookupMIME returns the format registered for the given MIME type.
+// MIME type parameters, like charset, are ignored.
+func (r *Registry) LookupMIME(mimetype string) (Format, bool) {
+	mimetype, _, _ = strings.Cut(mimetype, ";")
+	mimetype = strings.ToLower(strings.TrimSpace(mimetype))
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, f := range r.formats {
+		if slices.Contains(f.MIMETypes, mimetype) {
+			return f, true
+		}
+	}
+
+	return Format{}, false
+}
+
+// Formats returns the registered formats sorted by name.
+// If categories are passed, only the formats in those categories are returned.
+func (r *Registry) Formats(categories ...string) []Format {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var result []Format
+
+	for _, f := range r.formats {
+		if len(categories) == 0 || slices.Contains(categories, f.Category) {
+			result = append(result, f)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result
+}
+
+// Targets returns the formats the given format can be converted to.
+// Every key of the map represents the kind of a file,
+// just like the File.SupportedFormats method.
+// Besides the formats supported by the File itself, it includes the
+// formats whose Encoder accepts the given format.
+func (r *Registry) Targets(subType string) (map[string][]string, error) {
+	source, ok := r.Lookup(subType)
+	if !ok {
+		return nil, fmt.Errorf("format %s not registered", subType)
+	}
+
+	result := make(map[string][]string)
+	for k, v := range source.Decoder("").SupportedFormats() {
+		result[k] = slices.Clone(v)
+	}
+
+	for _, f := range r.Formats() {
+		if !f.encodes(source) {
+			continue
+		}
+
+		fileType := FileType(f.Category)
+		if !slices.Contains(result[fileType], f.Name) {
+			result[fileType] = append(result[fileType], f.Name)
+		}
+	}
+
+	return result, nil
+}
+
+// ConvertTo converts f, a file whose format is the given source sub-type,
+// to the target sub-type.
+// The conversions supported by the file itself take precedence over the
+// Encoder registered for the target format.
+func (r *Registry) ConvertTo(ctx context.Context, f File, source, target string, file io.Reader, opts ConvertOptions) (io.Reader, error) {
+	sourceFormat, ok := r.Lookup(source)
+	if !ok {
+		return nil, fmt.Errorf("format %s not registered", source)
+	}
+
+	targetFormat, ok := r.Lookup(target)
+	if !ok {
+		return nil, fmt.Errorf("format %s not registered", target)
+	}
+
+	fileType := FileType(targetFormat.Category)
+
+	if slices.Contains(f.SupportedFormats()[fileType], target) || !targetFormat.encodes(sourceFormat) {
+		return f.ConvertTo(ctx, fileType, target, file, opts)
+	}
+
+	return targetFormat.Encoder(ctx, sourceFormat.Name, file, opts)
+}
+
+// FileType returns the kind of file, as used by the keys of the
+// File.SupportedFormats maps, for a given category.
+// e.g. image -> Image
+func FileType(category string) string {
+	return cases.Title(language.English).String(category)
+}
+
+// Register adds a format to the DefaultRegistry.
+// It panics if the format cannot be registered,
+// since it is meant to be called from the init function of format packages.
+func Register(f Format) {
+	if err := DefaultRegistry.Register(f); err != nil {
+		panic(err)
+	}
+}
+
+// Lookup returns a format from the DefaultRegistry.
+func Lookup(subType string) (Format, bool) {
+	return DefaultRegistry.Lookup(subType)
+}
+
+// LookupMIME returns a format from the DefaultRegistry given its MIME type.
+func LookupMIME(mimetype string) (Format, bool) {
+	return DefaultRegistry.LookupMIME(mimetype)
+}
+
+// Formats returns the formats registered in the DefaultRegistry.
+func Formats(categories ...string) []Format {
+	return DefaultRegistry.Formats(categories...)
+}
+
+// Targets returns the targets of a format registered in the DefaultRegistry.
+func Targets(subType string) (map[string][]string, error) {
+	return DefaultRegistry.Targets(subType)
+}
+
+// ConvertTo converts a file using the formats of the DefaultRegistry.
+func ConvertTo(ctx context.Context, f File, source, target string, file io.Reader, opts ConvertOptions) (io.Reader, error) {
+	return DefaultRegistry.ConvertTo(ctx, f, source, target, file, opts)
+}
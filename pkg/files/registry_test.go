@@ -0,0 +1,163 @@
+package files_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/danvergara/morphos/pkg/files"
+	"github.com/danvergara/morphos/pkg/files/images"
+)
+
+type fakeFile struct {
+	formats map[string][]string
+}
+
+func (f *fakeFile) SupportedFormats() map[string][]string   { return f.formats }
+func (f *fakeFile) SupportedMIMETypes() map[string][]string { return f.formats }
+func (f *fakeFile) ConvertTo(_ context.Context, fileType, subType string, file io.Reader, _ files.ConvertOptions) (io.Reader, error) {
+	return strings.NewReader(fileType + "/" + subType), nil
+}
+
+func newTestRegistry(t *testing.T) *files.Registry {
+	t.Helper()
+
+	r := files.NewRegistry()
+
+	require.NoError(t, r.Register(files.Format{
+		Name:      "foo",
+		Category:  files.Img,
+		Aliases:   []string{"fo"},
+		MIMETypes: []string{"image/x-foo"},
+		Decoder: func(string) files.File {
+			return &fakeFile{formats: map[string][]string{"Image": {"bar"}}}
+		},
+	}))
+
+	require.NoError(t, r.Register(files.Format{
+		Name:      "bar",
+		Category:  files.Img,
+		MIMETypes: []string{"image/x-bar"},
+		Decoder: func(string) files.File {
+			return &fakeFile{formats: map[string][]string{"Image": {"foo"}}}
+		},
+	}))
+
+	require.NoError(t, r.Register(files.Format{
+		Name:      "baz",
+		Category:  files.Img,
+		MIMETypes: []string{"image/x-baz"},
+		Decoder: func(string) files.File {
+			return &fakeFile{formats: map[string][]string{}}
+		},
+		Encoder: func(_ context.Context, source string, file io.Reader, _ files.ConvertOptions) (io.Reader, error) {
+			return strings.NewReader("baz from " + source), nil
+		},
+	}))
+
+	return r
+}
+
+func TestRegistryLookup(t *testing.T) {
+	r := newTestRegistry(t)
+
+	var tests = []struct {
+		name     string
+		subType  string
+		expected string
+		found    bool
+	}{
+		{name: "by name", subType: "foo", expected: "foo", found: true},
+		{name: "by alias", subType: "fo", expected: "foo", found: true},
+		{name: "by mime sub-type", subType: "x-bar", expected: "bar", found: true},
+		{name: "case insensitive", subType: "BAZ", expected: "baz", found: true},
+		{name: "unknown", subType: "qux", found: false},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			f, ok := r.Lookup(tc.subType)
+			require.Equal(t, tc.found, ok)
+			require.Equal(t, tc.expected, f.Name)
+		})
+	}
+
+	f, ok := r.LookupMIME("image/x-foo; charset=binary")
+	require.True(t, ok)
+	require.Equal(t, "foo", f.Name)
+}
+
+func TestRegistryRegisterErrors(t *testing.T) {
+	r := newTestRegistry(t)
+
+	err := r.Register(files.Format{
+		Name:     "qux",
+		Category: files.Img,
+		Aliases:  []string{"fo"},
+		Decoder:  func(string) files.File { return &fakeFile{} },
+	})
+	require.Error(t, err)
+
+	err = r.Register(files.Format{Name: "qux", Category: files.Img})
+	require.Error(t, err)
+
+	err = r.Register(files.Format{Name: "qux", Decoder: func(string) files.File { return &fakeFile{} }})
+	require.Error(t, err)
+}
+
+func TestRegistryTargets(t *testing.T) {
+	r := newTestRegistry(t)
+
+	targets, err := r.Targets("foo")
+	require.NoError(t, err)
+	require.Equal(t, map[string][]string{"Image": {"bar", "baz"}}, targets)
+
+	targets, err = r.Targets("baz")
+	require.NoError(t, err)
+	require.Equal(t, map[string][]string{}, targets)
+
+	_, err = r.Targets("qux")
+	require.Error(t, err)
+}
+
+func TestRegistryConvertTo(t *testing.T) {
+	r := newTestRegistry(t)
+
+	f, ok := r.Lookup("foo")
+	require.True(t, ok)
+
+	var tests = []struct {
+		name     string
+		target   string
+		expected string
+	}{
+		{name: "supported by the file", target: "bar", expected: "Image/bar"},
+		{name: "supported by the encoder", target: "baz", expected: "baz from foo"},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := r.ConvertTo(context.Background(), f.Decoder(""), "foo", tc.target, bytes.NewReader(nil), files.ConvertOptions{})
+			require.NoError(t, err)
+
+			b, err := io.ReadAll(result)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, string(b))
+		})
+	}
+}
+
+func TestDefaultRegistry(t *testing.T) {
+	f, ok := files.Lookup(images.JPG)
+	require.True(t, ok)
+	require.Equal(t, images.JPEG, f.Name)
+	require.Equal(t, files.Img, f.Category)
+
+	require.Equal(t, files.Img, files.SupportedFileTypes()[images.JPG])
+}
@@ -0,0 +1,361 @@
+// Package jobs runs conversions in the background, by a bounded pool of workers,
+// and keeps track of their state so clients can poll it.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/danvergara/morphos/pkg/progress"
+)
+
+// Status is the state of a job.
+type Status string
+
+const (
+	Queued    Status = "queued"
+	Running   Status = "running"
+	Succeeded Status = "succeeded"
+	Failed    Status = "failed"
+)
+
+var (
+	// ErrQueueFull is returned when a job is submitted and every slot of the queue is taken.
+	ErrQueueFull = errors.New("job queue is full")
+	// ErrNotFound is returned when a job does not exist, or it already expired.
+	ErrNotFound = errors.New("job not found")
+	// ErrStopped is returned when a job is submitted once the pool is stopped.
+	ErrStopped = errors.New("job pool is stopped")
+)
+
+// Task is the work done by a job.
+// The context is done once the pool is stopped,
+// and the progress reported through it is kept in the job.
+type Task func(ctx context.Context) (Result, error)
+
+// Result describes the file produced by a job.
+type Result struct {
+	// Filename is the name the file is downloaded as.
+	Filename string
+	// Path is where the file is stored on disk.
+	Path string
+	// Plan is the chain of conversions followed to get the file.
+	Plan []string
+	// MIMEType is the MIME type of the file.
+	MIMEType string
+}
+
+// Job is a snapshot of the state of a job.
+type Job struct {
+	ID     string `json:"id"`
+	Status Status `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// Progress is the last progress reported by the task of the job.
+	Progress   progress.Event `json:"progress"`
+	CreatedAt  time.Time      `json:"createdAt"`
+	StartedAt  *time.Time     `json:"startedAt,omitempty"`
+	FinishedAt *time.Time     `json:"finishedAt,omitempty"`
+	Result     *Result        `json:"-"`
+}
+
+// Done tells if the job finished, either successfully or not.
+func (j Job) Done() bool {
+	return j.Status == Succeeded || j.Status == Failed
+}
+
+// Config sets up a Pool.
+type Config struct {
+	// Workers is the number of jobs run at the same time.
+	Workers int
+	// QueueSize is the number of jobs that can wait for a worker.
+	QueueSize int
+	// TTL is how long finished jobs are kept around. Zero keeps them forever.
+	TTL time.Duration
+	// OnExpire is optional. It is called when a finished job is forgotten,
+	// e.g. to remove its result from disk.
+	OnExpire func(Job)
+}
+
+type entry struct {
+	job  Job
+	task Task
+	// changed is closed, and replaced, every time the job is updated.
+	changed chan struct{}
+}
+
+// Pool runs the submitted jobs with a bounded number of workers.
+// Jobs are not tied to the request that submitted them,
+// they keep running until they finish or the pool is stopped.
+type Pool struct {
+	cfg   Config
+	queue chan *entry
+
+	mu   sync.RWMutex
+	jobs map[string]*entry
+	// stopped is set once the pool is stopped, and no more jobs are accepted.
+	stopped bool
+
+	wg sync.WaitGroup
+}
+
+// NewPool returns a Pool, which does not run any job until it is started.
+func NewPool(cfg Config) *Pool {
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+
+	if cfg.QueueSize < 0 {
+		cfg.QueueSize = 0
+	}
+
+	return &Pool{
+		cfg:   cfg,
+		queue: make(chan *entry, cfg.QueueSize),
+		jobs:  make(map[string]*entry),
+	}
+}
+
+// Start starts the workers of the pool.
+// They stop once the context is done, the running jobs are cancelled,
+// and the jobs still queued fail.
+func (p *Pool) Start(ctx context.Context) {
+	for i := 0; i < p.cfg.Workers; i++ {
+		p.wg.Add(1)
+		go p.work(ctx)
+	}
+
+	if p.cfg.TTL > 0 {
+		p.wg.Add(1)
+		go p.expire(ctx)
+	}
+}
+
+// Wait blocks until every worker of the pool returns.
+func (p *Pool) Wait() {
+	p.wg.Wait()
+}
+
+// Submit queues a task and returns the job created to run it.
+// It errors out with ErrQueueFull if there's no room left in the queue,
+// and with ErrStopped if the pool is stopped.
+func (p *Pool) Submit(task Task) (Job, error) {
+	id, err := newID()
+	if err != nil {
+		return Job{}, err
+	}
+
+	e := &entry{
+		job: Job{
+			ID:        id,
+			Status:    Queued,
+			CreatedAt: time.Now(),
+		},
+		task:    task,
+		changed: make(chan struct{}),
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stopped {
+		return Job{}, ErrStopped
+	}
+
+	select {
+	case p.queue <- e:
+	default:
+		return Job{}, ErrQueueFull
+	}
+
+	p.jobs[id] = e
+
+	return e.job, nil
+}
+
+// Watch returns the current state of a job, and a channel
+// that is closed as soon as the job changes.
+func (p *Pool) Watch(id string) (Job, <-chan struct{}, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	e, ok := p.jobs[id]
+	if !ok {
+		return Job{}, nil, ErrNotFound
+	}
+
+	return e.job, e.changed, nil
+}
+
+// Get returns the current state of a job.
+func (p *Pool) Get(id string) (Job, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	e, ok := p.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+
+	return e.job, nil
+}
+
+func (p *Pool) work(ctx context.Context) {
+	defer p.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.drain(ctx.Err())
+			return
+		case e := <-p.queue:
+			// Both may be ready at once, and jobs don't start once the pool is stopped.
+			if err := ctx.Err(); err != nil {
+				p.fail(e, err)
+				p.drain(err)
+				return
+			}
+
+			p.run(ctx, e)
+		}
+	}
+}
+
+// drain fails the jobs left in the queue once the pool is stopped,
+// so the clients waiting for them don't wait forever.
+func (p *Pool) drain(err error) {
+	p.mu.Lock()
+	p.stopped = true
+	p.mu.Unlock()
+
+	for {
+		select {
+		case e := <-p.queue:
+			p.fail(e, err)
+		default:
+			return
+		}
+	}
+}
+
+// fail records that a job failed without running.
+func (p *Pool) fail(e *entry, err error) {
+	p.update(e, func(j *Job) {
+		now := time.Now()
+		j.FinishedAt = &now
+		j.Status = Failed
+		j.Error = err.Error()
+	})
+}
+
+// run runs the task of a job and records its outcome.
+func (p *Pool) run(ctx context.Context, e *entry) {
+	p.update(e, func(j *Job) {
+		now := time.Now()
+		j.Status = Running
+		j.StartedAt = &now
+	})
+
+	ctx = progress.WithReporter(ctx, func(ev progress.Event) {
+		p.update(e, func(j *Job) { j.Progress = ev })
+	})
+
+	result, err := runTask(ctx, e.task)
+
+	p.update(e, func(j *Job) {
+		now := time.Now()
+		j.FinishedAt = &now
+
+		if err != nil {
+			j.Status = Failed
+			j.Error = err.Error()
+			return
+		}
+
+		j.Status = Succeeded
+		j.Result = &result
+	})
+}
+
+// runTask runs a task, and turns a panic into an error,
+// so a broken file only fails its job rather than the whole server.
+func runTask(ctx context.Context, task Task) (result Result, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("panic running a job: %v\n%s", r, debug.Stack())
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return task(ctx)
+}
+
+func (p *Pool) update(e *entry, f func(*Job)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	f(&e.job)
+
+	close(e.changed)
+	e.changed = make(chan struct{})
+}
+
+// expire forgets the jobs that finished longer than the TTL ago.
+func (p *Pool) expire(ctx context.Context) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.cfg.TTL / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			p.Expire(now)
+		}
+	}
+}
+
+// Expire forgets the jobs that finished longer than the TTL before the given time.
+// It is called periodically by the pool once started.
+func (p *Pool) Expire(now time.Time) {
+	if p.cfg.TTL <= 0 {
+		return
+	}
+
+	var expired []Job
+
+	p.mu.Lock()
+	for id, e := range p.jobs {
+		if e.job.Done() && now.Sub(*e.job.FinishedAt) >= p.cfg.TTL {
+			expired = append(expired, e.job)
+			delete(p.jobs, id)
+		}
+	}
+	p.mu.Unlock()
+
+	if p.cfg.OnExpire == nil {
+		return
+	}
+
+	for _, j := range expired {
+		p.cfg.OnExpire(j)
+	}
+}
+
+// newID returns a random job ID.
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
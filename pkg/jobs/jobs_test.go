@@ -0,0 +1,222 @@
+package jobs_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/danvergara/morphos/pkg/jobs"
+	"github.com/danvergara/morphos/pkg/progress"
+)
+
+// waitDone polls a job until it finishes.
+func waitDone(t *testing.T, p *jobs.Pool, id string) jobs.Job {
+	t.Helper()
+
+	var job jobs.Job
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = p.Get(id)
+		require.NoError(t, err)
+		return job.Done()
+	}, 2*time.Second, 5*time.Millisecond)
+
+	return job
+}
+
+func TestPool(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p := jobs.NewPool(jobs.Config{Workers: 2, QueueSize: 10})
+	p.Start(ctx)
+
+	var tests = []struct {
+		name     string
+		task     jobs.Task
+		status   jobs.Status
+		errText  string
+		filename string
+	}{
+		{
+			name: "succeeded",
+			task: func(context.Context) (jobs.Result, error) {
+				return jobs.Result{Filename: "foo.png", Path: "/tmp/foo.png"}, nil
+			},
+			status:   jobs.Succeeded,
+			filename: "foo.png",
+		},
+		{
+			name: "failed",
+			task: func(context.Context) (jobs.Result, error) {
+				return jobs.Result{}, errors.New("boom")
+			},
+			status:  jobs.Failed,
+			errText: "boom",
+		},
+		{
+			name: "panicked",
+			task: func(context.Context) (jobs.Result, error) {
+				panic("boom")
+			},
+			status:  jobs.Failed,
+			errText: "panic: boom",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			job, err := p.Submit(tc.task)
+			require.NoError(t, err)
+			require.NotEmpty(t, job.ID)
+
+			job = waitDone(t, p, job.ID)
+			require.Equal(t, tc.status, job.Status)
+			require.Equal(t, tc.errText, job.Error)
+			require.NotNil(t, job.StartedAt)
+			require.NotNil(t, job.FinishedAt)
+
+			if tc.filename != "" {
+				require.Equal(t, tc.filename, job.Result.Filename)
+			}
+		})
+	}
+
+	_, err := p.Get("unknown")
+	require.ErrorIs(t, err, jobs.ErrNotFound)
+}
+
+func TestPoolQueueFull(t *testing.T) {
+	// The pool is not started, so jobs stay in the queue.
+	p := jobs.NewPool(jobs.Config{Workers: 1, QueueSize: 1})
+
+	task := func(context.Context) (jobs.Result, error) { return jobs.Result{}, nil }
+
+	job, err := p.Submit(task)
+	require.NoError(t, err)
+	require.Equal(t, jobs.Queued, job.Status)
+
+	_, err = p.Submit(task)
+	require.ErrorIs(t, err, jobs.ErrQueueFull)
+}
+
+func TestPoolStop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	p := jobs.NewPool(jobs.Config{Workers: 1, QueueSize: 1})
+	p.Start(ctx)
+
+	started := make(chan struct{})
+	job, err := p.Submit(func(ctx context.Context) (jobs.Result, error) {
+		close(started)
+		<-ctx.Done()
+		return jobs.Result{}, ctx.Err()
+	})
+	require.NoError(t, err)
+
+	<-started
+
+	// The job waits in the queue while the worker is busy, and never runs.
+	var ran bool
+	queued, err := p.Submit(func(context.Context) (jobs.Result, error) {
+		ran = true
+		return jobs.Result{}, nil
+	})
+	require.NoError(t, err)
+
+	_, changed, err := p.Watch(queued.ID)
+	require.NoError(t, err)
+
+	cancel()
+	p.Wait()
+
+	job, err = p.Get(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, jobs.Failed, job.Status)
+	require.Equal(t, context.Canceled.Error(), job.Error)
+
+	<-changed
+
+	queued, err = p.Get(queued.ID)
+	require.NoError(t, err)
+	require.False(t, ran)
+	require.Equal(t, jobs.Failed, queued.Status)
+	require.Equal(t, context.Canceled.Error(), queued.Error)
+	require.NotNil(t, queued.FinishedAt)
+
+	_, err = p.Submit(func(context.Context) (jobs.Result, error) { return jobs.Result{}, nil })
+	require.ErrorIs(t, err, jobs.ErrStopped)
+}
+
+func TestPoolExpire(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var expired []string
+
+	p := jobs.NewPool(jobs.Config{
+		Workers:   1,
+		QueueSize: 1,
+		TTL:       time.Hour,
+		OnExpire:  func(j jobs.Job) { expired = append(expired, j.ID) },
+	})
+	p.Start(ctx)
+
+	job, err := p.Submit(func(context.Context) (jobs.Result, error) { return jobs.Result{}, nil })
+	require.NoError(t, err)
+
+	job = waitDone(t, p, job.ID)
+
+	p.Expire(job.FinishedAt.Add(time.Minute))
+	_, err = p.Get(job.ID)
+	require.NoError(t, err)
+	require.Empty(t, expired)
+
+	p.Expire(job.FinishedAt.Add(time.Hour))
+	_, err = p.Get(job.ID)
+	require.ErrorIs(t, err, jobs.ErrNotFound)
+	require.Equal(t, []string{job.ID}, expired)
+}
+
+func TestPoolWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p := jobs.NewPool(jobs.Config{Workers: 1, QueueSize: 1})
+
+	proceed := make(chan struct{})
+	job, err := p.Submit(func(ctx context.Context) (jobs.Result, error) {
+		progress.Report(ctx, progress.Event{Stage: progress.Converting, Current: 1, Total: 2})
+		<-proceed
+		return jobs.Result{}, nil
+	})
+	require.NoError(t, err)
+
+	job, changed, err := p.Watch(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, jobs.Queued, job.Status)
+
+	p.Start(ctx)
+
+	// Waits for the reported progress, skipping the job being started.
+	require.Eventually(t, func() bool {
+		<-changed
+		job, changed, err = p.Watch(job.ID)
+		require.NoError(t, err)
+		return job.Progress.Stage == progress.Converting
+	}, 2*time.Second, time.Millisecond)
+
+	require.Equal(t, jobs.Running, job.Status)
+	require.Equal(t, 1, job.Progress.Current)
+
+	close(proceed)
+	<-changed
+
+	job, _, err = p.Watch(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, jobs.Succeeded, job.Status)
+}
@@ -0,0 +1,284 @@
+// Package packaging bundles several files into a single archive,
+// in any of the formats supported: zip, tar, tar.gz and tar.zst.
+package packaging
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Format is an archive format.
+type Format string
+
+const (
+	Zip     Format = "zip"
+	Tar     Format = "tar"
+	TarGzip Format = "tar.gz"
+	TarZstd Format = "tar.zst"
+)
+
+const (
+	// DefaultCompression lets every format use its default compression level.
+	DefaultCompression = -1
+	// NoCompression stores the files as they are.
+	NoCompression = 0
+	// BestCompression is the highest compression level.
+	BestCompression = 9
+)
+
+// ErrUnknownFormat is returned when an archive format is not supported.
+var ErrUnknownFormat = errors.New("unknown archive format")
+
+var mimeTypes = map[Format]string{
+	Zip:     "application/zip",
+	Tar:     "application/x-tar",
+	TarGzip: "application/gzip",
+	TarZstd: "application/zstd",
+}
+
+// Formats returns the supported archive formats.
+func Formats() []Format {
+	return []Format{Zip, Tar, TarGzip, TarZstd}
+}
+
+// ParseFormat returns the archive format with the given name.
+// e.g. tar.gz
+func ParseFormat(name string) (Format, error) {
+	f := Format(name)
+	if _, ok := mimeTypes[f]; !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownFormat, name)
+	}
+
+	return f, nil
+}
+
+// Extension returns the file extension of the format, without the leading dot.
+func (f Format) Extension() string {
+	return string(f)
+}
+
+// MIMEType returns the MIME type of the archives of the format.
+func (f Format) MIMEType() string {
+	return mimeTypes[f]
+}
+
+// File is a file added to an archive.
+type File struct {
+	Name    string
+	Content []byte
+}
+
+// Writer writes files into an archive, as they are added.
+// Closing the Writer completes the archive, it doesn't close the underlying writer.
+type Writer struct {
+	format  Format
+	level   int
+	modTime time.Time
+
+	zw *zip.Writer
+	tw *tar.Writer
+	// compressor is the compression stream of the tarballs, if any.
+	compressor io.WriteCloser
+}
+
+// NewWriter returns a Writer that writes an archive of the given format into w.
+// The level goes from NoCompression to BestCompression, or DefaultCompression.
+// Tar archives are never compressed, and levels of tar.zst archives are mapped
+// to the zstd levels, from the fastest to the best compression.
+func NewWriter(w io.Writer, format Format, level int) (*Writer, error) {
+	if level < DefaultCompression || level > BestCompression {
+		return nil, fmt.Errorf(
+			"invalid compression level %d, it must be between %d and %d",
+			level,
+			NoCompression,
+			BestCompression,
+		)
+	}
+
+	pw := &Writer{format: format, level: level, modTime: time.Now()}
+
+	switch format {
+	case Zip:
+		pw.zw = zip.NewWriter(w)
+		pw.zw.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(out, level)
+		})
+		return pw, nil
+	case Tar:
+		pw.tw = tar.NewWriter(w)
+		return pw, nil
+	case TarGzip:
+		gw, err := gzip.NewWriterLevel(w, level)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the gzip writer: %w", err)
+		}
+		pw.compressor = gw
+	case TarZstd:
+		zstdLevel := zstd.SpeedDefault
+		if level != DefaultCompression {
+			// Maps 0-9 into the zstd levels, 1 being the fastest.
+			zstdLevel = zstd.EncoderLevelFromZstd(1 + level*2)
+		}
+
+		zw, err := zstd.NewWriter(w, zstd.WithEncoderLevel(zstdLevel))
+		if err != nil {
+			return nil, fmt.Errorf("error creating the zstd writer: %w", err)
+		}
+		pw.compressor = zw
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+
+	pw.tw = tar.NewWriter(pw.compressor)
+
+	return pw, nil
+}
+
+// Add writes a file into the archive.
+func (w *Writer) Add(name string, content []byte) error {
+	if w.zw != nil {
+		method := zip.Deflate
+		if w.level == NoCompression {
+			method = zip.Store
+		}
+
+		fw, err := w.zw.CreateHeader(&zip.FileHeader{
+			Name:     name,
+			Method:   method,
+			Modified: w.modTime,
+		})
+		if err != nil {
+			return fmt.Errorf("error creating the zip entry of %s: %w", name, err)
+		}
+
+		if _, err := fw.Write(content); err != nil {
+			return fmt.Errorf("error writing %s to the zip file: %w", name, err)
+		}
+
+		return nil
+	}
+
+	if err := w.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0o644,
+		Size:     int64(len(content)),
+		ModTime:  w.modTime,
+	}); err != nil {
+		return fmt.Errorf("error writing the tar header of %s: %w", name, err)
+	}
+
+	if _, err := w.tw.Write(content); err != nil {
+		return fmt.Errorf("error writing %s to the tar file: %w", name, err)
+	}
+
+	return nil
+}
+
+// Close completes the archive.
+func (w *Writer) Close() error {
+	if w.zw != nil {
+		return w.zw.Close()
+	}
+
+	if err := w.tw.Close(); err != nil {
+		return err
+	}
+
+	if w.compressor != nil {
+		return w.compressor.Close()
+	}
+
+	return nil
+}
+
+// Pack returns an archive with the given files, as a slice of bytes.
+func Pack(format Format, level int, files ...File) ([]byte, error) {
+	buf := new(bytes.Buffer)
+
+	w, err := NewWriter(buf, format, level)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range files {
+		if err := w.Add(f.Name, f.Content); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Archive is a set of files that is packaged once it's read.
+// It's meant to be copied straight into its destination, e.g. a response,
+// since io.Copy packages the files into the destination as they are written,
+// without storing the whole archive first.
+type Archive struct {
+	Format Format
+	Level  int
+	Files  []File
+
+	// buf holds the archive, if it's read instead of written to a writer.
+	buf *bytes.Buffer
+	err error
+}
+
+// WriteTo packages the files into w.
+func (a *Archive) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+
+	pw, err := NewWriter(cw, a.Format, a.Level)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, f := range a.Files {
+		if err := pw.Add(f.Name, f.Content); err != nil {
+			return cw.n, err
+		}
+	}
+
+	err = pw.Close()
+
+	return cw.n, err
+}
+
+// Read reads the archive, which is packaged in memory the first time it's called.
+func (a *Archive) Read(p []byte) (int, error) {
+	if a.buf == nil {
+		a.buf = new(bytes.Buffer)
+		_, a.err = a.WriteTo(a.buf)
+	}
+
+	if a.err != nil {
+		return 0, a.err
+	}
+
+	return a.buf.Read(p)
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
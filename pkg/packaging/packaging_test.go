@@ -0,0 +1,125 @@
+package packaging_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+
+	"github.com/danvergara/morphos/pkg/packaging"
+)
+
+var testFiles = []packaging.File{
+	{Name: "foo_1.png", Content: []byte("first page")},
+	{Name: "foo_2.png", Content: bytes.Repeat([]byte("second page"), 100)},
+}
+
+// unpack returns the files of an archive.
+func unpack(t *testing.T, format packaging.Format, b []byte) []packaging.File {
+	t.Helper()
+
+	var result []packaging.File
+
+	if format == packaging.Zip {
+		zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+		require.NoError(t, err)
+
+		for _, f := range zr.File {
+			rc, err := f.Open()
+			require.NoError(t, err)
+			content, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			rc.Close()
+
+			result = append(result, packaging.File{Name: f.Name, Content: content})
+		}
+
+		return result
+	}
+
+	var r io.Reader = bytes.NewReader(b)
+
+	switch format {
+	case packaging.TarGzip:
+		gr, err := gzip.NewReader(r)
+		require.NoError(t, err)
+		r = gr
+	case packaging.TarZstd:
+		zr, err := zstd.NewReader(r)
+		require.NoError(t, err)
+		defer zr.Close()
+		r = zr
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+
+		result = append(result, packaging.File{Name: h.Name, Content: content})
+	}
+
+	return result
+}
+
+func TestPack(t *testing.T) {
+	for _, format := range packaging.Formats() {
+		for _, level := range []int{packaging.DefaultCompression, packaging.NoCompression, packaging.BestCompression} {
+			format, level := format, level
+			t.Run(format.Extension(), func(t *testing.T) {
+				b, err := packaging.Pack(format, level, testFiles...)
+				require.NoError(t, err)
+				require.Equal(t, testFiles, unpack(t, format, b))
+			})
+		}
+	}
+}
+
+func TestPackErrors(t *testing.T) {
+	_, err := packaging.Pack("rar", packaging.DefaultCompression, testFiles...)
+	require.ErrorIs(t, err, packaging.ErrUnknownFormat)
+
+	_, err = packaging.Pack(packaging.Zip, 10, testFiles...)
+	require.Error(t, err)
+}
+
+func TestParseFormat(t *testing.T) {
+	f, err := packaging.ParseFormat("tar.zst")
+	require.NoError(t, err)
+	require.Equal(t, packaging.TarZstd, f)
+	require.Equal(t, "application/zstd", f.MIMEType())
+
+	_, err = packaging.ParseFormat("7z")
+	require.ErrorIs(t, err, packaging.ErrUnknownFormat)
+}
+
+func TestArchive(t *testing.T) {
+	expected, err := packaging.Pack(packaging.TarGzip, packaging.DefaultCompression, testFiles...)
+	require.NoError(t, err)
+
+	// Copying the archive packages it straight into the destination.
+	a := &packaging.Archive{Format: packaging.TarGzip, Level: packaging.DefaultCompression, Files: testFiles}
+	buf := new(bytes.Buffer)
+	n, err := io.Copy(buf, a)
+	require.NoError(t, err)
+	require.Equal(t, int64(buf.Len()), n)
+	require.Equal(t, testFiles, unpack(t, packaging.TarGzip, buf.Bytes()))
+
+	// Reading it packages it in memory.
+	a = &packaging.Archive{Format: packaging.TarGzip, Level: packaging.DefaultCompression, Files: testFiles}
+	b, err := io.ReadAll(a)
+	require.NoError(t, err)
+	require.Equal(t, testFiles, unpack(t, packaging.TarGzip, b))
+	require.Len(t, b, len(expected))
+}
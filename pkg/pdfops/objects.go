@@ -0,0 +1,446 @@
+package pdfops
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// The objects of a pdf file are one of these types, or nil for the null object.
+type (
+	// name is a name object, without its leading slash and as it's written, escapes included.
+	name string
+	// number is a number object as it's written, so it's copied without losing precision.
+	number string
+	// pdfString is a string object, unescaped.
+	pdfString []byte
+	// keyword is a bare word that's not a name, e.g. true, false or a content stream operator.
+	keyword string
+	array   []any
+	dict    map[name]any
+	// ref is a reference to an indirect object.
+	ref struct {
+		num int
+		gen int
+	}
+	// stream is a stream object, whose data is kept encoded.
+	stream struct {
+		dict dict
+		data []byte
+	}
+)
+
+// integer returns the value of an integer object.
+func integer(obj any) (int, bool) {
+	n, ok := obj.(number)
+	if !ok {
+		return 0, false
+	}
+
+	v, err := strconv.Atoi(string(n))
+	if err != nil {
+		// Integers are sometimes written as reals, e.g. 612.0
+		f, err := strconv.ParseFloat(string(n), 64)
+		if err != nil {
+			return 0, false
+		}
+
+		return int(f), true
+	}
+
+	return v, true
+}
+
+// float returns the value of a number object.
+func float(obj any) (float64, bool) {
+	n, ok := obj.(number)
+	if !ok {
+		return 0, false
+	}
+
+	v, err := strconv.ParseFloat(string(n), 64)
+
+	return v, err == nil
+}
+
+// isWhitespace tells whether the byte is a whitespace character of the pdf syntax.
+func isWhitespace(c byte) bool {
+	switch c {
+	case 0, '\t', '\n', '\f', '\r', ' ':
+		return true
+	}
+
+	return false
+}
+
+// isDelimiter tells whether the byte is a delimiter of the pdf syntax.
+func isDelimiter(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+
+	return false
+}
+
+// lexer reads the tokens of the pdf syntax from a buffer, starting at pos.
+type lexer struct {
+	buf []byte
+	pos int
+}
+
+// skipSpace skips whitespace and comments.
+func (l *lexer) skipSpace() {
+	for l.pos < len(l.buf) {
+		switch c := l.buf[l.pos]; {
+		case isWhitespace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.buf) && l.buf[l.pos] != '\n' && l.buf[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// word reads a run of regular characters, the text of numbers, names and keywords.
+func (l *lexer) word() string {
+	start := l.pos
+	for l.pos < len(l.buf) && !isWhitespace(l.buf[l.pos]) && !isDelimiter(l.buf[l.pos]) {
+		l.pos++
+	}
+
+	return string(l.buf[start:l.pos])
+}
+
+// object reads the object at the current position.
+// References are read as a whole, e.g. 12 0 R, so two numbers may be read at once.
+func (l *lexer) object() (any, error) {
+	l.skipSpace()
+
+	if l.pos >= len(l.buf) {
+		return nil, fmt.Errorf("unexpected end of file")
+	}
+
+	switch c := l.buf[l.pos]; c {
+	case '/':
+		l.pos++
+		return name(l.word()), nil
+	case '(':
+		return l.literalString()
+	case '<':
+		if l.pos+1 < len(l.buf) && l.buf[l.pos+1] == '<' {
+			l.pos += 2
+			return l.dict()
+		}
+		return l.hexString()
+	case '[':
+		l.pos++
+		return l.array()
+	case ']', '>', ')', '{', '}':
+		return nil, fmt.Errorf("unexpected %q at offset %d", c, l.pos)
+	}
+
+	word := l.word()
+	if word == "" {
+		return nil, fmt.Errorf("unexpected %q at offset %d", l.buf[l.pos], l.pos)
+	}
+
+	switch word {
+	case "null":
+		return nil, nil
+	case "true", "false":
+		return keyword(word), nil
+	}
+
+	if !isNumber(word) {
+		return keyword(word), nil
+	}
+
+	// A non-negative integer may be the object number of a reference.
+	if num, err := strconv.Atoi(word); err == nil && num >= 0 {
+		save := l.pos
+
+		l.skipSpace()
+		if gen, err := strconv.Atoi(l.word()); err == nil && gen >= 0 {
+			l.skipSpace()
+			if l.word() == "R" {
+				return ref{num: num, gen: gen}, nil
+			}
+		}
+
+		l.pos = save
+	}
+
+	return number(word), nil
+}
+
+// isNumber tells whether the word is an integer or a real number.
+func isNumber(word string) bool {
+	digits := false
+
+	for i, c := range word {
+		switch {
+		case c >= '0' && c <= '9':
+			digits = true
+		case (c == '+' || c == '-') && i == 0:
+		case c == '.':
+		default:
+			return false
+		}
+	}
+
+	return digits
+}
+
+func (l *lexer) array() (array, error) {
+	a := array{}
+
+	for {
+		l.skipSpace()
+		if l.pos >= len(l.buf) {
+			return nil, fmt.Errorf("unterminated array")
+		}
+
+		if l.buf[l.pos] == ']' {
+			l.pos++
+			return a, nil
+		}
+
+		obj, err := l.object()
+		if err != nil {
+			return nil, err
+		}
+
+		a = append(a, obj)
+	}
+}
+
+func (l *lexer) dict() (dict, error) {
+	d := dict{}
+
+	for {
+		l.skipSpace()
+		if l.pos >= len(l.buf) {
+			return nil, fmt.Errorf("unterminated dictionary")
+		}
+
+		if bytes.HasPrefix(l.buf[l.pos:], []byte(">>")) {
+			l.pos += 2
+			return d, nil
+		}
+
+		key, err := l.object()
+		if err != nil {
+			return nil, err
+		}
+
+		k, ok := key.(name)
+		if !ok {
+			return nil, fmt.Errorf("dictionary key %v is not a name, at offset %d", key, l.pos)
+		}
+
+		value, err := l.object()
+		if err != nil {
+			return nil, err
+		}
+
+		// Null values are the same as missing entries.
+		if value != nil {
+			d[k] = value
+		}
+	}
+}
+
+// literalString reads a string between balanced parentheses, and unescapes it.
+func (l *lexer) literalString() (pdfString, error) {
+	l.pos++
+
+	var (
+		s     []byte
+		depth = 1
+	)
+
+	for l.pos < len(l.buf) {
+		c := l.buf[l.pos]
+		l.pos++
+
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return s, nil
+			}
+		case '\r':
+			// End of lines are read as a line feed.
+			if l.pos < len(l.buf) && l.buf[l.pos] == '\n' {
+				l.pos++
+			}
+			c = '\n'
+		case '\\':
+			if l.pos >= len(l.buf) {
+				continue
+			}
+
+			c = l.buf[l.pos]
+			l.pos++
+
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				// A backslash at the end of a line continues the string on the next one.
+				if l.pos < len(l.buf) && l.buf[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if c >= '0' && c <= '7' {
+					v := int(c - '0')
+					for i := 0; i < 2 && l.pos < len(l.buf) && l.buf[l.pos] >= '0' && l.buf[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.buf[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(v)
+				}
+			}
+		}
+
+		s = append(s, c)
+	}
+
+	return nil, fmt.Errorf("unterminated string")
+}
+
+// hexString reads a string of hexadecimal digits between angle brackets.
+func (l *lexer) hexString() (pdfString, error) {
+	l.pos++
+
+	var (
+		s    []byte
+		half = -1
+	)
+
+	for l.pos < len(l.buf) {
+		c := l.buf[l.pos]
+		l.pos++
+
+		var v int
+		switch {
+		case c == '>':
+			// An odd number of digits is read as if it was followed by a zero.
+			if half >= 0 {
+				s = append(s, byte(half<<4))
+			}
+			return s, nil
+		case isWhitespace(c):
+			continue
+		case c >= '0' && c <= '9':
+			v = int(c - '0')
+		case c >= 'a' && c <= 'f':
+			v = int(c-'a') + 10
+		case c >= 'A' && c <= 'F':
+			v = int(c-'A') + 10
+		default:
+			return nil, fmt.Errorf("invalid hexadecimal string at offset %d", l.pos-1)
+		}
+
+		if half < 0 {
+			half = v
+		} else {
+			s = append(s, byte(half<<4|v))
+			half = -1
+		}
+	}
+
+	return nil, fmt.Errorf("unterminated hexadecimal string")
+}
+
+// writeObject writes the object in the pdf syntax.
+// Dictionary keys are sorted, so the same object is always written the same way.
+func writeObject(buf *bytes.Buffer, obj any) {
+	switch v := obj.(type) {
+	case nil:
+		buf.WriteString("null")
+	case name:
+		buf.WriteByte('/')
+		buf.WriteString(string(v))
+	case number:
+		buf.WriteString(string(v))
+	case keyword:
+		buf.WriteString(string(v))
+	case pdfString:
+		writeString(buf, v)
+	case ref:
+		fmt.Fprintf(buf, "%d %d R", v.num, v.gen)
+	case array:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(' ')
+			}
+			writeObject(buf, item)
+		}
+		buf.WriteByte(']')
+	case dict:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, string(k))
+		}
+		sort.Strings(keys)
+
+		buf.WriteString("<<")
+		for _, k := range keys {
+			buf.WriteByte('/')
+			buf.WriteString(k)
+			buf.WriteByte(' ')
+			writeObject(buf, v[name(k)])
+		}
+		buf.WriteString(">>")
+	case *stream:
+		d := dict{}
+		for k, value := range v.dict {
+			d[k] = value
+		}
+		d["Length"] = number(strconv.Itoa(len(v.data)))
+
+		writeObject(buf, d)
+		buf.WriteString("\nstream\n")
+		buf.Write(v.data)
+		buf.WriteString("\nendstream")
+	default:
+		panic(fmt.Sprintf("unexpected pdf object %T", obj))
+	}
+}
+
+// writeString writes a literal string, escaping what would be read differently otherwise.
+func writeString(buf *bytes.Buffer, s pdfString) {
+	buf.WriteByte('(')
+	for _, c := range s {
+		switch c {
+		case '(', ')', '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case '\r':
+			buf.WriteString(`\r`)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	buf.WriteByte(')')
+}
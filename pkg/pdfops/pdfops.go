@@ -0,0 +1,389 @@
+// Package pdfops merges, splits, extracts, reorders, rotates and deletes the pages of pdf files.
+// Pages are copied as they are, with the objects they use, e.g. fonts, images and links,
+// so nothing is rendered or converted again.
+package pdfops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/danvergara/morphos/pkg/files"
+	"github.com/danvergara/morphos/pkg/files/documents"
+	"github.com/danvergara/morphos/pkg/packaging"
+)
+
+const (
+	// OperationOption sets the operation applied to the pdf files.
+	OperationOption = "operation"
+	// PagesOption selects the pages extracted, rotated or deleted,
+	// or the ranges of pages every file holds when splitting. e.g. 1-3,7,10-
+	PagesOption = documents.PagesOption
+	// EveryOption splits the pdf in files of the given number of pages.
+	EveryOption = "every"
+	// OrderOption sets the new order of the pages. e.g. 3,1-2
+	OrderOption = "order"
+	// AngleOption sets the angle the pages are rotated clockwise by.
+	AngleOption = "angle"
+
+	// OperationMerge merges several pdf files into one, in the order they are given.
+	OperationMerge = "merge"
+	// OperationSplit splits a pdf into several ones, returned in an archive.
+	OperationSplit = "split"
+	// OperationExtract keeps the selected pages of a pdf, in the order they are selected.
+	OperationExtract = "extract"
+	// OperationReorder moves the pages of a pdf to the order given.
+	OperationReorder = "reorder"
+	// OperationRotate rotates the selected pages of a pdf, every page if there's no selection.
+	OperationRotate = "rotate"
+	// OperationDelete deletes the selected pages of a pdf.
+	OperationDelete = "delete"
+)
+
+// Operations are the operations that can be applied to pdf files.
+var Operations = []string{OperationMerge, OperationSplit, OperationExtract, OperationReorder, OperationRotate, OperationDelete}
+
+// ErrInvalidOperation is returned when an operation can't be applied to the files given.
+var ErrInvalidOperation = errors.New("invalid operation")
+
+// validatePages checks a selection of pages can be parsed.
+func validatePages(spec string) error {
+	_, err := documents.ParsePageRanges(spec)
+	return err
+}
+
+// Options are the options accepted by the operations.
+var Options = files.Schema{
+	{
+		Name:    OperationOption,
+		Label:   "Operation",
+		Help:    "Merge the files, split them, or extract, reorder, rotate or delete their pages",
+		Type:    files.ChoiceOption,
+		Default: OperationMerge,
+		Choices: Operations,
+	},
+	{
+		Name:     PagesOption,
+		Label:    "Pages",
+		Help:     "Pages to extract, rotate or delete, e.g. 1-3,7,10-, or the ranges of pages of every file when splitting",
+		Type:     files.StringOption,
+		Validate: validatePages,
+	},
+	{
+		Name:  EveryOption,
+		Label: "Pages per file",
+		Help:  "Splits the file in files of this number of pages, instead of by ranges",
+		Type:  files.IntOption,
+		Min:   1,
+		Max:   100000,
+	},
+	{
+		Name:     OrderOption,
+		Label:    "Order",
+		Help:     "New order of the pages, e.g. 3,1-2. The pages left out follow in their order",
+		Type:     files.StringOption,
+		Validate: validatePages,
+	},
+	{
+		Name:    AngleOption,
+		Label:   "Angle",
+		Help:    "Degrees the pages are rotated clockwise by",
+		Type:    files.ChoiceOption,
+		Default: "90",
+		Choices: []string{"90", "180", "270"},
+	},
+}
+
+// Run applies the operation set in the options to the pdf files, and returns the resulting files.
+// Merging takes any number of files, the rest of the operations take a single one.
+// Every operation returns a single file, but splitting, which returns a file per part.
+func Run(ctx context.Context, pdfs []packaging.File, opts files.ConvertOptions) ([]packaging.File, error) {
+	operation := opts.String(OperationOption, OperationMerge)
+
+	if len(pdfs) == 0 {
+		return nil, fmt.Errorf("%w: there are no pdf files", ErrInvalidOperation)
+	}
+
+	if operation != OperationMerge && len(pdfs) > 1 {
+		return nil, fmt.Errorf("%w: %s takes a single pdf file, got %d", ErrInvalidOperation, operation, len(pdfs))
+	}
+
+	if operation == OperationSplit {
+		return Split(ctx, pdfs[0], opts.String(PagesOption, ""), opts.Int(EveryOption, 0))
+	}
+
+	var (
+		result []byte
+		err    error
+	)
+
+	switch operation {
+	case OperationMerge:
+		contents := make([][]byte, len(pdfs))
+		for i, f := range pdfs {
+			contents[i] = f.Content
+		}
+		result, err = Merge(ctx, contents...)
+	case OperationExtract:
+		result, err = Extract(ctx, pdfs[0].Content, opts.String(PagesOption, ""))
+	case OperationReorder:
+		result, err = Reorder(ctx, pdfs[0].Content, opts.String(OrderOption, ""))
+	case OperationRotate:
+		// The angle is one of the choices, so it's always a number.
+		angle, _ := strconv.Atoi(opts.String(AngleOption, "90"))
+		result, err = Rotate(ctx, pdfs[0].Content, opts.String(PagesOption, ""), angle)
+	case OperationDelete:
+		result, err = Delete(ctx, pdfs[0].Content, opts.String(PagesOption, ""))
+	default:
+		return nil, fmt.Errorf("%w: unknown operation %q", ErrInvalidOperation, operation)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return []packaging.File{{Name: outputName(pdfs[0].Name, operation), Content: result}}, nil
+}
+
+// outputName returns the name of the file resulting of an operation. e.g. report-rotate.pdf
+func outputName(filename, operation string) string {
+	base := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	return fmt.Sprintf("%s-%s.pdf", base, operation)
+}
+
+// read reads a pdf file, and checks the context wasn't cancelled.
+func read(ctx context.Context, pdf []byte) (*document, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	d, err := readDocument(pdf)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the pdf file: %w", err)
+	}
+
+	return d, nil
+}
+
+// copies returns the pages of the document at the given zero-based indexes.
+func copies(d *document, indexes []int) []pageCopy {
+	pages := make([]pageCopy, len(indexes))
+	for i, n := range indexes {
+		pages[i] = pageCopy{doc: d, page: d.pages[n]}
+	}
+
+	return pages
+}
+
+// Merge returns a pdf with the pages of every pdf, in the order they are given.
+func Merge(ctx context.Context, pdfs ...[]byte) ([]byte, error) {
+	var pages []pageCopy
+
+	for i, pdf := range pdfs {
+		d, err := read(ctx, pdf)
+		if err != nil {
+			return nil, fmt.Errorf("file %d: %w", i+1, err)
+		}
+
+		pages = append(pages, copies(d, allPages(d))...)
+	}
+
+	return writePDF(pages), nil
+}
+
+// Split splits the pdf into several ones, either a file per range of pages,
+// or files of the given number of pages if every is greater than zero.
+// The files are named after the pdf and the pages they hold, e.g. report_1-3.pdf
+func Split(ctx context.Context, pdf packaging.File, ranges string, every int) ([]packaging.File, error) {
+	d, err := read(ctx, pdf.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	var parts []documents.PageRange
+
+	switch {
+	case every > 0:
+		for first := 1; first <= len(d.pages); first += every {
+			parts = append(parts, documents.PageRange{First: first, Last: min(first+every-1, len(d.pages))})
+		}
+	case ranges != "":
+		parts, err = documents.ParsePageRanges(ranges)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidOperation, err)
+		}
+	}
+
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("%w: splitting takes either the ranges of pages or the number of pages per file", ErrInvalidOperation)
+	}
+
+	base := strings.TrimSuffix(filepath.Base(pdf.Name), filepath.Ext(pdf.Name))
+
+	var result []packaging.File
+
+	for _, r := range parts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		last := r.Last
+		if last == 0 || last > len(d.pages) {
+			last = len(d.pages)
+		}
+
+		indexes, err := selectPages(d, fmt.Sprintf("%d-%d", r.First, last))
+		if err != nil {
+			return nil, err
+		}
+
+		name := fmt.Sprintf("%s_%d-%d.pdf", base, r.First, last)
+		if r.First == last {
+			name = fmt.Sprintf("%s_%d.pdf", base, r.First)
+		}
+
+		result = append(result, packaging.File{Name: name, Content: writePDF(copies(d, indexes))})
+	}
+
+	return result, nil
+}
+
+// Extract returns a pdf with the selected pages, in the order they are selected.
+func Extract(ctx context.Context, pdf []byte, pages string) ([]byte, error) {
+	d, err := read(ctx, pdf)
+	if err != nil {
+		return nil, err
+	}
+
+	if pages == "" {
+		return nil, fmt.Errorf("%w: select the pages to extract", ErrInvalidOperation)
+	}
+
+	indexes, err := selectPages(d, pages)
+	if err != nil {
+		return nil, err
+	}
+
+	return writePDF(copies(d, indexes)), nil
+}
+
+// Reorder returns the pdf with its pages in the given order.
+// The pages left out of the order follow the ones in it, in their order.
+func Reorder(ctx context.Context, pdf []byte, order string) ([]byte, error) {
+	d, err := read(ctx, pdf)
+	if err != nil {
+		return nil, err
+	}
+
+	if order == "" {
+		return nil, fmt.Errorf("%w: set the order of the pages", ErrInvalidOperation)
+	}
+
+	indexes, err := selectPages(d, order)
+	if err != nil {
+		return nil, err
+	}
+
+	listed := make(map[int]bool, len(indexes))
+	for _, n := range indexes {
+		listed[n] = true
+	}
+
+	for _, n := range allPages(d) {
+		if !listed[n] {
+			indexes = append(indexes, n)
+		}
+	}
+
+	return writePDF(copies(d, indexes)), nil
+}
+
+// Rotate returns the pdf with the selected pages rotated clockwise by the angle,
+// a multiple of 90. Every page is rotated if there's no selection.
+func Rotate(ctx context.Context, pdf []byte, pages string, angle int) ([]byte, error) {
+	if angle%90 != 0 {
+		return nil, fmt.Errorf("%w: pages can only be rotated by multiples of 90 degrees, not %d", ErrInvalidOperation, angle)
+	}
+
+	d, err := read(ctx, pdf)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes, err := selectPages(d, pages)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make(map[int]bool, len(indexes))
+	for _, n := range indexes {
+		selected[n] = true
+	}
+
+	result := copies(d, allPages(d))
+	for i := range result {
+		if selected[i] {
+			result[i].rotate = (angle%360 + 360) % 360
+		}
+	}
+
+	return writePDF(result), nil
+}
+
+// Delete returns the pdf without the selected pages.
+func Delete(ctx context.Context, pdf []byte, pages string) ([]byte, error) {
+	d, err := read(ctx, pdf)
+	if err != nil {
+		return nil, err
+	}
+
+	if pages == "" {
+		return nil, fmt.Errorf("%w: select the pages to delete", ErrInvalidOperation)
+	}
+
+	indexes, err := selectPages(d, pages)
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := make(map[int]bool, len(indexes))
+	for _, n := range indexes {
+		deleted[n] = true
+	}
+
+	var kept []int
+	for _, n := range allPages(d) {
+		if !deleted[n] {
+			kept = append(kept, n)
+		}
+	}
+
+	if len(kept) == 0 {
+		return nil, fmt.Errorf("%w: every page would be deleted", ErrInvalidOperation)
+	}
+
+	return writePDF(copies(d, kept)), nil
+}
+
+// selectPages returns the zero-based indexes of the pages selected in the document.
+func selectPages(d *document, spec string) ([]int, error) {
+	indexes, err := documents.SelectPages(spec, len(d.pages))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidOperation, err)
+	}
+
+	return indexes, nil
+}
+
+// allPages returns the zero-based indexes of every page of the document.
+func allPages(d *document) []int {
+	indexes := make([]int, len(d.pages))
+	for i := range indexes {
+		indexes[i] = i
+	}
+
+	return indexes
+}
@@ -0,0 +1,326 @@
+package pdfops_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"net/url"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/gen2brain/go-fitz"
+	"github.com/signintech/gopdf"
+	"github.com/stretchr/testify/require"
+
+	"github.com/danvergara/morphos/pkg/packaging"
+	"github.com/danvergara/morphos/pkg/pdfops"
+)
+
+// newPDF returns a pdf with a page per width, 500 points tall, so pages can be told apart by their width.
+func newPDF(t testing.TB, widths ...float64) []byte {
+	pdf := gopdf.GoPdf{}
+	pdf.Start(gopdf.Config{PageSize: gopdf.Rect{W: widths[0], H: 500}})
+
+	for _, w := range widths {
+		pdf.AddPageWithOption(gopdf.PageOption{PageSize: &gopdf.Rect{W: w, H: 500}})
+		pdf.SetFillColor(0, 0, 0)
+		pdf.RectFromUpperLeftWithStyle(10, 10, w/2, 100, "F")
+	}
+
+	buf := new(bytes.Buffer)
+	_, err := pdf.WriteTo(buf)
+	require.NoError(t, err)
+
+	return buf.Bytes()
+}
+
+// xrefStreamPDF returns a pdf whose objects are stored in an object stream, and whose
+// cross-reference table is a stream filtered with the png Up predictor.
+// The first page inherits its size from the page tree, and is rotated.
+func xrefStreamPDF(t testing.TB) []byte {
+	objects := []string{
+		"<</Type/Catalog/Pages 2 0 R>>",
+		"<</Type/Pages/Kids[3 0 R 4 0 R]/Count 2/MediaBox[0 0 200 300]>>",
+		"<</Type/Page/Parent 2 0 R/Rotate 90>>",
+		"<</Type/Page/Parent 2 0 R/MediaBox[0 0 400 100]>>",
+	}
+
+	var header, body bytes.Buffer
+	for i, obj := range objects {
+		fmt.Fprintf(&header, "%d %d ", i+1, body.Len())
+		body.WriteString(obj + "\n")
+	}
+
+	compress := func(b []byte) []byte {
+		buf := new(bytes.Buffer)
+		zw := zlib.NewWriter(buf)
+		_, err := zw.Write(b)
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		return buf.Bytes()
+	}
+
+	out := bytes.NewBufferString("%PDF-1.5\n")
+
+	objStm := compress(append(header.Bytes(), body.Bytes()...))
+	objStmOffset := out.Len()
+	fmt.Fprintf(out, "5 0 obj\n<</Type/ObjStm/N %d/First %d/Filter/FlateDecode/Length %d>>\nstream\n", len(objects), header.Len(), len(objStm))
+	out.Write(objStm)
+	out.WriteString("\nendstream\nendobj\n")
+
+	xrefOffset := out.Len()
+
+	// Entries of a type byte, a 4 bytes offset or object stream number, and a 2 bytes generation or index.
+	rows := [][]byte{{0, 0, 0, 0, 0, 0xff, 0xff}}
+	for i := range objects {
+		rows = append(rows, []byte{2, 0, 0, 0, 5, 0, byte(i)})
+	}
+	for _, offset := range []int{objStmOffset, xrefOffset} {
+		row := []byte{1, 0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(row[1:], uint32(offset))
+		rows = append(rows, row)
+	}
+
+	var predicted []byte
+	prev := make([]byte, 7)
+	for _, row := range rows {
+		predicted = append(predicted, 2)
+		for i := range row {
+			predicted = append(predicted, row[i]-prev[i])
+		}
+		prev = row
+	}
+
+	xref := compress(predicted)
+	fmt.Fprintf(out, "6 0 obj\n<</Type/XRef/Size 7/W[1 4 2]/Root 1 0 R/Filter/FlateDecode/DecodeParms<</Columns 7/Predictor 12>>/Length %d>>\nstream\n", len(xref))
+	out.Write(xref)
+	fmt.Fprintf(out, "\nendstream\nendobj\nstartxref\n%d\n%%%%EOF\n", xrefOffset)
+
+	return out.Bytes()
+}
+
+// pageSizes returns the size of every page of the pdf, as it's shown.
+func pageSizes(t *testing.T, pdf []byte) []image.Point {
+	doc, err := fitz.NewFromMemory(pdf)
+	require.NoError(t, err)
+	defer doc.Close()
+
+	var sizes []image.Point
+	for n := 0; n < doc.NumPage(); n++ {
+		bounds, err := doc.Bound(n)
+		require.NoError(t, err)
+		sizes = append(sizes, bounds.Size())
+	}
+
+	return sizes
+}
+
+// portrait returns the sizes of pages 500 points tall, and as wide as given.
+func portrait(widths ...int) []image.Point {
+	var sizes []image.Point
+	for _, w := range widths {
+		sizes = append(sizes, image.Pt(w, 500))
+	}
+
+	return sizes
+}
+
+func TestRun(t *testing.T) {
+	first := packaging.File{Name: "first.pdf", Content: newPDF(t, 100, 110, 120, 130, 140)}
+	second := packaging.File{Name: "second.pdf", Content: newPDF(t, 200, 210)}
+
+	var tests = []struct {
+		name     string
+		pdfs     []packaging.File
+		values   url.Values
+		expected map[string][]image.Point
+	}{
+		{
+			name:   "merge",
+			pdfs:   []packaging.File{second, first},
+			values: url.Values{"operation": {"merge"}},
+			expected: map[string][]image.Point{
+				"second-merge.pdf": portrait(200, 210, 100, 110, 120, 130, 140),
+			},
+		},
+		{
+			name:   "split every 2 pages",
+			pdfs:   []packaging.File{first},
+			values: url.Values{"operation": {"split"}, "every": {"2"}},
+			expected: map[string][]image.Point{
+				"first_1-2.pdf": portrait(100, 110),
+				"first_3-4.pdf": portrait(120, 130),
+				"first_5.pdf":   portrait(140),
+			},
+		},
+		{
+			name:   "split by ranges",
+			pdfs:   []packaging.File{first},
+			values: url.Values{"operation": {"split"}, "pages": {"2,3-"}},
+			expected: map[string][]image.Point{
+				"first_2.pdf":   portrait(110),
+				"first_3-5.pdf": portrait(120, 130, 140),
+			},
+		},
+		{
+			name:   "extract",
+			pdfs:   []packaging.File{first},
+			values: url.Values{"operation": {"extract"}, "pages": {"4,1-2"}},
+			expected: map[string][]image.Point{
+				"first-extract.pdf": portrait(130, 100, 110),
+			},
+		},
+		{
+			name:   "reorder",
+			pdfs:   []packaging.File{first},
+			values: url.Values{"operation": {"reorder"}, "order": {"5,3"}},
+			expected: map[string][]image.Point{
+				"first-reorder.pdf": portrait(140, 120, 100, 110, 130),
+			},
+		},
+		{
+			name:   "rotate",
+			pdfs:   []packaging.File{first},
+			values: url.Values{"operation": {"rotate"}, "pages": {"2,4"}, "angle": {"270"}},
+			expected: map[string][]image.Point{
+				"first-rotate.pdf": {image.Pt(100, 500), image.Pt(500, 110), image.Pt(120, 500), image.Pt(500, 130), image.Pt(140, 500)},
+			},
+		},
+		{
+			name:   "delete",
+			pdfs:   []packaging.File{first},
+			values: url.Values{"operation": {"delete"}, "pages": {"1,3-4"}},
+			expected: map[string][]image.Point{
+				"first-delete.pdf": portrait(110, 140),
+			},
+		},
+		{
+			name:   "object and cross-reference streams",
+			pdfs:   []packaging.File{{Name: "streams.pdf", Content: xrefStreamPDF(t)}},
+			values: url.Values{"operation": {"rotate"}, "pages": {"2"}, "angle": {"180"}},
+			expected: map[string][]image.Point{
+				"streams-rotate.pdf": {image.Pt(300, 200), image.Pt(400, 100)},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			opts, err := pdfops.Options.Parse(tc.values)
+			require.NoError(t, err)
+
+			result, err := pdfops.Run(context.Background(), tc.pdfs, opts)
+			require.NoError(t, err)
+
+			sizes := map[string][]image.Point{}
+			for _, f := range result {
+				sizes[f.Name] = pageSizes(t, f.Content)
+			}
+
+			require.Equal(t, tc.expected, sizes)
+		})
+	}
+}
+
+func TestRunInvalid(t *testing.T) {
+	pdf := packaging.File{Name: "doc.pdf", Content: newPDF(t, 100, 110)}
+
+	var tests = []struct {
+		name   string
+		pdfs   []packaging.File
+		values url.Values
+	}{
+		{
+			name:   "several files to rotate",
+			pdfs:   []packaging.File{pdf, pdf},
+			values: url.Values{"operation": {"rotate"}},
+		},
+		{
+			name:   "page out of range",
+			pdfs:   []packaging.File{pdf},
+			values: url.Values{"operation": {"extract"}, "pages": {"3"}},
+		},
+		{
+			name:   "every page deleted",
+			pdfs:   []packaging.File{pdf},
+			values: url.Values{"operation": {"delete"}, "pages": {"1-"}},
+		},
+		{
+			name:   "split without ranges",
+			pdfs:   []packaging.File{pdf},
+			values: url.Values{"operation": {"split"}},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			opts, err := pdfops.Options.Parse(tc.values)
+			require.NoError(t, err)
+
+			_, err = pdfops.Run(context.Background(), tc.pdfs, opts)
+			require.ErrorIs(t, err, pdfops.ErrInvalidOperation)
+		})
+	}
+}
+
+func TestRunEncrypted(t *testing.T) {
+	pdf := bytes.Replace(newPDF(t, 100), []byte("trailer\n<<"), []byte("trailer\n<</Encrypt 99 0 R"), 1)
+
+	opts, err := pdfops.Options.Parse(url.Values{"operation": {"rotate"}})
+	require.NoError(t, err)
+
+	_, err = pdfops.Run(context.Background(), []packaging.File{{Name: "secret.pdf", Content: pdf}}, opts)
+	require.ErrorIs(t, err, pdfops.ErrEncrypted)
+}
+
+func TestRunBrokenXref(t *testing.T) {
+	pdf := newPDF(t, 100, 110)
+
+	// The entry of the object 2 points past the end of the file.
+	entries := regexp.MustCompile(`(?m)^\d{10} \d{5} n`).FindAllIndex(pdf, -1)
+	require.Greater(t, len(entries), 2)
+
+	pastEOF := bytes.Clone(pdf)
+	copy(pastEOF[entries[1][0]:], "2000020481")
+
+	var tests = []struct {
+		name string
+		pdf  []byte
+	}{
+		{name: "object past the end of the file", pdf: pastEOF},
+		{
+			name: "cross-reference stream past the end of the file",
+			pdf:  bytes.Replace(pdf, []byte("trailer\n<<"), []byte("trailer\n<</XRefStm 2000020481"), 1),
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			// The cross-reference table is rebuilt by scanning the file.
+			result, err := pdfops.Merge(context.Background(), tc.pdf)
+			require.NoError(t, err)
+			require.Equal(t, portrait(100, 110), pageSizes(t, result))
+		})
+	}
+}
+
+func FuzzMerge(f *testing.F) {
+	f.Add(newPDF(f, 100, 110))
+	f.Add(xrefStreamPDF(f))
+
+	bitcoin, err := os.ReadFile("../files/documents/testdata/bitcoin.pdf")
+	require.NoError(f, err)
+	f.Add(bitcoin)
+
+	f.Fuzz(func(t *testing.T, pdf []byte) {
+		// Broken files return an error, but never panic.
+		_, _ = pdfops.Merge(context.Background(), pdf)
+	})
+}
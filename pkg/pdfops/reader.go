@@ -0,0 +1,770 @@
+package pdfops
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+// ErrEncrypted is returned when reading an encrypted pdf, whose objects can't be copied without its password.
+var ErrEncrypted = errors.New("encrypted pdf files are not supported")
+
+// maxObjectDepth is the deepest a chain of references is followed when resolving an object,
+// which stops reference cycles in broken files.
+const maxObjectDepth = 64
+
+// xrefEntry is where an indirect object is stored: either at an offset of the file,
+// or at an index of an object stream.
+type xrefEntry struct {
+	offset int
+	// stream is the object number of the object stream, if the object is stored in one.
+	stream int
+	index  int
+}
+
+// document is a pdf file, whose objects are read as they are needed.
+type document struct {
+	buf     []byte
+	xref    map[int]xrefEntry
+	trailer dict
+	objects map[int]any
+	// streams are the object streams decoded so far.
+	streams map[int]*objectStream
+	// pages are the page objects, in order.
+	pages []*page
+}
+
+// page is a page of a document, with the attributes it inherits from the page tree set on it.
+type page struct {
+	ref  ref
+	dict dict
+}
+
+// inheritable are the attributes of pages that may be set on the nodes of the page tree instead.
+var inheritable = []name{"Resources", "MediaBox", "CropBox", "Rotate"}
+
+// readDocument reads the cross-reference table and the page tree of a pdf file.
+// The table is rebuilt by scanning the file if it's missing or broken.
+func readDocument(buf []byte) (*document, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(buf, "\x00\t\n\f\r "), []byte("%PDF-")) {
+		return nil, fmt.Errorf("not a pdf file")
+	}
+
+	d := &document{buf: buf}
+
+	if err := d.readXref(); err != nil {
+		if err := d.rebuildXref(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Objects read while the table was incomplete may be missing.
+	d.objects = map[int]any{}
+	d.streams = map[int]*objectStream{}
+
+	if _, ok := d.trailer["Encrypt"]; ok {
+		return nil, ErrEncrypted
+	}
+
+	if err := d.readPages(); err != nil {
+		return nil, err
+	}
+
+	if len(d.pages) == 0 {
+		return nil, fmt.Errorf("the pdf file has no pages")
+	}
+
+	return d, nil
+}
+
+// readXref reads the cross-reference sections, from the last one to the first one,
+// following the offsets of the previous sections of incremental updates.
+func (d *document) readXref() error {
+	d.xref = map[int]xrefEntry{}
+	d.objects = map[int]any{}
+	d.streams = map[int]*objectStream{}
+
+	i := bytes.LastIndex(d.buf, []byte("startxref"))
+	if i < 0 {
+		return fmt.Errorf("startxref not found")
+	}
+
+	l := lexer{buf: d.buf, pos: i + len("startxref")}
+	l.skipSpace()
+
+	offset, err := strconv.Atoi(l.word())
+	if err != nil {
+		return fmt.Errorf("invalid startxref: %w", err)
+	}
+
+	seen := map[int]bool{}
+
+	for offset > 0 {
+		if seen[offset] || offset >= len(d.buf) {
+			return fmt.Errorf("invalid cross-reference offset %d", offset)
+		}
+		seen[offset] = true
+
+		trailer, err := d.readXrefSection(offset)
+		if err != nil {
+			return err
+		}
+
+		// The trailer of the last section is the one of the document.
+		if d.trailer == nil {
+			d.trailer = trailer
+		}
+
+		// Hybrid files hold the objects of object streams in a cross-reference stream of their own.
+		if stm, ok := integer(trailer["XRefStm"]); ok {
+			if stm < 0 || stm >= len(d.buf) {
+				return fmt.Errorf("invalid cross-reference stream offset %d", stm)
+			}
+
+			if _, err := d.readXrefSection(stm); err != nil {
+				return err
+			}
+		}
+
+		offset, _ = integer(trailer["Prev"])
+	}
+
+	if _, ok := d.trailer["Root"].(ref); !ok {
+		return fmt.Errorf("the trailer has no root")
+	}
+
+	// An object out of the file means the table is broken, so it's rebuilt rather than trusted.
+	for num, entry := range d.xref {
+		if entry.stream == 0 && (entry.offset < 0 || entry.offset >= len(d.buf)) {
+			return fmt.Errorf("invalid offset %d of the object %d", entry.offset, num)
+		}
+	}
+
+	return nil
+}
+
+// readXrefSection reads a cross-reference table or stream at the offset, and returns its trailer.
+// Entries of the newer sections, already read, are kept.
+func (d *document) readXrefSection(offset int) (dict, error) {
+	l := lexer{buf: d.buf, pos: offset}
+	l.skipSpace()
+
+	if !bytes.HasPrefix(d.buf[l.pos:], []byte("xref")) {
+		return d.readXrefStream(offset)
+	}
+
+	l.pos += len("xref")
+
+	for {
+		l.skipSpace()
+		if bytes.HasPrefix(d.buf[l.pos:], []byte("trailer")) {
+			l.pos += len("trailer")
+			break
+		}
+
+		start, err1 := strconv.Atoi(l.word())
+		l.skipSpace()
+		count, err2 := strconv.Atoi(l.word())
+		if err1 != nil || err2 != nil || start < 0 || count < 0 {
+			return nil, fmt.Errorf("invalid cross-reference subsection at offset %d", l.pos)
+		}
+
+		for n := start; n < start+count; n++ {
+			l.skipSpace()
+			off, err1 := strconv.Atoi(l.word())
+			l.skipSpace()
+			_, err2 := strconv.Atoi(l.word())
+			l.skipSpace()
+			kind := l.word()
+			if err1 != nil || err2 != nil || (kind != "n" && kind != "f") {
+				return nil, fmt.Errorf("invalid cross-reference entry of the object %d", n)
+			}
+
+			if _, ok := d.xref[n]; !ok && kind == "n" {
+				d.xref[n] = xrefEntry{offset: off}
+			}
+		}
+	}
+
+	trailer, err := l.object()
+	if err != nil {
+		return nil, fmt.Errorf("error reading the trailer: %w", err)
+	}
+
+	t, ok := trailer.(dict)
+	if !ok {
+		return nil, fmt.Errorf("the trailer is not a dictionary")
+	}
+
+	return t, nil
+}
+
+// readXrefStream reads a cross-reference stream at the offset, and returns its dictionary as the trailer.
+func (d *document) readXrefStream(offset int) (dict, error) {
+	_, obj, err := d.readObjectAt(offset)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the cross-reference stream: %w", err)
+	}
+
+	s, ok := obj.(*stream)
+	if !ok || s.dict["Type"] != name("XRef") {
+		return nil, fmt.Errorf("invalid cross-reference stream at offset %d", offset)
+	}
+
+	data, err := d.decode(s)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding the cross-reference stream: %w", err)
+	}
+
+	w, _ := d.resolve(s.dict["W"]).(array)
+	if len(w) != 3 {
+		return nil, fmt.Errorf("invalid widths of the cross-reference stream")
+	}
+
+	var widths [3]int
+	for i := range widths {
+		widths[i], _ = integer(w[i])
+		if widths[i] < 0 || widths[i] > 8 {
+			return nil, fmt.Errorf("invalid widths of the cross-reference stream")
+		}
+	}
+
+	size, _ := integer(s.dict["Size"])
+
+	index, _ := d.resolve(s.dict["Index"]).(array)
+	if index == nil {
+		index = array{number("0"), number(strconv.Itoa(size))}
+	}
+
+	entryLen := widths[0] + widths[1] + widths[2]
+	if entryLen == 0 {
+		return nil, fmt.Errorf("invalid widths of the cross-reference stream")
+	}
+
+	field := func(b []byte) int {
+		v := 0
+		for _, c := range b {
+			v = v<<8 | int(c)
+		}
+		return v
+	}
+
+	pos := 0
+	for i := 0; i+1 < len(index); i += 2 {
+		start, _ := integer(index[i])
+		count, _ := integer(index[i+1])
+
+		for n := start; n < start+count && pos+entryLen <= len(data); n++ {
+			entry := data[pos : pos+entryLen]
+			pos += entryLen
+
+			// The type defaults to 1, objects stored at an offset.
+			kind := 1
+			if widths[0] > 0 {
+				kind = field(entry[:widths[0]])
+			}
+
+			second := field(entry[widths[0] : widths[0]+widths[1]])
+			third := field(entry[widths[0]+widths[1]:])
+
+			if _, ok := d.xref[n]; ok {
+				continue
+			}
+
+			switch kind {
+			case 1:
+				d.xref[n] = xrefEntry{offset: second}
+			case 2:
+				d.xref[n] = xrefEntry{stream: second, index: third}
+			}
+		}
+	}
+
+	return s.dict, nil
+}
+
+// objectHeader matches the start of an indirect object, e.g. 12 0 obj
+var objectHeader = regexp.MustCompile(`(\d+)[\x00\t\f\r\n ]+(\d+)[\x00\t\f\r\n ]+obj\b`)
+
+// rebuildXref rebuilds the cross-reference table of a broken file,
+// by scanning it for indirect objects, and the objects of the object streams.
+// The root is the last catalog found.
+func (d *document) rebuildXref() error {
+	d.xref = map[int]xrefEntry{}
+	d.objects = map[int]any{}
+	d.streams = map[int]*objectStream{}
+	d.trailer = dict{}
+
+	var streams []int
+
+	for _, m := range objectHeader.FindAllSubmatchIndex(d.buf, -1) {
+		// Object numbers are preceded by the end of something else.
+		if m[0] > 0 && !isWhitespace(d.buf[m[0]-1]) && !isDelimiter(d.buf[m[0]-1]) {
+			continue
+		}
+
+		num, _ := strconv.Atoi(string(d.buf[m[2]:m[3]]))
+		d.xref[num] = xrefEntry{offset: m[0]}
+	}
+
+	for num, entry := range d.xref {
+		_, obj, err := d.readObjectAt(entry.offset)
+		if err != nil {
+			continue
+		}
+
+		switch v := obj.(type) {
+		case dict:
+			if v["Type"] == name("Catalog") {
+				d.trailer["Root"] = ref{num: num}
+			}
+		case *stream:
+			if v.dict["Type"] == name("ObjStm") {
+				streams = append(streams, num)
+			}
+		}
+	}
+
+	for _, num := range streams {
+		stm, err := d.objectStream(num)
+		if err != nil {
+			continue
+		}
+
+		for i, n := range stm.nums {
+			if _, ok := d.xref[n]; !ok {
+				d.xref[n] = xrefEntry{stream: num, index: i}
+			}
+		}
+	}
+
+	if _, ok := d.trailer["Root"]; !ok {
+		// The catalog may be stored in an object stream.
+		for num := range d.xref {
+			if c, ok := d.object(num).(dict); ok && c["Type"] == name("Catalog") {
+				d.trailer["Root"] = ref{num: num}
+				break
+			}
+		}
+	}
+
+	if _, ok := d.trailer["Root"]; !ok {
+		return fmt.Errorf("the pdf file is broken, its catalog was not found")
+	}
+
+	if i := bytes.LastIndex(d.buf, []byte("trailer")); i >= 0 {
+		l := lexer{buf: d.buf, pos: i + len("trailer")}
+		if t, err := l.object(); err == nil {
+			if t, ok := t.(dict); ok {
+				if _, ok := t["Encrypt"]; ok {
+					d.trailer["Encrypt"] = t["Encrypt"]
+				}
+				d.trailer["Info"] = t["Info"]
+			}
+		}
+	}
+
+	return nil
+}
+
+// readObjectAt reads the indirect object at the offset, and returns its number.
+func (d *document) readObjectAt(offset int) (int, any, error) {
+	if offset < 0 || offset >= len(d.buf) {
+		return 0, nil, fmt.Errorf("invalid object offset %d", offset)
+	}
+
+	l := lexer{buf: d.buf, pos: offset}
+
+	l.skipSpace()
+	num, err1 := strconv.Atoi(l.word())
+	l.skipSpace()
+	_, err2 := strconv.Atoi(l.word())
+	l.skipSpace()
+	if err1 != nil || err2 != nil || l.word() != "obj" {
+		return 0, nil, fmt.Errorf("no object at offset %d", offset)
+	}
+
+	obj, err := l.object()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	s, ok := obj.(dict)
+	if !ok {
+		return num, obj, nil
+	}
+
+	l.skipSpace()
+	if !bytes.HasPrefix(d.buf[l.pos:], []byte("stream")) {
+		return num, obj, nil
+	}
+
+	// The data starts after the end of line that follows the keyword.
+	start := l.pos + len("stream")
+	if start < len(d.buf) && d.buf[start] == '\r' {
+		start++
+	}
+	if start < len(d.buf) && d.buf[start] == '\n' {
+		start++
+	}
+
+	data, err := d.streamData(start, s["Length"])
+	if err != nil {
+		return 0, nil, fmt.Errorf("error reading the stream of the object %d: %w", num, err)
+	}
+
+	return num, &stream{dict: s, data: data}, nil
+}
+
+// streamData returns the data of a stream that starts at the offset.
+// The length is checked against the endstream keyword, which is searched for if it's wrong.
+func (d *document) streamData(start int, length any) ([]byte, error) {
+	endstream := []byte("endstream")
+
+	if n, ok := integer(d.resolve(length)); ok && n >= 0 && start+n <= len(d.buf) {
+		l := lexer{buf: d.buf, pos: start + n}
+		l.skipSpace()
+
+		if bytes.HasPrefix(d.buf[l.pos:], endstream) {
+			return d.buf[start : start+n], nil
+		}
+	}
+
+	end := bytes.Index(d.buf[start:], endstream)
+	if end < 0 {
+		return nil, fmt.Errorf("endstream not found")
+	}
+
+	data := d.buf[start : start+end]
+
+	// The end of line before the keyword is not part of the data.
+	data = bytes.TrimSuffix(data, []byte("\n"))
+	data = bytes.TrimSuffix(data, []byte("\r"))
+
+	return data, nil
+}
+
+// object returns the indirect object with the given number, or nil if it doesn't exist.
+func (d *document) object(num int) any {
+	if obj, ok := d.objects[num]; ok {
+		return obj
+	}
+
+	// It's nil until it's read, so a cycle of object streams returns nil.
+	d.objects[num] = nil
+
+	entry, ok := d.xref[num]
+	if !ok {
+		return nil
+	}
+
+	var (
+		obj any
+		err error
+	)
+
+	if entry.stream > 0 {
+		obj, err = d.objectFromStream(entry.stream, entry.index)
+	} else {
+		_, obj, err = d.readObjectAt(entry.offset)
+	}
+
+	if err != nil {
+		return nil
+	}
+
+	d.objects[num] = obj
+
+	return obj
+}
+
+// resolve follows the references until it gets to a direct object.
+func (d *document) resolve(obj any) any {
+	for i := 0; i < maxObjectDepth; i++ {
+		r, ok := obj.(ref)
+		if !ok {
+			return obj
+		}
+
+		obj = d.object(r.num)
+	}
+
+	return nil
+}
+
+// objectStream is a decoded object stream.
+type objectStream struct {
+	// nums and offsets are the numbers of its objects, and where they are in the data.
+	nums    []int
+	offsets []int
+	data    []byte
+}
+
+// objectStream decodes the object stream with the given number.
+func (d *document) objectStream(num int) (*objectStream, error) {
+	if stm, ok := d.streams[num]; ok {
+		return stm, nil
+	}
+
+	s, ok := d.object(num).(*stream)
+	if !ok {
+		return nil, fmt.Errorf("the object %d is not an object stream", num)
+	}
+
+	data, err := d.decode(s)
+	if err != nil {
+		return nil, err
+	}
+
+	n, _ := integer(d.resolve(s.dict["N"]))
+	first, _ := integer(d.resolve(s.dict["First"]))
+	if first < 0 || first > len(data) {
+		return nil, fmt.Errorf("invalid object stream %d", num)
+	}
+
+	stm := &objectStream{data: data}
+
+	l := lexer{buf: data[:first]}
+	for i := 0; i < n; i++ {
+		l.skipSpace()
+		objNum, err1 := strconv.Atoi(l.word())
+		l.skipSpace()
+		offset, err2 := strconv.Atoi(l.word())
+		if err1 != nil || err2 != nil || offset < 0 || first+offset > len(data) {
+			return nil, fmt.Errorf("invalid object stream %d", num)
+		}
+
+		stm.nums = append(stm.nums, objNum)
+		stm.offsets = append(stm.offsets, first+offset)
+	}
+
+	d.streams[num] = stm
+
+	return stm, nil
+}
+
+// objectFromStream reads the object at the index of an object stream.
+func (d *document) objectFromStream(num, index int) (any, error) {
+	stm, err := d.objectStream(num)
+	if err != nil {
+		return nil, err
+	}
+
+	if index >= len(stm.offsets) {
+		return nil, fmt.Errorf("the object stream %d has no object %d", num, index)
+	}
+
+	l := lexer{buf: stm.data, pos: stm.offsets[index]}
+
+	return l.object()
+}
+
+// decode decodes the data of a stream. Only the filters used by cross-reference
+// and object streams are supported, the rest of the streams are copied as they are.
+func (d *document) decode(s *stream) ([]byte, error) {
+	data := s.data
+
+	filters := d.resolve(s.dict["Filter"])
+	params := d.resolve(s.dict["DecodeParms"])
+
+	if f, ok := filters.(name); ok {
+		filters, params = array{f}, array{params}
+	}
+
+	filterList, _ := filters.(array)
+	paramList, _ := params.(array)
+
+	for i, f := range filterList {
+		if d.resolve(f) != name("FlateDecode") {
+			return nil, fmt.Errorf("unsupported filter %v", f)
+		}
+
+		zr, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+
+		// Truncated streams are decoded as far as they go.
+		data, err = io.ReadAll(zr)
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, err
+		}
+
+		var p dict
+		if i < len(paramList) {
+			p, _ = d.resolve(paramList[i]).(dict)
+		}
+
+		if data, err = unpredict(data, p); err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+// unpredict reverses the png predictors applied before compressing the data.
+func unpredict(data []byte, params dict) ([]byte, error) {
+	predictor, _ := integer(params["Predictor"])
+	if predictor < 10 {
+		if predictor > 1 {
+			return nil, fmt.Errorf("unsupported predictor %d", predictor)
+		}
+		return data, nil
+	}
+
+	columns, colors, bits := 1, 1, 8
+	if v, ok := integer(params["Columns"]); ok {
+		columns = v
+	}
+	if v, ok := integer(params["Colors"]); ok {
+		colors = v
+	}
+	if v, ok := integer(params["BitsPerComponent"]); ok {
+		bits = v
+	}
+
+	bpp := max(1, colors*bits/8)
+	rowLen := (columns*colors*bits + 7) / 8
+	if rowLen <= 0 {
+		return nil, fmt.Errorf("invalid predictor parameters")
+	}
+
+	var (
+		out  []byte
+		prev = make([]byte, rowLen)
+	)
+
+	// Every row is preceded by the png filter it was filtered with.
+	for pos := 0; pos+1+rowLen <= len(data); pos += 1 + rowLen {
+		filter, row := data[pos], bytes.Clone(data[pos+1:pos+1+rowLen])
+
+		for i := range row {
+			var left, upLeft byte
+			if i >= bpp {
+				left, upLeft = row[i-bpp], prev[i-bpp]
+			}
+			up := prev[i]
+
+			switch filter {
+			case 0:
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			default:
+				return nil, fmt.Errorf("invalid png filter %d", filter)
+			}
+		}
+
+		out = append(out, row...)
+		prev = row
+	}
+
+	return out, nil
+}
+
+// paeth is the predictor of the Paeth filter of png images.
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	default:
+		return c
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+
+	return v
+}
+
+// readPages walks the page tree, and sets the inherited attributes on every page.
+func (d *document) readPages() error {
+	root, ok := d.resolve(d.trailer["Root"]).(dict)
+	if !ok {
+		return fmt.Errorf("the catalog is missing")
+	}
+
+	pagesRef, ok := root["Pages"].(ref)
+	if !ok {
+		return fmt.Errorf("the page tree is missing")
+	}
+
+	visited := map[int]bool{}
+
+	var walk func(r ref, inherited dict) error
+	walk = func(r ref, inherited dict) error {
+		if visited[r.num] {
+			return fmt.Errorf("the page tree has a cycle")
+		}
+		visited[r.num] = true
+
+		node, ok := d.object(r.num).(dict)
+		if !ok {
+			// Missing pages are skipped, as readers do.
+			return nil
+		}
+
+		attrs := dict{}
+		for k, v := range inherited {
+			attrs[k] = v
+		}
+		for _, k := range inheritable {
+			if v, ok := node[k]; ok {
+				attrs[k] = v
+			}
+		}
+
+		kids, isTree := d.resolve(node["Kids"]).(array)
+		if node["Type"] == name("Page") || !isTree {
+			p := dict{}
+			for k, v := range node {
+				p[k] = v
+			}
+			for k, v := range attrs {
+				p[k] = v
+			}
+
+			d.pages = append(d.pages, &page{ref: r, dict: p})
+			return nil
+		}
+
+		for _, kid := range kids {
+			kidRef, ok := kid.(ref)
+			if !ok {
+				continue
+			}
+
+			if err := walk(kidRef, attrs); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return walk(pagesRef, dict{})
+}
+
+// rotation returns the rotation of the page, in degrees clockwise, a multiple of 90.
+func (d *document) rotation(p *page) int {
+	angle, _ := integer(d.resolve(p.dict["Rotate"]))
+	return ((angle/90)%4 + 4) % 4 * 90
+}
@@ -0,0 +1,246 @@
+package pdfops
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// pageCopy is a page copied into a new pdf file, turned clockwise by the given angle.
+type pageCopy struct {
+	doc    *document
+	page   *page
+	rotate int
+}
+
+// writer copies pages, and every object they use, into a new pdf file.
+// Objects are numbered from 1, the catalog and the page tree come first.
+type writer struct {
+	objects []any
+	// copied maps the numbers of the objects of every document to their numbers in the new file.
+	copied map[*document]map[int]int
+	// pages maps the numbers of the page objects copied to their numbers in the new file,
+	// so links between them still work. Links to pages that weren't copied are dropped.
+	pages map[*document]map[int]int
+	queue []queued
+}
+
+// queued is an object whose number is reserved, copied once the ones before it are.
+type queued struct {
+	doc *document
+	num int
+	new int
+}
+
+const (
+	catalogNum  = 1
+	pageTreeNum = 2
+)
+
+// writePDF writes a pdf file made of the pages, in order.
+// The document information of the first document is kept.
+func writePDF(pages []pageCopy) []byte {
+	w := &writer{
+		objects: make([]any, 2),
+		copied:  map[*document]map[int]int{},
+		pages:   map[*document]map[int]int{},
+	}
+
+	nums := make([]int, len(pages))
+	for i, p := range pages {
+		nums[i] = w.reserve()
+
+		if w.pages[p.doc] == nil {
+			w.pages[p.doc] = map[int]int{}
+		}
+		if _, ok := w.pages[p.doc][p.page.ref.num]; !ok {
+			w.pages[p.doc][p.page.ref.num] = nums[i]
+		}
+	}
+
+	kids := make(array, len(pages))
+
+	for i, p := range pages {
+		d := dict{}
+		for k, v := range p.page.dict {
+			switch k {
+			// The parent is the new page tree, and article beads belong to threads that aren't copied.
+			case "Parent", "B":
+			default:
+				if c := w.copyValue(p.doc, v); c != nil {
+					d[k] = c
+				}
+			}
+		}
+
+		d["Type"] = name("Page")
+		d["Parent"] = ref{num: pageTreeNum}
+
+		if angle := (p.doc.rotation(p.page) + p.rotate) % 360; angle != 0 {
+			d["Rotate"] = number(strconv.Itoa(angle))
+		} else {
+			delete(d, "Rotate")
+		}
+
+		w.objects[nums[i]-1] = d
+		kids[i] = ref{num: nums[i]}
+
+		w.flush()
+	}
+
+	w.objects[catalogNum-1] = dict{
+		"Type":  name("Catalog"),
+		"Pages": ref{num: pageTreeNum},
+	}
+	w.objects[pageTreeNum-1] = dict{
+		"Type":  name("Pages"),
+		"Kids":  kids,
+		"Count": number(strconv.Itoa(len(kids))),
+	}
+
+	trailer := dict{"Root": ref{num: catalogNum}}
+
+	if len(pages) > 0 {
+		if info, ok := pages[0].doc.trailer["Info"].(ref); ok {
+			if c := w.copyValue(pages[0].doc, info); c != nil {
+				trailer["Info"] = c
+			}
+			w.flush()
+		}
+	}
+
+	trailer["Size"] = number(strconv.Itoa(len(w.objects) + 1))
+
+	return w.encode(version(pages), trailer)
+}
+
+// reserve reserves the number of the next object.
+func (w *writer) reserve() int {
+	w.objects = append(w.objects, nil)
+	return len(w.objects)
+}
+
+// copyValue copies a direct object, replacing the references to the objects of the document
+// by references to their copies, which are queued to be copied.
+func (w *writer) copyValue(doc *document, obj any) any {
+	switch v := obj.(type) {
+	case ref:
+		return w.copyRef(doc, v)
+	case array:
+		a := make(array, len(v))
+		for i, item := range v {
+			a[i] = w.copyValue(doc, item)
+		}
+		return a
+	case dict:
+		d := dict{}
+		for k, item := range v {
+			if c := w.copyValue(doc, item); c != nil {
+				d[k] = c
+			}
+		}
+		return d
+	case *stream:
+		d := dict{}
+		for k, item := range v.dict {
+			// The length is written from the data, and may be an indirect object.
+			if k == "Length" {
+				continue
+			}
+			if c := w.copyValue(doc, item); c != nil {
+				d[k] = c
+			}
+		}
+		return &stream{dict: d, data: v.data}
+	default:
+		return obj
+	}
+}
+
+// copyRef returns a reference to the copy of an indirect object, or nil if it's not copied,
+// which is the case of the pages that aren't part of the new file, and of the old page tree.
+func (w *writer) copyRef(doc *document, r ref) any {
+	if num, ok := w.pages[doc][r.num]; ok {
+		return ref{num: num}
+	}
+
+	if num, ok := w.copied[doc][r.num]; ok {
+		return ref{num: num}
+	}
+
+	obj := doc.object(r.num)
+	if obj == nil {
+		return nil
+	}
+
+	if d, ok := obj.(dict); ok && (d["Type"] == name("Page") || d["Type"] == name("Pages") || d["Type"] == name("Catalog")) {
+		return nil
+	}
+
+	if w.copied[doc] == nil {
+		w.copied[doc] = map[int]int{}
+	}
+
+	num := w.reserve()
+	w.copied[doc][r.num] = num
+	w.queue = append(w.queue, queued{doc: doc, num: r.num, new: num})
+
+	return ref{num: num}
+}
+
+// flush copies the queued objects, and the ones they refer to.
+func (w *writer) flush() {
+	for len(w.queue) > 0 {
+		q := w.queue[0]
+		w.queue = w.queue[1:]
+
+		w.objects[q.new-1] = w.copyValue(q.doc, q.doc.object(q.num))
+	}
+}
+
+// encode writes the objects, the cross-reference table and the trailer.
+func (w *writer) encode(version string, trailer dict) []byte {
+	buf := new(bytes.Buffer)
+
+	// The comment of binary characters tells tools the file is binary.
+	fmt.Fprintf(buf, "%%PDF-%s\n%%\xe2\xe3\xcf\xd3\n", version)
+
+	offsets := make([]int, len(w.objects))
+
+	for i, obj := range w.objects {
+		offsets[i] = buf.Len()
+
+		fmt.Fprintf(buf, "%d 0 obj\n", i+1)
+		writeObject(buf, obj)
+		buf.WriteString("\nendobj\n")
+	}
+
+	xref := buf.Len()
+
+	fmt.Fprintf(buf, "xref\n0 %d\n", len(w.objects)+1)
+	buf.WriteString("0000000000 65535 f \n")
+	for _, offset := range offsets {
+		fmt.Fprintf(buf, "%010d 00000 n \n", offset)
+	}
+
+	buf.WriteString("trailer\n")
+	writeObject(buf, trailer)
+	fmt.Fprintf(buf, "\nstartxref\n%d\n%%%%EOF\n", xref)
+
+	return buf.Bytes()
+}
+
+// version returns the newest version of the documents the pages come from,
+// so the features they use are still allowed.
+func version(pages []pageCopy) string {
+	v := "1.4"
+
+	for _, p := range pages {
+		header := p.doc.buf[bytes.Index(p.doc.buf, []byte("%PDF-"))+len("%PDF-"):]
+		if len(header) >= 3 && header[1] == '.' && string(header[:3]) > v {
+			v = string(header[:3])
+		}
+	}
+
+	return v
+}
@@ -0,0 +1,55 @@
+// Package progress lets conversions report how far along they are,
+// without knowing who is listening.
+package progress
+
+import "context"
+
+// Stage is a step of a conversion.
+type Stage string
+
+const (
+	Received   Stage = "received"
+	Detected   Stage = "detected"
+	Converting Stage = "converting"
+	Packaging  Stage = "packaging"
+	Done       Stage = "done"
+)
+
+// Event tells the stage a conversion is at.
+type Event struct {
+	Stage Stage `json:"stage"`
+	// Current and Total count the units of work of the stage done so far,
+	// e.g. the pages of a pdf. Both are zero if the stage can't be measured.
+	Current int `json:"current,omitempty"`
+	Total   int `json:"total,omitempty"`
+	// Message is a human friendly description of the event.
+	// e.g. converting page 3 of 10
+	Message string `json:"message,omitempty"`
+}
+
+// Percent returns the percentage of the stage that is done,
+// or 0 if it can't be measured.
+func (e Event) Percent() int {
+	if e.Total <= 0 {
+		return 0
+	}
+
+	return e.Current * 100 / e.Total
+}
+
+// Reporter receives the events of a conversion.
+type Reporter func(Event)
+
+type reporterKey struct{}
+
+// WithReporter returns a copy of ctx that carries the reporter.
+func WithReporter(ctx context.Context, r Reporter) context.Context {
+	return context.WithValue(ctx, reporterKey{}, r)
+}
+
+// Report sends the event to the reporter carried by ctx, if any.
+func Report(ctx context.Context, e Event) {
+	if r, ok := ctx.Value(reporterKey{}).(Reporter); ok && r != nil {
+		r(e)
+	}
+}
@@ -0,0 +1,31 @@
+package progress_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/danvergara/morphos/pkg/progress"
+)
+
+func TestReport(t *testing.T) {
+	var events []progress.Event
+
+	ctx := progress.WithReporter(context.Background(), func(e progress.Event) {
+		events = append(events, e)
+	})
+
+	progress.Report(ctx, progress.Event{Stage: progress.Converting, Current: 1, Total: 4})
+	progress.Report(ctx, progress.Event{Stage: progress.Done})
+
+	// Reporting without a reporter is a no-op.
+	progress.Report(context.Background(), progress.Event{Stage: progress.Done})
+
+	require.Equal(t, []progress.Event{
+		{Stage: progress.Converting, Current: 1, Total: 4},
+		{Stage: progress.Done},
+	}, events)
+	require.Equal(t, 25, events[0].Percent())
+	require.Equal(t, 0, events[1].Percent())
+}
@@ -0,0 +1,82 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Tool is an external program morphos relies on to convert files.
+type Tool string
+
+const (
+	FFmpeg      Tool = "ffmpeg"
+	LibreOffice Tool = "libreoffice"
+	Calibre     Tool = "calibre"
+	LibJXL      Tool = "libjxl"
+	Tesseract   Tool = "tesseract"
+)
+
+// waitDelay is the time given to a killed process to release
+// its stdout and stderr before Wait gives up on them.
+const waitDelay = 5 * time.Second
+
+var (
+	timeoutsMu sync.RWMutex
+	// timeouts are the maximum time every tool is allowed to run for a single conversion.
+	timeouts = map[Tool]time.Duration{
+		FFmpeg:      2 * time.Minute,
+		LibreOffice: 5 * time.Minute,
+		Calibre:     5 * time.Minute,
+		LibJXL:      2 * time.Minute,
+		Tesseract:   5 * time.Minute,
+	}
+)
+
+// SetTimeout sets the maximum time a tool is allowed to run for a single conversion.
+// A zero or negative duration disables the timeout.
+func SetTimeout(tool Tool, d time.Duration) {
+	timeoutsMu.Lock()
+	defer timeoutsMu.Unlock()
+
+	timeouts[tool] = d
+}
+
+// Timeout returns the maximum time a tool is allowed to run for a single conversion.
+func Timeout(tool Tool) time.Duration {
+	timeoutsMu.RLock()
+	defer timeoutsMu.RUnlock()
+
+	return timeouts[tool]
+}
+
+// RunCommand runs an external program on behalf of the given tool and waits for it to finish.
+// The program is killed, alongside every process it started, once ctx is done
+// or the timeout of the tool is reached. In that case, the returned error wraps
+// the error of the context, e.g. context.DeadlineExceeded.
+func RunCommand(ctx context.Context, tool Tool, stdout, stderr io.Writer, name string, args ...string) error {
+	if d := Timeout(tool); d > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = waitDelay
+	killProcessGroup(cmd)
+
+	if err := cmd.Run(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return fmt.Errorf("%s did not finish: %w", tool, ctxErr)
+		}
+
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package util
+
+import "os/exec"
+
+// killProcessGroup is a no-op on platforms without process groups,
+// the command itself is still killed when cancelled.
+func killProcessGroup(cmd *exec.Cmd) {}
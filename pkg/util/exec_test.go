@@ -0,0 +1,38 @@
+//go:build unix
+
+package util
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunCommandTimeout(t *testing.T) {
+	timeout := Timeout(FFmpeg)
+	SetTimeout(FFmpeg, 100*time.Millisecond)
+	t.Cleanup(func() { SetTimeout(FFmpeg, timeout) })
+
+	start := time.Now()
+
+	// The child process keeps the pipes open, so Run only returns
+	// early if the whole process group is killed.
+	err := RunCommand(context.Background(), FFmpeg, new(lineLogger), new(lineLogger), "sh", "-c", "sleep 5; echo done")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), 3*time.Second)
+}
+
+func TestRunCommandCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := RunCommand(ctx, Calibre, nil, nil, "sh", "-c", "sleep 5")
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRunCommand(t *testing.T) {
+	require.NoError(t, RunCommand(context.Background(), LibreOffice, nil, nil, "sh", "-c", "exit 0"))
+	require.Error(t, RunCommand(context.Background(), LibreOffice, nil, nil, "sh", "-c", "exit 1"))
+}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package util
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killProcessGroup runs the command in its own process group,
+// so that cancelling it kills the processes it started too,
+// e.g. the soffice.bin process started by libreoffice.
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -1,16 +1,19 @@
 package util
 
 import (
-	"archive/zip"
-	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+
+	"github.com/danvergara/morphos/pkg/packaging"
 )
 
 // EbookConvert calls the ebook-convert binary from the Calibre project.
@@ -18,141 +21,156 @@ import (
 // and a target format which is the the format that the file is going to be converted to.
 // The function also receives the input file as an slice of bytes, which is the file that is
 // going to be converted.
-func EbookConvert(filename, inputFormat, outputFormat string, inputFile []byte) (io.Reader, error) {
-	tmpInputFile, err := os.Create(
-		fmt.Sprintf(
-			"/tmp/%s.%s",
-			strings.TrimSuffix(filename, filepath.Ext(filename)),
-			inputFormat,
-		),
-	)
+func EbookConvert(ctx context.Context, filename, inputFormat, outputFormat string, inputFile []byte) (io.Reader, error) {
+	// Every conversion gets a directory of its own, so files with the same name
+	// converted at the same time don't overwrite each other.
+	tmpDir, err := os.MkdirTemp("", "morphos-ebook-*")
 	if err != nil {
-		return nil, fmt.Errorf("error creating temporary file: %w", err)
+		return nil, fmt.Errorf("error creating temporary directory: %w", err)
 	}
 
-	defer os.Remove(tmpInputFile.Name())
+	defer os.RemoveAll(tmpDir)
+
+	name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+
+	tmpInputFileName := filepath.Join(tmpDir, fmt.Sprintf("%s.%s", name, inputFormat))
 
 	// Write the content of the input file into the temporary file.
-	if _, err = tmpInputFile.Write(inputFile); err != nil {
-		return nil, fmt.Errorf(
-			"error writting the input reader to the temporary file",
-		)
-	}
-	if err := tmpInputFile.Close(); err != nil {
-		return nil, err
+	if err := os.WriteFile(tmpInputFileName, inputFile, 0o600); err != nil {
+		return nil, fmt.Errorf("error writting the input file to the temporary file: %w", err)
 	}
 
 	// Parse the name of the output file.
-	tmpOutputFileName := fmt.Sprintf(
-		"%s.%s",
-		strings.TrimSuffix(tmpInputFile.Name(), filepath.Ext(tmpInputFile.Name())),
-		outputFormat,
-	)
+	tmpOutputFileName := filepath.Join(tmpDir, fmt.Sprintf("%s.%s", name, outputFormat))
 
 	// run the ebook-convert command with the input file and the name of the output file.
-	cmd := exec.Command("ebook-convert", tmpInputFile.Name(), tmpOutputFileName)
+	// Its stdout and stderr are logged line by line.
+	// ebook-convert is killed if the context is done or it takes too long.
+	if err := RunCommand(
+		ctx,
+		Calibre,
+		newLineLogger("STDOUT:"),
+		newLineLogger("STDERR:"),
+		"ebook-convert",
+		tmpInputFileName,
+		tmpOutputFileName,
+	); err != nil {
+		return nil, err
+	}
 
-	// Capture stdout.
-	stdout, err := cmd.StdoutPipe()
+	// Read the converted file to get the bytes out of it.
+	cf, err := os.ReadFile(tmpOutputFileName)
 	if err != nil {
 		return nil, err
 	}
 
-	// Capture stderr.
-	stderr, err := cmd.StderrPipe()
+	// Parse the output file name.
+	outputFilename := fmt.Sprintf("%s.%s", name, outputFormat)
+
+	// Wraps the converted file in a zip file.
+	zipFile, err := packaging.Pack(
+		packaging.Zip,
+		packaging.DefaultCompression,
+		packaging.File{Name: outputFilename, Content: cf},
+	)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("error at packaging the converted file: %w", err)
 	}
 
-	// Start the command.
-	if err := cmd.Start(); err != nil {
-		return nil, err
+	return bytes.NewReader(zipFile), nil
+}
+
+// OCR calls the tesseract binary to recognize the text of the images, every one of them a page.
+// It receives the output format, which is txt, hocr or pdf, a searchable pdf that holds
+// every image under its text, and the language of the text, e.g. eng, or several of them
+// joined by plus signs, e.g. eng+spa. The dpi is the resolution of the images,
+// which sets the size of the pages of the pdf, or 0 to let tesseract figure it out.
+// It returns the output file, a single one for every page.
+func OCR(ctx context.Context, format, language string, dpi int, images ...[]byte) ([]byte, error) {
+	if len(images) == 0 {
+		return nil, fmt.Errorf("there are no images to recognize")
 	}
 
-	// Create readers to read stdout and stderr.
-	stdoutScanner := bufio.NewScanner(stdout)
-	stderrScanner := bufio.NewScanner(stderr)
+	tmpDir, err := os.MkdirTemp("", "morphos-ocr-*")
+	if err != nil {
+		return nil, fmt.Errorf("error creating temporary directory: %w", err)
+	}
 
-	// Read stdout line by line.
-	go func() {
-		for stdoutScanner.Scan() {
-			log.Println("STDOUT:", stdoutScanner.Text())
-		}
-	}()
+	defer os.RemoveAll(tmpDir)
 
-	// Read stderr line by line.
-	go func() {
-		for stderrScanner.Scan() {
-			log.Println("STDERR:", stderrScanner.Text())
+	// Several images are passed to tesseract as a list of files, a line per file.
+	var list []string
+	for i, img := range images {
+		name := filepath.Join(tmpDir, fmt.Sprintf("page-%d%s", i+1, mimetype.Detect(img).Extension()))
+		if err := os.WriteFile(name, img, 0o600); err != nil {
+			return nil, fmt.Errorf("error writting the image to a temporary file: %w", err)
 		}
-	}()
 
-	// Wait for the command to finish.
-	if err := cmd.Wait(); err != nil {
-		return nil, err
+		list = append(list, name)
 	}
 
-	// Open the converted file to get the bytes out of it,
-	// and then turning them into a io.Reader.
-	cf, err := os.Open(tmpOutputFileName)
-	if err != nil {
-		return nil, err
+	input := list[0]
+	if len(list) > 1 {
+		input = filepath.Join(tmpDir, "pages.txt")
+		if err := os.WriteFile(input, []byte(strings.Join(list, "\n")+"\n"), 0o600); err != nil {
+			return nil, fmt.Errorf("error writting the list of images: %w", err)
+		}
 	}
-	defer os.Remove(cf.Name())
 
-	// Parse the file name of the Zip file.
-	zipFileName := fmt.Sprintf(
-		"%s.zip",
-		strings.TrimSuffix(filename, filepath.Ext(filename)),
-	)
+	// tesseract adds the extension of the format to the name of the output file.
+	output := filepath.Join(tmpDir, "output")
 
-	// Parse the output file name.
-	outputFilename := fmt.Sprintf(
-		"%s.%s",
-		strings.TrimSuffix(filename, filepath.Ext(filename)),
-		outputFormat,
-	)
+	args := []string{input, output, "-l", language}
+	if dpi > 0 {
+		args = append(args, "--dpi", strconv.Itoa(dpi))
+	}
+	args = append(args, format)
+
+	// Its stdout and stderr are logged line by line, and the last line
+	// of stderr, which explains why it failed, is part of the error.
+	// tesseract is killed if the context is done or it takes too long.
+	stderr := new(bytes.Buffer)
+	if err := RunCommand(
+		ctx,
+		Tesseract,
+		newLineLogger("STDOUT:"),
+		io.MultiWriter(newLineLogger("STDERR:"), stderr),
+		"tesseract",
+		args...,
+	); err != nil {
+		lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+		if last := lines[len(lines)-1]; last != "" {
+			return nil, fmt.Errorf("error recognizing the text with tesseract: %w: %s", err, last)
+		}
 
-	// Creates the zip file that will be returned.
-	archive, err := os.CreateTemp("", zipFileName)
-	if err != nil {
-		return nil, fmt.Errorf(
-			"error at creating the zip file to store the file: %w",
-			err,
-		)
+		return nil, fmt.Errorf("error recognizing the text with tesseract: %w", err)
 	}
 
-	defer os.Remove(archive.Name())
+	return os.ReadFile(fmt.Sprintf("%s.%s", output, format))
+}
 
-	// Creates a Zip Writer to add files later on.
-	zipWriter := zip.NewWriter(archive)
+// lineLogger is an io.Writer that logs every line written to it.
+type lineLogger struct {
+	prefix string
+	buf    []byte
+}
 
-	// Adds the image to the zip file.
-	w1, err := zipWriter.Create(outputFilename)
-	if err != nil {
-		return nil, fmt.Errorf(
-			"error creating the zip writer: %w",
-			err,
-		)
-	}
+func newLineLogger(prefix string) *lineLogger {
+	return &lineLogger{prefix: prefix}
+}
 
-	// Copy the content of the converted file to the zip file.
-	if _, err := io.Copy(w1, cf); err != nil {
-		return nil, fmt.Errorf(
-			"error at writing the file content to the zip writer: %w",
-			err,
-		)
-	}
+func (l *lineLogger) Write(p []byte) (int, error) {
+	l.buf = append(l.buf, p...)
 
-	// Closes both zip writer and the zip file after its done with the writing.
-	zipWriter.Close()
-	archive.Close()
+	for {
+		i := bytes.IndexByte(l.buf, '\n')
+		if i < 0 {
+			break
+		}
 
-	// Reads the zip file as an slice of bytes.
-	zipFile, err := os.ReadFile(archive.Name())
-	if err != nil {
-		return nil, fmt.Errorf("error reading zip file: %v", err)
+		log.Println(l.prefix, string(l.buf[:i]))
+		l.buf = l.buf[i+1:]
 	}
 
-	return bytes.NewReader(zipFile), nil
+	return len(p), nil
 }
@@ -0,0 +1,143 @@
+//go:build unix
+
+package util
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTool puts a script named after the tool on the PATH.
+func fakeTool(t *testing.T, name, script string) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(script), 0o755))
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// fakeTesseract puts a tesseract on the PATH that writes its arguments,
+// and the list of images it's given, to the output file.
+func fakeTesseract(t *testing.T) {
+	fakeTool(t, "tesseract", `#!/bin/sh
+out="$2.$(eval echo \${$#})"
+echo "$@" | sed "s|$(dirname "$1")/||g" > "$out"
+case "$1" in *.txt) sed "s|.*/||" "$1" >> "$out" ;; esac
+[ "$4" = "xxx" ] && echo "Failed loading language 'xxx'" >&2 && exit 1
+exit 0
+`)
+}
+
+func TestEbookConvertConcurrent(t *testing.T) {
+	// The fake ebook-convert copies the input file to the output file, slowly enough
+	// for the conversions to overlap.
+	fakeTool(t, "ebook-convert", "#!/bin/sh\nsleep 0.2\ncp \"$1\" \"$2\"\n")
+
+	const conversions = 4
+
+	var wg sync.WaitGroup
+	results := make([][]byte, conversions)
+	errs := make([]error, conversions)
+
+	for i := 0; i < conversions; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			// Every file has the same name, but not the same content.
+			input := []byte(fmt.Sprintf("book %d", i))
+
+			r, err := EbookConvert(context.Background(), "book.epub", "epub", "mobi", input)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+
+			results[i], errs[i] = io.ReadAll(r)
+		}(i)
+	}
+
+	wg.Wait()
+
+	for i := 0; i < conversions; i++ {
+		require.NoError(t, errs[i])
+
+		zr, err := zip.NewReader(bytes.NewReader(results[i]), int64(len(results[i])))
+		require.NoError(t, err)
+		require.Len(t, zr.File, 1)
+		require.Equal(t, "book.mobi", zr.File[0].Name)
+
+		f, err := zr.File[0].Open()
+		require.NoError(t, err)
+
+		content, err := io.ReadAll(f)
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("book %d", i), string(content))
+	}
+}
+
+func TestOCR(t *testing.T) {
+	fakeTesseract(t)
+
+	// The signature of png files is enough to name the images after their format.
+	png := []byte("\x89PNG\r\n\x1a\n")
+
+	tests := []struct {
+		name     string
+		format   string
+		language string
+		dpi      int
+		images   [][]byte
+		expected string
+		err      string
+	}{
+		{
+			name:     "one image",
+			format:   "txt",
+			language: "eng",
+			images:   [][]byte{png},
+			expected: "page-1.png output -l eng txt\n",
+		},
+		{
+			name:     "several images",
+			format:   "pdf",
+			language: "eng+spa",
+			dpi:      300,
+			images:   [][]byte{png, png},
+			expected: "pages.txt output -l eng+spa --dpi 300 pdf\npage-1.png\npage-2.png\n",
+		},
+		{
+			name:     "missing language",
+			format:   "hocr",
+			language: "xxx",
+			images:   [][]byte{png},
+			err:      "Failed loading language 'xxx'",
+		},
+		{
+			name:     "no images",
+			format:   "txt",
+			language: "eng",
+			err:      "there are no images to recognize",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := OCR(context.Background(), tc.format, tc.language, tc.dpi, tc.images...)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, string(result))
+		})
+	}
+}